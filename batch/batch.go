@@ -81,6 +81,12 @@ type funcShard struct {
 type batchContext struct {
 	mu                 sync.Mutex
 	pendingBatchGroups map[funcShard]*batchGroup
+	// roundTrips counts the invocations of Func.Many.
+	roundTrips int
+	// batchSizes holds the number of arguments of each invocation of Func.Many.
+	batchSizes []int
+	// loads holds the values loaded by Loaders, by Loader and key.
+	loads map[loaderKey]*load
 }
 
 // batchContextKey is a context.Value key used for type *batchContext.
@@ -94,6 +100,7 @@ func WithBatching(ctx context.Context) context.Context {
 
 	bctx := &batchContext{
 		pendingBatchGroups: make(map[funcShard]*batchGroup),
+		loads:              make(map[loaderKey]*load),
 	}
 	return context.WithValue(ctx, batchContextKey{}, bctx)
 }
@@ -103,6 +110,35 @@ func HasBatching(ctx context.Context) bool {
 	return ctx.Value(batchContextKey{}) != nil
 }
 
+// RoundTrips returns the number of batched invocations of Func.Many made
+// using the given context, or 0 if the context has no batching support.
+func RoundTrips(ctx context.Context) int {
+	bctx, ok := ctx.Value(batchContextKey{}).(*batchContext)
+	if !ok {
+		return 0
+	}
+
+	bctx.mu.Lock()
+	defer bctx.mu.Unlock()
+	return bctx.roundTrips
+}
+
+// BatchSizes returns the number of arguments passed to each batched invocation
+// of Func.Many made using the given context, in order, or nil if the context
+// has no batching support.
+func BatchSizes(ctx context.Context) []int {
+	bctx, ok := ctx.Value(batchContextKey{}).(*batchContext)
+	if !ok {
+		return nil
+	}
+
+	bctx.mu.Lock()
+	defer bctx.mu.Unlock()
+	sizes := make([]int, len(bctx.batchSizes))
+	copy(sizes, bctx.batchSizes)
+	return sizes
+}
+
 // safeInvoke invokes f, recovering panics and handling the case when
 // len(result) != len(args).
 func safeInvoke(
@@ -213,6 +249,8 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 		if bctx.pendingBatchGroups[fs] == bg {
 			delete(bctx.pendingBatchGroups, fs)
 		}
+		bctx.roundTrips++
+		bctx.batchSizes = append(bctx.batchSizes, len(bg.args))
 		bctx.mu.Unlock()
 
 		// Check for the context being canceled.
@@ -38,6 +38,10 @@ func TestBasic(t *testing.T) {
 	}
 	wg.Wait()
 
+	if roundTrips := batch.RoundTrips(ctx); roundTrips != calls {
+		t.Errorf("expected RoundTrips to be %d, got %d", calls, roundTrips)
+	}
+
 	// Expect 1, allow for 2 in case of races.
 	if calls > 2 {
 		t.Error(calls)
@@ -300,3 +304,96 @@ func TestError(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+// TestLoader tests that batch.Loader batches loads and loads each key once.
+func TestLoader(t *testing.T) {
+	var mu sync.Mutex
+	var loaded []interface{}
+	loader := batch.NewLoader(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loaded = append(loaded, keys...)
+		results := make([]interface{}, len(keys))
+		for i, key := range keys {
+			results[i] = key.(int) * 2
+		}
+		return results, nil
+	})
+
+	ctx := batch.WithBatching(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if result, err := loader.Load(ctx, i%5); err != nil || result != i%5*2 {
+				t.Error(err, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if result, err := loader.Load(ctx, 3); err != nil || result != 6 {
+		t.Error(err, result)
+	}
+
+	if len(loaded) != 5 {
+		t.Errorf("expected each key to be loaded once, got %v", loaded)
+	}
+	// Expect 1, allow for 2 in case of races.
+	if roundTrips := batch.RoundTrips(ctx); roundTrips > 2 {
+		t.Error(roundTrips)
+	}
+
+	// Without batching, keys are loaded on their own.
+	if result, err := loader.Load(context.Background(), 4); err != nil || result != 8 {
+		t.Error(err, result)
+	}
+}
+
+// TestLoaderMaxBatchSize tests that batch.Loader splits batches larger than
+// MaxBatchSize, and that batch sizes are reported.
+func TestLoaderMaxBatchSize(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	loader := batch.NewLoader(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(keys) > 4 {
+			return nil, errors.New("too many")
+		}
+		calls++
+		return keys, nil
+	})
+	loader.MaxBatchSize = 4
+
+	ctx := batch.WithBatching(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if result, err := loader.Load(ctx, i); err != nil || result != i {
+				t.Error(err, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sizes := batch.BatchSizes(ctx)
+	if len(sizes) != calls {
+		t.Errorf("expected %d batch sizes, got %v", calls, sizes)
+	}
+	total := 0
+	for _, size := range sizes {
+		if size > 4 {
+			t.Errorf("expected batches of at most 4, got %v", sizes)
+		}
+		total += size
+	}
+	if total != 10 {
+		t.Errorf("expected 10 keys, got %v", sizes)
+	}
+}
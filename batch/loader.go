@@ -0,0 +1,90 @@
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// A Loader loads values by key for graphql resolvers, avoiding the N+1
+// queries problem. Concurrent calls to Load within a computation are coalesced
+// into a single call to the Loader's batch function, which is flushed once
+// resolvers stop calling Load for the Loader's WaitInterval, or after its
+// MaxDuration. Each key is loaded at most once per computation: later calls to
+// Load with the same key return the same value or error.
+type Loader struct {
+	// MaxBatchSize optionally limits the number of keys passed to the batch
+	// function at once; larger batches are split into several calls. Zero, the
+	// default, means no limit. MaxBatchSize must be set before the first call
+	// to Load.
+	MaxBatchSize int
+
+	f    Func
+	once sync.Once
+}
+
+// loaderKey identifies a load of key by a Loader.
+type loaderKey struct {
+	loader *Loader
+	key    interface{}
+}
+
+// A load tracks the value of a key loaded by a Loader.
+type load struct {
+	// doneCh is a 0-sized channel that is closed once value and err are set.
+	doneCh chan struct{}
+	value  interface{}
+	err    error
+}
+
+// NewLoader creates a Loader with batchFunc, which loads the values of keys.
+// batchFunc must return one value for each key, in the same order; keys are
+// comparable, and are passed at most once per computation.
+//
+// For example, a Loader might fetch users by ID:
+//
+//	var userLoader = batch.NewLoader(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+//		return fetchUsers(ctx, keys)
+//	})
+//
+//	user.FieldFunc("manager", func(ctx context.Context, u *User) (*User, error) {
+//		manager, err := userLoader.Load(ctx, u.ManagerId)
+//		...
+//	})
+func NewLoader(batchFunc func(ctx context.Context, keys []interface{}) ([]interface{}, error)) *Loader {
+	return &Loader{f: Func{Many: batchFunc}}
+}
+
+// Load returns the value of key, batching its load with other concurrent
+// calls to Load. If ctx has no batching support, key is loaded on its own.
+func (l *Loader) Load(ctx context.Context, key interface{}) (interface{}, error) {
+	bctx, ok := ctx.Value(batchContextKey{}).(*batchContext)
+	if !ok {
+		results, err := safeInvoke(ctx, l.f.Many, []interface{}{key})
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
+
+	l.once.Do(func() {
+		l.f.MaxSize = l.MaxBatchSize
+	})
+
+	lk := loaderKey{loader: l, key: key}
+
+	bctx.mu.Lock()
+	ld, existed := bctx.loads[lk]
+	if !existed {
+		ld = &load{doneCh: make(chan struct{}, 0)}
+		bctx.loads[lk] = ld
+	}
+	bctx.mu.Unlock()
+
+	if existed {
+		<-ld.doneCh
+	} else {
+		ld.value, ld.err = l.f.Invoke(ctx, key)
+		close(ld.doneCh)
+	}
+	return ld.value, ld.err
+}
@@ -0,0 +1,183 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Apply applies the diff d, computed by Diff, to the previous value and
+// returns the new value. It is the inverse of Diff: for any JSON values old
+// and new, Apply(old, Diff(old, new)) equals new, except that __key fields of
+// replaced values are stripped.
+//
+// Apply accepts diffs as returned by Diff, and diffs decoded from JSON with
+// encoding/json, as received by clients. Apply does not modify previous; the
+// new value shares unchanged parts with it.
+func Apply(previous interface{}, d interface{}) (interface{}, error) {
+	if d == nil {
+		return previous, nil
+	}
+
+	diff, ok := d.(map[string]interface{})
+	if !ok {
+		return applyReplaced(d)
+	}
+
+	switch previous := previous.(type) {
+	case map[string]interface{}:
+		return applyMap(previous, diff)
+	case []interface{}:
+		return applyArray(previous, diff)
+	default:
+		return nil, fmt.Errorf("diff: cannot apply object diff to %T", previous)
+	}
+}
+
+// applyMap applies a diff to a map field-by-field.
+func applyMap(previous map[string]interface{}, diff map[string]interface{}) (map[string]interface{}, error) {
+	new := make(map[string]interface{}, len(previous))
+
+	for k, v := range previous {
+		d, ok := diff[k]
+		if !ok {
+			new[k] = v
+			continue
+		}
+		if isRemoved(d) {
+			continue
+		}
+		newV, err := Apply(v, d)
+		if err != nil {
+			return nil, err
+		}
+		new[k] = newV
+	}
+
+	// Added fields hold their new value as is.
+	for k, d := range diff {
+		if _, ok := previous[k]; !ok {
+			new[k] = d
+		}
+	}
+
+	return new, nil
+}
+
+// applyArray applies a diff to an array by first reordering the elements and
+// then applying the diffs of the elements one-by-one.
+func applyArray(previous []interface{}, diff map[string]interface{}) ([]interface{}, error) {
+	var new []interface{}
+	if compressed, ok := diff["$"]; ok {
+		indices, err := uncompressReorderIndices(compressed)
+		if err != nil {
+			return nil, err
+		}
+		new = make([]interface{}, len(indices))
+		for i, index := range indices {
+			if index < -1 || index >= len(previous) {
+				return nil, fmt.Errorf("diff: reorder index %d out of range [0, %d)", index, len(previous))
+			}
+			if index != -1 {
+				new[i] = previous[index]
+			}
+		}
+	} else {
+		new = make([]interface{}, len(previous))
+		copy(new, previous)
+	}
+
+	for k, d := range diff {
+		if k == "$" {
+			continue
+		}
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(new) {
+			return nil, fmt.Errorf("diff: bad array index %q", k)
+		}
+		newV, err := Apply(new[i], d)
+		if err != nil {
+			return nil, err
+		}
+		new[i] = newV
+	}
+
+	return new, nil
+}
+
+// applyReplaced returns the new value of a replacement diff, as computed by
+// markReplaced.
+func applyReplaced(d interface{}) (interface{}, error) {
+	switch d := d.(type) {
+	case []interface{}:
+		if len(d) != 1 {
+			return nil, fmt.Errorf("diff: replacement should be an array of length 1, got %d", len(d))
+		}
+		return d[0], nil
+	case map[string]interface{}:
+		return nil, fmt.Errorf("diff: cannot apply object diff to missing value")
+	default:
+		return d, nil
+	}
+}
+
+// isRemoved returns true if d marks a removed field, as computed by
+// markRemoved.
+func isRemoved(d interface{}) bool {
+	array, ok := d.([]interface{})
+	return ok && len(array) == 0
+}
+
+// uncompressReorderIndices reverses compressReorderIndices. It accepts
+// indices as computed by compressReorderIndices and as decoded from JSON.
+func uncompressReorderIndices(compressed interface{}) ([]int, error) {
+	runs, ok := compressed.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("diff: reorder indices should be an array, got %T", compressed)
+	}
+
+	var indices []int
+	for _, run := range runs {
+		var first, count int
+		switch run := run.(type) {
+		case [2]int:
+			first, count = run[0], run[1]
+		case []interface{}:
+			if len(run) != 2 {
+				return nil, fmt.Errorf("diff: reorder run should be [first, count], got %v", run)
+			}
+			var ok1, ok2 bool
+			first, ok1 = toInt(run[0])
+			count, ok2 = toInt(run[1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("diff: reorder run should be [first, count], got %v", run)
+			}
+		default:
+			index, ok := toInt(run)
+			if !ok {
+				return nil, fmt.Errorf("diff: bad reorder index %v", run)
+			}
+			indices = append(indices, index)
+			continue
+		}
+		for i := 0; i < count; i++ {
+			indices = append(indices, first+i)
+		}
+	}
+	return indices, nil
+}
+
+// toInt converts an index, either an int or a float64 decoded from JSON, to
+// an int.
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false
+		}
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
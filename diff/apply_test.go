@@ -0,0 +1,216 @@
+package diff_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/samsarahq/thunder/diff"
+	"github.com/samsarahq/thunder/internal"
+)
+
+func TestApply(t *testing.T) {
+	testcases := []struct {
+		desc string
+		old  interface{}
+		new  interface{}
+	}{
+		{"scalar", 1, 2},
+		{"scalar to nil", 1, nil},
+		{"nil to object", nil, map[string]interface{}{"a": 1}},
+		{"object to scalar", map[string]interface{}{"a": 1}, "a"},
+		{"array to nil", []interface{}{1}, nil},
+		{
+			"changed, added and removed fields",
+			map[string]interface{}{"changed": 1, "removed": 2, "same": 3},
+			map[string]interface{}{"changed": 4, "added": []interface{}{5}, "same": 3},
+		},
+		{
+			"added empty array",
+			map[string]interface{}{},
+			map[string]interface{}{"added": []interface{}{}},
+		},
+		{
+			"nested",
+			map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1, 2, 3}, "c": 4}},
+			map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{3, 1, 2, 5}, "c": 4}},
+		},
+		{
+			"reordered objects",
+			[]interface{}{
+				map[string]interface{}{"__key": 1, "name": "bob"},
+				map[string]interface{}{"__key": 2, "name": "alice"},
+				map[string]interface{}{"__key": 3, "name": "carol"},
+			},
+			[]interface{}{
+				map[string]interface{}{"__key": 3, "name": "carol"},
+				map[string]interface{}{"__key": 4, "name": "dave"},
+				map[string]interface{}{"__key": 1, "name": "bobby"},
+			},
+		},
+		{"null elements", []interface{}{nil, 1}, []interface{}{1, nil, nil}},
+	}
+
+	for _, tc := range testcases {
+		testApply(t, tc.desc, tc.old, tc.new, diff.Options{})
+	}
+}
+
+func TestApplyRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 2000; i++ {
+		old := randomValue(r, 3)
+		new := mutate(r, old, 3)
+		testApply(t, fmt.Sprintf("case %d", i), old, new, diff.Options{})
+		testApply(t, fmt.Sprintf("case %d with key", i), old, new, diff.Options{Key: diff.FieldKey("a")})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	testcases := []struct {
+		desc string
+		old  interface{}
+		diff string
+	}{
+		{"object diff on scalar", 1, `{"a": 1}`},
+		{"bad replacement", 1, `[1, 2]`},
+		{"bad array index", []interface{}{1}, `{"x": 1}`},
+		{"array index out of range", []interface{}{1}, `{"1": 1}`},
+		{"reorder index out of range", []interface{}{1}, `{"$": [1]}`},
+		{"bad reorder run", []interface{}{1}, `{"$": [[0]]}`},
+	}
+
+	for _, tc := range testcases {
+		if _, err := diff.Apply(internal.AsJSON(tc.old), internal.ParseJSON(tc.diff)); err == nil {
+			t.Errorf("%s: expected error", tc.desc)
+		}
+	}
+}
+
+// testApply checks that applying the diff between old and new to old returns
+// new, both for the diff as returned by DiffWithOptions and as decoded from
+// JSON.
+func testApply(t *testing.T, desc string, old, new interface{}, opts diff.Options) {
+	d := diff.DiffWithOptions(old, new, opts)
+
+	applied, err := diff.Apply(old, d)
+	if err != nil {
+		t.Errorf("%s: Apply(%v, %v): %s", desc, old, d, err)
+		return
+	}
+	if !reflect.DeepEqual(diff.StripKey(applied), diff.StripKey(new)) {
+		t.Errorf("%s: Apply(%v, %v) = %v, expected %v", desc, old, d, applied, new)
+	}
+
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(bytes, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	applied, err = diff.Apply(internal.AsJSON(old), decoded)
+	if err != nil {
+		t.Errorf("%s: Apply(%v, %s): %s", desc, old, bytes, err)
+		return
+	}
+	if !reflect.DeepEqual(internal.AsJSON(diff.StripKey(applied)), internal.AsJSON(diff.StripKey(new))) {
+		t.Errorf("%s: Apply(%v, %s) = %v, expected %v", desc, old, bytes, applied, new)
+	}
+}
+
+var randomFields = []string{"a", "b", "c", "d"}
+
+// randomValue returns a random JSON value nested at most depth levels deep.
+// Objects in arrays have a __key field some of the time.
+func randomValue(r *rand.Rand, depth int) interface{} {
+	n := 4
+	if depth > 0 {
+		n = 6
+	}
+	switch r.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(4)
+	case 2:
+		return fmt.Sprint(r.Intn(4))
+	case 3:
+		return r.Intn(2) == 0
+	case 4:
+		object := make(map[string]interface{})
+		for _, field := range randomFields {
+			if r.Intn(2) == 0 {
+				object[field] = randomValue(r, depth-1)
+			}
+		}
+		if r.Intn(2) == 0 {
+			object["__key"] = r.Intn(4)
+		}
+		return object
+	default:
+		array := make([]interface{}, r.Intn(5))
+		for i := range array {
+			array[i] = randomValue(r, depth-1)
+		}
+		return array
+	}
+}
+
+// mutate returns a random modification of value: either value itself, a
+// new random value, or a copy of value with some fields or elements added,
+// removed, reordered, or mutated.
+func mutate(r *rand.Rand, value interface{}, depth int) interface{} {
+	switch r.Intn(6) {
+	case 0:
+		return value
+	case 1:
+		return randomValue(r, depth)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{})
+		for k, v := range value {
+			switch {
+			case r.Intn(4) == 0:
+			case k == "__key":
+				// Keys must stay comparable.
+				object[k] = r.Intn(4)
+			default:
+				object[k] = mutate(r, v, depth-1)
+			}
+		}
+		for _, field := range randomFields {
+			if _, ok := object[field]; !ok && r.Intn(4) == 0 {
+				object[field] = randomValue(r, depth-1)
+			}
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			switch r.Intn(4) {
+			case 0:
+			case 1:
+				array = append(array, v)
+			default:
+				array = append(array, mutate(r, v, depth-1))
+			}
+			if r.Intn(4) == 0 {
+				array = append(array, randomValue(r, depth-1))
+			}
+		}
+		for i := range array {
+			if j := r.Intn(len(array)); r.Intn(2) == 0 {
+				array[i], array[j] = array[j], array[i]
+			}
+		}
+		return array
+	default:
+		return randomValue(r, depth)
+	}
+}
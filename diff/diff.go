@@ -76,6 +76,9 @@
 // Here, the diff first switches the order of the elements in the array,
 // using the __key field to identify the two objects, and then updates
 // the "age" field in the second element of the array to 23.
+//
+// The format is versioned by Version. Any change to the format must bump
+// Version, so that clients can reject diffs they do not understand.
 package diff
 
 import (
@@ -84,6 +87,10 @@ import (
 	"reflect"
 )
 
+// Version is the version of the diff format computed by Diff and applied by
+// Apply. Servers send it along with diffs; see graphql.DiffVersionKey.
+const Version = 1
+
 var emptyArray = []interface{}{}
 
 // markRemoved returns a 0-element JSON array to indicate a removed field.
@@ -134,61 +141,82 @@ func markReplaced(i interface{}) interface{} {
 }
 
 // diffMap computes a diff between two maps by comparing fields key-by-key.
-func diffMap(old map[string]interface{}, newAny interface{}) interface{} {
+func diffMap(old map[string]interface{}, newAny interface{}, opts *Options, path []string) (interface{}, bool) {
 	// Verify the type of new.
 	new, ok := newAny.(map[string]interface{})
 	if !ok {
-		return markReplaced(new)
+		return markReplaced(newAny), true
 	}
 
 	// Check if two map are identical by comparing their pointers, and
 	// short-circuit if so.
 	if reflect.ValueOf(old).Pointer() == reflect.ValueOf(new).Pointer() {
-		return nil
+		return nil, false
 	}
 
 	// Assert that the __key fields, if present, are equal.
 	if old["__key"] != new["__key"] {
-		return markReplaced(new)
+		return markReplaced(new), true
 	}
 
 	// Build the diff.
 	d := make(map[string]interface{})
+	changed := false
 
 	// Handle deleted fields.
 	for k := range old {
 		if _, ok := new[k]; !ok {
 			d[k] = markRemoved()
+			changed = changed || !opts.ignored(opts.fieldPath(path, k))
 		}
 	}
 
 	// Handle changed fields.
 	for k, newV := range new {
+		fieldPath := opts.fieldPath(path, k)
 		if oldV, ok := old[k]; ok {
-			if innerD := Diff(oldV, newV); innerD != nil {
+			if innerD, innerChanged := diff(oldV, newV, opts, fieldPath); innerD != nil {
 				d[k] = innerD
+				changed = changed || (innerChanged && !opts.ignored(fieldPath))
 			}
 		} else {
+			// Added fields are sent as is, rather than marked replaced, as
+			// clients expect.
 			d[k] = newV
+			changed = changed || !opts.ignored(fieldPath)
 		}
 	}
 
 	// Check if the diff is empty.
 	if len(d) == 0 {
-		return nil
+		return nil, false
 	}
-	return d
+
+	// Send fields that are always sent whole along with any other change.
+	if opts.AlwaysSend != nil {
+		for k, newV := range new {
+			if _, ok := old[k]; ok && opts.AlwaysSend(opts.fieldPath(path, k)) {
+				d[k] = markReplaced(newV)
+			}
+		}
+	}
+	return d, changed
 }
 
 // reoderKey returns the key to use for a
-func reorderKey(i interface{}) interface{} {
+func reorderKey(i interface{}, opts *Options) interface{} {
 	if object, ok := i.(map[string]interface{}); ok {
 		if key, ok := object["__key"]; ok {
 			return key
 		}
+		if opts.Key != nil {
+			if key, ok := opts.Key(object); ok && key != nil && reflect.TypeOf(key).Comparable() {
+				return key
+			}
+		}
 	}
 
-	if reflect.TypeOf(i).Comparable() {
+	if i == nil || reflect.TypeOf(i).Comparable() {
 		return i
 	}
 
@@ -199,18 +227,18 @@ func reorderKey(i interface{}) interface{} {
 // item in new
 //
 // If an item in new is not present in old, the index is -1. Objects are
-// identified using the __key field, if present. Otherwise, the values are used
-// as map keys if they are comparable.
-func computeReorderIndices(old, new []interface{}) []int {
+// identified using the __key field, if present, or else the key returned by
+// opts.Key. Otherwise, the values are used as map keys if they are comparable.
+func computeReorderIndices(old, new []interface{}, opts *Options) []int {
 	oldIndices := make(map[interface{}][]int)
 	for i, item := range old {
-		key := reorderKey(item)
+		key := reorderKey(item, opts)
 		oldIndices[key] = append(oldIndices[key], i)
 	}
 
 	indices := make([]int, len(new))
 	for i, item := range new {
-		key := reorderKey(item)
+		key := reorderKey(item, opts)
 		if index := oldIndices[key]; len(index) > 0 {
 			indices[i] = index[0]
 			oldIndices[key] = index[1:]
@@ -254,23 +282,24 @@ func compressReorderIndices(indices []int) []interface{} {
 
 // diffArray computes a diff between two arrays by first reordering the
 // elements and then comparing elements one-by-one.
-func diffArray(old []interface{}, newAny interface{}) interface{} {
+func diffArray(old []interface{}, newAny interface{}, opts *Options, path []string) (interface{}, bool) {
 	// Verify the type of new.
 	new, ok := newAny.([]interface{})
 	if !ok {
-		return markReplaced(new)
+		return markReplaced(newAny), true
 	}
 
 	// Check if two arrays are identical by comparing their pointers and length,
 	// and short-circuit if so.
 	if len(old) == len(new) && reflect.ValueOf(old).Pointer() == reflect.ValueOf(new).Pointer() {
-		return nil
+		return nil, false
 	}
 
 	d := make(map[string]interface{})
+	changed := false
 
 	// Compute reorder indices.
-	indices := computeReorderIndices(old, new)
+	indices := computeReorderIndices(old, new, opts)
 
 	// Check if the reorder indices can be omitted.
 	orderChanged := len(old) != len(indices)
@@ -281,6 +310,7 @@ func diffArray(old []interface{}, newAny interface{}) interface{} {
 	}
 	if orderChanged {
 		d["$"] = compressReorderIndices(indices)
+		changed = true
 	}
 
 	// Compare the array elements.
@@ -289,16 +319,17 @@ func diffArray(old []interface{}, newAny interface{}) interface{} {
 		if j := indices[i]; j != -1 {
 			oldI = old[j]
 		}
-		if innerD := Diff(oldI, newI); innerD != nil {
+		if innerD, innerChanged := diff(oldI, newI, opts, path); innerD != nil {
 			d[fmt.Sprint(i)] = innerD
+			changed = changed || innerChanged
 		}
 	}
 
 	// Check if the diff is empty.
 	if len(d) == 0 {
-		return nil
+		return nil, false
 	}
-	return d
+	return d, changed
 }
 
 // Diff computes a diff between two JSON objects. See the package comment for
@@ -306,20 +337,27 @@ func diffArray(old []interface{}, newAny interface{}) interface{} {
 //
 // A nil diff indicates that the old and new objects are equal.
 func Diff(old interface{}, new interface{}) interface{} {
+	d, _ := diff(old, new, &Options{}, nil)
+	return d
+}
+
+// diff computes a diff between old and new, the values at path. It also
+// returns whether the diff holds any change that is not ignored by opts.
+func diff(old interface{}, new interface{}, opts *Options, path []string) (interface{}, bool) {
 	switch old := old.(type) {
 	case map[string]interface{}:
-		return diffMap(old, new)
+		return diffMap(old, new, opts, path)
 	case []interface{}:
-		return diffArray(old, new)
+		return diffArray(old, new, opts, path)
 	case []uint8:
 		if new, ok := new.([]uint8); ok && bytes.Equal(old, new) {
-			return nil
+			return nil, false
 		}
-		return markReplaced(new)
+		return markReplaced(new), true
 	default:
 		if old != new {
-			return markReplaced(new)
+			return markReplaced(new), true
 		}
-		return nil
+		return nil, false
 	}
 }
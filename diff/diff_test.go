@@ -226,6 +226,19 @@ func TestDiffObjects(t *testing.T) {
 	if d != nil {
 		t.Error("bad identical")
 	}
+
+	// Added fields are sent as is, and not marked replaced.
+	d = diff.Diff(map[string]interface{}{
+		"foo": "bar",
+	}, map[string]interface{}{
+		"foo":   "bar",
+		"added": map[string]interface{}{"baz": 1},
+	})
+	if !reflect.DeepEqual(internal.AsJSON(d), internal.ParseJSON(`
+		{"added": {"baz": 1}}
+	`)) {
+		t.Error("bad added")
+	}
 }
 
 func TestKitchenSink(t *testing.T) {
@@ -278,3 +291,64 @@ func TestKitchenSink(t *testing.T) {
 		t.Error("bad kitchen sink")
 	}
 }
+
+func TestDiffWithKey(t *testing.T) {
+	old := []interface{}{
+		map[string]interface{}{"id": 1, "name": "a"},
+		map[string]interface{}{"id": 2, "name": "b"},
+		map[string]interface{}{"id": 3, "name": "c"},
+	}
+	new := []interface{}{
+		map[string]interface{}{"id": 3, "name": "c"},
+		map[string]interface{}{"id": 1, "name": "a"},
+		map[string]interface{}{"id": 2, "name": "bb"},
+	}
+
+	d := diff.DiffWithOptions(old, new, diff.Options{Key: diff.FieldKey("id")})
+	if !reflect.DeepEqual(internal.AsJSON(d), internal.ParseJSON(`
+		{"$": [2, [0, 2]], "2": {"name": "bb"}}
+	`)) {
+		t.Errorf("bad keyed reorder: %s", internal.MarshalJSON(d))
+	}
+
+	// Without a key, objects are compared position-by-position.
+	d = diff.Diff(old, new)
+	if !reflect.DeepEqual(internal.AsJSON(d), internal.ParseJSON(`
+		{"0": {"id": 3, "name": "c"}, "1": {"id": 1, "name": "a"}, "2": {"id": 2, "name": "bb"}}
+	`)) {
+		t.Errorf("bad unkeyed diff: %s", internal.MarshalJSON(d))
+	}
+
+	applied, err := diff.Apply(old, diff.DiffWithOptions(old, new, diff.Options{Key: diff.FieldKey("id")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(applied, new) {
+		t.Errorf("expected %v, got %v", new, applied)
+	}
+}
+
+func TestDiffAlwaysSendAndIgnore(t *testing.T) {
+	opts := diff.Options{
+		AlwaysSend: diff.MatchPaths("users.signature"),
+		Ignore:     diff.MatchPaths("users.lastSeen"),
+	}
+	user := func(name string, lastSeen int) map[string]interface{} {
+		return map[string]interface{}{"name": name, "lastSeen": lastSeen, "signature": "s"}
+	}
+	old := map[string]interface{}{"users": []interface{}{user("bob", 1)}}
+
+	// Changes to ignored fields alone do not produce a diff.
+	if d := diff.DiffWithOptions(old, map[string]interface{}{"users": []interface{}{user("bob", 2)}}, opts); d != nil {
+		t.Errorf("expected no diff, got %s", internal.MarshalJSON(d))
+	}
+
+	// Ignored fields are sent along with other changes, and fields that are
+	// always sent are sent whole.
+	d := diff.DiffWithOptions(old, map[string]interface{}{"users": []interface{}{user("alice", 2)}}, opts)
+	if !reflect.DeepEqual(internal.AsJSON(d), internal.ParseJSON(`
+		{"users": {"0": {"name": "alice", "lastSeen": 2, "signature": "s"}}}
+	`)) {
+		t.Errorf("bad diff: %s", internal.MarshalJSON(d))
+	}
+}
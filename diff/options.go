@@ -0,0 +1,86 @@
+package diff
+
+import "strings"
+
+// Options configure how DiffWithOptions computes diffs.
+//
+// Fields are identified by their path, which holds the names of the fields
+// leading to them from the root object. Paths skip array indices, so that a
+// path applies to all elements of an array. For example, the path of the
+// field name in
+//
+//	{"users": [{"name": "bob"}]}
+//
+// is ["users", "name"].
+type Options struct {
+	// Key, if set, identifies objects in arrays that have no __key field.
+	// Objects with equal keys are lined up when diffing arrays, so that
+	// reordering an array is sent as a compact reordering of the old
+	// elements rather than as new values for every changed position. Key
+	// returns false for objects that have no identity. Keys must be
+	// comparable.
+	Key func(object map[string]interface{}) (interface{}, bool)
+
+	// AlwaysSend, if set, returns true for the paths of fields that are sent
+	// whole whenever their object changes, even if the fields themselves did
+	// not change, such as a signature of the object.
+	AlwaysSend func(path []string) bool
+
+	// Ignore, if set, returns true for the paths of fields whose changes do
+	// not produce a diff by themselves. Changes to ignored fields are still
+	// sent with the next diff that has other changes, as long as the caller
+	// keeps diffing against the last value it sent.
+	Ignore func(path []string) bool
+}
+
+// DiffWithOptions computes a diff between two JSON objects like Diff, as
+// configured by opts. The diff is in the same format, and can be applied with
+// Apply.
+//
+// A nil diff indicates that the old and new objects are equal, except for
+// ignored fields.
+func DiffWithOptions(old interface{}, new interface{}, opts Options) interface{} {
+	d, changed := diff(old, new, &opts, nil)
+	if !changed {
+		return nil
+	}
+	return d
+}
+
+// FieldKey returns an Options.Key that identifies objects by the value of
+// their field name, such as "id".
+func FieldKey(name string) func(object map[string]interface{}) (interface{}, bool) {
+	return func(object map[string]interface{}) (interface{}, bool) {
+		key, ok := object[name]
+		return key, ok
+	}
+}
+
+// MatchPaths returns a function for Options.AlwaysSend or Options.Ignore that
+// matches the given dot-separated paths, such as "users.signature".
+func MatchPaths(paths ...string) func(path []string) bool {
+	set := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		set[path] = true
+	}
+	return func(path []string) bool {
+		return set[strings.Join(path, ".")]
+	}
+}
+
+// fieldPath returns the path of field k of the object at path, or nil if opts
+// does not use paths.
+func (opts *Options) fieldPath(path []string, k string) []string {
+	if opts.AlwaysSend == nil && opts.Ignore == nil {
+		return nil
+	}
+	fieldPath := make([]string, len(path)+1)
+	copy(fieldPath, path)
+	fieldPath[len(path)] = k
+	return fieldPath
+}
+
+// ignored returns true if changes to the field at path are ignored.
+func (opts *Options) ignored(path []string) bool {
+	return opts.Ignore != nil && opts.Ignore(path)
+}
@@ -0,0 +1,162 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A PatchOperation is an operation of a JSON patch, as defined by RFC 6902.
+type PatchOperation struct {
+	// Op is one of "add", "remove", "replace", or "move".
+	Op string
+	// Path is the JSON pointer of the location the operation changes.
+	Path string
+	// From is the JSON pointer of the location moved from, for "move".
+	From string
+	// Value is the new value, for "add" and "replace".
+	Value interface{}
+}
+
+// MarshalJSON encodes op as an RFC 6902 operation object. The value of "add"
+// and "replace" operations is always included, even if it is null.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	object := map[string]interface{}{
+		"op":   op.Op,
+		"path": op.Path,
+	}
+	switch op.Op {
+	case "add", "replace":
+		object["value"] = op.Value
+	case "move":
+		object["from"] = op.From
+	}
+	return json.Marshal(object)
+}
+
+// Patch computes a JSON patch, as defined by RFC 6902, that transforms old
+// into new, for clients that apply standard JSON patches rather than diffs.
+// Like Diff, Patch lines up objects in arrays by their __key field, and sends
+// reorderings of arrays as moves. Values in the patch have their __key fields
+// stripped.
+//
+// An empty patch indicates that the old and new objects are equal.
+func Patch(old interface{}, new interface{}) []PatchOperation {
+	return appendPatch(nil, "", old, new)
+}
+
+// appendPatch appends the operations transforming old into new, the values at
+// the JSON pointer path, to ops.
+func appendPatch(ops []PatchOperation, path string, old interface{}, new interface{}) []PatchOperation {
+	switch old := old.(type) {
+	case map[string]interface{}:
+		if new, ok := new.(map[string]interface{}); ok && old["__key"] == new["__key"] {
+			return appendMapPatch(ops, path, old, new)
+		}
+	case []interface{}:
+		if new, ok := new.([]interface{}); ok {
+			return appendArrayPatch(ops, path, old, new)
+		}
+	}
+
+	if Diff(old, new) == nil {
+		return ops
+	}
+	return append(ops, PatchOperation{Op: "replace", Path: path, Value: StripKey(new)})
+}
+
+// appendMapPatch appends the operations transforming the map old into new
+// field-by-field. Fields are visited in sorted order, so that patches are
+// deterministic.
+func appendMapPatch(ops []PatchOperation, path string, old map[string]interface{}, new map[string]interface{}) []PatchOperation {
+	if reflect.ValueOf(old).Pointer() == reflect.ValueOf(new).Pointer() {
+		return ops
+	}
+
+	for _, k := range sortedKeys(old) {
+		if _, ok := new[k]; !ok && k != "__key" {
+			ops = append(ops, PatchOperation{Op: "remove", Path: path + "/" + escapePointer(k)})
+		}
+	}
+	for _, k := range sortedKeys(new) {
+		if k == "__key" {
+			continue
+		}
+		fieldPath := path + "/" + escapePointer(k)
+		if oldV, ok := old[k]; ok {
+			ops = appendPatch(ops, fieldPath, oldV, new[k])
+		} else {
+			ops = append(ops, PatchOperation{Op: "add", Path: fieldPath, Value: StripKey(new[k])})
+		}
+	}
+	return ops
+}
+
+// appendArrayPatch appends the operations transforming the array old into
+// new. It first moves the elements of old that are still in new into place,
+// adding new elements and removing the elements left over, and then patches
+// the moved elements one-by-one.
+func appendArrayPatch(ops []PatchOperation, path string, old []interface{}, new []interface{}) []PatchOperation {
+	if len(old) == len(new) && reflect.ValueOf(old).Pointer() == reflect.ValueOf(new).Pointer() {
+		return ops
+	}
+
+	indices := computeReorderIndices(old, new, &Options{})
+
+	// current holds the index in old of each element of the array as patched
+	// so far, or -1 for added elements.
+	current := make([]int, len(old))
+	for i := range current {
+		current[i] = i
+	}
+
+	for i, index := range indices {
+		elementPath := path + "/" + strconv.Itoa(i)
+		if index == -1 {
+			ops = append(ops, PatchOperation{Op: "add", Path: elementPath, Value: StripKey(new[i])})
+			current = append(current[:i], append([]int{-1}, current[i:]...)...)
+			continue
+		}
+
+		j := i
+		for current[j] != index {
+			j++
+		}
+		if j != i {
+			ops = append(ops, PatchOperation{Op: "move", From: path + "/" + strconv.Itoa(j), Path: elementPath})
+			copy(current[i+1:j+1], current[i:j])
+			current[i] = index
+		}
+	}
+
+	// Remove the elements left over, from the end so that indices stay valid.
+	for i := len(current) - 1; i >= len(new); i-- {
+		ops = append(ops, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+
+	for i, index := range indices {
+		if index != -1 {
+			ops = appendPatch(ops, path+"/"+strconv.Itoa(i), old[index], new[i])
+		}
+	}
+	return ops
+}
+
+// sortedKeys returns the keys of object in sorted order.
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointer escapes a field name for use in a JSON pointer.
+func escapePointer(k string) string {
+	return pointerEscaper.Replace(k)
+}
@@ -0,0 +1,219 @@
+package diff_test
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/samsarahq/thunder/diff"
+	"github.com/samsarahq/thunder/internal"
+)
+
+func TestPatch(t *testing.T) {
+	patch := diff.Patch(map[string]interface{}{
+		"name":    "bob",
+		"removed": 1,
+		"a/b":     1,
+		"friends": []interface{}{
+			map[string]interface{}{"__key": 1, "name": "alice"},
+			map[string]interface{}{"__key": 2, "name": "carol"},
+			map[string]interface{}{"__key": 3, "name": "dave"},
+		},
+	}, map[string]interface{}{
+		"name":  "bob",
+		"added": nil,
+		"a/b":   2,
+		"friends": []interface{}{
+			map[string]interface{}{"__key": 3, "name": "dave"},
+			map[string]interface{}{"__key": 4, "name": "eve"},
+			map[string]interface{}{"__key": 1, "name": "alicia"},
+		},
+	})
+
+	if !reflect.DeepEqual(internal.AsJSON(patch), internal.ParseJSON(`[
+		{"op": "remove", "path": "/removed"},
+		{"op": "replace", "path": "/a~1b", "value": 2},
+		{"op": "add", "path": "/added", "value": null},
+		{"op": "move", "from": "/friends/2", "path": "/friends/0"},
+		{"op": "add", "path": "/friends/1", "value": {"name": "eve"}},
+		{"op": "remove", "path": "/friends/3"},
+		{"op": "replace", "path": "/friends/2/name", "value": "alicia"}
+	]`)) {
+		t.Errorf("bad patch: %s", internal.MarshalJSON(patch))
+	}
+
+	if patch := diff.Patch([]interface{}{1, 2}, []interface{}{1, 2}); len(patch) != 0 {
+		t.Errorf("expected empty patch, got %s", internal.MarshalJSON(patch))
+	}
+}
+
+func TestPatchRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 2000; i++ {
+		old := randomValue(r, 3)
+		new := mutate(r, old, 3)
+
+		patch := internal.AsJSON(diff.Patch(old, new))
+		applied, err := applyPatch(internal.AsJSON(diff.StripKey(old)), patch)
+		if err != nil {
+			t.Errorf("case %d: applying %s to %s: %s", i, internal.MarshalJSON(patch), internal.MarshalJSON(old), err)
+			continue
+		}
+		if !reflect.DeepEqual(applied, internal.AsJSON(diff.StripKey(new))) {
+			t.Errorf("case %d: applying %s to %s gave %s, expected %s", i, internal.MarshalJSON(patch),
+				internal.MarshalJSON(old), internal.MarshalJSON(applied), internal.MarshalJSON(new))
+		}
+	}
+}
+
+// applyPatch applies the JSON-decoded patch to doc, supporting the operations
+// emitted by Patch.
+func applyPatch(doc interface{}, patch interface{}) (interface{}, error) {
+	ops, _ := patch.([]interface{})
+	for _, op := range ops {
+		op := op.(map[string]interface{})
+		path := op["path"].(string)
+		var err error
+		switch op["op"] {
+		case "add":
+			doc, err = patchAt(doc, path, func(parent interface{}, k string) (interface{}, error) {
+				return insert(parent, k, op["value"])
+			})
+		case "replace":
+			doc, err = patchAt(doc, path, func(parent interface{}, k string) (interface{}, error) {
+				parent, _, err := remove(parent, k)
+				if err != nil {
+					return nil, err
+				}
+				return insert(parent, k, op["value"])
+			})
+		case "remove":
+			doc, err = patchAt(doc, path, func(parent interface{}, k string) (interface{}, error) {
+				parent, _, err := remove(parent, k)
+				return parent, err
+			})
+		case "move":
+			var value interface{}
+			doc, err = patchAt(doc, op["from"].(string), func(parent interface{}, k string) (interface{}, error) {
+				var err error
+				parent, value, err = remove(parent, k)
+				return parent, err
+			})
+			if err == nil {
+				doc, err = patchAt(doc, path, func(parent interface{}, k string) (interface{}, error) {
+					return insert(parent, k, value)
+				})
+			}
+		default:
+			err = fmt.Errorf("unknown op %v", op["op"])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// patchAt replaces the parent of the location at the JSON pointer path in
+// doc with f(parent, k), where k is the last token of path. The root is
+// replaced with the value f inserts into a nil parent.
+func patchAt(doc interface{}, path string, f func(parent interface{}, k string) (interface{}, error)) (interface{}, error) {
+	if path == "" {
+		wrapper, err := f(map[string]interface{}{"": doc}, "")
+		if err != nil {
+			return nil, err
+		}
+		return wrapper.(map[string]interface{})[""], nil
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(tokens[i], "~1", "/", -1), "~0", "~", -1)
+	}
+	var walk func(value interface{}, tokens []string) (interface{}, error)
+	walk = func(value interface{}, tokens []string) (interface{}, error) {
+		if len(tokens) == 1 {
+			return f(value, tokens[0])
+		}
+		child, err := get(value, tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		if child, err = walk(child, tokens[1:]); err != nil {
+			return nil, err
+		}
+		value, _, err = remove(value, tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		return insert(value, tokens[0], child)
+	}
+	return walk(doc, tokens)
+}
+
+func get(parent interface{}, k string) (interface{}, error) {
+	switch parent := parent.(type) {
+	case map[string]interface{}:
+		value, ok := parent[k]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s", k)
+		}
+		return value, nil
+	case []interface{}:
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(parent) {
+			return nil, fmt.Errorf("bad index %s", k)
+		}
+		return parent[i], nil
+	default:
+		return nil, fmt.Errorf("cannot get %s of %v", k, parent)
+	}
+}
+
+// insert returns a copy of parent with value inserted at k.
+func insert(parent interface{}, k string, value interface{}) (interface{}, error) {
+	switch parent := parent.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{})
+		for k, v := range parent {
+			copied[k] = v
+		}
+		copied[k] = value
+		return copied, nil
+	case []interface{}:
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i > len(parent) {
+			return nil, fmt.Errorf("bad index %s", k)
+		}
+		copied := append([]interface{}{}, parent[:i]...)
+		copied = append(copied, value)
+		return append(copied, parent[i:]...), nil
+	default:
+		return nil, fmt.Errorf("cannot insert %s into %v", k, parent)
+	}
+}
+
+// remove returns a copy of parent with k removed, and the removed value.
+func remove(parent interface{}, k string) (interface{}, interface{}, error) {
+	value, err := get(parent, k)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch parent := parent.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{})
+		for k, v := range parent {
+			copied[k] = v
+		}
+		delete(copied, k)
+		return copied, value, nil
+	default:
+		array := parent.([]interface{})
+		i, _ := strconv.Atoi(k)
+		copied := append([]interface{}{}, array[:i]...)
+		return append(copied, array[i+1:]...), value, nil
+	}
+}
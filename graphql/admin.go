@@ -0,0 +1,84 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// ConnectionInfo describes a live connection, as reported by AdminHandler.
+type ConnectionInfo struct {
+	// URL is the page the client reported in a url message, if any.
+	URL               string             `json:"url"`
+	SubscriptionCount int                `json:"subscriptionCount"`
+	Subscriptions     []SubscriptionInfo `json:"subscriptions"`
+}
+
+// SubscriptionInfo describes an open subscription of a live connection.
+type SubscriptionInfo struct {
+	ID        string `json:"id"`
+	QueryName string `json:"queryName"`
+	QueryType string `json:"queryType"`
+}
+
+// Connections describes the live connections in the registry, sorted by URL,
+// and their open subscriptions, sorted by id.
+func (r *Registry) Connections() []ConnectionInfo {
+	conns := r.live()
+	infos := make([]ConnectionInfo, 0, len(conns))
+	for _, c := range conns {
+		infos = append(infos, c.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].URL < infos[j].URL
+	})
+	return infos
+}
+
+// info describes the connection and its open subscriptions.
+func (c *conn) info() ConnectionInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subscriptions := make([]SubscriptionInfo, 0, len(c.subscriptionTags))
+	for id, tags := range c.subscriptionTags {
+		subscriptions = append(subscriptions, SubscriptionInfo{
+			ID:        id,
+			QueryName: tags["queryName"],
+			QueryType: tags["queryType"],
+		})
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	return ConnectionInfo{
+		URL:               c.url,
+		SubscriptionCount: len(subscriptions),
+		Subscriptions:     subscriptions,
+	}
+}
+
+type adminResponse struct {
+	Connections []ConnectionInfo `json:"connections"`
+}
+
+// AdminHandler returns an http.Handler reporting the live connections in
+// registry and their open subscriptions as JSON, for debugging. As the report
+// reveals what clients are running, requests must be allowed by authorize, or
+// are rejected with a 403 Forbidden. A nil authorize rejects all requests.
+func AdminHandler(registry *Registry, authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		responseJSON, err := json.Marshal(adminResponse{Connections: registry.Connections()})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	})
+}
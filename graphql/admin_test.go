@@ -0,0 +1,65 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestAdminHandler(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	registry := graphql.NewRegistry()
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetRegistry(registry)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{"type": "url", "message": "/dashboard"}
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "query Items { items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	handler := graphql.AdminHandler(registry, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "admin"
+	})
+
+	req := httptest.NewRequest("GET", "/admin/connections", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected %d without authorization, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/connections", nil)
+	req.Header.Set("Authorization", "admin")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Connections []graphql.ConnectionInfo `json:"connections"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := []graphql.ConnectionInfo{{
+		URL:               "/dashboard",
+		SubscriptionCount: 1,
+		Subscriptions:     []graphql.SubscriptionInfo{{ID: "1", QueryName: "Items", QueryType: "query"}},
+	}}
+	if !reflect.DeepEqual(response.Connections, expected) {
+		t.Errorf("expected %+v, got %+v", expected, response.Connections)
+	}
+}
@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ErrQueryNotAllowed is returned for queries that are not in the allowlist of
+// a connection. See SetAllowedQueries.
+var ErrQueryNotAllowed = NewSafeError("query is not allowed")
+
+// SetAllowedQueries restricts the connection to the queries and mutations
+// whose QueryHash is in allowed, such as the queries shipped with an app.
+// Other queries are rejected with ErrQueryNotAllowed before they are parsed. A
+// nil allowed allows all queries.
+func (c *conn) SetAllowedQueries(allowed map[string]bool) {
+	c.allowedQueries = allowed
+}
+
+// checkAllowed returns ErrQueryNotAllowed if query is not in the allowlist of
+// the connection.
+func (c *conn) checkAllowed(query string) error {
+	return checkAllowed(c.allowedQueries, query)
+}
+
+// checkAllowed returns ErrQueryNotAllowed if allowed is set and query is not
+// in it.
+func checkAllowed(allowed map[string]bool, query string) error {
+	if allowed != nil && !allowed[QueryHash(query)] {
+		return ErrQueryNotAllowed
+	}
+	return nil
+}
+
+// QueryHash returns the hex-encoded SHA-256 hash of the normalized query, as
+// used by SetAllowedQueries. Queries that only differ in whitespace, commas,
+// and comments have the same hash.
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(NormalizeQuery(query)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeQuery removes the insignificant whitespace, commas, and comments
+// from query, without parsing it. Tokens are separated by a single space only
+// where needed, such as between two names.
+func NormalizeQuery(query string) string {
+	var normalized bytes.Buffer
+	separate := false
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '#':
+			// Comments run until the end of the line.
+			for i < len(query) && query[i] != '\n' && query[i] != '\r' {
+				i++
+			}
+			separate = true
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			separate = true
+
+		case c == '"':
+			end := stringEnd(query, i)
+			normalized.WriteString(query[i:end])
+			i = end - 1
+			separate = false
+
+		default:
+			// Only names and numbers need to be separated, where a negative
+			// number starts with '-'.
+			if separate && normalized.Len() > 0 && isNameByte(normalized.Bytes()[normalized.Len()-1]) && (isNameByte(c) || c == '-') {
+				normalized.WriteByte(' ')
+			}
+			normalized.WriteByte(c)
+			separate = false
+		}
+	}
+	return normalized.String()
+}
+
+// stringEnd returns the index just past the string or block string starting
+// at index start of query.
+func stringEnd(query string, start int) int {
+	if len(query) >= start+3 && query[start:start+3] == `"""` {
+		for i := start + 3; i+3 <= len(query); i++ {
+			if query[i] == '\\' && len(query) >= i+4 && query[i+1:i+4] == `"""` {
+				i += 3
+				continue
+			}
+			if query[i:i+3] == `"""` {
+				return i + 3
+			}
+		}
+		return len(query)
+	}
+
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '"', '\n':
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// isNameByte returns true if c may start or end a name or number.
+func isNameByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,85 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	testCases := []struct {
+		query      string
+		normalized string
+	}{
+		{
+			query:      "{ items { name } }",
+			normalized: "{items{name}}",
+		},
+		{
+			query: `
+				query Items($id: int64!, $text: string) {
+					# Comments are removed.
+					items(id: $id, text: "a, # b") { id name }
+					... on Query { items { name } }
+					echo(values: [1, -2])
+				}`,
+			normalized: `query Items($id:int64!$text:string){items(id:$id text:"a, # b"){id name}...on Query{items{name}}echo(values:[1 -2])}`,
+		},
+		{
+			query:      `{ a(text: """block "quoted" # string""") }`,
+			normalized: `{a(text:"""block "quoted" # string""")}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if normalized := graphql.NormalizeQuery(testCase.query); normalized != testCase.normalized {
+			t.Errorf("expected %q to normalize to %q, got %q", testCase.query, testCase.normalized, normalized)
+		}
+	}
+
+	if graphql.QueryHash("{ items { name } }") != graphql.QueryHash("{items,{name}}") {
+		t.Error("expected equivalent queries to have the same hash")
+	}
+}
+
+func TestAllowedQueries(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetAllowedQueries(map[string]bool{
+		graphql.QueryHash("{ items { name } }"):                 true,
+		graphql.QueryHash(`mutation { echo(text: "allowed") }`): true,
+	})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{\n  items {\n    name\n  }\n}"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { id } }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "query is not allowed", "errors": [{"message": "query is not allowed"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "allowed") }`},
+	}
+	socket.expect(t, `{"id": "3", "type": "result", "message": [{"echo": "allowed"}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "4",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "other") }`},
+	}
+	socket.expect(t, `{"id": "4", "type": "error", "message": "query is not allowed", "errors": [{"message": "query is not allowed"}]}`)
+}
@@ -1,12 +1,26 @@
 package graphql
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
 
 func await(value interface{}) (interface{}, error) {
 	switch value := value.(type) {
 	case *thunk:
 		return value.await()
 
+	case *nullableResult:
+		v, err := await(value.value)
+		if err != nil {
+			if !recoverable(err) {
+				return nil, err
+			}
+			return fieldError{err: err}, nil
+		}
+		return v, nil
+
 	case map[string]interface{}:
 		for k, v := range value {
 			v, err := await(v)
@@ -33,22 +47,93 @@ type thunk struct {
 	value interface{}
 	err   error
 	done  chan struct{}
+
+	// f computes the thunk, once started is set by either the forked
+	// goroutine or the first call to await.
+	f       func() (interface{}, error)
+	started int32
+}
+
+// A forkLimiter limits the number of goroutines running forks. Forks beyond
+// the limit do not get a goroutine of their own: they are queued, and run by
+// the goroutines of earlier forks as those finish, unless they are awaited
+// first, in which case they run in the awaiting goroutine. Awaiting goroutines
+// thus never wait on a fork that cannot start, even if they are all the
+// goroutines the limiter allows.
+type forkLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	queue   []*thunk
 }
 
-func fork(f func() (interface{}, error)) *thunk {
+// fork runs f in a new goroutine, or queues it if limiter is full, and
+// returns a thunk for its result. A nil limiter allows any number of
+// goroutines.
+func fork(limiter *forkLimiter, f func() (interface{}, error)) *thunk {
 	t := &thunk{
 		done: make(chan struct{}, 0),
+		f:    f,
 	}
 
-	go func() {
-		t.value, t.err = f()
-		close(t.done)
-	}()
+	if limiter == nil {
+		go t.run()
+		return t
+	}
 
+	limiter.mu.Lock()
+	if limiter.running >= limiter.limit {
+		limiter.queue = append(limiter.queue, t)
+		limiter.mu.Unlock()
+		return t
+	}
+	limiter.running++
+	limiter.mu.Unlock()
+
+	go limiter.work(t)
 	return t
 }
 
+// work runs t, and then the queued thunks, until the queue is empty.
+func (l *forkLimiter) work(t *thunk) {
+	for {
+		t.run()
+
+		l.mu.Lock()
+		if len(l.queue) == 0 {
+			l.running--
+			l.mu.Unlock()
+			return
+		}
+		t = l.queue[0]
+		l.queue[0] = nil
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+	}
+}
+
+// run computes the thunk, unless it was already started.
+func (t *thunk) run() {
+	if !atomic.CompareAndSwapInt32(&t.started, 0, 1) {
+		return
+	}
+	t.value, t.err = safeCall(t.f)
+	close(t.done)
+}
+
 func (t *thunk) await() (interface{}, error) {
+	t.run()
 	<-t.done
 	return t.value, t.err
 }
+
+// safeCall calls f, returning a panicError if it panics. Panics in forked
+// goroutines would otherwise crash the process.
+func safeCall(f func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			value, err = nil, newPanicError(p)
+		}
+	}()
+	return f()
+}
@@ -2,6 +2,9 @@ package graphql
 
 import (
 	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -12,7 +15,7 @@ func TestAwait(t *testing.T) {
 	list := []interface{}{}
 	for i := 0; i < 10; i++ {
 		copy := i
-		list = append(list, fork(func() (interface{}, error) {
+		list = append(list, fork(nil, func() (interface{}, error) {
 			time.Sleep(50 * time.Millisecond)
 			return copy, nil
 		}))
@@ -39,3 +42,141 @@ func TestAwait(t *testing.T) {
 		t.Errorf("bad final %v", final)
 	}
 }
+
+func TestAwaitMaxConcurrency(t *testing.T) {
+	limiter := newForkLimiter(3)
+
+	var running, maxRunning int64
+	list := []interface{}{}
+	for i := 0; i < 10; i++ {
+		copy := i
+		list = append(list, fork(limiter, func() (interface{}, error) {
+			n := atomic.AddInt64(&running, 1)
+			defer atomic.AddInt64(&running, -1)
+			for {
+				max := atomic.LoadInt64(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return copy, nil
+		}))
+	}
+
+	final, err := await(list)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(final, []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("bad final %v", final)
+	}
+	// The forks beyond the limit run once a slot frees up, or while
+	// awaiting, alongside at most the three forks running in goroutines.
+	if maxRunning > 4 {
+		t.Errorf("expected at most 4 concurrent forks, got %d", maxRunning)
+	}
+}
+
+func TestAwaitMaxConcurrencyQueues(t *testing.T) {
+	limiter := newForkLimiter(1)
+
+	release := make(chan struct{})
+	first := fork(limiter, func() (interface{}, error) {
+		<-release
+		return 1, nil
+	})
+	ran := make(chan struct{})
+	second := fork(limiter, func() (interface{}, error) {
+		close(ran)
+		return 2, nil
+	})
+
+	// The second fork waits for the first to finish, and then runs without
+	// being awaited.
+	select {
+	case <-ran:
+		t.Fatal("expected second fork to wait for a slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected second fork to run once the slot was released")
+	}
+
+	final, err := await([]interface{}{first, second})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(final, []interface{}{1, 2}) {
+		t.Errorf("bad final %v", final)
+	}
+}
+
+func TestAwaitMaxConcurrencyGoroutines(t *testing.T) {
+	limiter := newForkLimiter(10)
+
+	before := runtime.NumGoroutine()
+	release := make(chan struct{})
+	list := []interface{}{}
+	for i := 0; i < 10000; i++ {
+		copy := i
+		list = append(list, fork(limiter, func() (interface{}, error) {
+			<-release
+			return copy, nil
+		}))
+	}
+
+	// Only the forks within the limit have goroutines.
+	if n := runtime.NumGoroutine() - before; n > 10 {
+		t.Errorf("expected at most 10 goroutines, got %d", n)
+	}
+	close(release)
+
+	final, err := await(list)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(final.([]interface{})) != 10000 {
+		t.Errorf("expected 10000 results, got %d", len(final.([]interface{})))
+	}
+}
+
+func TestAwaitMaxConcurrencyNested(t *testing.T) {
+	limiter := newForkLimiter(1)
+
+	// A fork holding the only slot can await forks of its own, which run
+	// in the awaiting goroutine.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		final, err := await(fork(limiter, func() (interface{}, error) {
+			return await(fork(limiter, func() (interface{}, error) {
+				return "nested", nil
+			}))
+		}))
+		if err != nil || final != "nested" {
+			t.Errorf("expected nested, got %v, %v", final, err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("nested forks deadlocked")
+	}
+}
+
+func TestAwaitPanic(t *testing.T) {
+	_, err := await(fork(nil, func() (interface{}, error) {
+		panic("test panic")
+	}))
+	if _, ok := err.(panicError); !ok {
+		t.Fatalf("expected panicError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "test panic") || !strings.Contains(err.Error(), "await_test.go") {
+		t.Errorf("expected panic value and stack, got %s", err)
+	}
+}
@@ -0,0 +1,32 @@
+package graphql
+
+import "sync"
+
+// CausedByKey is the metadata key set on subscription updates from reruns
+// triggered by mutations on the connection. It holds the ids of those
+// mutations, so that clients can reconcile optimistic changes once the
+// updates caused by a mutation arrive.
+const CausedByKey = "causedBy"
+
+// rerunCauses collects the ids of the mutations that triggered reruns of a
+// subscription since its last computation.
+type rerunCauses struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// add records the mutations ids as triggering the next rerun.
+func (r *rerunCauses) add(ids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, ids...)
+}
+
+// take returns and clears the recorded mutation ids.
+func (r *rerunCauses) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := r.ids
+	r.ids = nil
+	return ids
+}
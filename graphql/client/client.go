@@ -0,0 +1,485 @@
+// Package client is a Go client for Thunder GraphQL servers, for services
+// that query other services over the same websocket protocol as the
+// JavaScript client.
+//
+// Queries and mutations decode their result into a Go value:
+//
+//	var result struct {
+//		Users []struct {
+//			Name string
+//		}
+//	}
+//	err := c.Query(ctx, "{ users { name } }", nil, &result)
+//
+// Subscriptions reassemble the incremental diffs sent by the server into
+// complete snapshots of the result, delivered on a channel.
+//
+// Results are decoded with encoding/json, so struct fields match GraphQL
+// fields by name, case-insensitively, or by json tag. The client only
+// supports diffs computed by graphql.JSONDiffer, the server default, and
+// complete initial results sent by servers configured with
+// SetInitialSnapshot. It fails subscriptions whose updates are in another
+// version of the diff format, but cannot tell updates computed by other
+// Differs, such as graphql.SnapshotDiffer or graphql.JSONPatchDiffer, from
+// diffs, and must not be used with servers configured with them.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/samsarahq/thunder/diff"
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// ErrClosed is returned for operations on a closed Client.
+var ErrClosed = errors.New("client closed")
+
+// A ServerError is an error returned by the server for an operation.
+type ServerError struct {
+	Message string
+	Errors  []graphql.ResponseError
+}
+
+func (e *ServerError) Error() string {
+	return e.Message
+}
+
+// inEnvelope is a message received from the server. It mirrors
+// graphql.OutEnvelope, with the message left undecoded.
+type inEnvelope struct {
+	ID       string                  `json:"id"`
+	Type     string                  `json:"type"`
+	Message  json.RawMessage         `json:"message"`
+	Errors   []graphql.ResponseError `json:"errors"`
+	Metadata map[string]interface{}  `json:"metadata"`
+}
+
+// outEnvelope is a message sent to the server. It mirrors
+// graphql.InEnvelope.
+type outEnvelope struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Message interface{} `json:"message,omitempty"`
+}
+
+type operationMessage struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// A Client issues queries, mutations, and subscriptions over a single
+// connection to a Thunder server. A Client is safe for concurrent use.
+type Client struct {
+	socket graphql.JSONSocket
+
+	// writeMu serializes writes to socket.
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	nextID        int
+	subscriptions map[string]*Subscription
+	connected     chan error
+	err           error
+}
+
+// Dial connects to the Thunder server at url, such as ws://host/graphql.
+func Dial(url string, header http.Header) (*Client, error) {
+	socket, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, err
+	}
+	return New(socket), nil
+}
+
+// New creates a Client speaking over socket, and starts reading from it.
+func New(socket graphql.JSONSocket) *Client {
+	c := &Client{
+		socket:        socket,
+		subscriptions: make(map[string]*Subscription),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the connection. Open subscriptions are closed with ErrClosed.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.socket.Close()
+}
+
+func (c *Client) write(message outEnvelope) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.socket.WriteJSON(message)
+}
+
+// Connect sends the connect message, holding payload, that servers
+// configured with an OnConnectFunc require before any operation, and waits
+// for the server to accept it.
+func (c *Client) Connect(ctx context.Context, payload interface{}) error {
+	connected := make(chan error, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.connected = connected
+	c.mu.Unlock()
+
+	if err := c.write(outEnvelope{Type: "connect", Message: payload}); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-connected:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readLoop dispatches messages from the server until the connection fails.
+func (c *Client) readLoop() {
+	for {
+		var message inEnvelope
+		if err := c.socket.ReadJSON(&message); err != nil {
+			c.fail(err)
+			return
+		}
+
+		switch message.Type {
+		case "connected", "connection_error":
+			var err error
+			if message.Type == "connection_error" {
+				err = &ServerError{Message: decodeErrorMessage(message.Message)}
+			}
+			c.mu.Lock()
+			if c.connected != nil {
+				c.connected <- err
+				c.connected = nil
+			}
+			c.mu.Unlock()
+
+		default:
+			c.mu.Lock()
+			s, ok := c.subscriptions[message.ID]
+			c.mu.Unlock()
+			if ok {
+				s.handle(&message)
+			}
+		}
+	}
+}
+
+// fail closes all subscriptions with err, the first time it is called.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	subscriptions := c.subscriptions
+	c.subscriptions = make(map[string]*Subscription)
+	if c.connected != nil {
+		c.connected <- err
+		c.connected = nil
+	}
+	c.mu.Unlock()
+
+	for _, s := range subscriptions {
+		s.finish(err)
+	}
+}
+
+// A Snapshot is the complete result of a subscription after an update.
+type Snapshot struct {
+	// Data holds a pointer to a new value of the type passed to Subscribe,
+	// decoded from the result.
+	Data interface{}
+	// Metadata holds the metadata sent with the update, if any.
+	Metadata map[string]interface{}
+	// Errors holds the errors of the fields that failed, which are null in
+	// Data, for servers sending partial results.
+	Errors []graphql.ResponseError
+}
+
+// A Subscription receives the results of a subscribed query as they change.
+type Subscription struct {
+	client *Client
+	id     string
+	typ    reflect.Type
+
+	// previous is the last result, as raw JSON. It is only accessed by the
+	// reading goroutine of the client.
+	previous interface{}
+
+	// mu guards closing snapshots.
+	mu        sync.Mutex
+	closed    bool
+	snapshots chan Snapshot
+	err       error
+
+	// done is closed once the subscription ends.
+	done chan struct{}
+}
+
+// Subscribe subscribes to query, and delivers a Snapshot of the result every
+// time it changes. result is a pointer to a value of the Go type the result
+// is decoded into, such as &MyResult{}; every Snapshot holds a new pointer of
+// the same type.
+//
+// Canceling ctx unsubscribes, as Close does, and ends the subscription with
+// the error of ctx.
+func (c *Client) Subscribe(ctx context.Context, query string, variables map[string]interface{}, result interface{}) (*Subscription, error) {
+	s, err := c.start("subscribe", query, variables, result)
+	if err != nil {
+		return nil, err
+	}
+	if done := ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				s.close(ctx.Err())
+			case <-s.done:
+			}
+		}()
+	}
+	return s, nil
+}
+
+func (c *Client) start(kind string, query string, variables map[string]interface{}, result interface{}) (*Subscription, error) {
+	typ := reflect.TypeOf(result)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return nil, errors.New("result must be a pointer")
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	s := &Subscription{
+		client:    c,
+		id:        strconv.Itoa(c.nextID),
+		typ:       typ.Elem(),
+		snapshots: make(chan Snapshot, 1),
+		done:      make(chan struct{}),
+	}
+	c.subscriptions[s.id] = s
+	c.mu.Unlock()
+
+	if err := c.write(outEnvelope{
+		ID:      s.id,
+		Type:    kind,
+		Message: operationMessage{Query: query, Variables: variables},
+	}); err != nil {
+		c.remove(s.id)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *Client) remove(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	return ok
+}
+
+// Snapshots returns the channel on which the snapshots of the result are
+// delivered. Readers that fall behind skip intermediate snapshots, but always
+// receive the latest one. The channel is closed when the subscription ends;
+// Err then returns the reason.
+func (s *Subscription) Snapshots() <-chan Snapshot {
+	return s.snapshots
+}
+
+// Err returns the error that ended the subscription, once Snapshots is
+// closed. It returns nil if the subscription was closed with Close.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Close unsubscribes and closes the Snapshots channel.
+func (s *Subscription) Close() error {
+	return s.close(nil)
+}
+
+// close unsubscribes and ends the subscription with err.
+func (s *Subscription) close(err error) error {
+	if !s.client.remove(s.id) {
+		return nil
+	}
+	s.finish(err)
+	return s.client.write(outEnvelope{ID: s.id, Type: "unsubscribe"})
+}
+
+// finish records err and closes the snapshots channel, once.
+func (s *Subscription) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.snapshots)
+	close(s.done)
+}
+
+// handle applies a message from the server to the subscription.
+func (s *Subscription) handle(message *inEnvelope) {
+	switch message.Type {
+	case "update", "result":
+		snapshot, err := s.apply(message)
+		if err != nil {
+			s.client.remove(s.id)
+			s.finish(err)
+			return
+		}
+		s.deliver(snapshot)
+		if message.Type == "result" {
+			s.client.remove(s.id)
+			s.finish(nil)
+		}
+
+	case "error":
+		s.client.remove(s.id)
+		s.finish(&ServerError{Message: decodeErrorMessage(message.Message), Errors: message.Errors})
+
+	case "complete":
+		s.client.remove(s.id)
+		s.finish(nil)
+	}
+}
+
+// apply merges the diff in message into the previous result, or replaces the
+// previous result with a complete result marked with graphql.SnapshotKey, and
+// decodes the new result. Messages without a diff version are from servers
+// predating versioning, and are assumed to be compatible.
+func (s *Subscription) apply(message *inEnvelope) (Snapshot, error) {
+	if version, ok := message.Metadata[graphql.DiffVersionKey]; ok && version != float64(diff.Version) {
+		return Snapshot{}, fmt.Errorf("unsupported diff version %v, expected %d", version, diff.Version)
+	}
+
+	// Updates that only change the errors of failed fields have no diff.
+	var d interface{}
+	if len(message.Message) > 0 {
+		if err := json.Unmarshal(message.Message, &d); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	current := d
+	if snapshot, _ := message.Metadata[graphql.SnapshotKey].(bool); !snapshot {
+		var err error
+		if current, err = diff.Apply(s.previous, d); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	s.previous = current
+
+	data := reflect.New(s.typ).Interface()
+	if err := decode(current, data); err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Data: data, Metadata: message.Metadata, Errors: message.Errors}, nil
+}
+
+// deliver sends snapshot, replacing an undelivered older snapshot if the
+// reader fell behind. Only the reading goroutine of the client sends
+// snapshots, so the replaced snapshot cannot be newer.
+func (s *Subscription) deliver(snapshot Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	for {
+		select {
+		case s.snapshots <- snapshot:
+			return
+		default:
+		}
+		select {
+		case <-s.snapshots:
+		default:
+		}
+	}
+}
+
+// decode decodes the JSON value v into dest.
+func decode(v interface{}, dest interface{}) error {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, dest)
+}
+
+// decodeErrorMessage returns the error message sent by the server.
+func decodeErrorMessage(raw json.RawMessage) string {
+	var message string
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return strings.TrimSpace(string(raw))
+	}
+	return message
+}
+
+// Query runs query once, and decodes its result into result.
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	s, err := c.Subscribe(ctx, query, variables, result)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.wait(ctx, result)
+}
+
+// Mutate runs the mutation query, and decodes its result into result.
+func (c *Client) Mutate(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	s, err := c.start("mutate", query, variables, result)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.wait(ctx, result)
+}
+
+// wait waits for the first complete snapshot, and copies it into result.
+// Snapshots marked with graphql.DeferredKey are skipped, as the deferred
+// fragments and streamed elements they leave out follow in later updates.
+func (s *Subscription) wait(ctx context.Context, result interface{}) error {
+	for {
+		select {
+		case snapshot, ok := <-s.snapshots:
+			if !ok {
+				if s.err == nil {
+					return ErrClosed
+				}
+				return s.err
+			}
+			if deferred, _ := snapshot.Metadata[graphql.DeferredKey].(bool); deferred {
+				continue
+			}
+			reflect.ValueOf(result).Elem().Set(reflect.ValueOf(snapshot.Data).Elem())
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/samsarahq/thunder/diff"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/client"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+// pipeSocket is one end of an in-memory JSONSocket connection.
+type pipeSocket struct {
+	in   <-chan []byte
+	out  chan<- []byte
+	once sync.Once
+}
+
+// newPipe returns two connected pipeSockets.
+func newPipe() (*pipeSocket, *pipeSocket) {
+	a, b := make(chan []byte, 16), make(chan []byte, 16)
+	return &pipeSocket{in: a, out: b}, &pipeSocket{in: b, out: a}
+}
+
+func (s *pipeSocket) ReadJSON(value interface{}) error {
+	message, ok := <-s.in
+	if !ok {
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	return json.Unmarshal(message, value)
+}
+
+func (s *pipeSocket) WriteJSON(value interface{}) error {
+	message, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.out <- message
+	return nil
+}
+
+func (s *pipeSocket) Close() error {
+	s.once.Do(func() { close(s.out) })
+	return nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) StartExecution(ctx context.Context, tags map[string]string, initial bool)         {}
+func (nopLogger) FinishExecution(ctx context.Context, tags map[string]string, delay time.Duration) {}
+func (nopLogger) Error(ctx context.Context, err error, tags map[string]string)                     {}
+
+type Item struct {
+	Id   int64 `graphql:",key"`
+	Name string
+}
+
+type List struct {
+	Count int64
+	Items []Item
+}
+
+type result struct {
+	List List
+}
+
+// newTestClient serves a schema whose add mutation prepends to a list of
+// items, and returns a client connected to it. If initialSnapshot is set, the
+// server sends the initial update of each subscription as a complete result.
+func newTestClient(initialSnapshot bool) *client.Client {
+	var mu sync.Mutex
+	var items []Item
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("list", func(ctx context.Context) List {
+		reactive.AddDependencyKey(ctx, "items")
+		mu.Lock()
+		defer mu.Unlock()
+		return List{Count: int64(len(items)), Items: append([]Item(nil), items...)}
+	})
+	query.FieldFunc("fail", func() (int64, error) {
+		return 0, graphql.NewSafeError("failed")
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("add", func(args struct{ Name string }) int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		items = append([]Item{{Id: int64(len(items) + 1), Name: args.Name}}, items...)
+		reactive.Invalidate("items")
+		return int64(len(items))
+	})
+
+	clientSide, serverSide := newPipe()
+	conn := graphql.CreateJSONSocket(context.Background(), serverSide, schema.MustBuild(), func(ctx context.Context) context.Context { return ctx }, nopLogger{})
+	conn.SetInitialSnapshot(initialSnapshot)
+	go conn.ServeJSONSocket()
+	return client.New(clientSide)
+}
+
+func TestQueryAndMutate(t *testing.T) {
+	c := newTestClient(false)
+	defer c.Close()
+	ctx := context.Background()
+
+	var added struct{ Add int64 }
+	if err := c.Mutate(ctx, `mutation { add(name: "a") }`, nil, &added); err != nil {
+		t.Fatal(err)
+	}
+	if added.Add != 1 {
+		t.Errorf("expected 1 item, got %d", added.Add)
+	}
+
+	var r result
+	if err := c.Query(ctx, `{ list { count items { id name } } }`, nil, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, result{List{Count: 1, Items: []Item{{Id: 1, Name: "a"}}}}) {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	err := c.Query(ctx, `{ fail }`, nil, &r)
+	if serverErr, ok := err.(*client.ServerError); !ok || serverErr.Message != "failed" {
+		t.Errorf("expected server error, got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	testSubscribe(t, false)
+}
+
+func TestSubscribeInitialSnapshot(t *testing.T) {
+	testSubscribe(t, true)
+}
+
+// testSubscribe checks that a subscription's snapshots follow mutations,
+// whether its initial update is a diff or a complete result.
+func testSubscribe(t *testing.T, initialSnapshot bool) {
+	c := newTestClient(initialSnapshot)
+	defer c.Close()
+	ctx := context.Background()
+
+	s, err := c.Subscribe(ctx, `{ list { count items { id name } } }`, nil, &result{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	expect := func(expected result) {
+		select {
+		case snapshot, ok := <-s.Snapshots():
+			if !ok {
+				t.Fatalf("subscription closed: %v", s.Err())
+			}
+			if actual := *snapshot.Data.(*result); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected %+v, got %+v", expected, actual)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %+v", expected)
+		}
+	}
+
+	expect(result{List{Items: []Item{}}})
+
+	var expected []Item
+	for i, name := range []string{"a", "b", "c"} {
+		if err := c.Mutate(ctx, fmt.Sprintf(`mutation { add(name: %q) }`, name), nil, &struct{ Add int64 }{}); err != nil {
+			t.Fatal(err)
+		}
+		expected = append([]Item{{Id: int64(i + 1), Name: name}}, expected...)
+		expect(result{List{Count: int64(i + 1), Items: expected}})
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-s.Snapshots(); ok {
+		t.Error("expected closed subscription")
+	}
+}
+
+func TestQueryDeferred(t *testing.T) {
+	c := newTestClient(false)
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := c.Mutate(ctx, `mutation { add(name: "a") }`, nil, &struct{ Add int64 }{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var r result
+	if err := c.Query(ctx, `{ list { count ... @defer { items { id name } } } }`, nil, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, result{List{Count: 1, Items: []Item{{Id: 1, Name: "a"}}}}) {
+		t.Errorf("expected deferred fragment in result, got %+v", r)
+	}
+}
+
+func TestSubscribeContextCanceled(t *testing.T) {
+	clientSide, serverSide := newPipe()
+	c := client.New(clientSide)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := c.Subscribe(ctx, `{ list { count } }`, nil, &result{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subscribe graphql.InEnvelope
+	if err := serverSide.ReadJSON(&subscribe); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	var unsubscribe graphql.InEnvelope
+	if err := serverSide.ReadJSON(&unsubscribe); err != nil {
+		t.Fatal(err)
+	}
+	if unsubscribe.Type != "unsubscribe" || unsubscribe.ID != subscribe.ID {
+		t.Errorf("expected unsubscribe from %s, got %+v", subscribe.ID, unsubscribe)
+	}
+	if _, ok := <-s.Snapshots(); ok {
+		t.Error("expected closed subscription")
+	}
+	if s.Err() != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", s.Err())
+	}
+}
+
+func TestUnsupportedDiffVersion(t *testing.T) {
+	clientSide, serverSide := newPipe()
+	c := client.New(clientSide)
+	defer c.Close()
+
+	s, err := c.Subscribe(context.Background(), `{ list { count } }`, nil, &result{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subscribe graphql.InEnvelope
+	if err := serverSide.ReadJSON(&subscribe); err != nil {
+		t.Fatal(err)
+	}
+	if err := serverSide.WriteJSON(graphql.OutEnvelope{
+		ID:       subscribe.ID,
+		Type:     "update",
+		Message:  []interface{}{map[string]interface{}{"list": map[string]interface{}{"count": 1}}},
+		Metadata: map[string]interface{}{graphql.DiffVersionKey: diff.Version + 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case _, ok := <-s.Snapshots():
+		if ok {
+			t.Fatal("expected closed subscription")
+		}
+		if s.Err() == nil {
+			t.Error("expected unsupported diff version error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for subscription to close")
+	}
+}
@@ -0,0 +1,49 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxCloseReasonBytes is the longest reason that fits in a close frame, whose
+// payload holds at most 125 bytes including the two byte close code.
+const maxCloseReasonBytes = 123
+
+// closeWithReason closes the connection, first sending a close frame with
+// code and reason on sockets that support it, so that clients can tell why
+// they were disconnected.
+func (c *conn) closeWithReason(code int, reason string) {
+	c.sendClose(code, reason)
+	c.socket.Close()
+}
+
+// sendClose sends a close frame with code and reason, if the socket supports
+// it. It returns true if the frame was sent.
+func (c *conn) sendClose(code int, reason string) bool {
+	socket, ok := unwrapSocket(c.socket).(closeSocket)
+	if !ok {
+		return false
+	}
+	if len(reason) > maxCloseReasonBytes {
+		reason = reason[:maxCloseReasonBytes]
+	}
+	message := websocket.FormatCloseMessage(code, reason)
+	return socket.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeWriteTimeout)) == nil
+}
+
+// readCloseReason returns the close code and reason for a connection whose
+// socket failed to read a message with err.
+func readCloseReason(err error) (int, string) {
+	switch err := err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return websocket.CloseUnsupportedData, "invalid message"
+	case net.Error:
+		if err.Timeout() {
+			return websocket.CloseGoingAway, "keepalive timeout"
+		}
+	}
+	return websocket.CloseInternalServerErr, "read failed"
+}
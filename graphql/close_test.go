@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeFakeSocket is a JSONSocket that reads a malformed message, and
+// records the close frames written to it.
+type closeFakeSocket struct {
+	closeFrames [][]byte
+	closed      bool
+}
+
+func (s *closeFakeSocket) ReadJSON(value interface{}) error {
+	return json.Unmarshal([]byte("{"), value)
+}
+
+func (s *closeFakeSocket) WriteJSON(value interface{}) error { return nil }
+
+func (s *closeFakeSocket) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *closeFakeSocket) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType == websocket.CloseMessage {
+		s.closeFrames = append(s.closeFrames, data)
+	}
+	return nil
+}
+
+func TestCloseReason(t *testing.T) {
+	socket := &closeFakeSocket{}
+	c := CreateJSONSocket(context.Background(), socket, &Schema{}, nil, nil)
+	c.ServeJSONSocket()
+
+	expected := [][]byte{websocket.FormatCloseMessage(websocket.CloseUnsupportedData, "invalid message")}
+	if !reflect.DeepEqual(socket.closeFrames, expected) {
+		t.Errorf("expected close frames %q, got %q", expected, socket.closeFrames)
+	}
+	if !socket.closed {
+		t.Error("expected socket to be closed")
+	}
+}
+
+// silentSocket is a JSONSocket whose client never sends messages nor closes
+// the connection. Reads block until the socket is closed.
+type silentSocket struct {
+	closed chan struct{}
+}
+
+func (s *silentSocket) ReadJSON(value interface{}) error {
+	<-s.closed
+	return &websocket.CloseError{Code: websocket.CloseAbnormalClosure}
+}
+
+func (s *silentSocket) WriteJSON(value interface{}) error { return nil }
+
+func (s *silentSocket) Close() error {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	return nil
+}
+
+func (s *silentSocket) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return nil
+}
+
+func TestShutdownClosesSilentClients(t *testing.T) {
+	defer func(timeout time.Duration) { closeWaitTimeout = timeout }(closeWaitTimeout)
+	closeWaitTimeout = 10 * time.Millisecond
+
+	socket := &silentSocket{closed: make(chan struct{})}
+	registry := NewRegistry()
+	c := CreateJSONSocket(context.Background(), socket, &Schema{}, nil, nil)
+	c.SetRegistry(registry)
+	done := make(chan struct{})
+	go func() {
+		c.ServeJSONSocket()
+		close(done)
+	}()
+
+	// Wait for the connection to be served.
+	for registry.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := registry.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-socket.closed:
+	default:
+		t.Error("expected Shutdown to close the socket of a client that never closed it")
+	}
+	<-done
+}
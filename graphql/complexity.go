@@ -0,0 +1,153 @@
+package graphql
+
+// A ComplexityFunc computes a static cost estimate for a parsed query.
+type ComplexityFunc func(query *Query) int
+
+// DefaultComplexity estimates the cost of a query as the number of fields it
+// selects, counting fields in nested selection sets and fragments.
+func DefaultComplexity(query *Query) int {
+	return selectionSetComplexity(query.SelectionSet)
+}
+
+func selectionSetComplexity(selectionSet *SelectionSet) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	complexity := 0
+	for _, selection := range selectionSet.Selections {
+		complexity += 1 + selectionSetComplexity(selection.SelectionSet)
+	}
+	for _, fragment := range selectionSet.Fragments {
+		complexity += selectionSetComplexity(fragment.SelectionSet)
+	}
+	return complexity
+}
+
+// SetComplexityLimit rejects subscriptions whose complexity, as computed by
+// fn, exceeds limit. Over-budget subscriptions are rejected before they are
+// executed. If fn is nil, DefaultComplexity is used. A zero limit disables
+// the check.
+func (c *conn) SetComplexityLimit(limit int, fn ComplexityFunc) {
+	if fn == nil {
+		fn = DefaultComplexity
+	}
+	c.complexityLimit = limit
+	c.complexityFunc = fn
+}
+
+// checkComplexity returns a ClientError if query exceeds the complexity limit.
+func (c *conn) checkComplexity(query *Query) error {
+	return checkComplexity(query, c.complexityLimit, c.complexityFunc)
+}
+
+// checkComplexity returns a ClientError if the complexity of query, as
+// computed by fn or DefaultComplexity if fn is nil, exceeds limit. A zero
+// limit disables the check.
+func checkComplexity(query *Query, limit int, fn ComplexityFunc) error {
+	if limit <= 0 {
+		return nil
+	}
+	if fn == nil {
+		fn = DefaultComplexity
+	}
+	if complexity := fn(query); complexity > limit {
+		return NewClientError("query complexity %d exceeds limit %d", complexity, limit)
+	}
+	return nil
+}
+
+// QueryDepth returns the maximum nesting depth of selection sets in
+// selectionSet. Fragments do not add to the depth of their selections.
+func QueryDepth(selectionSet *SelectionSet) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	depth := 0
+	for _, selection := range selectionSet.Selections {
+		if d := 1 + QueryDepth(selection.SelectionSet); d > depth {
+			depth = d
+		}
+	}
+	for _, fragment := range selectionSet.Fragments {
+		if d := QueryDepth(fragment.SelectionSet); d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// SetMaxDepth rejects subscriptions and mutations whose selection sets are
+// nested more than maxDepth levels deep. A zero maxDepth, the default,
+// disables the check.
+func (c *conn) SetMaxDepth(maxDepth int) {
+	c.maxDepth = maxDepth
+}
+
+// checkDepth returns a SafeError if query is nested too deeply.
+func (c *conn) checkDepth(query *Query) error {
+	return checkDepth(query, c.maxDepth)
+}
+
+// checkDepth returns a SafeError if query is nested more than maxDepth levels
+// deep. A zero maxDepth disables the check.
+func checkDepth(query *Query, maxDepth int) error {
+	if maxDepth <= 0 {
+		return nil
+	}
+	if QueryDepth(query.SelectionSet) > maxDepth {
+		return NewSafeError("query exceeds maximum depth of %d", maxDepth)
+	}
+	return nil
+}
+
+// SetMaxFields rejects subscriptions and mutations that select more than
+// maxFields fields, counting every alias of a field and every spread of a
+// fragment, so that a query cannot amplify its cost by repeating an expensive
+// field under many aliases. A zero maxFields, the default, disables the check.
+func (c *conn) SetMaxFields(maxFields int) {
+	c.maxFields = maxFields
+}
+
+// checkFieldCount returns a SafeError if query selects too many fields.
+func (c *conn) checkFieldCount(query *Query) error {
+	return checkFieldCount(query, c.maxFields)
+}
+
+// checkFieldCount returns a SafeError if query selects more than maxFields
+// fields. A zero maxFields disables the check.
+func checkFieldCount(query *Query, maxFields int) error {
+	if maxFields <= 0 {
+		return nil
+	}
+	if countFields(query.SelectionSet, maxFields) > maxFields {
+		return NewSafeError("query exceeds maximum of %d fields", maxFields)
+	}
+	return nil
+}
+
+// countFields returns the number of fields selectionSet selects, counting
+// every alias of a field and every spread of a fragment. It stops counting
+// once the count exceeds max, as fragments spread repeatedly can select
+// exponentially many fields.
+func countFields(selectionSet *SelectionSet, max int) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	count := 0
+	for _, selection := range selectionSet.Selections {
+		count += 1 + countFields(selection.SelectionSet, max-count)
+		if count > max {
+			return count
+		}
+	}
+	for _, fragment := range selectionSet.Fragments {
+		count += countFields(fragment.SelectionSet, max-count)
+		if count > max {
+			return count
+		}
+	}
+	return count
+}
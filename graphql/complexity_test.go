@@ -0,0 +1,39 @@
+package graphql_test
+
+import (
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestDefaultComplexity(t *testing.T) {
+	query := graphql.MustParse(`
+		{
+			a { b c { d } }
+			e
+			...frag
+		}
+		fragment frag on Query {
+			f { g }
+		}`, nil)
+
+	if complexity := graphql.DefaultComplexity(query); complexity != 7 {
+		t.Errorf("expected complexity 7, got %d", complexity)
+	}
+}
+
+func TestQueryDepth(t *testing.T) {
+	query := graphql.MustParse(`
+		{
+			a { b { c } }
+			d
+			...frag
+		}
+		fragment frag on Query {
+			e { f { g { h } } }
+		}`, nil)
+
+	if depth := graphql.QueryDepth(query.SelectionSet); depth != 4 {
+		t.Errorf("expected depth 4, got %d", depth)
+	}
+}
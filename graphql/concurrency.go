@@ -0,0 +1,24 @@
+package graphql
+
+// DefaultMaxConcurrency is the default limit on the number of expensive fields
+// an execution resolves concurrently.
+const DefaultMaxConcurrency = 1000
+
+// SetMaxConcurrency limits the number of expensive fields each execution of
+// a subscription or mutation resolves concurrently. See
+// Executor.MaxConcurrency.
+func (c *conn) SetMaxConcurrency(limit int) {
+	c.maxConcurrency = limit
+}
+
+// newForkLimiter returns a limiter for fork allowing limit concurrent
+// goroutines, as configured by Executor.MaxConcurrency.
+func newForkLimiter(limit int) *forkLimiter {
+	switch {
+	case limit < 0:
+		return nil
+	case limit == 0:
+		limit = DefaultMaxConcurrency
+	}
+	return &forkLimiter{limit: limit}
+}
@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+// OnConnectFunc authenticates a connection using the payload of its connect
+// message. The returned context replaces the connection's context as the base
+// for all subsequent computations. If OnConnectFunc returns an error, the
+// connection is rejected and closed.
+type OnConnectFunc func(ctx context.Context, payload json.RawMessage) (context.Context, error)
+
+// SetOnConnect requires clients to send a connect message before subscribing
+// or mutating, and authenticates the connection with fn when they do.
+func (c *conn) SetOnConnect(fn OnConnectFunc) {
+	c.onConnect = fn
+}
+
+// checkConnected returns a SafeError if the connection must be initialized
+// with a connect message and has not been.
+func (c *conn) checkConnected() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.onConnect != nil && !c.connected {
+		return NewSafeError("connection not initialized")
+	}
+	return nil
+}
+
+func (c *conn) handleConnect(payload json.RawMessage) error {
+	c.mu.Lock()
+	connected, ctx := c.connected, c.ctx
+	c.mu.Unlock()
+
+	if connected {
+		return NewSafeError("connection already initialized")
+	}
+
+	// onConnect may be slow, so it runs without holding c.mu. Messages are
+	// handled one at a time, so no other connect message races with it.
+	if c.onConnect != nil {
+		var err error
+		ctx, err = c.onConnect(ctx, payload)
+		if err != nil {
+			if _, ok := err.(SanitizedError); !ok {
+				c.logger.Error(c.ctx, err, map[string]string{"url": c.url})
+			}
+			c.writeOrClose(OutEnvelope{
+				Type:    "connection_error",
+				Message: sanitizeError(err),
+			})
+			c.closeWithReason(websocket.ClosePolicyViolation, sanitizeError(err))
+			return nil
+		}
+	}
+
+	c.mu.Lock()
+	c.ctx = ctx
+	c.connected = true
+	c.mu.Unlock()
+
+	c.writeOrClose(OutEnvelope{
+		Type: "connected",
+	})
+	return nil
+}
@@ -0,0 +1,104 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+type userKey struct{}
+
+func TestOnConnect(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("user", func(ctx context.Context) string {
+		return ctx.Value(userKey{}).(string)
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	c.SetOnConnect(func(ctx context.Context, payload json.RawMessage) (context.Context, error) {
+		var params struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(payload, &params); err != nil {
+			return nil, err
+		}
+		if params.Token != "secret" {
+			return nil, graphql.NewSafeError("bad token")
+		}
+		return context.WithValue(ctx, userKey{}, "alice"), nil
+	})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ user }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "connection not initialized", "errors": [{"message": "connection not initialized"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"type":    "connect",
+		"message": map[string]interface{}{"token": "wrong"},
+	}
+	socket.expect(t, `{"type": "connection_error", "message": "bad token"}`)
+
+	socket.in <- map[string]interface{}{
+		"type":    "connect",
+		"message": map[string]interface{}{"token": "secret"},
+	}
+	socket.expect(t, `{"type": "connected"}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ user }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "update", "message": [{"user": "alice"}], "metadata": {"diffVersion": 1}}`)
+}
+
+func TestOnConnectDoesNotBlockConnection(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	registry := graphql.NewRegistry()
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetRegistry(registry)
+
+	connecting := make(chan struct{})
+	release := make(chan struct{})
+	c.SetOnConnect(func(ctx context.Context, payload json.RawMessage) (context.Context, error) {
+		close(connecting)
+		<-release
+		return ctx, nil
+	})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{"type": "connect", "message": map[string]interface{}{}}
+	<-connecting
+
+	// Inspecting the connection must not wait for the slow authenticator.
+	inspected := make(chan struct{})
+	go func() {
+		registry.Connections()
+		close(inspected)
+	}()
+	select {
+	case <-inspected:
+	case <-time.After(time.Second):
+		t.Error("timed out inspecting a connection running OnConnect")
+	}
+
+	close(release)
+	socket.expect(t, `{"type": "connected"}`)
+}
@@ -0,0 +1,126 @@
+package graphql
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// reserve reserves a slot for a connection being upgraded, if the registry's
+// live, parked, and reserved connections number less than max. It returns
+// false if the limit is reached. A successful reservation must be released
+// once the connection is registered, or the upgrade failed.
+func (r *Registry) reserve(max int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.conns)+len(r.parked)+r.reserved >= max {
+		return false
+	}
+	r.reserved++
+	return true
+}
+
+// release releases a slot reserved by reserve.
+func (r *Registry) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved--
+}
+
+// reserveConnection reserves a slot in registry for a connection upgraded by
+// a handler limited to max connections. If the limit is reached, it responds
+// to the upgrade request with a 503 Service Unavailable and returns false.
+// Otherwise, it returns a function releasing the slot, which is safe to call
+// more than once.
+func reserveConnection(w http.ResponseWriter, registry *Registry, max int) (func(), bool) {
+	if max <= 0 {
+		return func() {}, true
+	}
+	if !registry.reserve(max) {
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	var once sync.Once
+	return func() { once.Do(registry.release) }, true
+}
+
+// ConnectionQuota limits the number of concurrent connections per key, such
+// as a client's IP address or authenticated principal, so that a single
+// client cannot monopolize a server's connections. A ConnectionQuota is
+// usually shared between the handlers of a server.
+type ConnectionQuota interface {
+	// Acquire returns true and counts a connection for key if key may open
+	// another connection.
+	Acquire(key string) bool
+	// Release releases a connection acquired for key.
+	Release(key string)
+}
+
+// connectionQuota is a ConnectionQuota allowing up to limit connections per
+// key.
+type connectionQuota struct {
+	mu    sync.Mutex
+	limit int
+	conns map[string]int
+}
+
+// NewConnectionQuota creates a ConnectionQuota allowing up to limit
+// concurrent connections per key.
+func NewConnectionQuota(limit int) ConnectionQuota {
+	return &connectionQuota{
+		limit: limit,
+		conns: make(map[string]int),
+	}
+}
+
+func (q *connectionQuota) Acquire(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.conns[key] >= q.limit {
+		return false
+	}
+	q.conns[key]++
+	return true
+}
+
+func (q *connectionQuota) Release(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.conns[key]--
+	if q.conns[key] <= 0 {
+		delete(q.conns, key)
+	}
+}
+
+// RemoteIP returns the IP address of the client that sent r. It is the
+// default key of connection quotas.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// acquireConnectionQuota acquires a connection from quota for the key
+// returned by keyFunc, or RemoteIP if keyFunc is nil. Requests with an empty
+// key are not limited. If the key's quota is exhausted, it responds to the
+// upgrade request with a 429 Too Many Requests and returns false. Otherwise,
+// it returns a function releasing the connection.
+func acquireConnectionQuota(w http.ResponseWriter, r *http.Request, quota ConnectionQuota, keyFunc func(r *http.Request) string) (func(), bool) {
+	if quota == nil {
+		return func() {}, true
+	}
+	if keyFunc == nil {
+		keyFunc = RemoteIP
+	}
+	key := keyFunc(r)
+	if key == "" {
+		return func() {}, true
+	}
+	if !quota.Acquire(key) {
+		http.Error(w, "too many connections from client", http.StatusTooManyRequests)
+		return nil, false
+	}
+	return func() { quota.Release(key) }, true
+}
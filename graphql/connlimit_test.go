@@ -0,0 +1,104 @@
+package graphql_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestMaxConnections(t *testing.T) {
+	schema := makeGraphQLWSSchema()
+	registry := graphql.NewRegistry()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema, makeCtx, nopLogger{})
+	c.SetRegistry(registry)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	upgrade := func(maxConnections int) int {
+		handler := graphql.HandlerWithOptions(schema, graphql.HandlerOptions{
+			Registry:       registry,
+			MaxConnections: maxConnections,
+		})
+		req := httptest.NewRequest("GET", "/graphql", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := upgrade(1); code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d when the limit is reached, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	// Failed upgrades must release their reservations.
+	for i := 0; i < 3; i++ {
+		if code := upgrade(2); code == http.StatusServiceUnavailable {
+			t.Errorf("expected upgrade %d below the limit to be attempted, got %d", i, code)
+		}
+	}
+	if registry.Len() != 1 {
+		t.Errorf("expected 1 live connection, got %d", registry.Len())
+	}
+}
+
+func TestConnectionQuota(t *testing.T) {
+	schema := makeGraphQLWSSchema()
+	quota := graphql.NewConnectionQuota(1)
+	handler := graphql.HandlerWithOptions(schema, graphql.HandlerOptions{
+		Registry:        graphql.NewRegistry(),
+		ConnectionQuota: quota,
+		ConnectionKey: func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+	})
+	upgrade := func(user string) int {
+		req := httptest.NewRequest("GET", "/graphql", nil)
+		req.Header.Set("X-User", user)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if !quota.Acquire("alice") {
+		t.Fatal("expected alice's first connection to be allowed")
+	}
+	if code := upgrade("alice"); code != http.StatusTooManyRequests {
+		t.Errorf("expected %d for alice, got %d", http.StatusTooManyRequests, code)
+	}
+	if code := upgrade("bob"); code == http.StatusTooManyRequests {
+		t.Errorf("expected bob's upgrade to be attempted, got %d", code)
+	}
+	if code := upgrade(""); code == http.StatusTooManyRequests {
+		t.Errorf("expected upgrade without a key to be attempted, got %d", code)
+	}
+
+	// bob's failed upgrade must release its connection.
+	if !quota.Acquire("bob") {
+		t.Error("expected bob's quota to be released")
+	}
+	quota.Release("alice")
+	if !quota.Acquire("alice") {
+		t.Error("expected alice's quota to be released")
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/graphql", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	if ip := graphql.RemoteIP(req); ip != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1, got %s", ip)
+	}
+}
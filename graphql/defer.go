@@ -0,0 +1,52 @@
+package graphql
+
+// DeferredKey is the metadata key set on the initial update of a subscription
+// whose query has fragments marked with @defer or lists marked with @stream,
+// and on its follow-up updates until the query is complete. Its presence
+// tells clients that the deferred fragments or some elements of streamed lists
+// are not in the update yet, and will arrive in follow-up updates for the same
+// subscription.
+const DeferredKey = "deferred"
+
+// DefaultStreamChunkSize is the default number of elements of each list
+// marked with @stream appended by each follow-up update.
+const DefaultStreamChunkSize = 100
+
+// SetStreamChunkSize sets the number of elements of each list marked with
+// @stream appended by each follow-up update of a subscription. The initial
+// update holds the first initialCount elements of the list, and the following
+// updates append the remaining elements size at a time.
+func (c *conn) SetStreamChunkSize(size int) {
+	if size <= 0 {
+		size = DefaultStreamChunkSize
+	}
+	c.streamChunkSize = size
+}
+
+// isIncremental returns true if selectionSet has any fragment marked with
+// @defer or list marked with @stream.
+func isIncremental(selectionSet *SelectionSet) bool {
+	state := make(map[*SelectionSet]visitState)
+
+	var visit func(*SelectionSet) bool
+	visit = func(selectionSet *SelectionSet) bool {
+		if selectionSet == nil || state[selectionSet] == visited {
+			return false
+		}
+		state[selectionSet] = visited
+
+		for _, selection := range selectionSet.Selections {
+			if selection.Streamed || visit(selection.SelectionSet) {
+				return true
+			}
+		}
+		for _, fragment := range selectionSet.Fragments {
+			if fragment.Deferred || visit(fragment.SelectionSet) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return visit(selectionSet)
+}
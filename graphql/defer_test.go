@@ -0,0 +1,135 @@
+package graphql_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+func TestDefer(t *testing.T) {
+	release := make(chan struct{})
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("fast", func() string {
+		return "fast"
+	})
+	query.FieldFunc("slow", func() string {
+		<-release
+		return "slow"
+	})
+	var expensiveResolves, otherResolves int64
+	query.FieldFunc("expensive", func(ctx context.Context) *partialUser {
+		atomic.AddInt64(&expensiveResolves, 1)
+		return &partialUser{Name: "bob"}
+	})
+	query.FieldFunc("other", func(ctx context.Context) *partialUser {
+		atomic.AddInt64(&otherResolves, 1)
+		return &partialUser{Name: "alice"}
+	})
+	schema.Object("User", partialUser{})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ fast ... @defer { slow } expensive { ... @defer { name } } other { name } }"},
+	}
+	// The initial update is sent before the deferred field resolves.
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"fast": "fast", "expensive": {}, "other": {"name": "alice"}}],
+		"metadata": {"diffVersion": 1, "deferred": true}}`)
+
+	close(release)
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"slow": "slow", "expensive": {"name": "bob"}},
+		"metadata": {"diffVersion": 1}}`)
+
+	// The follow-up update reuses the expensive fields resolved for the
+	// initial update.
+	if n := atomic.LoadInt64(&expensiveResolves); n != 1 {
+		t.Errorf("expected expensive to resolve once, got %d", n)
+	}
+	if n := atomic.LoadInt64(&otherResolves); n != 1 {
+		t.Errorf("expected other to resolve once, got %d", n)
+	}
+}
+
+func TestStream(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*partialUser {
+		return []*partialUser{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	})
+	query.FieldFunc("static", func() string {
+		return "static"
+	})
+	schema.Object("User", partialUser{})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, builtSchema, makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ users @stream(initialCount: 1) { name } }"},
+	}
+	// The initial update only has the first element, and the rest of the
+	// list is appended by the next update.
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"users": [{"name": "a"}]}],
+		"metadata": {"diffVersion": 1, "deferred": true}}`)
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"users": {"$": [0, -1, -1], "1": [{"name": "b"}], "2": [{"name": "c"}]}},
+		"metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ static @stream }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "directive \"@stream\" on \"static\" requires a list field",
+		"errors": [{"message": "directive \"@stream\" on \"static\" requires a list field"}]}`)
+}
+
+func TestStreamChunks(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*partialUser {
+		return []*partialUser{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	})
+	schema.Object("User", partialUser{})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	c.SetStreamChunkSize(2)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ users @stream(initialCount: 1) { name } }"},
+	}
+	// The remaining elements are appended two at a time, and the last update
+	// tells clients that the list is complete.
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"users": [{"name": "a"}]}],
+		"metadata": {"diffVersion": 1, "deferred": true}}`)
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"users": {"$": [0, -1, -1], "1": [{"name": "b"}], "2": [{"name": "c"}]}},
+		"metadata": {"diffVersion": 1, "deferred": true}}`)
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"users": {"$": [[0, 3], -1], "3": [{"name": "d"}]}},
+		"metadata": {"diffVersion": 1}}`)
+}
@@ -0,0 +1,75 @@
+package graphql
+
+import "context"
+
+// DeprecationLogger is an optional interface implemented by a GraphqlLogger
+// that wants to be told when a subscription or mutation selects deprecated
+// fields, to track the clients still using them before they are removed.
+// DeprecatedField is called once for each deprecated field a query selects,
+// with the name of the type holding the field.
+type DeprecationLogger interface {
+	DeprecatedField(ctx context.Context, tags map[string]string, typ string, field string, reason string)
+}
+
+// logDeprecatedFields reports the deprecated fields that selectionSet, which
+// has been prepared against typ, selects to c.logger if it implements
+// DeprecationLogger.
+func (c *conn) logDeprecatedFields(typ Type, selectionSet *SelectionSet, tags map[string]string) {
+	logger, ok := c.logger.(DeprecationLogger)
+	if !ok {
+		return
+	}
+
+	seen := make(map[*Field]bool)
+	visitFields(typ, selectionSet, func(typ string, name string, field *Field) {
+		if field.DeprecationReason == "" || seen[field] {
+			return
+		}
+		seen[field] = true
+		logger.DeprecatedField(c.ctx, tags, typ, name, field.DeprecationReason)
+	})
+}
+
+// visitFields calls visit for every field that selectionSet, which has been
+// prepared against typ, selects, including the fields selected in fragments,
+// with the name of the type holding the field.
+func visitFields(typ Type, selectionSet *SelectionSet, visit func(typ string, name string, field *Field)) {
+	if selectionSet == nil {
+		return
+	}
+
+	var fields map[string]*Field
+	var types map[string]*Object
+	switch typ := typ.(type) {
+	case *Object:
+		fields = typ.Fields
+	case *Interface:
+		fields, types = typ.Fields, typ.Types
+	case *Union:
+		types = typ.Types
+	case *List:
+		visitFields(typ.Type, selectionSet, visit)
+		return
+	case *NonNull:
+		visitFields(typ.Type, selectionSet, visit)
+		return
+	default:
+		return
+	}
+
+	for _, selection := range selectionSet.Selections {
+		field, ok := fields[selection.Name]
+		if !ok {
+			continue
+		}
+		visit(typ.String(), selection.Name, field)
+		visitFields(field.Type, selection.SelectionSet, visit)
+	}
+	for _, fragment := range selectionSet.Fragments {
+		fragmentTyp := typ
+		if object, ok := types[fragment.On]; ok {
+			fragmentTyp = object
+		}
+		visitFields(fragmentTyp, fragment.SelectionSet, visit)
+	}
+}
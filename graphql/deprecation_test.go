@@ -0,0 +1,75 @@
+package graphql_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+type deprecationLogger struct {
+	nopLogger
+	fields chan []string
+}
+
+func (l *deprecationLogger) DeprecatedField(ctx context.Context, tags map[string]string, typ string, field string, reason string) {
+	l.fields <- []string{tags["id"], typ, field, reason}
+}
+
+type deprecatedUser struct {
+	Name string
+}
+
+func TestDeprecationLogger(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("me", func() deprecatedUser {
+		return deprecatedUser{Name: "bob"}
+	})
+	user := schema.Object("User", deprecatedUser{})
+	user.FieldFunc("fullName", func(u deprecatedUser) string {
+		return u.Name
+	}, schemabuilder.Deprecated("use name instead"))
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	logger := &deprecationLogger{fields: make(chan []string, 10)}
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, logger)
+
+	// A deprecated field is reported once per query, even if it is selected
+	// several times.
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ me { name fullName ... on User { fullName } } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"me": {"name": "bob", "fullName": "bob"}}],
+		"metadata": {"diffVersion": 1}}`)
+
+	if fields := <-logger.fields; !reflect.DeepEqual(fields, []string{"1", "User", "fullName", "use name instead"}) {
+		t.Errorf("unexpected deprecated field %v", fields)
+	}
+	select {
+	case fields := <-logger.fields:
+		t.Errorf("unexpected deprecated field %v", fields)
+	default:
+	}
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ me { name } }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "update", "message": [{"me": {"name": "bob"}}],
+		"metadata": {"diffVersion": 1}}`)
+	select {
+	case fields := <-logger.fields:
+		t.Errorf("unexpected deprecated field %v", fields)
+	default:
+	}
+}
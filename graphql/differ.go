@@ -0,0 +1,117 @@
+package graphql
+
+import "github.com/samsarahq/thunder/diff"
+
+// Differ computes the messages sent to clients as a subscription's result
+// changes.
+type Differ interface {
+	// Diff returns the update to send when the result changes from previous
+	// to current, or nil if there is nothing to send. previous is nil for the
+	// initial result.
+	Diff(previous, current interface{}) interface{}
+}
+
+// A VersionedDiffer is a Differ whose messages are in a versioned format.
+// Updates computed by a VersionedDiffer carry the version of their format in
+// their metadata, under DiffVersionKey, so that clients can reject formats
+// they do not understand.
+type VersionedDiffer interface {
+	Differ
+	DiffVersion() int
+}
+
+// DiffVersionKey is the metadata key holding the format version of updates
+// computed by a VersionedDiffer.
+const DiffVersionKey = "diffVersion"
+
+// JSONDiffer sends diffs computed by diff.Diff, versioned by diff.Version. It
+// is the default Differ.
+var JSONDiffer Differ = jsonDiffer{}
+
+// NewJSONDiffer returns a Differ that sends diffs like JSONDiffer, computed
+// with diff.DiffWithOptions. For example, to send reorderings of lists of
+// objects with an id field as moves even if their types declare no key:
+//
+//	c.SetDiffer(graphql.NewJSONDiffer(diff.Options{Key: diff.FieldKey("id")}))
+func NewJSONDiffer(opts diff.Options) Differ {
+	return jsonDiffer{opts: opts}
+}
+
+// SnapshotDiffer sends the complete result whenever it changes, which is
+// simpler for clients to apply than a diff.
+var SnapshotDiffer Differ = snapshotDiffer{}
+
+// JSONPatchDiffer sends JSON patches, as defined by RFC 6902 and computed by
+// diff.Patch, for clients that apply standard JSON patches. The initial
+// result is sent as a patch replacing the whole document.
+var JSONPatchDiffer Differ = jsonPatchDiffer{}
+
+type jsonDiffer struct {
+	opts diff.Options
+}
+
+func (d jsonDiffer) Diff(previous, current interface{}) interface{} {
+	return diff.DiffWithOptions(previous, current, d.opts)
+}
+
+func (jsonDiffer) DiffVersion() int {
+	return diff.Version
+}
+
+type jsonPatchDiffer struct{}
+
+func (jsonPatchDiffer) Diff(previous, current interface{}) interface{} {
+	patch := diff.Patch(previous, current)
+	if len(patch) == 0 {
+		return nil
+	}
+	return patch
+}
+
+type snapshotDiffer struct{}
+
+func (snapshotDiffer) Diff(previous, current interface{}) interface{} {
+	if previous != nil && diff.Diff(previous, current) == nil {
+		return nil
+	}
+	return diff.StripKey(current)
+}
+
+// SetDiffer configures how updates sent on the connection are computed.
+func (c *conn) SetDiffer(differ Differ) {
+	c.differ = differ
+}
+
+// updateMetadata returns the metadata of an update computed by the
+// connection's Differ, adding the format version for a VersionedDiffer.
+func (c *conn) updateMetadata(metadata map[string]interface{}) map[string]interface{} {
+	versioned, ok := c.differ.(VersionedDiffer)
+	if !ok {
+		return metadata
+	}
+	return withMetadata(metadata, DiffVersionKey, versioned.DiffVersion())
+}
+
+// withMetadata returns a copy of metadata with key set to value, leaving
+// metadata itself, which may be shared, unchanged.
+func withMetadata(metadata map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+// SnapshotKey is the metadata key set on the initial update of a subscription
+// on a connection configured with SetInitialSnapshot.
+const SnapshotKey = "snapshot"
+
+// SetInitialSnapshot configures whether the initial update of each
+// subscription is the complete result, rather than the connection's Differ's
+// diff against nothing, for clients that bootstrap from a plain result.
+// Initial updates sent as complete results carry SnapshotKey in their
+// metadata. Later updates are always diffs against the previous result.
+func (c *conn) SetInitialSnapshot(enabled bool) {
+	c.initialSnapshot = enabled
+}
@@ -0,0 +1,100 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestSnapshotDiffer(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetDiffer(graphql.SnapshotDiffer)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { id name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "hi") }`},
+	}
+	socket.expect(t, `{"id": "2", "type": "result", "message": {"echo": "hi"}}`)
+}
+
+func TestJSONPatchDiffer(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetDiffer(graphql.JSONPatchDiffer)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { id name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [
+		{"op": "replace", "path": "", "value": {"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]}}
+	]}`)
+}
+
+// defaultsDiffer diffs initial results against defaults, the result clients
+// start from.
+type defaultsDiffer struct {
+	defaults interface{}
+}
+
+func (d defaultsDiffer) Diff(previous, current interface{}) interface{} {
+	if previous == nil {
+		previous = d.defaults
+	}
+	return graphql.SnapshotDiffer.Diff(previous, current)
+}
+
+func TestEmptyInitialDiff(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makePartialSchema(), makeCtx, nopLogger{})
+	c.SetDiffer(defaultsDiffer{defaults: map[string]interface{}{"static": "static"}})
+	go c.ServeJSONSocket()
+
+	// The initial update is sent even though the result diffs empty.
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ static }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update"}`)
+}
+
+func TestInitialSnapshot(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetInitialSnapshot(true)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { id name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]},
+		"metadata": {"diffVersion": 1, "snapshot": true}}`)
+}
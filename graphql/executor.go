@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/samsarahq/thunder/reactive"
 )
@@ -16,16 +17,37 @@ type pathError struct {
 	path  []string
 }
 
-func nestPathError(key string, err error) error {
-	// Don't nest SanitzedError's, as they are intended for human consumption.
-	if se, ok := err.(SanitizedError); ok {
-		return se
-	}
+// sanitizedPathError tracks the path of a SanitizedError. It remains a
+// SanitizedError, and its message is not prefixed with the path as it is
+// intended for human consumption.
+type sanitizedPathError struct {
+	*pathError
+}
+
+func (se sanitizedPathError) Error() string {
+	return se.inner.Error()
+}
 
-	if pe, ok := err.(*pathError); ok {
+func (se sanitizedPathError) SanitizedError() string {
+	return se.inner.(SanitizedError).SanitizedError()
+}
+
+func nestPathError(key string, err error) error {
+	switch e := err.(type) {
+	case sanitizedPathError:
+		return sanitizedPathError{&pathError{
+			inner: e.inner,
+			path:  append(e.path[:len(e.path):len(e.path)], key),
+		}}
+	case SanitizedError:
+		return sanitizedPathError{&pathError{
+			inner: e,
+			path:  []string{key},
+		}}
+	case *pathError:
 		return &pathError{
-			inner: pe.inner,
-			path:  append(pe.path, key),
+			inner: e.inner,
+			path:  append(e.path[:len(e.path):len(e.path)], key),
 		}
 	}
 
@@ -36,12 +58,33 @@ func nestPathError(key string, err error) error {
 }
 
 func extractPathError(err error) error {
-	if pe, ok := err.(*pathError); ok {
-		return pe.inner
+	switch e := err.(type) {
+	case *pathError:
+		return e.inner
+	case sanitizedPathError:
+		return e.inner
 	}
 	return err
 }
 
+// errorPath returns the path tracked by err, from the outermost key to the
+// innermost key, or nil if err does not track a path.
+func errorPath(err error) []string {
+	var reversed []string
+	switch e := err.(type) {
+	case *pathError:
+		reversed = e.path
+	case sanitizedPathError:
+		reversed = e.path
+	}
+
+	var path []string
+	for i := len(reversed) - 1; i >= 0; i-- {
+		path = append(path, reversed[i])
+	}
+	return path
+}
+
 func (pe *pathError) Error() string {
 	var buffer bytes.Buffer
 	for i := len(pe.path) - 1; i >= 0; i-- {
@@ -73,85 +116,250 @@ func unwrap(v interface{}) interface{} {
 	return i.Interface()
 }
 
+// serialize unwraps the scalar or enum value source and converts it with fn,
+// if set. Nil values are returned as nil.
+func serialize(fn func(interface{}) (interface{}, error), source interface{}) (interface{}, error) {
+	value := unwrap(source)
+	if v := reflect.ValueOf(value); !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil, nil
+	}
+	if fn == nil {
+		return value, nil
+	}
+	return fn(value)
+}
+
 // PrepareQuery checks that the given selectionSet matches the schema typ, and
-// parses the args in selectionSet
+// parses the args in selectionSet. It returns the errors it finds, at most
+// 100, as ValidationErrors.
 func PrepareQuery(typ Type, selectionSet *SelectionSet) error {
+	var p preparer
+	p.prepare(typ, selectionSet, nil)
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return ValidationErrors(p.errs)
+}
+
+// prepareQuery is like PrepareQuery, but also rejects selection sets
+// selecting introspection fields if schema disables introspection. All the
+// transports prepare queries with prepareQuery, so that they check queries
+// the same way.
+func prepareQuery(schema *Schema, typ Type, selectionSet *SelectionSet) error {
+	if err := checkIntrospection(schema, selectionSet); err != nil {
+		return ValidationErrors{&ValidationError{Err: err}}
+	}
+	return PrepareQuery(typ, selectionSet)
+}
+
+// maxValidationErrors is the maximum number of errors PrepareQuery returns.
+const maxValidationErrors = 100
+
+// A preparer checks selection sets against the schema, and collects all the
+// errors found.
+type preparer struct {
+	errs []*ValidationError
+	// reported holds the errors already collected, by path and message.
+	reported map[string]bool
+	// prepared holds the fragments already checked against a type. Fragments
+	// are shared by all their spreads, so that checking them again would only
+	// find the same errors, exponentially many times for nested spreads.
+	prepared map[preparedFragment]bool
+}
+
+type preparedFragment struct {
+	fragment *Fragment
+	typ      Type
+}
+
+// fail records err, found at the selection with the given path, unless it
+// was already recorded or too many errors were found.
+func (p *preparer) fail(path []string, err error) {
+	if len(p.errs) >= maxValidationErrors {
+		return
+	}
+	validationErr := &ValidationError{Path: path, Err: err}
+	key := validationErr.Error()
+	if p.reported[key] {
+		return
+	}
+	if p.reported == nil {
+		p.reported = make(map[string]bool)
+	}
+	p.reported[key] = true
+	p.errs = append(p.errs, validationErr)
+}
+
+// prepareFragment checks fragment against typ, unless it was already checked.
+func (p *preparer) prepareFragment(typ Type, fragment *Fragment, path []string) {
+	key := preparedFragment{fragment: fragment, typ: typ}
+	if p.prepared[key] {
+		return
+	}
+	if p.prepared == nil {
+		p.prepared = make(map[preparedFragment]bool)
+	}
+	p.prepared[key] = true
+	p.prepare(typ, fragment.SelectionSet, path)
+}
+
+// prepare checks selectionSet, at path, against typ.
+func (p *preparer) prepare(typ Type, selectionSet *SelectionSet, path []string) {
 	switch typ := typ.(type) {
 	case *Scalar:
 		if selectionSet != nil {
-			return NewClientError("scalar field must have no selections")
+			p.fail(path, NewClientError("scalar field must have no selections"))
+		}
+
+	case *Enum:
+		if selectionSet != nil {
+			p.fail(path, NewClientError("enum field must have no selections"))
 		}
-		return nil
 
 	case *Object:
 		if selectionSet == nil {
-			return NewClientError("object field must have selections")
+			p.fail(path, NewClientError("object field must have selections"))
+			return
+		}
+		p.prepareSelections(typ.Fields, selectionSet.Selections, path)
+		for _, fragment := range selectionSet.Fragments {
+			p.prepareFragment(typ, fragment, path)
 		}
-		for _, selection := range selectionSet.Selections {
-			if selection.Name == "__typename" {
-				if !isNilArgs(selection.Args) {
-					return NewClientError(`error parsing args for "__typename": no args expected`)
-				}
-				if selection.SelectionSet != nil {
-					return NewClientError(`scalar field "__typename" must have no selection`)
-				}
-				continue
-			}
 
-			field, ok := typ.Fields[selection.Name]
-			if !ok {
-				return NewClientError(`unknown field "%s"`, selection.Name)
+	case *Interface:
+		if selectionSet == nil {
+			p.fail(path, NewClientError("interface field must have selections"))
+			return
+		}
+		p.prepareAbstract(typ, typ.Fields, typ.Types, selectionSet, path)
+
+	case *Union:
+		if selectionSet == nil {
+			p.fail(path, NewClientError("union field must have selections"))
+			return
+		}
+		p.prepareAbstract(typ, nil, typ.Types, selectionSet, path)
+
+	case *List:
+		p.prepare(typ.Type, selectionSet, path)
+
+	case *NonNull:
+		p.prepare(typ.Type, selectionSet, path)
+
+	default:
+		panic("unknown type kind")
+	}
+}
+
+// prepareSelections checks that selections select fields, and parses their
+// args.
+func (p *preparer) prepareSelections(fields map[string]*Field, selections []*Selection, path []string) {
+	for _, selection := range selections {
+		if selection.Name == "__typename" {
+			if !isNilArgs(selection.Args) {
+				p.fail(path, NewClientError(`error parsing args for "__typename": no args expected`))
+			}
+			if selection.SelectionSet != nil {
+				p.fail(path, NewClientError(`scalar field "__typename" must have no selection`))
 			}
+			continue
+		}
 
-			// Only parse args once for a given selection.
-			if !selection.parsed {
-				parsed, err := field.ParseArguments(selection.Args)
-				if err != nil {
-					return NewClientError(`error parsing args for "%s": %s`, selection.Name, err)
-				}
+		field, ok := fields[selection.Name]
+		if !ok {
+			p.fail(path, NewClientError(`unknown field "%s"`, selection.Name))
+			continue
+		}
+
+		// Only parse args once for a given selection.
+		if !selection.parsed {
+			parsed, err := field.ParseArguments(selection.Args)
+			if err != nil {
+				p.fail(path, NewClientError(`error parsing args for "%s": %s`, selection.Name, err))
+			} else {
 				selection.Args = parsed
 				selection.parsed = true
 			}
-
-			if err := PrepareQuery(field.Type, selection.SelectionSet); err != nil {
-				return err
-			}
 		}
-		for _, fragment := range selectionSet.Fragments {
-			if err := PrepareQuery(typ, fragment.SelectionSet); err != nil {
-				return err
-			}
+
+		if selection.Streamed && !isList(field.Type) {
+			p.fail(path, NewClientError(`directive "@stream" on "%s" requires a list field`, selection.Name))
 		}
-		return nil
 
-	case *List:
-		return PrepareQuery(typ.Type, selectionSet)
+		p.prepare(field.Type, selection.SelectionSet, append(path[:len(path):len(path)], selection.Alias))
+	}
+}
 
-	case *NonNull:
-		return PrepareQuery(typ.Type, selectionSet)
+// isList returns true if typ is a List, or a NonNull List.
+func isList(typ Type) bool {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+	_, ok := typ.(*List)
+	return ok
+}
 
-	default:
-		panic("unknown type kind")
+// prepareAbstract checks selectionSet against the Interface or Union typ,
+// whose fields can be selected directly and whose Objects can be selected with
+// fragments.
+func (p *preparer) prepareAbstract(typ Type, fields map[string]*Field, types map[string]*Object, selectionSet *SelectionSet, path []string) {
+	p.prepareSelections(fields, selectionSet.Selections, path)
+	for _, fragment := range selectionSet.Fragments {
+		// Fragments on an Object are checked against it.
+		fragmentTyp := typ
+		if fragment.On != "" && fragment.On != typ.String() {
+			object, ok := types[fragment.On]
+			if !ok {
+				p.fail(path, NewClientError(`fragment on "%s" cannot apply to %s`, fragment.On, typ))
+				continue
+			}
+			fragmentTyp = object
+		}
+		p.prepareFragment(fragmentTyp, fragment, path)
 	}
 }
 
+// panicError is the error of a recovered panic. It is not a SanitizedError,
+// so clients only see an internal error, while the panic and its stack are
+// logged.
 type panicError struct {
-	message string
+	value interface{}
+	stack []byte
+}
+
+// newPanicError captures the stack of the goroutine that panicked with value.
+// It must be called by the deferred function that recovered.
+func newPanicError(value interface{}) panicError {
+	const size = 64 << 10
+	stack := make([]byte, size)
+	stack = stack[:runtime.Stack(stack, false)]
+	return panicError{value: value, stack: stack}
 }
 
 func (p panicError) Error() string {
-	return p.message
+	return fmt.Sprintf("graphql: panic: %v\n%s", p.value, p.stack)
 }
 
-func safeResolve(ctx context.Context, field *Field, source, args interface{}, selectionSet *SelectionSet) (result interface{}, err error) {
+func (e *Executor) safeResolve(ctx context.Context, field *Field, source, args interface{}, selectionSet *SelectionSet) (result interface{}, err error) {
+	// Once the computation is cancelled, such as when the client
+	// unsubscribes, skip the remaining resolvers.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&e.fieldsExecuted, 1)
+
 	defer func() {
 		if panicErr := recover(); panicErr != nil {
-			const size = 64 << 10
-			buf := make([]byte, size)
-			buf = buf[:runtime.Stack(buf, false)]
-			result, err = nil, fmt.Errorf("graphql: panic: %v\n%s", panicErr, buf)
+			result, err = nil, newPanicError(panicErr)
 		}
 	}()
+
+	if field.Timeout > 0 {
+		fieldCtx, cancel := context.WithTimeout(ctx, field.Timeout)
+		defer cancel()
+		result, err = field.Resolve(fieldCtx, source, args, selectionSet)
+		return result, fieldTimeoutError(fieldCtx, ctx, field, err)
+	}
 	return field.Resolve(ctx, source, args, selectionSet)
 }
 
@@ -159,16 +367,32 @@ type resolveAndExecuteCacheKey struct {
 	field     *Field
 	source    interface{}
 	selection *Selection
+
+	// skipDeferred and truncateStreams separate results with and without
+	// deferred fragments and streamed elements. They are only set for
+	// selections with deferred fragments or streamed lists, as the results of
+	// other selections are the same either way.
+	skipDeferred     bool
+	truncateStreams  bool
+	streamedElements int
+
+	// resolveOnly caches the resolved value of the field, before executing
+	// selection on it.
+	resolveOnly bool
 }
 
 func (e *Executor) resolveAndExecute(ctx context.Context, field *Field, source interface{}, selection *Selection) (interface{}, error) {
 	if field.Expensive {
 		// TODO: Skip goroutine for cached value
-		return fork(func() (interface{}, error) {
+		return fork(e.limiter, func() (interface{}, error) {
 			value := reflect.ValueOf(source)
 			// cache the body of resolve and excecute so that if the source doesn't change, we
 			// don't need to recompute
-			key := resolveAndExecuteCacheKey{field: field, source: source, selection: selection}
+			key := resolveAndExecuteCacheKey{
+				field:     field,
+				source:    source,
+				selection: selection,
+			}
 
 			// some types can't be put in a map; for those, use a always different value
 			// as source
@@ -177,12 +401,35 @@ func (e *Executor) resolveAndExecute(ctx context.Context, field *Field, source i
 				key.source = new(byte)
 			}
 
+			// Selections with deferred fragments or streamed lists are
+			// executed again once they are included, but their field is only
+			// resolved once. Other selections have the same result either way.
+			incremental := e.incremental && (selection.Streamed || isIncremental(selection.SelectionSet))
+			if incremental {
+				key.skipDeferred = e.SkipDeferred
+				key.truncateStreams = e.TruncateStreams
+				key.streamedElements = e.StreamedElements
+			}
+
 			// TODO: Consider cacheing resolve and execute independently
 			resolvedValue, err := reactive.Cache(ctx, key, func(ctx context.Context) (interface{}, error) {
-				value, err := safeResolve(ctx, field, source, selection.Args, selection.SelectionSet)
+				resolve := func(ctx context.Context) (interface{}, error) {
+					return e.safeResolve(ctx, field, source, selection.Args, selection.SelectionSet)
+				}
+				var value interface{}
+				var err error
+				if incremental {
+					resolveKey := key
+					resolveKey.skipDeferred, resolveKey.truncateStreams, resolveKey.streamedElements = false, false, 0
+					resolveKey.resolveOnly = true
+					value, err = reactive.Cache(ctx, resolveKey, resolve)
+				} else {
+					value, err = resolve(ctx)
+				}
 				if err != nil {
 					return nil, err
 				}
+				value = e.truncateStream(selection, value)
 				e.mu.Lock()
 				value, err = e.execute(ctx, field.Type, value, selection.SelectionSet)
 				e.mu.Unlock()
@@ -197,26 +444,75 @@ func (e *Executor) resolveAndExecute(ctx context.Context, field *Field, source i
 		}), nil
 	}
 
-	value, err := safeResolve(ctx, field, source, selection.Args, selection.SelectionSet)
+	value, err := e.safeResolve(ctx, field, source, selection.Args, selection.SelectionSet)
 	if err != nil {
 		return nil, err
 	}
-	return e.execute(ctx, field.Type, value, selection.SelectionSet)
+	return e.execute(ctx, field.Type, e.truncateStream(selection, value), selection.SelectionSet)
+}
+
+// truncateStream returns the first selection.InitialCount+e.StreamedElements
+// elements of value, the resolved list of a field marked with @stream, if
+// e.TruncateStreams is set.
+func (e *Executor) truncateStream(selection *Selection, value interface{}) interface{} {
+	if !e.TruncateStreams || !selection.Streamed {
+		return value
+	}
+	limit := selection.InitialCount + e.StreamedElements
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice || slice.Len() <= limit {
+		return value
+	}
+	atomic.StoreInt32(&e.truncatedStreams, 1)
+	return slice.Slice(0, limit).Interface()
 }
 
 // executeObject executes an object query
 func (e *Executor) executeObject(ctx context.Context, typ *Object, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	return e.executeSelections(ctx, typ, source, flatten(selectionSet, e.includeFragment))
+}
+
+// includeFragment returns false for deferred fragments if e.SkipDeferred is
+// set.
+func (e *Executor) includeFragment(fragment *Fragment) bool {
+	return !e.SkipDeferred || !fragment.Deferred
+}
+
+// executeAbstract executes a query on an Interface or Union typ named name,
+// using the Object returned by resolveType for source. Fragments on other
+// Objects are skipped.
+func (e *Executor) executeAbstract(ctx context.Context, name string, resolveType func(interface{}) (*Object, error), source interface{}, selectionSet *SelectionSet) (interface{}, error) {
 	value := reflect.ValueOf(source)
-	if value.Kind() == reflect.Ptr && value.IsNil() {
+	if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
 		return nil, nil
 	}
 
-	selections := Flatten(selectionSet)
+	object, err := resolveType(source)
+	if err != nil {
+		return nil, err
+	}
+	selections := flatten(selectionSet, func(fragment *Fragment) bool {
+		return e.includeFragment(fragment) && (fragment.On == "" || fragment.On == name || fragment.On == object.Name)
+	})
+	return e.executeSelections(ctx, object, source, selections)
+}
+
+// executeSelections resolves selections on source, an instance of typ.
+func (e *Executor) executeSelections(ctx context.Context, typ *Object, source interface{}, selections []*Selection) (interface{}, error) {
+	value := reflect.ValueOf(source)
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil, nil
+	}
 
 	fields := make(map[string]interface{})
 
 	// for every selection, resolve the value and store it in the output object
 	for _, selection := range selections {
+		// Stop resolving the remaining fields once the computation is
+		// cancelled.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		if selection.Name == "__typename" {
 			fields[selection.Alias] = typ.Name
 			continue
@@ -224,6 +520,13 @@ func (e *Executor) executeObject(ctx context.Context, typ *Object, source interf
 
 		field := typ.Fields[selection.Name]
 		resolved, err := e.resolveAndExecute(ctx, field, source, selection)
+		if e.PartialResults && isNullable(field.Type) {
+			if err != nil && recoverable(err) {
+				fields[selection.Alias] = fieldError{err: err}
+				continue
+			}
+			resolved = guardNullable(resolved)
+		}
 		if err != nil {
 			return nil, nestPathError(selection.Alias, err)
 		}
@@ -257,6 +560,13 @@ func (e *Executor) executeList(ctx context.Context, typ *List, source interface{
 	for i := 0; i < slice.Len(); i++ {
 		value := slice.Index(i)
 		resolved, err := e.execute(ctx, typ.Type, value.Interface(), selectionSet)
+		if e.PartialResults && isNullable(typ.Type) {
+			if err != nil && recoverable(err) {
+				items[i] = fieldError{err: err}
+				continue
+			}
+			resolved = guardNullable(resolved)
+		}
 		if err != nil {
 			return nil, nestPathError(fmt.Sprint(i), err)
 		}
@@ -273,25 +583,104 @@ func (e *Executor) execute(ctx context.Context, typ Type, source interface{}, se
 	}
 	switch typ := typ.(type) {
 	case *Scalar:
-		return unwrap(source), nil
+		return serialize(typ.Serialize, source)
+	case *Enum:
+		return serialize(typ.Serialize, source)
 	case *Object:
 		return e.executeObject(ctx, typ, source, selectionSet)
+	case *Interface:
+		return e.executeAbstract(ctx, typ.Name, typ.ResolveType, source, selectionSet)
+	case *Union:
+		return e.executeAbstract(ctx, typ.Name, typ.ResolveType, source, selectionSet)
 	case *List:
 		return e.executeList(ctx, typ, source, selectionSet)
 	case *NonNull:
-		return e.execute(ctx, typ.Type, source, selectionSet)
+		value, err := e.execute(ctx, typ.Type, source, selectionSet)
+		// With partial results, a null value for a non-null type is an error,
+		// which nulls out the nearest nullable field or list element enclosing
+		// it. Otherwise, the null value is returned as is, as schemabuilder
+		// declares the elements of slices of pointers non-null.
+		if e.PartialResults && err == nil && value == nil {
+			return nil, fmt.Errorf("null value for non-null type %s", typ)
+		}
+		return value, err
 	default:
 		panic(typ)
 	}
 }
 
 type Executor struct {
+	// PartialResults, if set, makes failed fields null out the nearest
+	// nullable field or list element enclosing them, instead of failing the
+	// whole query. Their errors are returned by FieldErrors.
+	PartialResults bool
+
+	// SkipDeferred, if set, leaves fragments marked with @defer out of the
+	// result.
+	SkipDeferred bool
+
+	// TruncateStreams, if set, only executes the first InitialCount elements
+	// of lists marked with @stream, and StreamedElements more.
+	TruncateStreams  bool
+	StreamedElements int
+
+	// MaxConcurrency limits the number of expensive fields each call to
+	// Execute resolves concurrently, each in its own goroutine. Expensive
+	// fields beyond the limit are queued without a goroutine, and resolved
+	// once another field finishes, or by the goroutine awaiting their result
+	// if it gets to them first. Zero means DefaultMaxConcurrency, and a
+	// negative value removes the limit.
+	MaxConcurrency int
+
 	mu sync.Mutex
+
+	// limiter limits the concurrency of the current call to Execute.
+	limiter *forkLimiter
+
+	// incremental is true if the query of the current call to Execute has
+	// fragments marked with @defer or lists marked with @stream.
+	incremental bool
+
+	// fieldsExecuted counts the resolvers invoked by the last call to Execute.
+	fieldsExecuted int64
+
+	// truncatedStreams is set if the last call to Execute left out elements
+	// of lists marked with @stream.
+	truncatedStreams int32
+
+	// fieldErrors holds the errors of the fields that failed during the last
+	// call to Execute.
+	fieldErrors []error
+}
+
+// FieldsExecuted returns the number of resolvers invoked by the last call to
+// Execute. Cached resolvers that did not run are not counted.
+func (e *Executor) FieldsExecuted() int {
+	return int(atomic.LoadInt64(&e.fieldsExecuted))
+}
+
+// TruncatedStreams returns true if the last call to Execute with
+// TruncateStreams set left out elements of lists marked with @stream.
+func (e *Executor) TruncatedStreams() bool {
+	return atomic.LoadInt32(&e.truncatedStreams) != 0
+}
+
+// FieldErrors returns the errors of the fields that failed during the last
+// call to Execute with PartialResults set, with their paths. The failed
+// fields are null in the result.
+func (e *Executor) FieldErrors() []error {
+	return e.fieldErrors
 }
 
 // Execute executes a query by dispatches according to typ
 func (e *Executor) Execute(ctx context.Context, typ Type, source interface{}, query *Query) (interface{}, error) {
+	atomic.StoreInt64(&e.fieldsExecuted, 0)
+	atomic.StoreInt32(&e.truncatedStreams, 0)
+	e.fieldErrors = nil
+
 	e.mu.Lock()
+	e.limiter = newForkLimiter(e.MaxConcurrency)
+	e.incremental = isIncremental(query.SelectionSet)
 	value, err := e.execute(ctx, typ, source, query.SelectionSet)
 	e.mu.Unlock()
 
@@ -305,5 +694,13 @@ func (e *Executor) Execute(ctx context.Context, typ Type, source interface{}, qu
 		err = nestPathError(query.Name, err)
 	}
 
+	if err == nil && e.PartialResults {
+		var path []string
+		if query.Name != "" {
+			path = []string{query.Name}
+		}
+		value, e.fieldErrors = collectFieldErrors(value, path)
+	}
+
 	return value, err
 }
@@ -262,6 +262,65 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestCancellationStopsResolution(t *testing.T) {
+	noArguments := func(json interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var resolved []int
+	item := &Object{
+		Name:   "Item",
+		Fields: make(map[string]*Field),
+	}
+	item.Fields["value"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			resolved = append(resolved, source.(int))
+			// Cancel the computation, as if the client unsubscribed. The
+			// cancellation happens within the item's only field, so it does not
+			// depend on the order in which fields are resolved.
+			if source.(int) == 2 {
+				cancel()
+			}
+			return source, nil
+		},
+		Type:           &Scalar{Type: "int64"},
+		ParseArguments: noArguments,
+	}
+	query := &Object{
+		Name: "Query",
+		Fields: map[string]*Field{
+			"items": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+					items := make([]int, 1000)
+					for i := range items {
+						items[i] = i
+					}
+					return items, nil
+				},
+				Type:           &List{Type: item},
+				ParseArguments: noArguments,
+			},
+		},
+	}
+
+	q := MustParse(`{ items { value } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{}
+	_, err := e.Execute(ctx, query, nil, q)
+	if extractPathError(err) != context.Canceled {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	// The items after the cancellation are not resolved.
+	if !reflect.DeepEqual(resolved, []int{0, 1, 2}) {
+		t.Errorf("expected items 0 to 2 to be resolved, got %v", resolved)
+	}
+}
+
 // TestPanic tests that a panicing resolver will report an error to a
 // context implementing PanicReporter instead of crashing the server.
 func TestPanic(t *testing.T) {
@@ -288,4 +347,186 @@ func TestPanic(t *testing.T) {
 	}
 }
 
+type character struct {
+	name    *string
+	friends []*character
+}
+
+// makeNonNullQuery builds a schema like the examples of non-null propagation
+// in the GraphQL spec. Characters without a name have a null name, even
+// though names are non-null.
+func makeNonNullQuery() *Object {
+	noArguments := func(json interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	query := &Object{
+		Name:   "Query",
+		Fields: make(map[string]*Field),
+	}
+
+	characterType := &Object{
+		Name:   "Character",
+		Fields: make(map[string]*Field),
+	}
+
+	luke, leia := "Luke", "Leia"
+	hero := &character{
+		name:    &luke,
+		friends: []*character{{name: &leia}, {}},
+	}
+
+	query.Fields["hero"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return hero, nil
+		},
+		Type:           characterType,
+		ParseArguments: noArguments,
+	}
+
+	query.Fields["requiredHero"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return hero, nil
+		},
+		Type:           &NonNull{Type: characterType},
+		ParseArguments: noArguments,
+	}
+
+	query.Fields["static"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return "static", nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: noArguments,
+	}
+
+	characterType.Fields["name"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return source.(*character).name, nil
+		},
+		Type:           &NonNull{Type: &Scalar{Type: "string"}},
+		ParseArguments: noArguments,
+	}
+
+	characterType.Fields["friends"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return source.(*character).friends, nil
+		},
+		Type:           &List{Type: characterType},
+		ParseArguments: noArguments,
+	}
+
+	characterType.Fields["nonNullFriends"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return source.(*character).friends, nil
+		},
+		Type:           &List{Type: &NonNull{Type: characterType}},
+		ParseArguments: noArguments,
+	}
+
+	characterType.Fields["rival"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return (*character)(nil), nil
+		},
+		Type:           &NonNull{Type: characterType},
+		ParseArguments: noArguments,
+	}
+
+	characterType.Fields["secret"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return nil, errors.New("secret failed")
+		},
+		Type:           &NonNull{Type: &Scalar{Type: "string"}},
+		ParseArguments: noArguments,
+	}
+
+	return query
+}
+
+func TestNonNullPropagation(t *testing.T) {
+	query := makeNonNullQuery()
+
+	testCases := []struct {
+		name   string
+		query  string
+		result string
+		errors []string
+	}{
+		{
+			name:   "null element of nullable list",
+			query:  `{ hero { name friends { name } } }`,
+			result: `{"hero": {"name": "Luke", "friends": [{"name": "Leia"}, null]}}`,
+			errors: []string{"hero.friends.1.name: null value for non-null type string!"},
+		},
+		{
+			name:   "null non-null element of nullable list",
+			query:  `{ hero { name nonNullFriends { name } } }`,
+			result: `{"hero": {"name": "Luke", "nonNullFriends": null}}`,
+			errors: []string{"hero.nonNullFriends.1.name: null value for non-null type string!"},
+		},
+		{
+			name:   "null non-null object",
+			query:  `{ hero { name rival { name } } static }`,
+			result: `{"hero": null, "static": "static"}`,
+			errors: []string{"hero.rival: null value for non-null type Character!"},
+		},
+		{
+			name:   "failed non-null field",
+			query:  `{ hero { name secret } static }`,
+			result: `{"hero": null, "static": "static"}`,
+			errors: []string{"hero.secret: secret failed"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		q := MustParse(testCase.query, nil)
+		if err := PrepareQuery(query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+
+		e := Executor{PartialResults: true}
+		result, err := e.Execute(context.Background(), query, nil, q)
+		if err != nil {
+			t.Errorf("%s: %s", testCase.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(testCase.result)) {
+			t.Errorf("%s: bad result: %s", testCase.name, internal.MarshalJSON(result))
+		}
+
+		var messages []string
+		for _, err := range e.FieldErrors() {
+			messages = append(messages, err.Error())
+		}
+		if !reflect.DeepEqual(messages, testCase.errors) {
+			t.Errorf("%s: expected errors %v, got %v", testCase.name, testCase.errors, messages)
+		}
+	}
+
+	// Nulls propagating up to a non-null root field fail the whole query.
+	q := MustParse(`{ requiredHero { name secret } static }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := Executor{PartialResults: true}
+	_, err := e.Execute(context.Background(), query, nil, q)
+	if err == nil || err.Error() != "requiredHero.secret: secret failed" {
+		t.Errorf("expected secret failed, got %v", err)
+	}
+
+	// Without partial results, null values of non-null types are kept.
+	q = MustParse(`{ hero { friends { name } } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e = Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"hero": {"friends": [{"name": "Leia"}, {"name": null}]}}`)) {
+		t.Errorf("bad result: %s", internal.MarshalJSON(result))
+	}
+}
+
 // TODO: Verify caching and concurrency
@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"context"
+	"sort"
+)
+
+// FieldUsageLogger is an optional interface implemented by a GraphqlLogger
+// that wants to know which fields are queried, such as to find fields that
+// are safe to remove. See SetFieldUsageTracking.
+type FieldUsageLogger interface {
+	FieldUsage(ctx context.Context, tags map[string]string, coordinates []string)
+}
+
+// SetFieldUsageTracking configures whether the schema coordinates of the
+// fields selected by each subscription and mutation are reported to the
+// connection's logger, if it implements FieldUsageLogger. The coordinates are
+// collected once, when the query is prepared, as reruns of a subscription
+// select the same fields. Tracking is disabled by default, as it walks every
+// query.
+func (c *conn) SetFieldUsageTracking(enabled bool) {
+	c.trackFieldUsage = enabled
+}
+
+// logFieldUsage reports the fields that selectionSet, which has been prepared
+// against typ, selects to c.logger if field usage tracking is enabled.
+func (c *conn) logFieldUsage(typ Type, selectionSet *SelectionSet, tags map[string]string) {
+	if !c.trackFieldUsage {
+		return
+	}
+	if logger, ok := c.logger.(FieldUsageLogger); ok {
+		logger.FieldUsage(c.ctx, tags, FieldCoordinates(typ, selectionSet))
+	}
+}
+
+// FieldCoordinates returns the sorted schema coordinates, such as
+// "User.name", of the fields that selectionSet, which has been prepared
+// against typ, selects. Fields selected several times are only included once.
+func FieldCoordinates(typ Type, selectionSet *SelectionSet) []string {
+	seen := make(map[string]bool)
+	var coordinates []string
+	visitFields(typ, selectionSet, func(typ string, name string, field *Field) {
+		coordinate := typ + "." + name
+		if !seen[coordinate] {
+			seen[coordinate] = true
+			coordinates = append(coordinates, coordinate)
+		}
+	})
+	sort.Strings(coordinates)
+	return coordinates
+}
@@ -0,0 +1,53 @@
+package graphql_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+type fieldUsageLogger struct {
+	nopLogger
+	usages chan []string
+}
+
+func (l *fieldUsageLogger) FieldUsage(ctx context.Context, tags map[string]string, coordinates []string) {
+	l.usages <- coordinates
+}
+
+func TestFieldUsageTracking(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		socket := newFakeSocket()
+		logger := &fieldUsageLogger{usages: make(chan []string, 10)}
+		makeCtx := func(ctx context.Context) context.Context { return ctx }
+		c := graphql.CreateJSONSocket(context.Background(), socket, makePartialSchema(), makeCtx, logger)
+		c.SetFieldUsageTracking(enabled)
+		go c.ServeJSONSocket()
+
+		socket.in <- map[string]interface{}{
+			"id":      "1",
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": "{ a: user { name } b: user { name } users { ... on User { name } } static }"},
+		}
+		socket.expect(t, `{"id": "1", "type": "update", "message": [{
+			"a": {"name": "bob"}, "b": {"name": "bob"}, "users": [{"name": "a"}, {"name": "b"}], "static": "static"
+		}], "metadata": {"diffVersion": 1}}`)
+
+		select {
+		case usage := <-logger.usages:
+			expected := []string{"Query.static", "Query.user", "Query.users", "User.name"}
+			if !enabled {
+				t.Errorf("expected no usage with tracking disabled, got %v", usage)
+			} else if !reflect.DeepEqual(usage, expected) {
+				t.Errorf("expected usage %v, got %v", expected, usage)
+			}
+		default:
+			if enabled {
+				t.Error("expected usage")
+			}
+		}
+		close(socket.in)
+	}
+}
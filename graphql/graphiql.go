@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// Default endpoints queried by the page served by GraphiQLHandler.
+const (
+	DefaultGraphiQLHTTPURL      = "/graphql/http"
+	DefaultGraphiQLWebsocketURL = "/graphql"
+)
+
+// GraphiQLOptions configures the endpoints queried by the page served by
+// GraphiQLHandlerWithOptions. URLs may be relative to the page's host.
+type GraphiQLOptions struct {
+	// HTTPURL is the URL of an HTTPHandler. If set, the schema is
+	// introspected over HTTP, and, if WebsocketURL is not set, all queries
+	// and mutations are sent over HTTP.
+	HTTPURL string
+
+	// WebsocketURL is the URL of a Handler. If set, queries are sent over a
+	// websocket speaking the graphql-ws protocol, and the page shows live
+	// updates to their results.
+	WebsocketURL string
+}
+
+// GraphiQLHandler returns an http.Handler serving a GraphiQL page for exploring
+// a schema, alongside a Handler at DefaultGraphiQLWebsocketURL and an
+// HTTPHandler at DefaultGraphiQLHTTPURL:
+//
+//	http.Handle("/graphql", graphql.Handler(schema))
+//	http.Handle("/graphql/http", graphql.HTTPHandler(schema))
+//	http.Handle("/graphiql", graphql.GraphiQLHandler())
+//
+// GraphiQL relies on introspection, so the schema must have introspection
+// added with introspection.AddIntrospectionToSchema. The page loads GraphiQL
+// from a CDN, and is meant for development and debugging.
+func GraphiQLHandler() http.Handler {
+	return GraphiQLHandlerWithOptions(GraphiQLOptions{
+		HTTPURL:      DefaultGraphiQLHTTPURL,
+		WebsocketURL: DefaultGraphiQLWebsocketURL,
+	})
+}
+
+// GraphiQLHandlerWithOptions is like GraphiQLHandler, but queries the
+// endpoints in opts.
+func GraphiQLHandlerWithOptions(opts GraphiQLOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := graphiQLTemplate.Execute(w, opts); err != nil {
+			log.Printf("graphiql: %v", err)
+		}
+	})
+}
+
+var graphiQLTemplate = template.Must(template.New("graphiql").Parse(`<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Thunder GraphiQL</title>
+    <link rel="stylesheet" href="https://unpkg.com/graphiql@0.17.5/graphiql.min.css" crossorigin="anonymous">
+    <style>
+      html, body, #root {
+        width: 100%;
+        height: 100%;
+        margin: 0;
+        padding: 0;
+      }
+    </style>
+  </head>
+  <body>
+    <div id="root"></div>
+    <script src="https://unpkg.com/react@16.14.0/umd/react.production.min.js" crossorigin="anonymous"></script>
+    <script src="https://unpkg.com/react-dom@16.14.0/umd/react-dom.production.min.js" crossorigin="anonymous"></script>
+    <script src="https://unpkg.com/graphiql@0.17.5/graphiql.min.js" crossorigin="anonymous"></script>
+    <script>
+      var httpURL = {{.HTTPURL}};
+      var websocketURL = {{.WebsocketURL}};
+
+      function absoluteWebsocketURL(url) {
+        if (/^wss?:/.test(url)) {
+          return url;
+        }
+        var protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+        return protocol + "//" + window.location.host + url;
+      }
+
+      // socket lazily connects to websocketURL, and dispatches graphql-ws
+      // messages to the observers of running operations by id.
+      var socket = null;
+      var connected = false;
+      var pending = [];
+      var observers = {};
+      var nextID = 0;
+
+      function send(message) {
+        if (connected) {
+          socket.send(JSON.stringify(message));
+        } else {
+          pending.push(message);
+        }
+      }
+
+      function connect() {
+        socket = new WebSocket(absoluteWebsocketURL(websocketURL), "graphql-ws");
+        socket.onopen = function() {
+          socket.send(JSON.stringify({type: "connection_init", payload: {}}));
+        };
+        socket.onmessage = function(event) {
+          var message = JSON.parse(event.data);
+          if (message.type === "connection_ack") {
+            connected = true;
+            pending.forEach(send);
+            pending = [];
+            return;
+          }
+          var observer = observers[message.id];
+          if (!observer) {
+            return;
+          }
+          if (message.type === "data") {
+            observer.next(message.payload);
+          } else if (message.type === "error") {
+            observer.next({errors: message.payload});
+          } else if (message.type === "complete") {
+            delete observers[message.id];
+            observer.complete();
+          }
+        };
+        socket.onclose = function() {
+          for (var id in observers) {
+            observers[id].next({errors: [{message: "connection closed"}]});
+          }
+          socket = null;
+          connected = false;
+          observers = {};
+        };
+      }
+
+      function websocketFetcher(params) {
+        return {
+          subscribe: function(subscriber) {
+            var next = subscriber.next || subscriber;
+            var observer = {
+              next: next,
+              complete: subscriber.complete || function() {}
+            };
+            var id = String(nextID++);
+            if (!socket) {
+              connect();
+            }
+            observers[id] = observer;
+            send({id: id, type: "start", payload: params});
+            return {
+              unsubscribe: function() {
+                if (observers[id]) {
+                  delete observers[id];
+                  send({id: id, type: "stop"});
+                }
+              }
+            };
+          }
+        };
+      }
+
+      function httpFetcher(params) {
+        return fetch(httpURL, {
+          method: "POST",
+          headers: {"Content-Type": "application/json"},
+          credentials: "same-origin",
+          body: JSON.stringify(params)
+        }).then(function(response) {
+          return response.json();
+        });
+      }
+
+      function fetcher(params) {
+        if (httpURL && (!websocketURL || params.operationName === "IntrospectionQuery")) {
+          return httpFetcher(params);
+        }
+        return websocketFetcher(params);
+      }
+
+      ReactDOM.render(
+        React.createElement(GraphiQL, {fetcher: fetcher}),
+        document.getElementById("root")
+      );
+    </script>
+  </body>
+</html>
+`))
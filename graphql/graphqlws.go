@@ -0,0 +1,229 @@
+package graphql
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// This file contains an adapter for the graphql-ws subprotocol spoken by
+// Apollo's subscriptions-transport-ws client. The adapter translates between
+// graphql-ws frames and the InEnvelope and OutEnvelope messages understood by
+// conn, so that the same connection logic serves both protocols.
+
+// GraphQLWSProtocol is the websocket subprotocol negotiated by Apollo clients.
+const GraphQLWSProtocol = "graphql-ws"
+
+// graphql-ws message types.
+const (
+	gqlConnectionInit      = "connection_init"
+	gqlConnectionAck       = "connection_ack"
+	gqlConnectionError     = "connection_error"
+	gqlConnectionTerminate = "connection_terminate"
+	gqlStart               = "start"
+	gqlStop                = "stop"
+	gqlData                = "data"
+	gqlError               = "error"
+	gqlComplete            = "complete"
+	gqlConnectionKeepAlive = "ka"
+)
+
+// graphqlWSMessage is a single graphql-ws frame.
+type graphqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphqlWSData is the payload of a data frame. Errors holds the errors of
+// fields that failed in a partial result.
+type graphqlWSData struct {
+	Data   interface{}     `json:"data"`
+	Errors []ResponseError `json:"errors,omitempty"`
+}
+
+// graphqlWSSocket wraps a JSONSocket speaking graphql-ws.
+type graphqlWSSocket struct {
+	socket JSONSocket
+
+	// writeMu serializes writes to socket.
+	writeMu sync.Mutex
+}
+
+// NewGraphQLWSSocket wraps a JSONSocket speaking the graphql-ws protocol so
+// that it can be served with ServeJSONSocket.
+//
+// Incoming start frames become operation messages, which the connection runs
+// as subscriptions or mutations depending on the operation, and stop frames
+// become unsubscribe messages. Outgoing updates
+// and results are sent as data frames holding the complete result, as
+// graphql-ws clients do not understand diffs.
+func NewGraphQLWSSocket(socket JSONSocket) JSONSocket {
+	return &graphqlWSSocket{socket: socket}
+}
+
+func (s *graphqlWSSocket) write(message graphqlWSMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.socket.WriteJSON(message)
+}
+
+func mustMarshalRaw(v interface{}) json.RawMessage {
+	return json.RawMessage(mustMarshalJson(v))
+}
+
+func (s *graphqlWSSocket) ReadJSON(value interface{}) error {
+	envelope, ok := value.(*InEnvelope)
+	if !ok {
+		return s.socket.ReadJSON(value)
+	}
+
+	for {
+		var message graphqlWSMessage
+		if err := s.socket.ReadJSON(&message); err != nil {
+			return err
+		}
+
+		switch message.Type {
+		case gqlConnectionInit:
+			*envelope = InEnvelope{
+				Type:    "connect",
+				Message: message.Payload,
+			}
+
+		case gqlConnectionTerminate:
+			return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+
+		case gqlStart:
+			// Apollo sends mutations using start frames as well, so the
+			// connection decides how to run the operation.
+			*envelope = InEnvelope{
+				ID:      message.ID,
+				Type:    "operation",
+				Message: message.Payload,
+			}
+
+		case gqlStop:
+			*envelope = InEnvelope{
+				ID:   message.ID,
+				Type: "unsubscribe",
+			}
+
+		default:
+			*envelope = InEnvelope{
+				ID:      message.ID,
+				Type:    message.Type,
+				Message: message.Payload,
+			}
+		}
+
+		return nil
+	}
+}
+
+func (s *graphqlWSSocket) WriteJSON(value interface{}) error {
+	out, ok := value.(OutEnvelope)
+	if !ok {
+		s.writeMu.Lock()
+		defer s.writeMu.Unlock()
+		return s.socket.WriteJSON(value)
+	}
+
+	switch out.Type {
+	case "update":
+		return s.write(graphqlWSMessage{
+			ID:      out.ID,
+			Type:    gqlData,
+			Payload: mustMarshalRaw(graphqlWSData{Data: out.Message, Errors: out.Errors}),
+		})
+
+	case "result":
+		if err := s.write(graphqlWSMessage{
+			ID:      out.ID,
+			Type:    gqlData,
+			Payload: mustMarshalRaw(graphqlWSData{Data: out.Message, Errors: out.Errors}),
+		}); err != nil {
+			return err
+		}
+		return s.write(graphqlWSMessage{
+			ID:   out.ID,
+			Type: gqlComplete,
+		})
+
+	case "connected":
+		return s.write(graphqlWSMessage{Type: gqlConnectionAck})
+
+	case "connection_error":
+		message, _ := out.Message.(string)
+		return s.write(graphqlWSMessage{
+			Type:    gqlConnectionError,
+			Payload: mustMarshalRaw(ResponseError{Message: message}),
+		})
+
+	case "complete":
+		return s.write(graphqlWSMessage{
+			ID:   out.ID,
+			Type: gqlComplete,
+		})
+
+	case "ka":
+		// graphql-ws only has keepalives for the whole connection.
+		return s.write(graphqlWSMessage{Type: gqlConnectionKeepAlive})
+
+	case "error":
+		errors := out.Errors
+		if errors == nil {
+			message, _ := out.Message.(string)
+			errors = []ResponseError{{Message: message}}
+		}
+		return s.write(graphqlWSMessage{
+			ID:      out.ID,
+			Type:    gqlError,
+			Payload: mustMarshalRaw(errors),
+		})
+
+	default:
+		// Drop messages that have no graphql-ws equivalent, such as echo.
+		return nil
+	}
+}
+
+func (s *graphqlWSSocket) Close() error {
+	return s.socket.Close()
+}
+
+// handleOperation runs the operation of a graphql-ws start frame as a
+// mutation if it is one, and as a subscription otherwise. The operation is
+// parsed once persisted queries are resolved, through the connection's query
+// cache, which then serves the parse in handleSubscribe or handleMutate.
+func (c *conn) handleOperation(id string, message json.RawMessage) error {
+	var operation subscribeMessage
+	if err := json.Unmarshal(message, &operation); err != nil {
+		return err
+	}
+	query, err := c.resolvePersistedQuery(operation.Query, persistedQueryHash(operation.SHA256Hash, operation.Extensions))
+	if err != nil {
+		return err
+	}
+	operation.Query = query
+
+	// Invalid operations are run as subscriptions, which report the error.
+	if parsed, err := c.queryCache.ParseOperation(operation.Query, operation.Variables, operation.OperationName); err == nil && parsed.Kind == "mutation" {
+		return c.handleMutate(id, &mutateMessage{
+			Query:         operation.Query,
+			Variables:     operation.Variables,
+			OperationName: operation.OperationName,
+		})
+	}
+	return c.handleSubscribe(id, &operation)
+}
+
+// defaultDiffer returns the Differ for updates written to socket. graphql-ws
+// clients do not understand diffs, and are sent complete results instead.
+func defaultDiffer(socket JSONSocket) Differ {
+	if _, ok := socket.(*graphqlWSSocket); ok {
+		return SnapshotDiffer
+	}
+	return JSONDiffer
+}
@@ -0,0 +1,133 @@
+package graphql_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+func makeGraphQLWSSchema() *graphql.Schema {
+	type Item struct {
+		Id   int64 `graphql:",key"`
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("items", func() []Item {
+		return []Item{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}}
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("echo", func(args struct{ Text string }) string {
+		return args.Text
+	})
+	return schema.MustBuild()
+}
+
+func TestGraphQLWSProtocol(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), graphql.NewGraphQLWSSocket(socket), makeGraphQLWSSchema(), makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{"type": "connection_init"}
+	socket.expect(t, `{"type": "connection_ack"}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "data", "payload": {"data": {"items": [{"name": "a"}, {"name": "b"}]}}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": `mutation { echo(text: "hi") }`},
+	}
+	socket.expect(t, `{"id": "2", "type": "data", "payload": {"data": {"echo": "hi"}}}`)
+	socket.expect(t, `{"id": "2", "type": "complete"}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": "{ missing }"},
+	}
+	socket.expect(t, `{"id": "3", "type": "error", "payload": [{"message": "unknown field \"missing\""}]}`)
+}
+
+func TestGraphQLWSPersistedMutation(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), graphql.NewGraphQLWSSocket(socket), makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetPersistedQueryStore(graphql.NewMemoryPersistedQueryStore(10))
+	go c.ServeJSONSocket()
+
+	query := `mutation { echo(text: "hi") }`
+	sum := sha256.Sum256([]byte(query))
+	extensions := map[string]interface{}{
+		"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hex.EncodeToString(sum[:])},
+	}
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": query, "extensions": extensions},
+	}
+	socket.expect(t, `{"id": "1", "type": "data", "payload": {"data": {"echo": "hi"}}}`)
+	socket.expect(t, `{"id": "1", "type": "complete"}`)
+
+	// The persisted mutation is only known to be a mutation once its query
+	// is resolved.
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "start",
+		"payload": map[string]interface{}{"extensions": extensions},
+	}
+	socket.expect(t, `{"id": "2", "type": "data", "payload": {"data": {"echo": "hi"}}}`)
+	socket.expect(t, `{"id": "2", "type": "complete"}`)
+}
+
+func TestGraphQLWSPartialResults(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	failing := func() (*string, error) {
+		return nil, graphql.NewSafeError("failed")
+	}
+	query := schema.Query()
+	query.FieldFunc("fail", failing)
+	query.FieldFunc("static", func() string {
+		return "static"
+	})
+	schema.Mutation().FieldFunc("fail", failing)
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), graphql.NewGraphQLWSSocket(socket), schema.MustBuild(), makeCtx, nopLogger{})
+	c.SetPartialResults(true)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": "{ fail static }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "data", "payload": {"data": {"fail": null, "static": "static"},
+		"errors": [{"message": "failed", "path": ["fail"]}]}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": "mutation { fail }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "data", "payload": {"data": {"fail": null}, "errors": [{"message": "failed", "path": ["fail"]}]}}`)
+	socket.expect(t, `{"id": "2", "type": "complete"}`)
+}
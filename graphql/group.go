@@ -0,0 +1,62 @@
+package graphql
+
+import "strconv"
+
+// handleSubscribeGroup subscribes to several operations at once under the
+// group id. Each operation is an independent subscription, whose id is the
+// group id followed by a colon and the index of the operation, such as "7:0",
+// and whose updates and errors carry that id. Unsubscribing from the group id
+// closes all its subscriptions.
+//
+// If any operation cannot be subscribed to, the subscriptions to the previous
+// operations are closed, and the group fails with the operation's error.
+func (c *conn) handleSubscribeGroup(id string, operations []*subscribeMessage) error {
+	c.mu.Lock()
+	_, duplicate := c.groups[id]
+	if _, ok := c.subscriptions[id]; ok {
+		duplicate = true
+	}
+	c.mu.Unlock()
+	if duplicate {
+		return NewSafeError("duplicate subscription")
+	}
+
+	members := make([]string, 0, len(operations))
+	for i, operation := range operations {
+		member := groupMemberID(id, i)
+		err := c.subscribeGroupMember(member, operation)
+		if err != nil {
+			c.mu.Lock()
+			for _, member := range members {
+				c.stopSubscription(member, UnsubscribeError)
+			}
+			c.mu.Unlock()
+			return err
+		}
+		members = append(members, member)
+	}
+
+	c.mu.Lock()
+	c.groups[id] = members
+	c.mu.Unlock()
+	return nil
+}
+
+// subscribeGroupMember subscribes to operation under the id member.
+func (c *conn) subscribeGroupMember(member string, operation *subscribeMessage) error {
+	if len(operation.Operations) > 0 {
+		return NewSafeError("subscription groups cannot be nested")
+	}
+	query, err := c.resolvePersistedQuery(operation.Query, persistedQueryHash(operation.SHA256Hash, operation.Extensions))
+	if err != nil {
+		return err
+	}
+	operation.Query = query
+	return c.handleSubscribe(member, operation)
+}
+
+// groupMemberID returns the id of the subscription to the operation at index
+// in the group id.
+func groupMemberID(id string, index int) string {
+	return id + ":" + strconv.Itoa(index)
+}
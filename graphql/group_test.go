@@ -0,0 +1,116 @@
+package graphql_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/internal"
+)
+
+func TestSubscriptionGroup(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+	logger := &subscriptionLogger{events: make(chan subscriptionEvent, 16)}
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, logger)
+
+	socket.in <- map[string]interface{}{
+		"id":   "1",
+		"type": "subscribe",
+		"message": map[string]interface{}{
+			"operations": []interface{}{
+				map[string]interface{}{"query": "{ items { name } }"},
+				map[string]interface{}{"query": "{ items { id } }"},
+			},
+		},
+	}
+	logger.expect(t, subscriptionEvent{id: "1:0"})
+	logger.expect(t, subscriptionEvent{id: "1:1"})
+
+	// The operations run independently, so their updates arrive in any
+	// order.
+	var updates []string
+	for i := 0; i < 2; i++ {
+		select {
+		case message := <-socket.out:
+			updates = append(updates, internal.MarshalJSON(message))
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for update")
+		}
+	}
+	sort.Strings(updates)
+	expected := []string{
+		`{"id":"1:0","message":[{"items":[{"name":"a"},{"name":"b"}]}],"metadata":{"diffVersion":1},"type":"update"}`,
+		`{"id":"1:1","message":[{"items":[{"id":1},{"id":2}]}],"metadata":{"diffVersion":1},"type":"update"}`,
+	}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("expected updates %v, got %v", expected, updates)
+	}
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "unsubscribe"}
+	logger.expect(t, subscriptionEvent{id: "1:0", reason: graphql.UnsubscribeClient})
+	logger.expect(t, subscriptionEvent{id: "1:1", reason: graphql.UnsubscribeClient})
+
+	// A group with an invalid operation fails as a whole.
+	socket.in <- map[string]interface{}{
+		"id":   "2",
+		"type": "subscribe",
+		"message": map[string]interface{}{
+			"operations": []interface{}{
+				map[string]interface{}{"query": "{ items { name } }"},
+				map[string]interface{}{"query": "{ missing }"},
+			},
+		},
+	}
+	logger.expect(t, subscriptionEvent{id: "2:0"})
+	logger.expect(t, subscriptionEvent{id: "2:0", reason: graphql.UnsubscribeError})
+	for {
+		select {
+		case message := <-socket.out:
+			if message.(map[string]interface{})["id"] != "2" {
+				continue
+			}
+			if !reflect.DeepEqual(message, internal.ParseJSON(`{"id": "2", "type": "error", "message": "unknown field \"missing\"", "errors": [{"message": "unknown field \"missing\""}]}`)) {
+				t.Errorf("unexpected error %s", internal.MarshalJSON(message))
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for error")
+		}
+	}
+}
+
+func TestSubscriptionGroupDuplicateID(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+	logger := &subscriptionLogger{events: make(chan subscriptionEvent, 16)}
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, logger)
+
+	socket.in <- map[string]interface{}{
+		"id":   "1",
+		"type": "subscribe",
+		"message": map[string]interface{}{
+			"operations": []interface{}{
+				map[string]interface{}{"query": "{ items { name } }"},
+			},
+		},
+	}
+	logger.expect(t, subscriptionEvent{id: "1:0"})
+	socket.expect(t, `{"id": "1:0", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	// The id of a group cannot be reused by a subscription, which could not
+	// be unsubscribed from separately.
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "duplicate subscription", "errors": [{"message": "duplicate subscription"}]}`)
+}
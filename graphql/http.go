@@ -3,7 +3,6 @@ package graphql
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"sync"
 
@@ -12,32 +11,80 @@ import (
 )
 
 func HTTPHandler(schema *Schema, middlewares ...MiddlewareFunc) http.Handler {
+	return HTTPHandlerWithOptions(schema, HTTPHandlerOptions{Middlewares: middlewares})
+}
+
+// HTTPHandlerOptions configures the handler returned by
+// HTTPHandlerWithOptions.
+type HTTPHandlerOptions struct {
+	// Middlewares wrap the execution of every query.
+	Middlewares []MiddlewareFunc
+
+	// AllowedQueries, if set, restricts the handler to the queries whose
+	// QueryHash is in AllowedQueries, as HandlerOptions.AllowedQueries does
+	// for websocket connections. Other queries are rejected with
+	// ErrQueryNotAllowed before they are parsed.
+	AllowedQueries map[string]bool
+
+	// MaxFields, if positive, rejects queries selecting more than MaxFields
+	// fields, counting every alias of a field and every spread of a fragment.
+	// See SetMaxFields.
+	MaxFields int
+
+	// MaxDepth, if positive, rejects queries whose selection sets are nested
+	// more than MaxDepth levels deep. See SetMaxDepth.
+	MaxDepth int
+
+	// ComplexityLimit, if positive, rejects queries whose complexity, as
+	// computed by ComplexityFunc, exceeds ComplexityLimit. If ComplexityFunc
+	// is nil, DefaultComplexity is used. See SetComplexityLimit.
+	ComplexityLimit int
+	ComplexityFunc  ComplexityFunc
+}
+
+// HTTPHandlerWithOptions is like HTTPHandler, but configured with opts.
+func HTTPHandlerWithOptions(schema *Schema, opts HTTPHandlerOptions) http.Handler {
 	return &httpHandler{
-		schema:      schema,
-		middlewares: middlewares,
+		schema:          schema,
+		middlewares:     opts.Middlewares,
+		allowedQueries:  opts.AllowedQueries,
+		maxFields:       opts.MaxFields,
+		maxDepth:        opts.MaxDepth,
+		complexityLimit: opts.ComplexityLimit,
+		complexityFunc:  opts.ComplexityFunc,
 	}
 }
 
 type httpHandler struct {
-	schema      *Schema
-	middlewares []MiddlewareFunc
+	schema          *Schema
+	middlewares     []MiddlewareFunc
+	allowedQueries  map[string]bool
+	maxFields       int
+	maxDepth        int
+	complexityLimit int
+	complexityFunc  ComplexityFunc
 }
 
 type httpPostBody struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
 }
 
 type httpResponse struct {
-	Data   interface{} `json:"data"`
-	Errors []string    `json:"errors"`
+	Data   interface{}     `json:"data"`
+	Errors []ResponseError `json:"errors"`
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// query is set once the request has been parsed, and is used to report the
+	// paths of execution errors.
+	var query *Query
+
 	writeResponse := func(value interface{}, err error) {
 		response := httpResponse{}
 		if err != nil {
-			response.Errors = []string{err.Error()}
+			response.Errors = formatErrors(err, query)
 		} else {
 			response.Data = value
 		}
@@ -51,29 +98,68 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, string(responseJSON), http.StatusOK)
 	}
 
-	if r.Method != "POST" {
-		writeResponse(nil, errors.New("request must be a POST"))
+	var params httpPostBody
+	switch r.Method {
+	case "GET":
+		// Support GET requests with query and variables URL parameters for
+		// tooling such as introspection clients.
+		values := r.URL.Query()
+		params.Query = values.Get("query")
+		params.OperationName = values.Get("operationName")
+		if params.Query == "" {
+			writeResponse(nil, NewClientError("request must include a query"))
+			return
+		}
+		if variables := values.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &params.Variables); err != nil {
+				writeResponse(nil, NewClientError("bad variables: %s", err))
+				return
+			}
+		}
+
+	case "POST":
+		if r.Body == nil {
+			writeResponse(nil, NewClientError("request must include a query"))
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(nil, NewClientError("bad request body: %s", err))
+			return
+		}
+
+	default:
+		writeResponse(nil, NewClientError("request must be a GET or POST"))
 		return
 	}
 
-	if r.Body == nil {
-		writeResponse(nil, errors.New("request must include a query"))
+	if err := checkAllowed(h.allowedQueries, params.Query); err != nil {
+		writeResponse(nil, err)
 		return
 	}
 
-	var params httpPostBody
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+	query, err := ParseOperation(params.Query, params.Variables, params.OperationName)
+	if err != nil {
 		writeResponse(nil, err)
 		return
 	}
 
-	query, err := Parse(params.Query, params.Variables)
-	if err != nil {
+	if err := checkFieldCount(query, h.maxFields); err != nil {
+		writeResponse(nil, err)
+		return
+	}
+
+	if err := checkDepth(query, h.maxDepth); err != nil {
+		writeResponse(nil, err)
+		return
+	}
+
+	if err := prepareQuery(h.schema, h.schema.Query, query.SelectionSet); err != nil {
 		writeResponse(nil, err)
 		return
 	}
 
-	if err := PrepareQuery(h.schema.Query, query.SelectionSet); err != nil {
+	if err := checkComplexity(query, h.complexityLimit, h.complexityFunc); err != nil {
 		writeResponse(nil, err)
 		return
 	}
@@ -1,6 +1,7 @@
 package graphql_test
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/kylelemons/godebug/pretty"
 
 	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/introspection"
 	"github.com/samsarahq/thunder/graphql/schemabuilder"
 )
 
@@ -30,7 +32,7 @@ func testHTTPRequest(req *http.Request) *httptest.ResponseRecorder {
 }
 
 func TestHTTPMustPost(t *testing.T) {
-	req, err := http.NewRequest("GET", "/graphql", nil)
+	req, err := http.NewRequest("PUT", "/graphql", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,7 +43,7 @@ func TestHTTPMustPost(t *testing.T) {
 		t.Errorf("expected 200, but received %d", rr.Code)
 	}
 
-	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"request must be a POST\"]}\n"); diff != "" {
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"request must be a GET or POST\"}]}\n"); diff != "" {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
@@ -58,7 +60,7 @@ func TestHTTPParseQuery(t *testing.T) {
 		t.Errorf("expected 200, but received %d", rr.Code)
 	}
 
-	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"request must include a query\"]}\n"); diff != "" {
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"request must include a query\"}]}\n"); diff != "" {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
@@ -75,7 +77,7 @@ func TestHTTPMustHaveQuery(t *testing.T) {
 		t.Errorf("expected 200, but received %d", rr.Code)
 	}
 
-	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"must have a single query\"]}\n"); diff != "" {
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"must have a single query\"}]}\n"); diff != "" {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
@@ -96,3 +98,227 @@ func TestHTTPSuccess(t *testing.T) {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
+
+func TestHTTPGet(t *testing.T) {
+	req, err := http.NewRequest("GET", "/graphql?query=query+TestQuery($value:+int64)+{+mirror(value:+$value)+}&variables={\"value\":2}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := testHTTPRequest(req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, but received %d", rr.Code)
+	}
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-2},\"errors\":null}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPSanitizesErrors(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("secret", func() (string, error) {
+		return "", errors.New("database password is hunter2")
+	})
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ secret }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	graphql.HTTPHandler(schema.MustBuild()).ServeHTTP(rr, req)
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"Internal server error\",\"path\":[\"secret\"]}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPDisableIntrospection(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("value", func() int64 {
+		return 1
+	})
+	schema.Mutation()
+
+	builtSchema := schema.MustBuild()
+	introspection.AddIntrospectionToSchema(builtSchema)
+	builtSchema.DisableIntrospection = true
+
+	for _, query := range []string{
+		"{ __schema { queryType { name } } }",
+		`{ value ... on Query { __type(name: \"Query\") { name } } }`,
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+query+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		graphql.HTTPHandler(builtSchema).ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"introspection is disabled\"}]}\n"); diff != "" {
+			t.Errorf("expected response to match, but received %s", diff)
+		}
+	}
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ value }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	graphql.HTTPHandler(builtSchema).ServeHTTP(rr, req)
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"value\":1},\"errors\":null}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPAllowedQueries(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("value", func() int64 {
+		return 1
+	})
+	schema.Mutation()
+
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(), graphql.HTTPHandlerOptions{
+		AllowedQueries: map[string]bool{graphql.QueryHash("{ value }"): true},
+	})
+
+	for query, expected := range map[string]string{
+		"{ value }":               "{\"data\":{\"value\":1},\"errors\":null}\n",
+		"{ value value2: value }": "{\"data\":null,\"errors\":[{\"message\":\"query is not allowed\"}]}\n",
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+query+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), expected); diff != "" {
+			t.Errorf("%s: expected response to match, but received %s", query, diff)
+		}
+	}
+}
+
+func TestHTTPMaxFields(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("value", func() int64 {
+		return 1
+	})
+	schema.Mutation()
+
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(), graphql.HTTPHandlerOptions{
+		MaxFields: 2,
+	})
+
+	for query, expected := range map[string]string{
+		"{ a: value b: value }":          "{\"data\":{\"a\":1,\"b\":1},\"errors\":null}\n",
+		"{ a: value b: value c: value }": "{\"data\":null,\"errors\":[{\"message\":\"query exceeds maximum of 2 fields\"}]}\n",
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+query+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), expected); diff != "" {
+			t.Errorf("%s: expected response to match, but received %s", query, diff)
+		}
+	}
+}
+
+type httpDepthNode struct {
+	Value int64
+}
+
+func TestHTTPMaxDepthAndComplexity(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("node", func() *httpDepthNode {
+		return &httpDepthNode{Value: 1}
+	})
+	node := schema.Object("Node", httpDepthNode{})
+	node.FieldFunc("child", func(n *httpDepthNode) *httpDepthNode {
+		return &httpDepthNode{Value: n.Value + 1}
+	})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	for _, testCase := range []struct {
+		opts     graphql.HTTPHandlerOptions
+		query    string
+		expected string
+	}{
+		{
+			opts:     graphql.HTTPHandlerOptions{MaxDepth: 3},
+			query:    "{ node { child { value } } }",
+			expected: "{\"data\":{\"node\":{\"child\":{\"value\":2}}},\"errors\":null}\n",
+		},
+		{
+			opts:     graphql.HTTPHandlerOptions{MaxDepth: 3},
+			query:    "{ node { child { child { value } } } }",
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"query exceeds maximum depth of 3\"}]}\n",
+		},
+		{
+			opts:     graphql.HTTPHandlerOptions{ComplexityLimit: 3},
+			query:    "{ node { value child { value } } }",
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"query complexity 4 exceeds limit 3\"}]}\n",
+		},
+		{
+			opts: graphql.HTTPHandlerOptions{ComplexityLimit: 3, ComplexityFunc: func(query *graphql.Query) int {
+				return 1
+			}},
+			query:    "{ node { value child { value } } }",
+			expected: "{\"data\":{\"node\":{\"child\":{\"value\":2},\"value\":1}},\"errors\":null}\n",
+		},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+testCase.query+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		graphql.HTTPHandlerWithOptions(builtSchema, testCase.opts).ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), testCase.expected); diff != "" {
+			t.Errorf("%s: expected response to match, but received %s", testCase.query, diff)
+		}
+	}
+}
+
+func TestGraphiQLHandler(t *testing.T) {
+	req, err := http.NewRequest("GET", "/graphiql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	graphql.GraphiQLHandlerWithOptions(graphql.GraphiQLOptions{
+		HTTPURL:      "/api/http",
+		WebsocketURL: "/api/ws",
+	}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, but received %d", rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("expected html, but received %s", contentType)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{"api/http", "api/ws"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected page to contain %s", expected)
+		}
+	}
+}
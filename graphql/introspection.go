@@ -0,0 +1,31 @@
+package graphql
+
+// errIntrospectionDisabled is returned for queries selecting introspection
+// fields on a schema with DisableIntrospection set.
+var errIntrospectionDisabled = NewSafeError("introspection is disabled")
+
+// isIntrospectionField returns true for the introspection meta-fields of the
+// query root.
+func isIntrospectionField(name string) bool {
+	return name == "__schema" || name == "__type"
+}
+
+// checkIntrospection rejects queries selecting introspection fields if the
+// schema disables introspection. Introspection fields only exist on the query
+// root, so only the root selections and fragments are checked.
+func checkIntrospection(schema *Schema, selectionSet *SelectionSet) error {
+	if !schema.DisableIntrospection || selectionSet == nil {
+		return nil
+	}
+	for _, selection := range selectionSet.Selections {
+		if isIntrospectionField(selection.Name) {
+			return errIntrospectionDisabled
+		}
+	}
+	for _, fragment := range selectionSet.Fragments {
+		if err := checkIntrospection(schema, fragment.SelectionSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -13,6 +13,43 @@ type introspection struct {
 	types    map[string]graphql.Type
 	query    graphql.Type
 	mutation graphql.Type
+
+	// visibleTypes holds the types reachable without passing through hidden
+	// objects or fields.
+	visibleTypes map[string]graphql.Type
+	// showHidden reports whether hidden objects and fields are shown to the
+	// client with ctx.
+	showHidden func(ctx context.Context) bool
+}
+
+// typesFor returns the types shown to the client with ctx.
+func (s *introspection) typesFor(ctx context.Context) map[string]graphql.Type {
+	if s.showHidden != nil && s.showHidden(ctx) {
+		return s.types
+	}
+	return s.visibleTypes
+}
+
+// isVisible returns true if the field f is shown to the client with ctx.
+func (s *introspection) isVisible(ctx context.Context, f *graphql.Field) bool {
+	if s.showHidden != nil && s.showHidden(ctx) {
+		return true
+	}
+	return !f.Hidden && !isHidden(f.Type)
+}
+
+// isHidden returns true if typ is, or is a list of, a hidden object.
+func isHidden(typ graphql.Type) bool {
+	switch typ := typ.(type) {
+	case *graphql.Object:
+		return typ.Hidden
+	case *graphql.List:
+		return isHidden(typ.Type)
+	case *graphql.NonNull:
+		return isHidden(typ.Type)
+	default:
+		return false
+	}
 }
 
 type DirectiveLocation string
@@ -46,6 +83,16 @@ type InputValue struct {
 	DefaultValue *string
 }
 
+// defaultValue returns the default of the input value name in defaults, or
+// nil if it has none.
+func defaultValue(defaults map[string]string, name string) *string {
+	value, ok := defaults[name]
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
 func (s *introspection) registerInputValue(schema *schemabuilder.Schema) {
 	schema.Object("__InputValue", InputValue{})
 }
@@ -95,8 +142,14 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		switch t.Inner.(type) {
 		case *graphql.Object:
 			return OBJECT
+		case *graphql.Interface:
+			return INTERFACE
+		case *graphql.Union:
+			return UNION
 		case *graphql.Scalar:
 			return SCALAR
+		case *graphql.Enum:
+			return ENUM
 		case *graphql.List:
 			return LIST
 		case *graphql.InputObject:
@@ -112,8 +165,14 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		switch t := t.Inner.(type) {
 		case *graphql.Object:
 			return t.Name
+		case *graphql.Interface:
+			return t.Name
+		case *graphql.Union:
+			return t.Name
 		case *graphql.Scalar:
 			return t.Type
+		case *graphql.Enum:
+			return t.Name
 		case *graphql.InputObject:
 			return t.Name
 		default:
@@ -125,13 +184,47 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		switch t := t.Inner.(type) {
 		case *graphql.Object:
 			return t.Description
+		case *graphql.Interface:
+			return t.Description
+		case *graphql.Union:
+			return t.Description
 		default:
 			return ""
 		}
 	})
 
-	object.FieldFunc("interfaces", func() []Type { return nil })
-	object.FieldFunc("possibleTypes", func() []Type { return nil })
+	object.FieldFunc("interfaces", func(t Type) []Type {
+		object, ok := t.Inner.(*graphql.Object)
+		if !ok {
+			return nil
+		}
+
+		var interfaces []Type
+		for _, typ := range s.types {
+			if iface, ok := typ.(*graphql.Interface); ok && iface.Types[object.Name] == object {
+				interfaces = append(interfaces, Type{Inner: iface})
+			}
+		}
+		sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Inner.String() < interfaces[j].Inner.String() })
+		return interfaces
+	})
+
+	object.FieldFunc("possibleTypes", func(t Type) []Type {
+		var objects map[string]*graphql.Object
+		switch t := t.Inner.(type) {
+		case *graphql.Interface:
+			objects = t.Types
+		case *graphql.Union:
+			objects = t.Types
+		}
+
+		var types []Type
+		for _, object := range objects {
+			types = append(types, Type{Inner: object})
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
+		return types
+	})
 
 	object.FieldFunc("inputFields", func(t Type) []InputValue {
 		var fields []InputValue
@@ -140,8 +233,9 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		case *graphql.InputObject:
 			for name, f := range t.InputFields {
 				fields = append(fields, InputValue{
-					Name: name,
-					Type: Type{Inner: f},
+					Name:         name,
+					Type:         Type{Inner: f},
+					DefaultValue: defaultValue(t.DefaultValues, name),
 				})
 			}
 		}
@@ -150,29 +244,45 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		return fields
 	})
 
-	object.FieldFunc("fields", func(t Type, args struct {
+	object.FieldFunc("fields", func(ctx context.Context, t Type, args struct {
 		IncludeDeprecated *bool
 	}) []field {
 		var fields []field
 
+		var objectFields map[string]*graphql.Field
 		switch t := t.Inner.(type) {
 		case *graphql.Object:
-			for name, f := range t.Fields {
-				var args []InputValue
-				for name, a := range f.Args {
-					args = append(args, InputValue{
-						Name: name,
-						Type: Type{Inner: a},
-					})
-				}
-				sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
-
-				fields = append(fields, field{
-					Name: name,
-					Type: Type{Inner: f.Type},
-					Args: args,
+			objectFields = t.Fields
+		case *graphql.Interface:
+			objectFields = t.Fields
+		}
+
+		includeDeprecated := args.IncludeDeprecated != nil && *args.IncludeDeprecated
+		for name, f := range objectFields {
+			if !s.isVisible(ctx, f) {
+				continue
+			}
+			if f.DeprecationReason != "" && !includeDeprecated {
+				continue
+			}
+
+			var args []InputValue
+			for name, a := range f.Args {
+				args = append(args, InputValue{
+					Name:         name,
+					Type:         Type{Inner: a},
+					DefaultValue: defaultValue(f.ArgDefaults, name),
 				})
 			}
+			sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+
+			fields = append(fields, field{
+				Name:              name,
+				Type:              Type{Inner: f.Type},
+				Args:              args,
+				IsDeprecated:      f.DeprecationReason != "",
+				DeprecationReason: f.DeprecationReason,
+			})
 		}
 		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
 
@@ -190,8 +300,17 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		}
 	})
 
-	object.FieldFunc("enumValues", func(args struct{ IncludeDeprecated *bool }) []EnumValue {
-		return nil
+	object.FieldFunc("enumValues", func(t Type, args struct{ IncludeDeprecated *bool }) []EnumValue {
+		enum, ok := t.Inner.(*graphql.Enum)
+		if !ok {
+			return nil
+		}
+
+		values := make([]EnumValue, 0, len(enum.Values))
+		for _, name := range enum.Values {
+			values = append(values, EnumValue{Name: name})
+		}
+		return values
 	})
 }
 
@@ -208,24 +327,56 @@ func (s *introspection) registerField(schema *schemabuilder.Schema) {
 	schema.Object("__Field", field{})
 }
 
-func collectTypes(typ graphql.Type, types map[string]graphql.Type) {
+// collectTypes adds typ and all types reachable from it to types. Hidden
+// objects and fields are skipped unless includeHidden is set.
+func collectTypes(typ graphql.Type, types map[string]graphql.Type, includeHidden bool) {
 	switch typ := typ.(type) {
 	case *graphql.Object:
+		if typ.Hidden && !includeHidden {
+			return
+		}
 		if _, ok := types[typ.Name]; ok {
 			return
 		}
 		types[typ.Name] = typ
 
 		for _, field := range typ.Fields {
-			collectTypes(field.Type, types)
+			if field.Hidden && !includeHidden {
+				continue
+			}
+
+			collectTypes(field.Type, types, includeHidden)
 
 			for _, arg := range field.Args {
-				collectTypes(arg, types)
+				collectTypes(arg, types, includeHidden)
 			}
 		}
 
+	case *graphql.Interface:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+
+		for _, field := range typ.Fields {
+			collectTypes(field.Type, types, includeHidden)
+		}
+		for _, object := range typ.Types {
+			collectTypes(object, types, includeHidden)
+		}
+
+	case *graphql.Union:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+
+		for _, object := range typ.Types {
+			collectTypes(object, types, includeHidden)
+		}
+
 	case *graphql.List:
-		collectTypes(typ.Type, types)
+		collectTypes(typ.Type, types, includeHidden)
 
 	case *graphql.Scalar:
 		if _, ok := types[typ.Type]; ok {
@@ -233,6 +384,12 @@ func collectTypes(typ graphql.Type, types map[string]graphql.Type) {
 		}
 		types[typ.Type] = typ
 
+	case *graphql.Enum:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+
 	case *graphql.InputObject:
 		if _, ok := types[typ.Name]; ok {
 			return
@@ -240,21 +397,21 @@ func collectTypes(typ graphql.Type, types map[string]graphql.Type) {
 		types[typ.Name] = typ
 
 		for _, field := range typ.InputFields {
-			collectTypes(field, types)
+			collectTypes(field, types, includeHidden)
 		}
 
 	case *graphql.NonNull:
-		collectTypes(typ.Type, types)
+		collectTypes(typ.Type, types, includeHidden)
 	}
 }
 
 func (s *introspection) registerQuery(schema *schemabuilder.Schema) {
 	object := schema.Query()
 
-	object.FieldFunc("__schema", func() *Schema {
+	object.FieldFunc("__schema", func(ctx context.Context) *Schema {
 		var types []Type
 
-		for _, typ := range s.types {
+		for _, typ := range s.typesFor(ctx) {
 			types = append(types, Type{Inner: typ})
 		}
 		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
@@ -266,8 +423,8 @@ func (s *introspection) registerQuery(schema *schemabuilder.Schema) {
 		}
 	})
 
-	object.FieldFunc("__type", func(args struct{ Name string }) *Type {
-		if typ, ok := s.types[args.Name]; ok {
+	object.FieldFunc("__type", func(ctx context.Context, args struct{ Name string }) *Type {
+		if typ, ok := s.typesFor(ctx)[args.Name]; ok {
 			return &Type{Inner: typ}
 		}
 		return nil
@@ -293,15 +450,31 @@ func (s *introspection) schema() *graphql.Schema {
 	return schema.MustBuild()
 }
 
+// AddIntrospectionToSchema adds the __schema and __type fields to schema.
+// Objects and fields hidden with schemabuilder.HideFromIntrospection are not
+// shown.
 func AddIntrospectionToSchema(schema *graphql.Schema) {
+	AddIntrospectionToSchemaWithFilter(schema, nil)
+}
+
+// AddIntrospectionToSchemaWithFilter is like AddIntrospectionToSchema, but
+// shows hidden objects and fields to clients for whose context showHidden
+// returns true, such as internal admin tools.
+func AddIntrospectionToSchemaWithFilter(schema *graphql.Schema, showHidden func(ctx context.Context) bool) {
 	types := make(map[string]graphql.Type)
-	collectTypes(schema.Query, types)
-	collectTypes(schema.Mutation, types)
+	collectTypes(schema.Query, types, true)
+	collectTypes(schema.Mutation, types, true)
+
+	visibleTypes := make(map[string]graphql.Type)
+	collectTypes(schema.Query, visibleTypes, false)
+	collectTypes(schema.Mutation, visibleTypes, false)
 
 	is := &introspection{
-		types:    types,
-		query:    schema.Query,
-		mutation: schema.Mutation,
+		types:        types,
+		query:        schema.Query,
+		mutation:     schema.Mutation,
+		visibleTypes: visibleTypes,
+		showHidden:   showHidden,
 	}
 	isSchema := is.schema()
 
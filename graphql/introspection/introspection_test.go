@@ -1,12 +1,15 @@
 package introspection_test
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"sort"
 	"testing"
 
+	"github.com/samsarahq/thunder/graphql"
 	"github.com/samsarahq/thunder/graphql/introspection"
 	"github.com/samsarahq/thunder/graphql/schemabuilder"
 )
@@ -79,3 +82,290 @@ func TestComputeSchemaJSON(t *testing.T) {
 		t.Errorf("schema JSONs do not match:\n---expected---\n%+v\n---actual---\n%+v", expected, actual)
 	}
 }
+
+type adminKey struct{}
+
+type AdminStats struct {
+	Users int64
+}
+
+func TestHideFromIntrospection(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("me", func() User {
+		return User{Name: "me"}
+	})
+	query.FieldFunc("secret", func() string {
+		return "secret"
+	}, schemabuilder.HideFromIntrospection)
+	query.FieldFunc("stats", func() AdminStats {
+		return AdminStats{Users: 1}
+	})
+	schemaBuilderSchema.Object("AdminStats", AdminStats{}).HideFromIntrospection()
+	schemaBuilderSchema.Mutation()
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchemaWithFilter(schema, func(ctx context.Context) bool {
+		return ctx.Value(adminKey{}) != nil
+	})
+
+	run := func(ctx context.Context, source string) map[string]interface{} {
+		query, err := graphql.Parse(source, map[string]interface{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := graphql.PrepareQuery(schema.Query, query.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		value, err := e.Execute(ctx, schema.Query, nil, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value.(map[string]interface{})
+	}
+
+	introspect := func(ctx context.Context) (types []string, fields []string) {
+		value := run(ctx, `{ __schema { types { name } } __type(name: "Query") { fields { name } } }`)
+		for _, typ := range value["__schema"].(map[string]interface{})["types"].([]interface{}) {
+			types = append(types, typ.(map[string]interface{})["name"].(string))
+		}
+		for _, field := range value["__type"].(map[string]interface{})["fields"].([]interface{}) {
+			fields = append(fields, field.(map[string]interface{})["name"].(string))
+		}
+		sort.Strings(types)
+		return types, fields
+	}
+
+	types, fields := introspect(context.Background())
+	if !reflect.DeepEqual(types, []string{"Mutation", "Query", "User", "int64", "string"}) {
+		t.Errorf("unexpected visible types %v", types)
+	}
+	if !reflect.DeepEqual(fields, []string{"me"}) {
+		t.Errorf("unexpected visible fields %v", fields)
+	}
+
+	types, fields = introspect(context.WithValue(context.Background(), adminKey{}, true))
+	if !reflect.DeepEqual(types, []string{"AdminStats", "Mutation", "Query", "User", "int64", "string"}) {
+		t.Errorf("unexpected admin types %v", types)
+	}
+	if !reflect.DeepEqual(fields, []string{"me", "secret", "stats"}) {
+		t.Errorf("unexpected admin fields %v", fields)
+	}
+
+	// Hidden fields can still be queried directly.
+	value := run(context.Background(), `{ secret stats { users } }`)
+	if value["secret"] != "secret" {
+		t.Errorf("expected hidden field to be queryable, got %v", value)
+	}
+}
+
+type Node interface {
+	isNode()
+}
+
+func (User) isNode() {}
+
+func TestInterfaceIntrospection(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	schemaBuilderSchema.Interface("Node", (*Node)(nil), User{})
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("node", func() Node {
+		return User{Name: "me"}
+	})
+	schemaBuilderSchema.Mutation()
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q, err := graphql.Parse(`{
+		node: __type(name: "Node") { kind fields { name } possibleTypes { name } }
+		user: __type(name: "User") { interfaces { name } }
+	}`, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"node": map[string]interface{}{
+			"kind": "INTERFACE",
+			"fields": []interface{}{
+				map[string]interface{}{"name": "maybeAge"},
+				map[string]interface{}{"name": "name"},
+			},
+			"possibleTypes": []interface{}{
+				map[string]interface{}{"name": "User"},
+			},
+		},
+		"user": map[string]interface{}{
+			"interfaces": []interface{}{
+				map[string]interface{}{"name": "Node"},
+			},
+		},
+	}
+	var actual map[string]interface{}
+	bytes, _ := json.Marshal(value)
+	json.Unmarshal(bytes, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected introspection %v", actual)
+	}
+}
+
+type Color string
+
+func TestEnumIntrospection(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	schemaBuilderSchema.Enum("Color", map[string]interface{}{
+		"RED":  Color("red"),
+		"BLUE": Color("blue"),
+	})
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("color", func() Color {
+		return "red"
+	})
+	schemaBuilderSchema.Mutation()
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q, err := graphql.Parse(`{
+		__type(name: "Color") { kind name enumValues { name } }
+	}`, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"__type": map[string]interface{}{
+			"kind": "ENUM",
+			"name": "Color",
+			"enumValues": []interface{}{
+				map[string]interface{}{"name": "BLUE"},
+				map[string]interface{}{"name": "RED"},
+			},
+		},
+	}
+	var actual map[string]interface{}
+	bytes, _ := json.Marshal(value)
+	json.Unmarshal(bytes, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected introspection %v", actual)
+	}
+}
+
+func TestDefaultValueIntrospection(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("users", func(args struct {
+		Limit  int64 `graphql:",default=10"`
+		Offset int64
+	}) []User {
+		return nil
+	})
+	schemaBuilderSchema.Mutation()
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q, err := graphql.Parse(`{
+		__type(name: "Query") { fields { name args { name defaultValue type { kind } } } }
+	}`, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"__type": map[string]interface{}{
+			"fields": []interface{}{
+				map[string]interface{}{
+					"name": "users",
+					"args": []interface{}{
+						map[string]interface{}{"name": "limit", "defaultValue": "10", "type": map[string]interface{}{"kind": "SCALAR"}},
+						map[string]interface{}{"name": "offset", "defaultValue": nil, "type": map[string]interface{}{"kind": "NON_NULL"}},
+					},
+				},
+			},
+		},
+	}
+	var actual map[string]interface{}
+	bytes, _ := json.Marshal(value)
+	json.Unmarshal(bytes, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected introspection %v", actual)
+	}
+}
+
+func TestDeprecatedFieldIntrospection(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("name", func() string {
+		return ""
+	})
+	query.FieldFunc("fullName", func() string {
+		return ""
+	}, schemabuilder.Deprecated("use name instead"))
+	schemaBuilderSchema.Mutation()
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q, err := graphql.Parse(`{
+		all: __type(name: "Query") { fields(includeDeprecated: true) { name isDeprecated deprecationReason } }
+		current: __type(name: "Query") { fields { name } }
+	}`, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"all": map[string]interface{}{
+			"fields": []interface{}{
+				map[string]interface{}{"name": "fullName", "isDeprecated": true, "deprecationReason": "use name instead"},
+				map[string]interface{}{"name": "name", "isDeprecated": false, "deprecationReason": ""},
+			},
+		},
+		"current": map[string]interface{}{
+			"fields": []interface{}{
+				map[string]interface{}{"name": "name"},
+			},
+		},
+	}
+	var actual map[string]interface{}
+	bytes, _ := json.Marshal(value)
+	json.Unmarshal(bytes, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected introspection %v", actual)
+	}
+}
@@ -0,0 +1,171 @@
+package graphql
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultPingInterval is the default interval between websocket pings.
+	DefaultPingInterval = 30 * time.Second
+	// DefaultPongTimeout is the default time to wait for a pong before
+	// considering a connection dead.
+	DefaultPongTimeout = 60 * time.Second
+
+	pingWriteTimeout = 10 * time.Second
+)
+
+// pingSocket is implemented by sockets that support websocket ping and pong
+// control frames, such as *websocket.Conn.
+type pingSocket interface {
+	SetReadDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+}
+
+// unwrapSocket returns the socket underlying any adapters, so that optional
+// capabilities of the original socket can be detected.
+func unwrapSocket(socket JSONSocket) JSONSocket {
+	switch s := socket.(type) {
+	case *graphqlWSSocket:
+		return unwrapSocket(s.socket)
+	case *resumableSocket:
+		if current := s.current(); current != nil {
+			return unwrapSocket(current)
+		}
+	}
+	return socket
+}
+
+// SetKeepAlive configures websocket keepalive. Every pingInterval a ping is
+// sent, and the connection is closed if no pong arrives within pongTimeout.
+// A zero pingInterval disables keepalive.
+//
+// Keepalive only works for sockets that support control frames, such as
+// *websocket.Conn; it is ignored for other sockets.
+func (c *conn) SetKeepAlive(pingInterval, pongTimeout time.Duration) {
+	c.pingInterval = pingInterval
+	c.pongTimeout = pongTimeout
+}
+
+// keepAlive sends pings on socket until the returned stop function is called.
+// A missing pong causes the pending ReadJSON to fail, which in turn closes the
+// connection and its subscriptions.
+func (c *conn) keepAlive(socket pingSocket) (stop func()) {
+	socket.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	socket.SetPongHandler(func(string) error {
+		return socket.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := socket.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingWriteTimeout)); err != nil {
+					if !isCloseError(err) {
+						log.Println("socket.WriteControl:", err)
+					}
+					c.closeWithReason(websocket.CloseGoingAway, "keepalive failed")
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// SetSubscriptionKeepAlive makes every subscription send a "ka" message with
+// its id whenever it has sent no update for interval, so that clients can
+// tell a subscription without changes from one that silently died. Unlike
+// websocket keepalive, it is per subscription and at the application level.
+// A zero interval, the default, disables it.
+func (c *conn) SetSubscriptionKeepAlive(interval time.Duration) {
+	c.subscriptionKeepAlive = interval
+}
+
+// A subscriptionKeepAlive sends "ka" messages for a subscription until it is
+// stopped. A nil *subscriptionKeepAlive does nothing.
+type subscriptionKeepAlive struct {
+	c        *conn
+	id       string
+	interval time.Duration
+	once     sync.Once
+	reset    chan struct{}
+	done     chan struct{}
+}
+
+// newSubscriptionKeepAlive returns the keepalive for subscription id, if
+// enabled. It only starts sending "ka" messages once the first update is
+// sent, so that a slow initial execution does not send "ka" messages before
+// the subscription has any result.
+func (c *conn) newSubscriptionKeepAlive(id string) *subscriptionKeepAlive {
+	interval := c.subscriptionKeepAlive
+	if interval <= 0 {
+		return nil
+	}
+
+	return &subscriptionKeepAlive{
+		c:        c,
+		id:       id,
+		interval: interval,
+		reset:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// run sends "ka" messages whenever no update was sent for the interval.
+func (k *subscriptionKeepAlive) run() {
+	timer := time.NewTimer(k.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-k.done:
+			return
+		case <-k.reset:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+			k.c.writeOrClose(OutEnvelope{ID: k.id, Type: "ka"})
+		}
+		timer.Reset(k.interval)
+	}
+}
+
+// touch restarts the interval after an update was sent, starting the
+// keepalive after the first update.
+func (k *subscriptionKeepAlive) touch() {
+	if k == nil {
+		return
+	}
+	started := false
+	k.once.Do(func() {
+		started = true
+		go k.run()
+	})
+	if started {
+		return
+	}
+	select {
+	case k.reset <- struct{}{}:
+	default:
+	}
+}
+
+// stop stops sending "ka" messages.
+func (k *subscriptionKeepAlive) stop() {
+	if k != nil {
+		close(k.done)
+	}
+}
@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pingFakeSocket is a JSONSocket supporting pings that never receives any
+// messages. It answers the first pongs pings with a pong.
+type pingFakeSocket struct {
+	mu          sync.Mutex
+	deadline    time.Time
+	pongHandler func(string) error
+	pings       int
+	pongs       int
+}
+
+func (s *pingFakeSocket) ReadJSON(value interface{}) error {
+	for {
+		s.mu.Lock()
+		expired := !s.deadline.IsZero() && time.Now().After(s.deadline)
+		s.mu.Unlock()
+		if expired {
+			return errors.New("i/o timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *pingFakeSocket) WriteJSON(value interface{}) error { return nil }
+func (s *pingFakeSocket) Close() error                      { return nil }
+
+func (s *pingFakeSocket) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = t
+	return nil
+}
+
+func (s *pingFakeSocket) SetPongHandler(h func(string) error) {
+	s.pongHandler = h
+}
+
+func (s *pingFakeSocket) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	s.mu.Lock()
+	s.pings++
+	pong := s.pings <= s.pongs
+	s.mu.Unlock()
+
+	if pong {
+		return s.pongHandler("")
+	}
+	return nil
+}
+
+func TestKeepAliveClosesDeadConnection(t *testing.T) {
+	socket := &pingFakeSocket{pongs: 5}
+	c := CreateJSONSocket(context.Background(), socket, &Schema{}, nil, nil)
+	c.SetKeepAlive(10*time.Millisecond, 30*time.Millisecond)
+
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		c.ServeJSONSocket()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected connection to close without pongs")
+	}
+
+	// The connection should have stayed alive while pongs were arriving.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("connection closed after %v, before pongs stopped", elapsed)
+	}
+
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.pings <= socket.pongs {
+		t.Errorf("expected more than %d pings, got %d", socket.pongs, socket.pings)
+	}
+}
@@ -0,0 +1,49 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestSimpleLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := graphql.NewSimpleLogger(log.New(&buf, "", 0))
+	logger.Error(context.Background(), errors.New("boom"), map[string]string{"id": "1"})
+
+	if expected := "error:map[id:1]\nboom\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := graphql.NewJSONLogger(&buf)
+	logger.Error(context.Background(), errors.New("boom"), map[string]string{"id": "1"})
+	logger.Error(context.Background(), errors.New("bang"), nil)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entry["time"].(string); !ok {
+		t.Errorf("expected time in %s", lines[0])
+	}
+	delete(entry, "time")
+	expected := map[string]interface{}{"error": "boom", "tags": map[string]interface{}{"id": "1"}}
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("expected %v, got %v", expected, entry)
+	}
+}
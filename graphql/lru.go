@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a concurrency-safe cache holding up to size entries, evicting
+// the least recently used entry when full. If maxCost is positive, entries are
+// also evicted until the total cost of the entries is at most maxCost.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	maxCost int
+	cost    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+	cost  int
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).value, true
+}
+
+func (c *lruCache) put(key string, value interface{}) {
+	c.putCost(key, value, 0)
+}
+
+// putCost is like put, but counts cost towards the maxCost of the cache.
+// Entries costing more than maxCost are not cached.
+func (c *lruCache) putCost(key string, value interface{}, cost int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxCost > 0 && cost > c.maxCost {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		c.cost += cost - entry.cost
+		entry.value = value
+		entry.cost = cost
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, cost: cost})
+		c.cost += cost
+	}
+
+	for c.order.Len() > c.size || (c.maxCost > 0 && c.cost > c.maxCost) {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*lruEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.cost -= entry.cost
+	}
+}
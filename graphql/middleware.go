@@ -4,6 +4,13 @@ import (
 	"context"
 )
 
+// ComputationInput describes a single execution of a subscription, mutation,
+// or HTTP query.
+//
+// ParsedQuery is the query that is executed once all middlewares have called
+// next. A middleware may replace it, for example to remove selections a user
+// may not see. The replacement must only contain selections from the original
+// query, which has been validated against the schema.
 type ComputationInput struct {
 	Id          string
 	Query       string
@@ -13,13 +20,28 @@ type ComputationInput struct {
 	Previous    interface{}
 }
 
+// ComputationOutput holds the result of an execution.
+//
+// With partial results, FieldErrors holds the errors of the fields that
+// failed, which are null in Current.
 type ComputationOutput struct {
-	Metadata map[string]interface{}
-	Current  interface{}
-	Error    error
+	Metadata    map[string]interface{}
+	Current     interface{}
+	Error       error
+	FieldErrors []error
 }
 
+// MiddlewareFunc wraps executions. A middleware calls next to continue the
+// execution, and may inspect or modify input beforehand and the output
+// afterwards.
+//
+// To abort an execution, a middleware returns an output with Error set
+// without calling next. The query is then not executed, and the error is sent
+// to the client like any other execution error; use NewSafeError or
+// NewClientError for errors the client may see.
 type MiddlewareFunc func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput
+
+// MiddlewareNextFunc runs the remaining middlewares and the execution.
 type MiddlewareNextFunc func(input *ComputationInput) *ComputationOutput
 
 func runMiddlewares(middlewares []MiddlewareFunc, input *ComputationInput) *ComputationOutput {
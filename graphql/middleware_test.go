@@ -0,0 +1,102 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+func TestMiddlewareAbortsExecution(t *testing.T) {
+	executed := 0
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("value", func() int64 {
+		executed++
+		return 1
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("value", func() int64 {
+		executed++
+		return 1
+	})
+	builtSchema := schema.MustBuild()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocketWithMutationSchema(context.Background(), socket, builtSchema, builtSchema, makeCtx, nopLogger{})
+	c.Use(func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		return &graphql.ComputationOutput{Error: graphql.NewSafeError("denied")}
+	})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "denied", "errors": [{"message": "denied"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { value }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "denied", "errors": [{"message": "denied"}]}`)
+
+	if executed != 0 {
+		t.Errorf("expected aborted executions not to run, ran %d", executed)
+	}
+}
+
+// stripSecret is a middleware that removes the secret field from queries.
+func stripSecret(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+	stripped := &graphql.SelectionSet{}
+	for _, selection := range input.ParsedQuery.Selections {
+		if selection.Name != "secret" {
+			stripped.Selections = append(stripped.Selections, selection)
+		}
+	}
+	input.ParsedQuery = &graphql.Query{
+		Name:         input.ParsedQuery.Name,
+		Kind:         input.ParsedQuery.Kind,
+		SelectionSet: stripped,
+	}
+	return next(input)
+}
+
+func TestMiddlewareRewritesQuery(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("public", func() string { return "public" })
+	query.FieldFunc("secret", func() string { return "secret" })
+	mutation := schema.Mutation()
+	mutation.FieldFunc("public", func() string { return "public" })
+	mutation.FieldFunc("secret", func() string { return "secret" })
+	builtSchema := schema.MustBuild()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocketWithMutationSchema(context.Background(), socket, builtSchema, builtSchema, makeCtx, nopLogger{})
+	c.Use(stripSecret)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ public secret }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"public": "public"}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { public secret }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "result", "message": [{"public": "public"}], "metadata": {"diffVersion": 1}}`)
+}
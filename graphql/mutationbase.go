@@ -0,0 +1,44 @@
+package graphql
+
+// MaxMutationBases is the number of recent mutation results kept on a
+// connection to serve as bases for later mutation results.
+const MaxMutationBases = 16
+
+// BaseVersionKey is the metadata key set on a mutation result that is a diff
+// against the result of an earlier mutation, rather than a diff against
+// nothing. It holds the id of the earlier mutation.
+const BaseVersionKey = "baseVersion"
+
+// mutationBases holds the results of the most recent mutations on a
+// connection, by id. A mutation whose message sets baseVersion to the id of
+// one of these receives its result as a diff against that result, so that
+// clients applying optimistic responses only receive the fields that differ
+// from what they already have.
+type mutationBases struct {
+	results map[string]interface{}
+	// order holds the ids of results from oldest to newest.
+	order []string
+}
+
+// get returns the result of mutation id, if it is still kept.
+func (b *mutationBases) get(id string) (interface{}, bool) {
+	result, ok := b.results[id]
+	return result, ok
+}
+
+// put keeps the result of mutation id, dropping the oldest result if more
+// than MaxMutationBases are kept.
+func (b *mutationBases) put(id string, result interface{}) {
+	if b.results == nil {
+		b.results = make(map[string]interface{})
+	}
+	if _, ok := b.results[id]; !ok {
+		b.order = append(b.order, id)
+	}
+	b.results[id] = result
+
+	if len(b.order) > MaxMutationBases {
+		delete(b.results, b.order[0])
+		b.order = b.order[1:]
+	}
+}
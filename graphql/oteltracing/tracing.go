@@ -0,0 +1,85 @@
+// Package oteltracing traces graphql executions with OpenTelemetry.
+//
+// Tracing is implemented as middleware rather than as a GraphqlLogger, since
+// a GraphqlLogger cannot pass a span's context on to the execution it
+// observes, and so cannot parent field spans to the execution span.
+//
+// To trace executions and field resolution, install both middlewares:
+//
+//	schema.UseFieldMiddleware(oteltracing.FieldMiddleware(tracer))
+//	...
+//	conn.Use(oteltracing.Middleware(tracer))
+//
+// Execution spans are children of any span in the context passed to the
+// middleware, such as a span started in a connection's makeCtx.
+package oteltracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+// Attribute keys set on spans.
+const (
+	OperationNameKey = attribute.Key("graphql.operation.name")
+	OperationTypeKey = attribute.Key("graphql.operation.type")
+	FieldTypeKey     = attribute.Key("graphql.field.type")
+	FieldNameKey     = attribute.Key("graphql.field.name")
+)
+
+// Middleware returns a graphql.MiddlewareFunc that wraps every execution in a
+// span named after the operation. Errors returned by the execution are
+// recorded on the span.
+func Middleware(tracer trace.Tracer) graphql.MiddlewareFunc {
+	return func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		name, kind := "", "query"
+		if input.ParsedQuery != nil {
+			name, kind = input.ParsedQuery.Name, input.ParsedQuery.Kind
+		}
+
+		spanName := "graphql." + kind
+		if name != "" {
+			spanName += " " + name
+		}
+
+		ctx, span := tracer.Start(input.Ctx, spanName, trace.WithAttributes(
+			OperationNameKey.String(name),
+			OperationTypeKey.String(kind),
+		))
+		defer span.End()
+
+		input.Ctx = ctx
+		output := next(input)
+		if output.Error != nil {
+			span.RecordError(output.Error)
+			span.SetStatus(codes.Error, output.Error.Error())
+		}
+		return output
+	}
+}
+
+// FieldMiddleware returns a schemabuilder.FieldMiddlewareFunc that wraps the
+// resolution of every field in a span named Type.Field. The span is a child
+// of the execution span started by Middleware.
+func FieldMiddleware(tracer trace.Tracer) schemabuilder.FieldMiddlewareFunc {
+	return func(ctx context.Context, info schemabuilder.FieldInfo, next func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+		ctx, span := tracer.Start(ctx, info.Type+"."+info.Field, trace.WithAttributes(
+			FieldTypeKey.String(info.Type),
+			FieldNameKey.String(info.Field),
+		))
+		defer span.End()
+
+		result, err := next(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,84 @@
+package oteltracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/oteltracing"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("ok", func() string {
+		return "ok"
+	})
+	query.FieldFunc("fail", func() (string, error) {
+		return "", errors.New("failed")
+	})
+	schema.UseFieldMiddleware(oteltracing.FieldMiddleware(tracer))
+	handler := graphql.HTTPHandler(schema.MustBuild(), oteltracing.Middleware(tracer))
+
+	// Execution stops at the first error, so ok and fail are queried
+	// separately to ensure both are resolved.
+	ctx, parent := tracer.Start(context.Background(), "request")
+	for _, body := range []string{
+		`{"query": "query good { ok }"}`,
+		`{"query": "query named { fail }"}`,
+	} {
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body)).WithContext(ctx)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	parent.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	execution, ok := spans["graphql.query named"]
+	if !ok {
+		t.Fatalf("missing execution span, got %v", spans)
+	}
+	if execution.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected execution span to be a child of the request span")
+	}
+	attributes := make(map[string]string)
+	for _, kv := range execution.Attributes() {
+		attributes[string(kv.Key)] = kv.Value.AsString()
+	}
+	if attributes["graphql.operation.name"] != "named" || attributes["graphql.operation.type"] != "query" {
+		t.Errorf("bad execution span attributes %v", attributes)
+	}
+	if execution.Status().Code != codes.Error {
+		t.Errorf("expected execution span to record error, got %v", execution.Status())
+	}
+
+	for field, execution := range map[string]string{"Query.ok": "graphql.query good", "Query.fail": "graphql.query named"} {
+		span, ok := spans[field]
+		if !ok {
+			t.Fatalf("missing field span %s", field)
+		}
+		if span.Parent().SpanID() != spans[execution].SpanContext().SpanID() {
+			t.Errorf("expected field span %s to be a child of the execution span", field)
+		}
+	}
+	if spans["Query.ok"].Status().Code == codes.Error {
+		t.Error("expected Query.ok to succeed")
+	}
+	if spans["Query.fail"].Status().Code != codes.Error {
+		t.Error("expected Query.fail to record error")
+	}
+}
@@ -33,6 +33,9 @@ func valueToJson(value ast.Value, vars map[string]interface{}) (interface{}, err
 		return value.Value, nil
 	case *ast.BooleanValue:
 		return value.Value, nil
+	case *ast.EnumValue:
+		// Enum values are passed as their names, like in JSON variables.
+		return value.Value, nil
 	case *ast.Variable:
 		actual, ok := vars[value.Name.Value]
 		if !ok {
@@ -86,9 +89,73 @@ func argsToJson(input []*ast.Argument, vars map[string]interface{}) (interface{}
 	return args, nil
 }
 
+// directives holds the evaluated directives of a selection.
+type directives struct {
+	// include is false if @skip or @include leave the selection out of the
+	// query.
+	include bool
+	// deferred is true if @defer defers a fragment.
+	deferred bool
+	// streamed is true if @stream streams a list field, whose first
+	// initialCount elements are sent initially.
+	streamed     bool
+	initialCount int
+}
+
+// parseDirectives evaluates the directives of a selection, which is a field
+// if field is true and a fragment otherwise. @defer is only supported on
+// fragments, and @stream only on fields. Other directives are not supported.
+func parseDirectives(input []*ast.Directive, vars map[string]interface{}, field bool) (directives, error) {
+	parsed := directives{include: true}
+	for _, directive := range input {
+		name := directive.Name.Value
+		switch {
+		case name == "defer" && field:
+			return directives{}, NewClientError(`directive "@defer" is only supported on fragments`)
+		case name == "stream" && !field:
+			return directives{}, NewClientError(`directive "@stream" is only supported on fields`)
+		case name != "skip" && name != "include" && name != "defer" && name != "stream":
+			return directives{}, NewClientError("directives not supported")
+		}
+
+		args, err := argsToJson(directive.Arguments, vars)
+		if err != nil {
+			return directives{}, err
+		}
+		arg, found := args.(map[string]interface{})["if"]
+		condition, ok := arg.(bool)
+		if (name == "defer" || name == "stream") && !found {
+			// The "if" argument of @defer and @stream is optional, and
+			// defaults to true.
+			condition, ok = true, true
+		}
+		if !ok {
+			return directives{}, NewClientError(`directive "@%s" requires a boolean "if" argument`, name)
+		}
+
+		switch {
+		case name == "skip" && condition, name == "include" && !condition:
+			parsed.include = false
+		case name == "defer" && condition:
+			parsed.deferred = true
+		case name == "stream" && condition:
+			parsed.streamed = true
+			if arg, found := args.(map[string]interface{})["initialCount"]; found {
+				count, ok := arg.(float64)
+				if !ok || count < 0 || count != float64(int(count)) {
+					return directives{}, NewClientError(`directive "@stream" requires a non-negative integer "initialCount" argument`)
+				}
+				parsed.initialCount = int(count)
+			}
+		}
+	}
+	return parsed, nil
+}
+
 // parseSelectionSet takes a grapqhl-go selection set and converts it to a
-// simplified *SelectionSet, bindings vars
-func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Fragment, vars map[string]interface{}) (*SelectionSet, error) {
+// simplified *SelectionSet, bindings vars. Fragments spreads left out by
+// @skip or @include are added to skipped.
+func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Fragment, vars map[string]interface{}, skipped map[*Fragment]bool) (*SelectionSet, error) {
 	if input == nil {
 		return nil, nil
 	}
@@ -103,8 +170,12 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 				alias = selection.Alias.Value
 			}
 
-			if len(selection.Directives) != 0 {
-				return nil, NewClientError("directives not supported")
+			directives, err := parseDirectives(selection.Directives, vars, true)
+			if err != nil {
+				return nil, err
+			}
+			if !directives.include {
+				continue
 			}
 
 			args, err := argsToJson(selection.Arguments, vars)
@@ -112,7 +183,7 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 				return nil, err
 			}
 
-			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars)
+			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars, skipped)
 			if err != nil {
 				return nil, err
 			}
@@ -122,30 +193,55 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 				Name:         selection.Name.Value,
 				Args:         args,
 				SelectionSet: selectionSet,
+				Streamed:     directives.streamed,
+				InitialCount: directives.initialCount,
 			})
 
 		case *ast.FragmentSpread:
 			name := selection.Name.Value
 
-			if len(selection.Directives) != 0 {
-				return nil, NewClientError("directives not supported")
-			}
-
 			fragment, found := globalFragments[name]
 			if !found {
 				return nil, NewClientError("unknown fragment")
 			}
 
+			directives, err := parseDirectives(selection.Directives, vars, false)
+			if err != nil {
+				return nil, err
+			}
+			if !directives.include {
+				skipped[fragment] = true
+				continue
+			}
+
+			if directives.deferred {
+				// Global fragments are shared by all their spreads, so a
+				// deferred spread wraps the fragment instead of marking it.
+				fragments = append(fragments, &Fragment{
+					SelectionSet: &SelectionSet{Fragments: []*Fragment{fragment}},
+					Deferred:     true,
+				})
+				continue
+			}
 			fragments = append(fragments, fragment)
 
 		case *ast.InlineFragment:
-			on := selection.TypeCondition.Name.Value
+			// Inline fragments may omit the type condition, in which case they
+			// apply to the enclosing type.
+			var on string
+			if selection.TypeCondition != nil {
+				on = selection.TypeCondition.Name.Value
+			}
 
-			if len(selection.Directives) != 0 {
-				return nil, NewClientError("directives not supported")
+			directives, err := parseDirectives(selection.Directives, vars, false)
+			if err != nil {
+				return nil, err
+			}
+			if !directives.include {
+				continue
 			}
 
-			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars)
+			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars, skipped)
 			if err != nil {
 				return nil, err
 			}
@@ -153,6 +249,7 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 			fragments = append(fragments, &Fragment{
 				On:           on,
 				SelectionSet: selectionSet,
+				Deferred:     directives.deferred,
 			})
 		}
 	}
@@ -173,8 +270,9 @@ const (
 )
 
 // detectCyclesAndUnusedFragments finds cycles in fragments that include
-// eachother as well as fragments that don't appear anywhere
-func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments map[string]*Fragment) error {
+// eachother as well as fragments that don't appear anywhere. Fragments in
+// skipped were spread but left out by a directive, and are not unused.
+func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments map[string]*Fragment, skipped map[*Fragment]bool) error {
 	state := make(map[*Fragment]visitState)
 
 	var visitFragment func(*Fragment) error
@@ -222,7 +320,7 @@ func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments
 	}
 
 	for _, fragment := range globalFragments {
-		if state[fragment] != visited {
+		if state[fragment] != visited && !skipped[fragment] {
 			return NewClientError("unused fragment")
 		}
 	}
@@ -333,12 +431,36 @@ type Query struct {
 // does not validate that the query is legal under a given schema, which
 // instead is done by PrepareQuery.
 func Parse(source string, vars map[string]interface{}) (*Query, error) {
+	return ParseOperation(source, vars, "")
+}
+
+// ParseOperation is like Parse, but supports documents holding several named
+// operations, selecting the operation named operationName. operationName may
+// be empty if the document holds a single operation.
+func ParseOperation(source string, vars map[string]interface{}, operationName string) (*Query, error) {
+	document, err := parseDocument(source)
+	if err != nil {
+		return nil, err
+	}
+	return parseQuery(document, vars, operationName)
+}
+
+// parseDocument parses source into a syntax tree.
+func parseDocument(source string) (*ast.Document, error) {
 	document, err := parser.Parse(parser.ParseParams{Source: source})
 	if err != nil {
 		return nil, NewClientError(err.Error())
 	}
+	return document, nil
+}
 
+// parseQuery converts the operation named operationName in a syntax tree into
+// a *Query, substituting vars. It does not modify document, which may be
+// shared between calls.
+func parseQuery(document *ast.Document, vars map[string]interface{}, operationName string) (*Query, error) {
 	var queryDefinition *ast.OperationDefinition
+	operationNames := make(map[string]bool)
+	numOperations := 0
 	fragmentDefinitions := make(map[string]*ast.FragmentDefinition)
 
 	for _, definition := range document.Definitions {
@@ -354,16 +476,30 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 			if definition.Operation != "query" && definition.Operation != "mutation" {
 				return nil, NewClientError("only support queries or mutations")
 			}
-			if queryDefinition != nil {
-				return nil, NewClientError("only support a single query")
+			numOperations++
+			var name string
+			if definition.Name != nil {
+				name = definition.Name.Value
+				if operationNames[name] {
+					return nil, NewClientError("duplicate operation %s", name)
+				}
+				operationNames[name] = true
+			}
+			if operationName == "" || name == operationName {
+				queryDefinition = definition
 			}
-			queryDefinition = definition
 
 		default:
 			return nil, NewClientError("unsupported definition")
 		}
 	}
 
+	if operationName != "" && queryDefinition == nil {
+		return nil, NewClientError("unknown operation %s", operationName)
+	}
+	if operationName == "" && numOperations > 1 {
+		return nil, NewClientError("must provide an operationName for a document with multiple operations")
+	}
 	if queryDefinition == nil {
 		return nil, NewClientError("must have a single query")
 	}
@@ -385,6 +521,12 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 	for _, variableDefinition := range queryDefinition.VariableDefinitions {
 		name := variableDefinition.Variable.Name.Value
 
+		if value, ok := vars[name]; ok && value != nil {
+			if err := validateVariable(variableDefinition, value); err != nil {
+				return rv, err
+			}
+		}
+
 		if _, ok := variableDefinition.Type.(*ast.NonNull); ok {
 			if variableDefinition.DefaultValue != nil {
 				return rv, NewClientError("required variable cannot provide a default value: $%s", name)
@@ -398,8 +540,9 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		}
 
 		if variableDefinition.DefaultValue != nil {
-			// Ignore default if the value exists.
-			if vars[name] != nil {
+			// Ignore default if the value was provided, even if it is an
+			// explicit null.
+			if _, ok := vars[name]; ok {
 				continue
 			}
 
@@ -433,20 +576,21 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		}
 	}
 
+	skipped := make(map[*Fragment]bool)
 	for name, fragment := range fragmentDefinitions {
-		selectionSet, err := parseSelectionSet(fragment.SelectionSet, globalFragments, vars)
+		selectionSet, err := parseSelectionSet(fragment.SelectionSet, globalFragments, vars, skipped)
 		if err != nil {
 			return rv, err
 		}
 		globalFragments[name].SelectionSet = selectionSet
 	}
 
-	selectionSet, err := parseSelectionSet(queryDefinition.SelectionSet, globalFragments, vars)
+	selectionSet, err := parseSelectionSet(queryDefinition.SelectionSet, globalFragments, vars, skipped)
 	if err != nil {
 		return rv, err
 	}
 
-	if err := detectCyclesAndUnusedFragments(selectionSet, globalFragments); err != nil {
+	if err := detectCyclesAndUnusedFragments(selectionSet, globalFragments, skipped); err != nil {
 		return rv, err
 	}
 
@@ -489,6 +633,12 @@ func MustParse(source string, vars map[string]interface{}) *Query {
 // Flatten does _not_ flatten out the inner queries, so the name above does not
 // get flattened out yet.
 func Flatten(selectionSet *SelectionSet) []*Selection {
+	return flatten(selectionSet, nil)
+}
+
+// flatten is like Flatten, but only includes fragments for which include
+// returns true. A nil include includes all fragments.
+func flatten(selectionSet *SelectionSet, include func(*Fragment) bool) []*Selection {
 	grouped := make(map[string][]*Selection)
 
 	state := make(map[*SelectionSet]visitState)
@@ -502,6 +652,9 @@ func Flatten(selectionSet *SelectionSet) []*Selection {
 			grouped[selection.Alias] = append(grouped[selection.Alias], selection)
 		}
 		for _, fragment := range selectionSet.Fragments {
+			if include != nil && !include(fragment) {
+				continue
+			}
 			visit(fragment.SelectionSet)
 		}
 
@@ -528,6 +681,8 @@ func Flatten(selectionSet *SelectionSet) []*Selection {
 			Alias:        selections[0].Alias,
 			Args:         selections[0].Args,
 			SelectionSet: merged,
+			Streamed:     selections[0].Streamed,
+			InitialCount: selections[0].InitialCount,
 		})
 	}
 
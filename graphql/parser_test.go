@@ -2,6 +2,7 @@ package graphql_test
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 
 	. "github.com/samsarahq/thunder/graphql"
@@ -177,7 +178,7 @@ func TestParseUnsupported(t *testing.T) {
 {
 	baz
 }`, map[string]interface{}{})
-	if err == nil || err.Error() != "only support a single query" {
+	if err == nil || err.Error() != "must provide an operationName for a document with multiple operations" {
 		t.Error("expected multiple queries to fail", err)
 	}
 
@@ -249,6 +250,64 @@ fragment foo on Foo {
 	}
 }
 
+func TestParseOperationName(t *testing.T) {
+	source := `
+query First {
+	a
+}
+
+mutation Second($x: int64!) {
+	b(x: $x)
+}`
+
+	query, err := ParseOperation(source, map[string]interface{}{"x": float64(1)}, "Second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != "Second" || query.Kind != "mutation" || query.SelectionSet.Selections[0].Name != "b" {
+		t.Errorf("expected operation Second, got %s %s", query.Kind, query.Name)
+	}
+
+	// Variables of operations that are not selected are not required.
+	query, err = ParseOperation(source, map[string]interface{}{}, "First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != "First" || query.SelectionSet.Selections[0].Name != "a" {
+		t.Errorf("expected operation First, got %s", query.Name)
+	}
+
+	_, err = ParseOperation(source, map[string]interface{}{}, "")
+	if err == nil || err.Error() != "must provide an operationName for a document with multiple operations" {
+		t.Error("expected missing operation name to fail", err)
+	}
+
+	_, err = ParseOperation(source, map[string]interface{}{}, "Third")
+	if err == nil || err.Error() != "unknown operation Third" {
+		t.Error("expected unknown operation name to fail", err)
+	}
+
+	_, err = ParseOperation(`
+query First {
+	a
+}
+
+query First {
+	b
+}`, map[string]interface{}{}, "First")
+	if err == nil || err.Error() != "duplicate operation First" {
+		t.Error("expected duplicate operation name to fail", err)
+	}
+
+	// A single operation may be selected by name, or without one.
+	if _, err := ParseOperation(`query First { a }`, map[string]interface{}{}, "First"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseOperation(`query First { a }`, map[string]interface{}{}, ""); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestParseVariableDefinitions(t *testing.T) {
 	// Expect required variables to be provided.
 	_, err := Parse(`
@@ -294,3 +353,220 @@ query Operation($x: int64 = 2) {
 		t.Errorf("expected 2, received %v", val)
 	}
 }
+
+func TestParseDefaultValuesOnlyReplaceOmittedVariables(t *testing.T) {
+	query, err := Parse(`
+query Operation($x: int64 = 2, $y: [int64] = [1, 2], $z: int64 = 3) {
+	field(x: $x, y: $y, z: $z)
+}	`, map[string]interface{}{"z": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := query.SelectionSet.Selections[0].Args.(map[string]interface{})
+	expected := map[string]interface{}{
+		"x": float64(2),
+		"y": []interface{}{float64(1), float64(2)},
+		"z": nil,
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected defaults for omitted variables only, but received %v", args)
+	}
+
+	// Defaults also apply when no variables are provided at all.
+	query, err = Parse(`
+query Operation($x: int64 = 2) {
+	field(x: $x)
+}	`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := query.SelectionSet.Selections[0].Args.(map[string]interface{})["x"]; val != float64(2) {
+		t.Errorf("expected 2, received %v", val)
+	}
+}
+
+func TestParseValidatesVariableTypes(t *testing.T) {
+	cases := []struct {
+		query string
+		vars  map[string]interface{}
+		err   string
+	}{
+		{`query Q($id: Int!) { field(id: $id) }`, map[string]interface{}{"id": "abc"}, "variable $id expected Int!, got string"},
+		{`query Q($id: int64) { field(id: $id) }`, map[string]interface{}{"id": 1.5}, "variable $id expected int64, got number"},
+		{`query Q($ids: [int64]) { field(ids: $ids) }`, map[string]interface{}{"ids": []interface{}{float64(1), "2"}}, "variable $ids expected [int64], got list"},
+		{`query Q($ids: [int64]) { field(ids: $ids) }`, map[string]interface{}{"ids": "2"}, "variable $ids expected [int64], got string"},
+		{`query Q($ids: [int64]) { field(ids: $ids) }`, map[string]interface{}{"ids": float64(1)}, ""},
+		{`query Q($ok: bool) { field(ok: $ok) }`, map[string]interface{}{"ok": "true"}, "variable $ok expected bool, got string"},
+		{`query Q($n: uint64) { field(n: $n) }`, map[string]interface{}{"n": float64(-1)}, "variable $n expected uint64, got number"},
+		{`query Q($n: uint64) { field(n: $n) }`, map[string]interface{}{"n": float64(0)}, ""},
+		{`query Q($id: Int!, $ids: [string], $id2: ID) { field(id: $id, ids: $ids, id2: $id2) }`, map[string]interface{}{"id": float64(1), "ids": []interface{}{"a"}, "id2": "x"}, ""},
+		{`query Q($filter: Filter) { field(filter: $filter) }`, map[string]interface{}{"filter": map[string]interface{}{"a": 1}}, ""},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.query, c.vars)
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %s", c.query, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: expected %q, got %v", c.query, c.err, err)
+		}
+	}
+}
+
+func TestParseFragments(t *testing.T) {
+	query, err := Parse(`
+{
+	user {
+		...UserFields
+		... on User { email }
+		... { phone }
+	}
+}
+fragment UserFields on User {
+	name
+	...MoreUserFields
+}
+fragment MoreUserFields on User {
+	age
+}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, selection := range Flatten(query.SelectionSet.Selections[0].SelectionSet) {
+		names = append(names, selection.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"age", "email", "name", "phone"}) {
+		t.Errorf("expected fragments to be expanded, got %v", names)
+	}
+
+	_, err = Parse(`
+{
+	...A
+}
+fragment A on Query {
+	...B
+}
+fragment B on Query {
+	...A
+}`, nil)
+	if err == nil || err.Error() != "fragment contains itself" {
+		t.Error("expected mutually recursive fragments to fail, but got", err)
+	}
+}
+
+func TestParseSkipAndInclude(t *testing.T) {
+	query, err := Parse(`
+query Q($yes: bool, $no: bool) {
+	a @skip(if: true)
+	b @skip(if: $no)
+	c @include(if: $yes)
+	d @include(if: false)
+	e @include(if: true) @skip(if: true)
+	...F @include(if: $no)
+	... on Query @skip(if: $no) {
+		g
+	}
+}
+fragment F on Query {
+	f
+}`, map[string]interface{}{"yes": true, "no": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, selection := range Flatten(query.SelectionSet) {
+		names = append(names, selection.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"b", "c", "g"}) {
+		t.Errorf("expected skipped selections to be pruned, got %v", names)
+	}
+
+	_, err = Parse(`{ a @skip(if: "yes") }`, nil)
+	if err == nil || err.Error() != `directive "@skip" requires a boolean "if" argument` {
+		t.Error("expected non-boolean condition to fail, but got", err)
+	}
+}
+
+func TestParseDefer(t *testing.T) {
+	query, err := Parse(`
+query Q($no: bool) {
+	a
+	...F @defer
+	... on Query @defer {
+		b
+	}
+	... @defer(if: $no) {
+		c
+	}
+}
+fragment F on Query {
+	f
+}`, map[string]interface{}{"no": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deferred []bool
+	for _, fragment := range query.SelectionSet.Fragments {
+		deferred = append(deferred, fragment.Deferred)
+	}
+	if !reflect.DeepEqual(deferred, []bool{true, true, false}) {
+		t.Errorf("expected fragments to be deferred, got %v", deferred)
+	}
+
+	var names []string
+	for _, selection := range Flatten(query.SelectionSet) {
+		names = append(names, selection.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"a", "b", "c", "f"}) {
+		t.Errorf("expected deferred selections to be kept, got %v", names)
+	}
+
+	_, err = Parse(`{ a @defer }`, nil)
+	if err == nil || err.Error() != `directive "@defer" is only supported on fragments` {
+		t.Error("expected deferred field to fail, but got", err)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	query, err := Parse(`
+query Q($no: bool) {
+	a @stream
+	b @stream(initialCount: 2)
+	c @stream(if: $no)
+}`, map[string]interface{}{"no": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := make(map[string][2]int)
+	for _, selection := range query.SelectionSet.Selections {
+		if selection.Streamed {
+			streamed[selection.Name] = [2]int{1, selection.InitialCount}
+		}
+	}
+	if !reflect.DeepEqual(streamed, map[string][2]int{"a": {1, 0}, "b": {1, 2}}) {
+		t.Errorf("expected fields to be streamed, got %v", streamed)
+	}
+
+	_, err = Parse(`{ ... @stream { a } }`, nil)
+	if err == nil || err.Error() != `directive "@stream" is only supported on fields` {
+		t.Error("expected streamed fragment to fail, but got", err)
+	}
+
+	_, err = Parse(`{ a @stream(initialCount: -1) }`, nil)
+	if err == nil || err.Error() != `directive "@stream" requires a non-negative integer "initialCount" argument` {
+		t.Error("expected negative initial count to fail, but got", err)
+	}
+}
@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// With partial results, a field that fails does not fail the whole query.
+// Instead, as in standard GraphQL, the nearest nullable field or list element
+// enclosing the failed field becomes null, and the error is reported with its
+// path alongside the rest of the result.
+//
+// The executor marks the locations of failed fields in the result with a
+// fieldError. Errors of expensive fields only surface when their thunks are
+// awaited, so the results of nullable fields that may hold thunks are wrapped
+// in a nullableResult, at which await stops errors. Execute finally replaces
+// the fieldErrors with null, and collects their errors.
+
+// fieldError marks a field or list element that became null because of err.
+// The path of err is relative to the location of the fieldError.
+type fieldError struct {
+	err error
+}
+
+// nullableResult wraps the result of a nullable field or list element, so
+// that errors awaiting the result null out the field instead of failing its
+// parent.
+type nullableResult struct {
+	value interface{}
+}
+
+// SetPartialResults configures whether failed fields only null out the nearest
+// nullable field enclosing them, instead of failing the whole query. The
+// errors of failed fields are then sent, with their paths, in the errors of
+// each update alongside the partial result.
+func (c *conn) SetPartialResults(enabled bool) {
+	c.partialResults = enabled
+}
+
+// isNullable returns true if a field of type typ may be null.
+func isNullable(typ Type) bool {
+	_, ok := typ.(*NonNull)
+	return !ok
+}
+
+// recoverable returns true if err may null out a single field. Cancellations
+// and timeouts fail the whole query.
+func recoverable(err error) bool {
+	inner := extractPathError(err)
+	return inner != context.Canceled && inner != context.DeadlineExceeded
+}
+
+// guardNullable wraps the result of a nullable field or list element in a
+// nullableResult if it may hold thunks.
+func guardNullable(value interface{}) interface{} {
+	switch value.(type) {
+	case *thunk, map[string]interface{}, []interface{}:
+		return &nullableResult{value: value}
+	default:
+		return value
+	}
+}
+
+// collectFieldErrors returns value with all fieldErrors replaced by null, and
+// the errors of the fieldErrors, nested under path. value is not modified,
+// as it may hold results cached across executions; containers holding
+// fieldErrors are copied instead.
+func collectFieldErrors(value interface{}, path []string) (interface{}, []error) {
+	switch value := value.(type) {
+	case fieldError:
+		err := value.err
+		for i := len(path) - 1; i >= 0; i-- {
+			err = nestPathError(path[i], err)
+		}
+		return nil, []error{err}
+
+	case map[string]interface{}:
+		var copied map[string]interface{}
+		var errs []error
+		for k, v := range value {
+			newV, fieldErrs := collectFieldErrors(v, append(path[:len(path):len(path)], k))
+			if len(fieldErrs) == 0 {
+				continue
+			}
+			if copied == nil {
+				copied = make(map[string]interface{}, len(value))
+				for k, v := range value {
+					copied[k] = v
+				}
+			}
+			copied[k] = newV
+			errs = append(errs, fieldErrs...)
+		}
+		if copied == nil {
+			return value, nil
+		}
+		return copied, errs
+
+	case []interface{}:
+		var copied []interface{}
+		var errs []error
+		for i, v := range value {
+			newV, fieldErrs := collectFieldErrors(v, append(path[:len(path):len(path)], fmt.Sprint(i)))
+			if len(fieldErrs) == 0 {
+				continue
+			}
+			if copied == nil {
+				copied = make([]interface{}, len(value))
+				copy(copied, value)
+			}
+			copied[i] = newV
+			errs = append(errs, fieldErrs...)
+		}
+		if copied == nil {
+			return value, nil
+		}
+		return copied, errs
+
+	default:
+		return value, nil
+	}
+}
+
+// logFieldErrors logs the errors of failed fields that are not safe to show
+// to clients.
+func (c *conn) logFieldErrors(ctx context.Context, errs []error, tags map[string]string) {
+	for _, err := range errs {
+		if _, ok := err.(SanitizedError); !ok {
+			c.logger.Error(ctx, err, tags)
+		}
+	}
+}
+
+// formatFieldErrors converts the errors of failed fields of query into
+// ResponseErrors.
+func formatFieldErrors(errs []error, query *Query) []ResponseError {
+	var formatted []ResponseError
+	for _, err := range errs {
+		formatted = append(formatted, formatError(err, query))
+	}
+	// Errors are collected in no particular order. Sort them by path, so
+	// that updates only carry new errors when they change.
+	sort.Slice(formatted, func(i, j int) bool {
+		return fmt.Sprint(formatted[i].Path) < fmt.Sprint(formatted[j].Path)
+	})
+	return formatted
+}
@@ -0,0 +1,130 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+)
+
+type partialUser struct {
+	Name string
+}
+
+func makePartialSchema() *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("user", func() *partialUser {
+		return &partialUser{Name: "bob"}
+	})
+	query.FieldFunc("expensiveUser", func(ctx context.Context) *partialUser {
+		return &partialUser{Name: "alice"}
+	})
+	query.FieldFunc("users", func() []*partialUser {
+		return []*partialUser{{Name: "a"}, {Name: "b"}}
+	})
+	query.FieldFunc("static", func() string {
+		return "static"
+	})
+	schema.Mutation()
+
+	user := schema.Object("User", partialUser{})
+	user.FieldFunc("fail", func() (*string, error) {
+		return nil, errors.New("nullable failed")
+	})
+	user.FieldFunc("failNonNull", func(u *partialUser) (string, error) {
+		if u.Name == "a" {
+			return "ok", nil
+		}
+		return "", errors.New("non-null failed")
+	})
+	return schema.MustBuild()
+}
+
+func TestPartialResults(t *testing.T) {
+	schema := makePartialSchema()
+
+	q := graphql.MustParse(`{
+		a: user { name fail }
+		b: user { name failNonNull }
+		c: expensiveUser { name failNonNull }
+		users { name }
+		static
+	}`, nil)
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{PartialResults: true}
+	result, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{
+		"a": {"name": "bob", "fail": null},
+		"b": null,
+		"c": null,
+		"users": [{"name": "a"}, {"name": "b"}],
+		"static": "static"
+	}`)) {
+		t.Errorf("bad result: %s", internal.MarshalJSON(result))
+	}
+
+	var messages []string
+	for _, err := range e.FieldErrors() {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+	expected := []string{
+		"a.fail: nullable failed",
+		"b.failNonNull: non-null failed",
+		"c.failNonNull: non-null failed",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected errors %v, got %v", expected, messages)
+	}
+
+	// Without partial results, the first error fails the whole query. Each
+	// execution uses its own Executor, as resolvers of a failed execution may
+	// still be running.
+	strict := graphql.Executor{}
+	if _, err := strict.Execute(context.Background(), schema.Query, nil, q); err == nil {
+		t.Error("expected error")
+	}
+
+	// Elements of slices are non-null, so errors in them fail the whole
+	// query, even with partial results.
+	q = graphql.MustParse(`{ users { failNonNull } static }`, nil)
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	partial := graphql.Executor{PartialResults: true}
+	if _, err := partial.Execute(context.Background(), schema.Query, nil, q); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestPartialResultsSubscription(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makePartialSchema(), makeCtx, nopLogger{})
+	c.SetPartialResults(true)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ user { name failNonNull } static }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"user": null, "static": "static"}],
+		"errors": [{"message": "Internal server error", "path": ["user", "failNonNull"]}],
+		"metadata": {"diffVersion": 1}}`)
+}
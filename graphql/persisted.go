@@ -0,0 +1,96 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ErrPersistedQueryNotFound is returned when a client sends the hash of a
+// query the server does not know. The client should resend the message with
+// the full query, which the server then stores under its hash.
+var ErrPersistedQueryNotFound = NewSafeError("PersistedQueryNotFound")
+
+// PersistedQueryStore holds queries by their SHA-256 hash for automatic
+// persisted queries. A PersistedQueryStore may be shared between connections
+// and must be safe for concurrent use.
+type PersistedQueryStore interface {
+	// Get returns the query stored under hash.
+	Get(hash string) (query string, ok bool)
+	// Put stores query under hash.
+	Put(hash string, query string)
+}
+
+// memoryPersistedQueryStore is a PersistedQueryStore holding the most
+// recently used queries in memory.
+type memoryPersistedQueryStore struct {
+	cache *lruCache
+}
+
+// NewMemoryPersistedQueryStore creates a PersistedQueryStore that holds up to
+// size queries in memory, evicting the least recently used.
+func NewMemoryPersistedQueryStore(size int) PersistedQueryStore {
+	return &memoryPersistedQueryStore{cache: newLRUCache(size)}
+}
+
+func (s *memoryPersistedQueryStore) Get(hash string) (string, bool) {
+	query, ok := s.cache.get(hash)
+	if !ok {
+		return "", false
+	}
+	return query.(string), true
+}
+
+func (s *memoryPersistedQueryStore) Put(hash string, query string) {
+	s.cache.put(hash, query)
+}
+
+// persistedQueryExtensions holds the persisted query extension sent by
+// Apollo clients.
+type persistedQueryExtensions struct {
+	PersistedQuery *struct {
+		Sha256Hash string `json:"sha256Hash"`
+	} `json:"persistedQuery"`
+}
+
+// persistedQueryHash returns the hash sent with a message, either directly
+// or in the Apollo persistedQuery extension.
+func persistedQueryHash(hash string, extensions *persistedQueryExtensions) string {
+	if hash == "" && extensions != nil && extensions.PersistedQuery != nil {
+		return extensions.PersistedQuery.Sha256Hash
+	}
+	return hash
+}
+
+// SetPersistedQueryStore enables automatic persisted queries on the
+// connection. Clients may then send a sha256Hash in place of a query; queries
+// sent along with their hash are stored in store.
+func (c *conn) SetPersistedQueryStore(store PersistedQueryStore) {
+	c.persistedQueries = store
+}
+
+// resolvePersistedQuery returns the query for a message carrying query and
+// hash. If hash is set and query is empty, the query is looked up in the
+// connection's store; if both are set, query is stored under hash.
+func (c *conn) resolvePersistedQuery(query string, hash string) (string, error) {
+	if hash == "" {
+		return query, nil
+	}
+	if c.persistedQueries == nil {
+		return "", NewSafeError("PersistedQueryNotSupported")
+	}
+
+	if query == "" {
+		query, ok := c.persistedQueries.Get(hash)
+		if !ok {
+			return "", ErrPersistedQueryNotFound
+		}
+		return query, nil
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return "", NewSafeError("provided sha256Hash does not match query")
+	}
+	c.persistedQueries.Put(hash, query)
+	return query, nil
+}
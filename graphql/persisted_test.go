@@ -0,0 +1,71 @@
+package graphql_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestPersistedQueries(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetPersistedQueryStore(graphql.NewMemoryPersistedQueryStore(10))
+	go c.ServeJSONSocket()
+
+	query := "{ items { name } }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"sha256Hash": hash},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "PersistedQueryNotFound", "errors": [{"message": "PersistedQueryNotFound"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { id } }", "sha256Hash": hash},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "provided sha256Hash does not match query", "errors": [{"message": "provided sha256Hash does not match query"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": query, "sha256Hash": hash},
+	}
+	socket.expect(t, `{"id": "3", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":   "4",
+		"type": "subscribe",
+		"message": map[string]interface{}{
+			"extensions": map[string]interface{}{
+				"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash},
+			},
+		},
+	}
+	socket.expect(t, `{"id": "4", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+}
+
+func TestPersistedQueriesNotSupported(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"sha256Hash": "abc"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "PersistedQueryNotSupported", "errors": [{"message": "PersistedQueryNotSupported"}]}`)
+}
@@ -0,0 +1,151 @@
+// Package promlogger implements a graphql.GraphqlLogger that exports
+// Prometheus metrics.
+//
+// Metrics are labeled with the queryName and queryType tags set on every
+// subscription and mutation. Register the Logger with Prometheus and serve
+// connections with it:
+//
+//	logger := promlogger.New(graphql.DefaultRegistry)
+//	prometheus.MustRegister(logger)
+//	http.Handle("/graphql", graphql.HandlerWithOptions(schema, graphql.HandlerOptions{
+//		Logger: logger,
+//	}))
+package promlogger
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+const namespace = "thunder_graphql"
+
+var labels = []string{"queryName", "queryType"}
+
+// Logger is a graphql.GraphqlLogger that records executions as Prometheus
+// metrics. It is also a prometheus.Collector exporting those metrics.
+type Logger struct {
+	started       *prometheus.CounterVec
+	finished      *prometheus.CounterVec
+	errors        *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	subscriptions prometheus.GaugeFunc
+	rerunners     prometheus.GaugeFunc
+	batchFlushes  *prometheus.CounterVec
+	batchSizes    *prometheus.HistogramVec
+}
+
+var _ graphql.GraphqlLogger = &Logger{}
+var _ graphql.ExecutionStatsLogger = &Logger{}
+var _ prometheus.Collector = &Logger{}
+
+// New creates a Logger. The active subscriptions gauge counts the
+// subscriptions open on connections in registry.
+func New(registry *graphql.Registry) *Logger {
+	return &Logger{
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "executions_started_total",
+			Help:      "Number of executions started.",
+		}, labels),
+		finished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "executions_finished_total",
+			Help:      "Number of executions finished.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "execution_errors_total",
+			Help:      "Number of executions that failed.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "execution_duration_seconds",
+			Help:      "Duration of executions.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		subscriptions: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_subscriptions",
+			Help:      "Number of open subscriptions.",
+		}, func() float64 {
+			return float64(registry.Subscriptions())
+		}),
+		rerunners: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "live_rerunners",
+			Help:      "Number of reactive Rerunners created and not yet stopped. If it drifts above active_subscriptions, Rerunners are leaking.",
+		}, func() float64 {
+			created, stopped := reactive.RerunnerCounts()
+			return float64(created - stopped)
+		}),
+		batchFlushes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "batch_flushes_total",
+			Help:      "Number of batched calls made through package batch.",
+		}, labels),
+		batchSizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size",
+			Help:      "Number of arguments of batched calls made through package batch.",
+			Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+		}, labels),
+	}
+}
+
+func labelValues(tags map[string]string) []string {
+	return []string{tags["queryName"], tags["queryType"]}
+}
+
+func (l *Logger) StartExecution(ctx context.Context, tags map[string]string, initial bool) {
+	l.started.WithLabelValues(labelValues(tags)...).Inc()
+}
+
+func (l *Logger) FinishExecution(ctx context.Context, tags map[string]string, delay time.Duration) {
+	values := labelValues(tags)
+	l.finished.WithLabelValues(values...).Inc()
+	l.duration.WithLabelValues(values...).Observe(delay.Seconds())
+}
+
+func (l *Logger) Error(ctx context.Context, err error, tags map[string]string) {
+	l.errors.WithLabelValues(labelValues(tags)...).Inc()
+}
+
+// ExecutionStats implements graphql.ExecutionStatsLogger, recording the
+// batched calls of every execution.
+func (l *Logger) ExecutionStats(ctx context.Context, tags map[string]string, stats graphql.ExecutionStats) {
+	values := labelValues(tags)
+	l.batchFlushes.WithLabelValues(values...).Add(float64(stats.BatchRoundTrips))
+	sizes := l.batchSizes.WithLabelValues(values...)
+	for _, size := range stats.BatchSizes {
+		sizes.Observe(float64(size))
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (l *Logger) Describe(ch chan<- *prometheus.Desc) {
+	l.started.Describe(ch)
+	l.finished.Describe(ch)
+	l.errors.Describe(ch)
+	l.duration.Describe(ch)
+	l.subscriptions.Describe(ch)
+	l.rerunners.Describe(ch)
+	l.batchFlushes.Describe(ch)
+	l.batchSizes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (l *Logger) Collect(ch chan<- prometheus.Metric) {
+	l.started.Collect(ch)
+	l.finished.Collect(ch)
+	l.errors.Collect(ch)
+	l.duration.Collect(ch)
+	l.subscriptions.Collect(ch)
+	l.rerunners.Collect(ch)
+	l.batchFlushes.Collect(ch)
+	l.batchSizes.Collect(ch)
+}
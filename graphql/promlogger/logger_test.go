@@ -0,0 +1,133 @@
+package promlogger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+type fakeSocket struct {
+	in  chan graphql.InEnvelope
+	out chan graphql.OutEnvelope
+}
+
+func (s *fakeSocket) ReadJSON(value interface{}) error {
+	envelope, ok := <-s.in
+	if !ok {
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	*value.(*graphql.InEnvelope) = envelope
+	return nil
+}
+
+func (s *fakeSocket) WriteJSON(value interface{}) error {
+	s.out <- value.(graphql.OutEnvelope)
+	return nil
+}
+
+func (s *fakeSocket) Close() error {
+	return nil
+}
+
+func (s *fakeSocket) expect(t *testing.T, typ string) {
+	select {
+	case out := <-s.out:
+		if out.Type != typ {
+			t.Fatalf("expected %s, got %s", typ, out.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s", typ)
+	}
+}
+
+func expectSubscriptions(t *testing.T, logger *Logger, expected float64) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		got := testutil.ToFloat64(logger.subscriptions)
+		if got == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v active subscriptions, got %v", expected, got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func subscribe(id, query string) graphql.InEnvelope {
+	message, _ := json.Marshal(map[string]interface{}{"query": query, "variables": map[string]interface{}{}})
+	return graphql.InEnvelope{ID: id, Type: "subscribe", Message: message}
+}
+
+func TestLogger(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("ok", func() string {
+		return "ok"
+	})
+	query.FieldFunc("fail", func() (string, error) {
+		return "", errors.New("failed")
+	})
+
+	registry := graphql.NewRegistry()
+	logger := New(registry)
+
+	socket := &fakeSocket{
+		in:  make(chan graphql.InEnvelope, 16),
+		out: make(chan graphql.OutEnvelope, 16),
+	}
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	conn := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, logger)
+	conn.SetRegistry(registry)
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	socket.in <- subscribe("1", "query good { ok }")
+	socket.expect(t, "update")
+	socket.in <- subscribe("2", "query bad { fail }")
+	socket.expect(t, "error")
+
+	// The failed subscription is closed asynchronously.
+	expectSubscriptions(t, logger, 1)
+
+	for _, c := range []struct {
+		name  string
+		count float64
+	}{
+		{"good", 1},
+		{"bad", 1},
+	} {
+		if got := testutil.ToFloat64(logger.started.WithLabelValues(c.name, "query")); got != c.count {
+			t.Errorf("expected %v %s executions started, got %v", c.count, c.name, got)
+		}
+	}
+	if got := testutil.ToFloat64(logger.errors.WithLabelValues("bad", "query")); got != 1 {
+		t.Errorf("expected 1 error, got %v", got)
+	}
+	if got := testutil.ToFloat64(logger.errors.WithLabelValues("good", "query")); got != 0 {
+		t.Errorf("expected no errors, got %v", got)
+	}
+
+	logger.ExecutionStats(context.Background(), map[string]string{"queryName": "good", "queryType": "query"}, graphql.ExecutionStats{
+		BatchRoundTrips: 2,
+		BatchSizes:      []int{3, 1},
+	})
+	if got := testutil.ToFloat64(logger.batchFlushes.WithLabelValues("good", "query")); got != 2 {
+		t.Errorf("expected 2 batch flushes, got %v", got)
+	}
+
+	close(socket.in)
+	<-done
+	expectSubscriptions(t, logger, 0)
+}
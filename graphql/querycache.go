@@ -0,0 +1,75 @@
+package graphql
+
+import "github.com/graphql-go/graphql/language/ast"
+
+const (
+	// DefaultQueryCacheSize is the number of queries held by
+	// DefaultQueryCache.
+	DefaultQueryCacheSize = 1000
+	// DefaultQueryCacheBytes is the total length of the queries held by
+	// DefaultQueryCache.
+	DefaultQueryCacheBytes = 4 << 20
+)
+
+// QueryCache caches the syntax trees of recently parsed queries, keyed by the
+// raw query string, so that many clients sending the same query only parse it
+// once. Only parsing is skipped: variables are substituted, and the resulting
+// query is prepared against the schema, on every Parse, as both the variables
+// and the schema may differ between clients. A QueryCache is safe for
+// concurrent use.
+type QueryCache struct {
+	documents *lruCache
+}
+
+// NewQueryCache creates a QueryCache holding up to size queries, evicting
+// the least recently used.
+func NewQueryCache(size int) *QueryCache {
+	return NewQueryCacheWithLimit(size, 0)
+}
+
+// NewQueryCacheWithLimit creates a QueryCache holding up to size queries
+// whose total length is at most maxBytes, evicting the least recently used.
+// Queries longer than maxBytes are not cached. A zero maxBytes only limits
+// the number of queries.
+func NewQueryCacheWithLimit(size int, maxBytes int) *QueryCache {
+	documents := newLRUCache(size)
+	documents.maxCost = maxBytes
+	return &QueryCache{documents: documents}
+}
+
+// DefaultQueryCache is shared by all connections, unless configured otherwise
+// with SetQueryCache.
+var DefaultQueryCache = NewQueryCacheWithLimit(DefaultQueryCacheSize, DefaultQueryCacheBytes)
+
+// Parse is like the package-level Parse, but reuses the syntax tree of source
+// if it is in the cache. Queries that fail to parse are not cached.
+func (c *QueryCache) Parse(source string, vars map[string]interface{}) (*Query, error) {
+	return c.ParseOperation(source, vars, "")
+}
+
+// ParseOperation is like the package-level ParseOperation, but reuses the
+// syntax tree of source if it is in the cache.
+func (c *QueryCache) ParseOperation(source string, vars map[string]interface{}, operationName string) (*Query, error) {
+	if c == nil {
+		return ParseOperation(source, vars, operationName)
+	}
+
+	var document *ast.Document
+	if cached, ok := c.documents.get(source); ok {
+		document = cached.(*ast.Document)
+	} else {
+		var err error
+		if document, err = parseDocument(source); err != nil {
+			return nil, err
+		}
+		// The syntax tree of a query grows with the length of its source.
+		c.documents.putCost(source, document, len(source))
+	}
+	return parseQuery(document, vars, operationName)
+}
+
+// SetQueryCache configures the cache used to parse queries on the connection.
+// A nil cache disables caching.
+func (c *conn) SetQueryCache(cache *QueryCache) {
+	c.queryCache = cache
+}
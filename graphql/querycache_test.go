@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryCache(t *testing.T) {
+	cache := NewQueryCache(1)
+	source := `query q($a: Int) { field(a: $a) }`
+
+	first, err := cache.Parse(source, map[string]interface{}{"a": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.documents.get(source); !ok {
+		t.Fatal("expected query to be cached")
+	}
+
+	// Variables are substituted on every parse of a cached query.
+	second, err := cache.Parse(source, map[string]interface{}{"a": float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(first, second) {
+		t.Error("expected variables to be substituted per parse")
+	}
+	expected, _ := Parse(source, map[string]interface{}{"a": float64(2)})
+	if !reflect.DeepEqual(second, expected) {
+		t.Errorf("expected cached parse to match Parse, got %v", second)
+	}
+
+	if _, err := cache.Parse("{", nil); err == nil {
+		t.Error("expected syntax error")
+	}
+	if _, ok := cache.documents.get("{"); ok {
+		t.Error("expected failed parse not to be cached")
+	}
+
+	cache.Parse("{ other }", nil)
+	if _, ok := cache.documents.get(source); ok {
+		t.Error("expected least recently used query to be evicted")
+	}
+}
+
+func TestQueryCacheMaxBytes(t *testing.T) {
+	cache := NewQueryCacheWithLimit(10, 20)
+
+	cache.Parse("{ a }", nil)
+	cache.Parse("{ b }", nil)
+	if _, ok := cache.documents.get("{ a }"); !ok {
+		t.Error("expected query within limit to be cached")
+	}
+
+	long := "{ aaaaaaaaaaaaaaaaaaaaaaaaa }"
+	if _, err := cache.Parse(long, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.documents.get(long); ok {
+		t.Error("expected query longer than limit not to be cached")
+	}
+
+	cache.Parse("{ ccccccccccc }", nil)
+	if _, ok := cache.documents.get("{ b }"); ok {
+		t.Error("expected least recently used query to be evicted over limit")
+	}
+	if _, ok := cache.documents.get("{ ccccccccccc }"); !ok {
+		t.Error("expected new query to be cached")
+	}
+}
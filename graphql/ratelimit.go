@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles mutations. A RateLimiter may be shared between
+// connections to enforce a global limit; ctx is the connection's context and
+// can be used to key limits by user.
+type RateLimiter interface {
+	// Allow returns true if a mutation may run now.
+	Allow(ctx context.Context) bool
+}
+
+// tokenBucket is a RateLimiter that allows bursts of up to burst mutations,
+// refilled at rate mutations per second.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a RateLimiter allowing rate mutations per second on
+// average, with bursts of up to burst mutations.
+func NewTokenBucket(rate float64, burst int) RateLimiter {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) Allow(ctx context.Context) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetMutationRateLimiter throttles mutations sent on the connection with
+// limiter. Mutations over the limit are rejected with a ClientError. A nil
+// limiter disables rate limiting.
+func (c *conn) SetMutationRateLimiter(limiter RateLimiter) {
+	c.mutationLimiter = limiter
+}
+
+// checkMutationRate returns a ClientError if the mutation rate limit has been
+// exceeded.
+func (c *conn) checkMutationRate() error {
+	if c.mutationLimiter != nil && !c.mutationLimiter.Allow(c.ctx) {
+		return NewClientError("too many mutations")
+	}
+	return nil
+}
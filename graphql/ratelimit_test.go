@@ -0,0 +1,44 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := graphql.NewTokenBucket(0, 2)
+	ctx := context.Background()
+
+	if !limiter.Allow(ctx) || !limiter.Allow(ctx) {
+		t.Error("expected burst to be allowed")
+	}
+	if limiter.Allow(ctx) {
+		t.Error("expected limit to be exceeded")
+	}
+}
+
+func TestMutationRateLimit(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetMutationRateLimiter(graphql.NewTokenBucket(0, 1))
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "a") }`},
+	}
+	socket.expect(t, `{"id": "1", "type": "result", "message": [{"echo": "a"}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "b") }`},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "too many mutations", "errors": [{"message": "too many mutations"}]}`)
+}
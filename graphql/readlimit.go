@@ -0,0 +1,29 @@
+package graphql
+
+// DefaultMaxMessageBytes is the default limit on the size of messages read
+// from a connection.
+const DefaultMaxMessageBytes = 1 << 20
+
+// readLimitSocket is implemented by sockets that can bound the size of
+// incoming messages, such as *websocket.Conn.
+type readLimitSocket interface {
+	SetReadLimit(limit int64)
+}
+
+// SetMaxMessageBytes limits the size of messages read from the connection, so
+// that clients cannot exhaust memory with huge queries. A connection that
+// receives a larger message is closed with a message too big close frame. A
+// zero limit disables the check.
+//
+// The limit only applies to sockets that support it, such as
+// *websocket.Conn; it is ignored for other sockets.
+func (c *conn) SetMaxMessageBytes(limit int64) {
+	c.maxMessageBytes = limit
+}
+
+// applyReadLimit sets the connection's read limit on its socket.
+func (c *conn) applyReadLimit() {
+	if socket, ok := unwrapSocket(c.socket).(readLimitSocket); ok && c.maxMessageBytes > 0 {
+		socket.SetReadLimit(c.maxMessageBytes)
+	}
+}
@@ -0,0 +1,72 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/internal"
+)
+
+// limitSocket is a fakeSocket that enforces a read limit like
+// *websocket.Conn.
+type limitSocket struct {
+	*fakeSocket
+	limit int64
+}
+
+func (s *limitSocket) SetReadLimit(limit int64) {
+	s.limit = limit
+}
+
+func (s *limitSocket) ReadJSON(value interface{}) error {
+	message, ok := <-s.in
+	if !ok {
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	data := internal.MarshalJSON(message)
+	if s.limit > 0 && int64(len(data)) > s.limit {
+		return websocket.ErrReadLimit
+	}
+	return json.Unmarshal([]byte(data), value)
+}
+
+func TestMaxMessageBytes(t *testing.T) {
+	socket := &limitSocket{fakeSocket: newFakeSocket()}
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetMaxMessageBytes(200)
+	done := make(chan struct{})
+	go func() {
+		c.ServeJSONSocket()
+		close(done)
+	}()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+	if socket.limit != 200 {
+		t.Errorf("expected read limit of 200, got %d", socket.limit)
+	}
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ " + strings.Repeat("items { name } ", 20) + "}"},
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected oversized message to close the connection")
+	}
+}
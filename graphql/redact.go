@@ -0,0 +1,54 @@
+package graphql
+
+// RedactedValue replaces the values of sensitive variables in logger tags.
+const RedactedValue = "[REDACTED]"
+
+// SetRedactedVariables marks variables as sensitive, such as passwords or
+// tokens, so that their values are replaced with RedactedValue in the
+// "queryVariables" tag passed to the connection's GraphqlLogger. Fields of
+// input object variables with these names are redacted as well, at any depth.
+// Execution still sees the real values.
+func (c *conn) SetRedactedVariables(names ...string) {
+	if len(names) == 0 {
+		c.redactedVariables = nil
+		return
+	}
+	c.redactedVariables = make(map[string]bool, len(names))
+	for _, name := range names {
+		c.redactedVariables[name] = true
+	}
+}
+
+// variablesTag returns the "queryVariables" tag for variables, with sensitive
+// variables redacted.
+func (c *conn) variablesTag(variables map[string]interface{}) string {
+	if len(c.redactedVariables) == 0 {
+		return mustMarshalJson(variables)
+	}
+	return mustMarshalJson(redact(variables, c.redactedVariables))
+}
+
+// redact returns a copy of value with the values of fields in names replaced
+// by RedactedValue. value itself is not modified.
+func redact(value interface{}, names map[string]bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if names[k] {
+				redacted[k] = RedactedValue
+			} else {
+				redacted[k] = redact(v, names)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, v := range value {
+			redacted[i] = redact(v, names)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,44 @@
+package graphql
+
+import "sync/atomic"
+
+// A SchemaHolder holds the schema served by connections, so that it can be
+// replaced without dropping live connections. A SchemaHolder is safe for
+// concurrent use.
+type SchemaHolder struct {
+	schema atomic.Value
+}
+
+// NewSchemaHolder creates a SchemaHolder holding schema.
+func NewSchemaHolder(schema *Schema) *SchemaHolder {
+	h := &SchemaHolder{}
+	h.schema.Store(schema)
+	return h
+}
+
+// Schema returns the current schema.
+func (h *SchemaHolder) Schema() *Schema {
+	return h.schema.Load().(*Schema)
+}
+
+// ReloadSchema replaces the current schema. Subscriptions and mutations
+// started afterwards use schema, while subscriptions already running keep
+// using the schema their query was prepared against until they end.
+func (h *SchemaHolder) ReloadSchema(schema *Schema) {
+	h.schema.Store(schema)
+}
+
+// SetSchemaHolder makes the connection serve the current schema of holder,
+// for both queries and mutations, instead of the schemas it was created with.
+func (c *conn) SetSchemaHolder(holder *SchemaHolder) {
+	c.schemaHolder = holder
+}
+
+// schemas returns the schemas for new subscriptions and mutations.
+func (c *conn) schemas() (schema, mutationSchema *Schema) {
+	if c.schemaHolder != nil {
+		schema := c.schemaHolder.Schema()
+		return schema, schema
+	}
+	return c.schema, c.mutationSchema
+}
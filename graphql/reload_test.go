@@ -0,0 +1,77 @@
+package graphql_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+func TestReloadSchema(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	makeSchema := func(field string) *graphql.Schema {
+		schema := schemabuilder.NewSchema()
+		query := schema.Query()
+		query.FieldFunc("count", func(ctx context.Context) int64 {
+			reactive.AddDependency(ctx, resource)
+			return atomic.AddInt64(&executions, 1)
+		})
+		query.FieldFunc(field, func() string {
+			return field
+		})
+		mutation := schema.Mutation()
+		mutation.FieldFunc("bump", func() bool {
+			resource.Strobe()
+			return true
+		})
+		return schema.MustBuild()
+	}
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	holder := graphql.NewSchemaHolder(makeSchema("old"))
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, nil, makeCtx, nopLogger{})
+	c.SetSchemaHolder(holder)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ count old }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"count": 1, "old": "old"}], "metadata": {"diffVersion": 1}}`)
+
+	holder.ReloadSchema(makeSchema("new"))
+
+	// The running subscription keeps using the schema it was prepared
+	// against when it reruns.
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { bump }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "result", "message": [{"bump": true}], "metadata": {"diffVersion": 1}}`)
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"count": 2}, "metadata": {"diffVersion": 1, "causedBy": ["2"]}}`)
+
+	// New subscriptions use the reloaded schema.
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ old }"},
+	}
+	socket.expect(t, `{"id": "3", "type": "error", "message": "unknown field \"old\"", "errors": [{"message": "unknown field \"old\""}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "4",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ new }"},
+	}
+	socket.expect(t, `{"id": "4", "type": "update", "message": [{"new": "new"}], "metadata": {"diffVersion": 1}}`)
+}
@@ -0,0 +1,71 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultRerunAllInterval is the minimum interval between calls to
+// RerunAllImmediately allowed by a new Registry.
+const DefaultRerunAllInterval = 10 * time.Second
+
+// ErrRerunAllRateLimited is returned by RerunAllImmediately when it is called
+// too often.
+var ErrRerunAllRateLimited = errors.New("rerun all rate limited")
+
+// SetRerunAllRateLimiter throttles RerunAllImmediately with limiter, instead of allowing
+// one call per DefaultRerunAllInterval. A nil limiter disables rate limiting.
+func (r *Registry) SetRerunAllRateLimiter(limiter RateLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rerunLimiter = limiter
+}
+
+// RerunAllImmediately recomputes every subscription of the live connections,
+// and of the connections awaiting resumption, whether or not any of its
+// dependencies were invalidated. Like a mutation does for the subscriptions
+// of its own connection, it removes the MinRerunInterval delay from the
+// reruns, which are spread across each connection's rerun jitter window. It
+// is meant for bulk changes that bypass invalidation, such as clearing a
+// backend cache.
+//
+// Rerunning all subscriptions at once is expensive, so calls are throttled by
+// the registry's rate limiter; calls over the limit return
+// ErrRerunAllRateLimited without rerunning anything. RerunAllImmediately is
+// safe to call concurrently.
+func (r *Registry) RerunAllImmediately(ctx context.Context) error {
+	r.mu.Lock()
+	limiter := r.rerunLimiter
+	r.mu.Unlock()
+	if limiter != nil && !limiter.Allow(ctx) {
+		return ErrRerunAllRateLimited
+	}
+
+	r.mu.Lock()
+	conns := make([]*conn, 0, len(r.conns)+len(r.parked))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	for _, c := range r.parked {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		c.invalidateSubscriptions()
+		c.rerunSubscriptions(nil)
+	}
+	return nil
+}
+
+// invalidateSubscriptions makes all subscriptions recompute from scratch on
+// their next rerun.
+func (c *conn) invalidateSubscriptions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, runner := range c.subscriptions {
+		runner.Invalidate()
+	}
+}
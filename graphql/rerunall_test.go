@@ -0,0 +1,68 @@
+package graphql_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+func TestRegistryRerunAllImmediately(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("count", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource)
+		return atomic.AddInt64(&executions, 1)
+	})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	registry := graphql.NewRegistry()
+	c := graphql.CreateJSONSocket(context.Background(), socket, builtSchema, makeCtx, nopLogger{})
+	c.SetRegistry(registry)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ count }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"count": 1}], "metadata": {"diffVersion": 1}}`)
+
+	// Without RerunAllImmediately, the subscription would only rerun after
+	// MinRerunInterval.
+	ctx := context.Background()
+	resource.Strobe()
+	if err := registry.RerunAllImmediately(ctx); err != nil {
+		t.Fatal(err)
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"count": 2}, "metadata": {"diffVersion": 1}}`)
+
+	if err := registry.RerunAllImmediately(ctx); err != graphql.ErrRerunAllRateLimited {
+		t.Errorf("expected ErrRerunAllRateLimited, got %v", err)
+	}
+
+	registry.SetRerunAllRateLimiter(nil)
+	resource.Strobe()
+	if err := registry.RerunAllImmediately(ctx); err != nil {
+		t.Fatal(err)
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"count": 3}, "metadata": {"diffVersion": 1}}`)
+
+	// Subscriptions are recomputed even if none of their dependencies were
+	// invalidated.
+	if err := registry.RerunAllImmediately(ctx); err != nil {
+		t.Fatal(err)
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": {"count": 4}, "metadata": {"diffVersion": 1}}`)
+}
@@ -0,0 +1,76 @@
+package graphql
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultRerunLoopThreshold is the default number of consecutive changed
+	// reruns that are reported as a rerun loop.
+	DefaultRerunLoopThreshold = 20
+	// DefaultRerunLoopWindow is the default window in which
+	// DefaultRerunLoopThreshold changed reruns are reported as a rerun loop.
+	DefaultRerunLoopWindow = 2 * time.Minute
+)
+
+// SetRerunLoopDetection reports subscriptions whose result changes on
+// threshold consecutive reruns within window to the logger. Such
+// subscriptions usually have a resolver returning nondeterministic data, such
+// as time.Now(), and rerun forever. Once a loop is detected, the reruns of the
+// subscription are backed off with reactive.Backoff: the delay before each
+// rerun doubles while the result keeps changing, up to the maximum retry delay
+// of its Rerunner, and is reset once a rerun leaves the result unchanged.
+// Reruns caused by mutations on the connection are not delayed. A zero
+// threshold disables detection.
+func (c *conn) SetRerunLoopDetection(threshold int, window time.Duration) {
+	c.rerunLoopThreshold = threshold
+	c.rerunLoopWindow = window
+}
+
+// rerunLoopDetector tracks the consecutive changed reruns of a subscription.
+type rerunLoopDetector struct {
+	threshold int
+	window    time.Duration
+
+	// changes holds the times of the most recent consecutive changed reruns.
+	changes []time.Time
+	// looping is set once a loop is detected, until a rerun is unchanged.
+	looping bool
+}
+
+func (c *conn) newRerunLoopDetector() *rerunLoopDetector {
+	return &rerunLoopDetector{
+		threshold: c.rerunLoopThreshold,
+		window:    c.rerunLoopWindow,
+	}
+}
+
+// observe records a rerun at now, and returns an error if it completes a
+// rerun loop.
+func (d *rerunLoopDetector) observe(now time.Time, changed bool) error {
+	if d.threshold <= 0 {
+		return nil
+	}
+	if !changed {
+		d.changes = d.changes[:0]
+		d.looping = false
+		return nil
+	}
+
+	d.changes = append(d.changes, now)
+	if len(d.changes) < d.threshold {
+		return nil
+	}
+
+	first := d.changes[0]
+	d.changes = d.changes[1:]
+	if now.Sub(first) > d.window {
+		return nil
+	}
+
+	// Start over so that a loop is reported once every threshold reruns.
+	d.changes = d.changes[:0]
+	d.looping = true
+	return fmt.Errorf("subscription changed on %d consecutive reruns within %v; a resolver may be nondeterministic", d.threshold, d.window)
+}
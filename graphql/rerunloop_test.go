@@ -0,0 +1,36 @@
+package graphql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRerunLoopDetector(t *testing.T) {
+	d := &rerunLoopDetector{threshold: 3, window: time.Minute}
+	now := time.Now()
+
+	if d.observe(now, true) != nil || d.observe(now.Add(time.Second), true) != nil {
+		t.Error("expected no loop before threshold")
+	}
+	if d.observe(now.Add(2*time.Second), true) == nil || !d.looping {
+		t.Error("expected loop at threshold")
+	}
+
+	// An unchanged rerun breaks the streak.
+	d.observe(now.Add(3*time.Second), true)
+	d.observe(now.Add(4*time.Second), false)
+	if d.looping {
+		t.Error("expected unchanged rerun to end the loop")
+	}
+	d.observe(now.Add(5*time.Second), true)
+	if d.observe(now.Add(6*time.Second), true) != nil {
+		t.Error("expected unchanged rerun to reset streak")
+	}
+
+	// Changes spread out over more than the window are not a loop.
+	d = &rerunLoopDetector{threshold: 2, window: time.Second}
+	d.observe(now, true)
+	if d.observe(now.Add(time.Minute), true) != nil {
+		t.Error("expected slow changes to not be a loop")
+	}
+}
@@ -0,0 +1,289 @@
+package graphql
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MaxParkedMessages is the number of messages buffered for a disconnected
+// connection awaiting resumption. A connection whose subscriptions send more
+// messages while disconnected can no longer be resumed.
+const MaxParkedMessages = 256
+
+var (
+	errUnknownResumeToken = NewSafeError("unknown resume token")
+	errResumeAfterStart   = NewSafeError("resume must be the first message")
+	errParkedBufferFull   = errors.New("parked connection buffer full")
+	errSocketDetached     = errors.New("socket detached")
+)
+
+// SetResumeWindow lets clients resume the connection's subscriptions from a
+// new connection after a transient disconnect, instead of subscribing again
+// and rerunning all of their queries.
+//
+// The connection sends the client a token in a "resumeToken" message. When
+// the socket disconnects, the connection's subscriptions stay alive for
+// window, and the updates they send are buffered. A new connection on the
+// same Registry whose first message, after the connect message if the
+// connection requires one, is a "resume" message with the token then takes
+// over the subscriptions: it is sent a "resumed" message, followed by the
+// buffered updates, and all further messages are handled by the original
+// connection. If window passes without a resume, or more than
+// MaxParkedMessages updates are buffered, the subscriptions are closed.
+//
+// The token alone authorizes resuming the subscriptions, which run with the
+// context of the original connection. Use SetResumeIdentity to also require
+// the new connection to be authenticated as the same principal.
+//
+// SetResumeWindow must be called before ServeJSONSocket. As subscriptions
+// may outlive the socket, the connection's context is detached from the
+// cancellation of the context it was created with. Resumption is only
+// supported by the native protocol.
+func (c *conn) SetResumeWindow(window time.Duration) {
+	c.resumeWindow = window
+	if window <= 0 || c.cancelCtx != nil {
+		return
+	}
+	c.ctx, c.cancelCtx = context.WithCancel(detachedContext{parent: c.ctx})
+	c.socket = &resumableSocket{socket: c.socket}
+}
+
+// ResumeIdentityFunc returns the principal a connection is authenticated as,
+// such as a user ID, from its context.
+type ResumeIdentityFunc func(ctx context.Context) string
+
+// SetResumeIdentity restricts resuming a connection to new connections whose
+// context, as returned by MakeCtx and the OnConnectFunc, fn maps to the same
+// principal as the original connection's context. Other connections are told
+// the token is unknown.
+func (c *conn) SetResumeIdentity(fn ResumeIdentityFunc) {
+	c.resumeIdentity = fn
+}
+
+// sameIdentity returns true if c may resume parked according to
+// resumeIdentity.
+func (c *conn) sameIdentity(parked *conn) bool {
+	if c.resumeIdentity == nil {
+		return true
+	}
+	c.mu.Lock()
+	ctx := c.ctx
+	c.mu.Unlock()
+	parked.mu.Lock()
+	parkedCtx := parked.ctx
+	parked.mu.Unlock()
+	return c.resumeIdentity(ctx) == c.resumeIdentity(parkedCtx)
+}
+
+// detachedContext carries the values of its parent, but not its deadline or
+// cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// resumableSocket is a JSONSocket whose underlying socket can be swapped. While
+// detached, it buffers written messages until it is attached to a new socket.
+type resumableSocket struct {
+	mu     sync.Mutex
+	socket JSONSocket
+	parked []interface{}
+	// closed is set once the socket is closed while detached, after which it
+	// can no longer be attached.
+	closed bool
+}
+
+func (s *resumableSocket) current() JSONSocket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.socket
+}
+
+func (s *resumableSocket) ReadJSON(value interface{}) error {
+	socket := s.current()
+	if socket == nil {
+		return errSocketDetached
+	}
+	return socket.ReadJSON(value)
+}
+
+func (s *resumableSocket) WriteJSON(value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.socket != nil {
+		return s.socket.WriteJSON(value)
+	}
+	if len(s.parked) >= MaxParkedMessages {
+		s.closed = true
+		s.parked = nil
+		return errParkedBufferFull
+	}
+	s.parked = append(s.parked, value)
+	return nil
+}
+
+func (s *resumableSocket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.socket != nil {
+		return s.socket.Close()
+	}
+	s.closed = true
+	s.parked = nil
+	return nil
+}
+
+// detach starts buffering writes.
+func (s *resumableSocket) detach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.socket = nil
+}
+
+// attach writes first and the buffered messages to socket, and makes it the
+// underlying socket. It returns false without writing anything if the socket
+// was closed while detached.
+func (s *resumableSocket) attach(socket JSONSocket, first interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if err := socket.WriteJSON(first); err != nil {
+		return false
+	}
+	for _, value := range s.parked {
+		if err := socket.WriteJSON(value); err != nil {
+			return false
+		}
+	}
+	s.parked = nil
+	s.socket = socket
+	return true
+}
+
+// newResumeToken returns a random token identifying a resumable connection.
+func newResumeToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// sendResumeToken sends the client the token it can resume the connection
+// with, once.
+func (c *conn) sendResumeToken() {
+	if c.resumeWindow <= 0 || c.resumeToken != "" {
+		return
+	}
+	c.resumeToken = newResumeToken()
+	c.writeOrClose(OutEnvelope{
+		Type:    "resumeToken",
+		Message: c.resumeToken,
+	})
+}
+
+// park keeps the connection's subscriptions alive after its socket
+// disconnected, so that a new connection can resume them. It returns false if
+// the connection cannot be resumed.
+func (c *conn) park() bool {
+	socket, ok := c.socket.(*resumableSocket)
+	if !ok || c.resumeWindow <= 0 || c.isShuttingDown() {
+		return false
+	}
+
+	c.mu.Lock()
+	empty := len(c.subscriptions) == 0
+	c.mu.Unlock()
+	if empty {
+		return false
+	}
+
+	socket.detach()
+	c.registry.park(c.resumeToken, c, c.resumeWindow)
+	return true
+}
+
+// close closes the connection's subscriptions for good.
+func (c *conn) close() {
+	c.closeSubscriptions()
+	if c.cancelCtx != nil {
+		c.cancelCtx()
+	}
+	if c.stopWrites != nil {
+		close(c.stopWrites)
+	}
+}
+
+// closeParked closes the connection if it is still parked, without waiting
+// for its resume window to pass.
+func (c *conn) closeParked() {
+	if c.registry.unpark(c.resumeToken) != c {
+		return
+	}
+	c.parkTimer.Stop()
+	c.close()
+}
+
+// resume takes over the subscriptions of the parked connection identified by
+// the token in message, attaching it to the connection's socket. The
+// connection must be initialized, if it requires a connect message, and must
+// not have started any subscriptions of its own. Once resume succeeds, the
+// connection no longer writes to its socket.
+func (c *conn) resume(message json.RawMessage) (*conn, error) {
+	if c.isShuttingDown() {
+		return nil, errShuttingDown
+	}
+	if err := c.checkConnected(); err != nil {
+		return nil, err
+	}
+
+	var token string
+	if err := json.Unmarshal(message, &token); err != nil {
+		return nil, NewSafeError("invalid resume token")
+	}
+
+	c.mu.Lock()
+	started := len(c.subscriptions) > 0 || len(c.mutations) > 0
+	c.mu.Unlock()
+	if started {
+		return nil, errResumeAfterStart
+	}
+
+	parked := c.registry.parkedConn(token)
+	if parked == nil || !c.sameIdentity(parked) || c.registry.unpark(token) != parked {
+		return nil, errUnknownResumeToken
+	}
+	parked.parkTimer.Stop()
+
+	socket := c.socket
+	if resumable, ok := socket.(*resumableSocket); ok {
+		socket = resumable.current()
+	}
+
+	// The parked connection writes to the socket directly from now on, so
+	// this connection's queued writes must be flushed first.
+	c.stopWriter()
+	if !parked.socket.(*resumableSocket).attach(socket, OutEnvelope{Type: "resumed"}) {
+		parked.close()
+		c.startWriter()
+		return nil, errUnknownResumeToken
+	}
+	// Only the resumed connection is live from now on.
+	c.registry.deregister(c)
+	c.registry.register(parked)
+	return parked, nil
+}
@@ -0,0 +1,276 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+func TestResume(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("count", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource)
+		return atomic.AddInt64(&executions, 1)
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("bump", func() bool {
+		resource.Strobe()
+		return true
+	})
+	builtSchema := schema.MustBuild()
+
+	registry := graphql.NewRegistry()
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	serve := func(socket *fakeSocket) (string, chan struct{}) {
+		c := graphql.CreateJSONSocket(context.Background(), socket, builtSchema, makeCtx, nopLogger{})
+		c.SetRegistry(registry)
+		c.SetResumeWindow(time.Second)
+		done := make(chan struct{})
+		go func() {
+			c.ServeJSONSocket()
+			close(done)
+		}()
+		return readResumeToken(t, socket), done
+	}
+
+	first := newFakeSocket()
+	token, done := serve(first)
+	first.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ count }"},
+	}
+	first.expect(t, `{"id": "1", "type": "update", "message": [{"count": 1}], "metadata": {"diffVersion": 1}}`)
+
+	// Disconnecting leaves the subscription alive.
+	close(first.in)
+	<-done
+	if registry.Len() != 0 {
+		t.Errorf("expected no live connections, got %d", registry.Len())
+	}
+
+	second := newFakeSocket()
+	defer close(second.in)
+	serve(second)
+
+	second.in <- map[string]interface{}{"type": "resume", "message": "bogus"}
+	second.expect(t, `{"type": "error", "message": "unknown resume token", "errors": [{"message": "unknown resume token"}]}`)
+
+	second.in <- map[string]interface{}{"type": "resume", "message": token}
+	second.expect(t, `{"type": "resumed"}`)
+	if registry.Len() != 1 {
+		t.Errorf("expected 1 live connection, got %d", registry.Len())
+	}
+
+	// The resumed subscription sends its updates to the new socket.
+	second.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { bump }"},
+	}
+	second.expect(t, `{"id": "2", "type": "result", "message": [{"bump": true}], "metadata": {"diffVersion": 1}}`)
+	second.expect(t, `{"id": "1", "type": "update", "message": {"count": 2}, "metadata": {"diffVersion": 1, "causedBy": ["2"]}}`)
+
+	// A token can only be used once.
+	third := newFakeSocket()
+	defer close(third.in)
+	serve(third)
+
+	third.in <- map[string]interface{}{"type": "resume", "message": token}
+	third.expect(t, `{"type": "error", "message": "unknown resume token", "errors": [{"message": "unknown resume token"}]}`)
+}
+
+// readResumeToken returns the token of the resumeToken message sent on socket.
+func readResumeToken(t *testing.T, socket *fakeSocket) string {
+	select {
+	case message := <-socket.out:
+		return message.(map[string]interface{})["message"].(string)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for resume token")
+		return ""
+	}
+}
+
+func TestResumeWindowExpires(t *testing.T) {
+	registry := graphql.NewRegistry()
+	logger := &subscriptionLogger{events: make(chan subscriptionEvent, 16)}
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+
+	first := newFakeSocket()
+	c := graphql.CreateJSONSocket(context.Background(), first, makeGraphQLWSSchema(), makeCtx, logger)
+	c.SetRegistry(registry)
+	c.SetResumeWindow(50 * time.Millisecond)
+	go c.ServeJSONSocket()
+	token := readResumeToken(t, first)
+
+	first.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	logger.expect(t, subscriptionEvent{id: "1"})
+	first.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	// Once the window passes, the subscriptions are closed and the token can
+	// no longer be used.
+	close(first.in)
+	logger.expect(t, subscriptionEvent{id: "1", reason: graphql.UnsubscribeConnectionClosed})
+
+	second := newFakeSocket()
+	defer close(second.in)
+	c = graphql.CreateJSONSocket(context.Background(), second, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetRegistry(registry)
+	c.SetResumeWindow(50 * time.Millisecond)
+	go c.ServeJSONSocket()
+	readResumeToken(t, second)
+
+	second.in <- map[string]interface{}{"type": "resume", "message": token}
+	second.expect(t, `{"type": "error", "message": "unknown resume token", "errors": [{"message": "unknown resume token"}]}`)
+}
+
+func TestResumeParkedBufferFull(t *testing.T) {
+	registry := graphql.NewRegistry()
+	logger := &subscriptionLogger{events: make(chan subscriptionEvent, 16)}
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+
+	socket := newFakeSocket()
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, logger)
+	c.SetRegistry(registry)
+	c.SetResumeWindow(time.Minute)
+	c.SetSubscriptionKeepAlive(time.Millisecond)
+	go c.ServeJSONSocket()
+	readResumeToken(t, socket)
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	logger.expect(t, subscriptionEvent{id: "1"})
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	// The keepalives fill the buffer of the parked connection, which is then
+	// closed without waiting for the window to pass.
+	close(socket.in)
+	select {
+	case event := <-logger.events:
+		if expected := (subscriptionEvent{id: "1", reason: graphql.UnsubscribeConnectionClosed}); event != expected {
+			t.Errorf("expected %v, got %v", expected, event)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the parked connection to close")
+	}
+	if registry.Len() != 0 {
+		t.Errorf("expected no live connections, got %d", registry.Len())
+	}
+}
+
+func TestResumeWithWriteBuffer(t *testing.T) {
+	registry := graphql.NewRegistry()
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	serve := func(socket *fakeSocket) (string, chan struct{}) {
+		c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+		c.SetRegistry(registry)
+		c.SetResumeWindow(time.Minute)
+		c.SetWriteBuffer(4)
+		c.SetSubscriptionKeepAlive(10 * time.Millisecond)
+		done := make(chan struct{})
+		go func() {
+			c.ServeJSONSocket()
+			close(done)
+		}()
+		return readResumeToken(t, socket), done
+	}
+
+	first := newFakeSocket()
+	token, done := serve(first)
+	first.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	first.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	// More messages are sent while parked than fit in the write buffer, as it
+	// is still drained into the parked buffer.
+	close(first.in)
+	<-done
+	time.Sleep(80 * time.Millisecond)
+
+	second := newFakeSocket()
+	defer close(second.in)
+	serve(second)
+
+	second.in <- map[string]interface{}{"type": "resume", "message": token}
+	second.expect(t, `{"type": "resumed"}`)
+	second.expect(t, `{"id": "1", "type": "ka"}`)
+}
+
+type principalKey struct{}
+
+func TestResumeRequiresSamePrincipal(t *testing.T) {
+	registry := graphql.NewRegistry()
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	serve := func(socket *fakeSocket) (string, chan struct{}) {
+		c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+		c.SetRegistry(registry)
+		c.SetResumeWindow(time.Minute)
+		c.SetOnConnect(func(ctx context.Context, payload json.RawMessage) (context.Context, error) {
+			return context.WithValue(ctx, principalKey{}, string(payload)), nil
+		})
+		c.SetResumeIdentity(func(ctx context.Context) string {
+			principal, _ := ctx.Value(principalKey{}).(string)
+			return principal
+		})
+		done := make(chan struct{})
+		go func() {
+			c.ServeJSONSocket()
+			close(done)
+		}()
+		return readResumeToken(t, socket), done
+	}
+	connect := func(socket *fakeSocket, principal string) {
+		socket.in <- map[string]interface{}{"type": "connect", "message": principal}
+		socket.expect(t, `{"type": "connected"}`)
+	}
+
+	first := newFakeSocket()
+	token, done := serve(first)
+	connect(first, "alice")
+	first.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	first.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+	close(first.in)
+	<-done
+
+	second := newFakeSocket()
+	defer close(second.in)
+	serve(second)
+
+	// Resuming requires the connect handshake, and the same principal.
+	second.in <- map[string]interface{}{"type": "resume", "message": token}
+	second.expect(t, `{"type": "error", "message": "connection not initialized", "errors": [{"message": "connection not initialized"}]}`)
+	connect(second, "mallory")
+	second.in <- map[string]interface{}{"type": "resume", "message": token}
+	second.expect(t, `{"type": "error", "message": "unknown resume token", "errors": [{"message": "unknown resume token"}]}`)
+
+	third := newFakeSocket()
+	defer close(third.in)
+	serve(third)
+	connect(third, "alice")
+	third.in <- map[string]interface{}{"type": "resume", "message": token}
+	third.expect(t, `{"type": "resumed"}`)
+}
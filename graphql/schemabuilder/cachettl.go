@@ -0,0 +1,128 @@
+package schemabuilder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+// CacheTTL is an option that can be passed to a FieldFunc to cache its
+// results for ttl, for expensive fields that change rarely:
+//
+//	query.FieldFunc("totalRevenue", computeRevenue, schemabuilder.CacheTTL(time.Minute))
+//
+// Results are shared by all queries resolving the field with the same
+// arguments on the same source, and are recomputed once they expire.
+// Subscriptions reading a cached result rerun when it expires. Reactive
+// dependencies of the resolver, such as keys passed to
+// reactive.AddDependencyKey, still apply: invalidating one drops the cached
+// result immediately.
+//
+// Sources are identified by the key of their object if it has one, and by
+// value otherwise. Fields on sources that cannot be compared, or with
+// arguments that cannot be marshaled to JSON, are not cached.
+//
+// As results are shared between queries, CacheTTL cannot be used on
+// resolvers taking a context, whose results may depend on the user running
+// the query. Use CacheTTLPerContext for those.
+func CacheTTL(ttl time.Duration) FieldFuncOption {
+	return func(m *method) {
+		m.CacheTTL = ttl
+	}
+}
+
+// CacheTTLPerContext is like CacheTTL, but only shares results between
+// queries whose contexts have the same key, as returned by key, such as the
+// ID of the user running the query:
+//
+//	query.FieldFunc("myRevenue", computeMyRevenue, schemabuilder.CacheTTLPerContext(time.Minute, userID))
+//
+// Keys must be comparable. Results are not cached for contexts with a nil
+// key.
+func CacheTTLPerContext(ttl time.Duration, key func(ctx context.Context) interface{}) FieldFuncOption {
+	return func(m *method) {
+		m.CacheTTL = ttl
+		m.CacheContextKey = key
+	}
+}
+
+// fieldCacheKey identifies a result of a cached field.
+type fieldCacheKey struct {
+	context interface{}
+	source  interface{}
+	args    string
+}
+
+// cacheField caches the results of field on object, built from m, for
+// m.CacheTTL.
+func cacheField(field *graphql.Field, object *graphql.Object, m *method) error {
+	if m.CacheContextKey == nil && takesContext(m.Fn) {
+		return errors.New("CacheTTL cannot cache a resolver taking a context, use CacheTTLPerContext")
+	}
+
+	cache := reactive.NewTTLCache()
+	ttl := m.CacheTTL
+	contextKey := m.CacheContextKey
+
+	resolve := field.Resolve
+	field.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		key, ok, err := makeFieldCacheKey(ctx, object, contextKey, source, args)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return resolve(ctx, source, args, selectionSet)
+		}
+		return cache.Get(ctx, key, ttl, func(ctx context.Context) (interface{}, error) {
+			return resolve(ctx, source, args, selectionSet)
+		})
+	}
+	return nil
+}
+
+// takesContext returns true if fn takes a context as its first argument.
+func takesContext(fn interface{}) bool {
+	typ := reflect.TypeOf(fn)
+	return typ.Kind() == reflect.Func && typ.NumIn() > 0 && typ.In(0) == contextType
+}
+
+// makeFieldCacheKey returns the cache key of the result of a field on source
+// with args, resolved with ctx, or false if the result should not be cached.
+func makeFieldCacheKey(ctx context.Context, object *graphql.Object, contextKey func(ctx context.Context) interface{}, source, args interface{}) (fieldCacheKey, bool, error) {
+	var key fieldCacheKey
+
+	if contextKey != nil {
+		value := contextKey(ctx)
+		if value == nil || !reflect.TypeOf(value).Comparable() {
+			return key, false, nil
+		}
+		key.context = value
+	}
+
+	// The key of the object is read when resolving, as it is only set once
+	// the object is fully built.
+	if object.Key != nil {
+		value, err := object.Key(ctx, source, nil, nil)
+		if err != nil {
+			return key, false, err
+		}
+		source = value
+	}
+	if value := reflect.ValueOf(source); value.IsValid() && !value.Type().Comparable() {
+		return key, false, nil
+	}
+	key.source = source
+
+	marshaled, err := json.Marshal(args)
+	if err != nil {
+		return key, false, nil
+	}
+	key.args = string(marshaled)
+
+	return key, true, nil
+}
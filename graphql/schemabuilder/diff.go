@@ -0,0 +1,353 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// Severity classifies a Change by whether it may break existing clients.
+type Severity string
+
+const (
+	// Breaking changes may make queries of existing clients fail, such as
+	// removing a field or adding a required argument.
+	Breaking Severity = "BREAKING"
+	// NonBreaking changes keep all existing queries valid, such as adding a
+	// field or an optional argument.
+	NonBreaking Severity = "NON_BREAKING"
+)
+
+// A Change is a difference between two schemas found by Diff.
+type Change struct {
+	// Path locates the change, such as "User", "User.name", or
+	// "Query.users(first)".
+	Path     string
+	Severity Severity
+	// Description explains the change, such as "field removed".
+	Description string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Severity, c.Path, c.Description)
+}
+
+// Diff compares the types reachable from the query and mutation objects of
+// two built schemas, and returns the changes from old to new, sorted by path.
+// It can be run in CI to reject schema changes that break existing clients,
+// allowing intentional breaks by their paths.
+//
+// Removing types, fields, arguments, enum values, or union members, adding
+// required arguments or input fields, and changing the type of a field in a
+// way that clients may not expect are Breaking. Making an output type
+// non-null, or an input type nullable, is NonBreaking, as are all additions
+// that keep existing queries valid. Like introspection, Diff ignores hidden
+// objects and fields.
+func Diff(old, new *graphql.Schema) []Change {
+	d := &schemaDiff{}
+	oldTypes, newTypes := diffTypes(old), diffTypes(new)
+
+	for name, oldType := range oldTypes {
+		newType, ok := newTypes[name]
+		if !ok {
+			d.add(name, Breaking, "type removed")
+			continue
+		}
+		d.diffType(name, oldType, newType)
+	}
+	for name := range newTypes {
+		if _, ok := oldTypes[name]; !ok {
+			d.add(name, NonBreaking, "type added")
+		}
+	}
+
+	sort.Slice(d.changes, func(i, j int) bool {
+		if d.changes[i].Path != d.changes[j].Path {
+			return d.changes[i].Path < d.changes[j].Path
+		}
+		return d.changes[i].Description < d.changes[j].Description
+	})
+	return d.changes
+}
+
+// schemaDiff accumulates the changes found by Diff.
+type schemaDiff struct {
+	changes []Change
+}
+
+func (d *schemaDiff) add(path string, severity Severity, format string, a ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Path:        path,
+		Severity:    severity,
+		Description: fmt.Sprintf(format, a...),
+	})
+}
+
+// diffType compares two types of the same name.
+func (d *schemaDiff) diffType(name string, oldType, newType graphql.Type) {
+	if typeKind(oldType) != typeKind(newType) {
+		d.add(name, Breaking, "changed from %s to %s", typeKind(oldType), typeKind(newType))
+		return
+	}
+
+	switch oldType := oldType.(type) {
+	case *graphql.Object:
+		newType := newType.(*graphql.Object)
+		d.diffFields(name, oldType.Fields, newType.Fields)
+
+	case *graphql.Interface:
+		newType := newType.(*graphql.Interface)
+		d.diffFields(name, oldType.Fields, newType.Fields)
+		d.diffMembers(name, oldType.Types, newType.Types, "implementation")
+
+	case *graphql.Union:
+		newType := newType.(*graphql.Union)
+		d.diffMembers(name, oldType.Types, newType.Types, "member")
+
+	case *graphql.Enum:
+		newType := newType.(*graphql.Enum)
+		oldValues, newValues := stringSet(oldType.Values), stringSet(newType.Values)
+		for value := range oldValues {
+			if !newValues[value] {
+				d.add(name+"."+value, Breaking, "enum value removed")
+			}
+		}
+		for value := range newValues {
+			if !oldValues[value] {
+				d.add(name+"."+value, NonBreaking, "enum value added")
+			}
+		}
+
+	case *graphql.InputObject:
+		newType := newType.(*graphql.InputObject)
+		inputFieldPath := func(field string) string { return name + "." + field }
+		d.diffInputValues(inputFieldPath, oldType.InputFields, newType.InputFields, newType.DefaultValues, "input field")
+	}
+}
+
+// diffFields compares the fields of two objects or interfaces named name.
+func (d *schemaDiff) diffFields(name string, oldFields, newFields map[string]*graphql.Field) {
+	oldFields, newFields = visibleFields(oldFields), visibleFields(newFields)
+
+	for fieldName, oldField := range oldFields {
+		path := name + "." + fieldName
+		newField, ok := newFields[fieldName]
+		if !ok {
+			d.add(path, Breaking, "field removed")
+			continue
+		}
+		if oldField.Type.String() != newField.Type.String() {
+			severity := Breaking
+			if isSafeOutputChange(oldField.Type, newField.Type) {
+				severity = NonBreaking
+			}
+			d.add(path, severity, "type changed from %s to %s", oldField.Type, newField.Type)
+		}
+		if oldField.DeprecationReason == "" && newField.DeprecationReason != "" {
+			d.add(path, NonBreaking, "field deprecated: %s", newField.DeprecationReason)
+		}
+		argPath := func(arg string) string { return path + "(" + arg + ")" }
+		d.diffInputValues(argPath, oldField.Args, newField.Args, newField.ArgDefaults, "argument")
+	}
+	for fieldName := range newFields {
+		if _, ok := oldFields[fieldName]; !ok {
+			d.add(name+"."+fieldName, NonBreaking, "field added")
+		}
+	}
+}
+
+// diffInputValues compares arguments or input fields, whose paths are
+// formatted by valuePath.
+func (d *schemaDiff) diffInputValues(valuePath func(name string) string, oldValues, newValues map[string]graphql.Type, newDefaults map[string]string, what string) {
+	for name, oldType := range oldValues {
+		newType, ok := newValues[name]
+		if !ok {
+			d.add(valuePath(name), Breaking, "%s removed", what)
+			continue
+		}
+		if oldType.String() != newType.String() {
+			severity := Breaking
+			if isSafeInputChange(oldType, newType) {
+				severity = NonBreaking
+			}
+			d.add(valuePath(name), severity, "type changed from %s to %s", oldType, newType)
+		}
+	}
+	for name, newType := range newValues {
+		if _, ok := oldValues[name]; ok {
+			continue
+		}
+		_, hasDefault := newDefaults[name]
+		if _, required := newType.(*graphql.NonNull); required && !hasDefault {
+			d.add(valuePath(name), Breaking, "required %s added", what)
+		} else {
+			d.add(valuePath(name), NonBreaking, "optional %s added", what)
+		}
+	}
+}
+
+// diffMembers compares the implementations of an interface or the members of
+// a union.
+func (d *schemaDiff) diffMembers(name string, oldMembers, newMembers map[string]*graphql.Object, what string) {
+	for member := range oldMembers {
+		if _, ok := newMembers[member]; !ok {
+			d.add(name, Breaking, "%s %s removed", what, member)
+		}
+	}
+	for member := range newMembers {
+		if _, ok := oldMembers[member]; !ok {
+			d.add(name, NonBreaking, "%s %s added", what, member)
+		}
+	}
+}
+
+// isSafeOutputChange returns true if clients expecting values of the output
+// type old can handle values of new, which may only be non-null where old is
+// nullable.
+func isSafeOutputChange(old, new graphql.Type) bool {
+	if newNonNull, ok := new.(*graphql.NonNull); ok {
+		if oldNonNull, ok := old.(*graphql.NonNull); ok {
+			return isSafeOutputChange(oldNonNull.Type, newNonNull.Type)
+		}
+		return isSafeOutputChange(old, newNonNull.Type)
+	}
+	if _, ok := old.(*graphql.NonNull); ok {
+		return false
+	}
+	if oldList, ok := old.(*graphql.List); ok {
+		newList, ok := new.(*graphql.List)
+		return ok && isSafeOutputChange(oldList.Type, newList.Type)
+	}
+	return old.String() == new.String()
+}
+
+// isSafeInputChange returns true if values clients send for the input type
+// old are valid values of new, which may only be nullable where old is
+// non-null.
+func isSafeInputChange(old, new graphql.Type) bool {
+	if oldNonNull, ok := old.(*graphql.NonNull); ok {
+		if newNonNull, ok := new.(*graphql.NonNull); ok {
+			return isSafeInputChange(oldNonNull.Type, newNonNull.Type)
+		}
+		return isSafeInputChange(oldNonNull.Type, new)
+	}
+	if _, ok := new.(*graphql.NonNull); ok {
+		return false
+	}
+	if oldList, ok := old.(*graphql.List); ok {
+		newList, ok := new.(*graphql.List)
+		return ok && isSafeInputChange(oldList.Type, newList.Type)
+	}
+	return old.String() == new.String()
+}
+
+// typeKind returns the kind of the named type typ, as in introspection.
+func typeKind(typ graphql.Type) string {
+	switch typ.(type) {
+	case *graphql.Object:
+		return "OBJECT"
+	case *graphql.Interface:
+		return "INTERFACE"
+	case *graphql.Union:
+		return "UNION"
+	case *graphql.Enum:
+		return "ENUM"
+	case *graphql.InputObject:
+		return "INPUT_OBJECT"
+	default:
+		return "SCALAR"
+	}
+}
+
+// diffTypes returns the named types reachable from the query and mutation
+// objects of schema, skipping hidden objects and fields.
+func diffTypes(schema *graphql.Schema) map[string]graphql.Type {
+	types := make(map[string]graphql.Type)
+	var collect func(typ graphql.Type)
+	collect = func(typ graphql.Type) {
+		switch typ := typ.(type) {
+		case nil:
+			return
+		case *graphql.List:
+			collect(typ.Type)
+			return
+		case *graphql.NonNull:
+			collect(typ.Type)
+			return
+		case *graphql.Object:
+			if typ.Hidden {
+				return
+			}
+		}
+
+		if _, ok := types[typ.String()]; ok {
+			return
+		}
+		types[typ.String()] = typ
+
+		switch typ := typ.(type) {
+		case *graphql.Object:
+			for _, field := range visibleFields(typ.Fields) {
+				collect(field.Type)
+				for _, arg := range field.Args {
+					collect(arg)
+				}
+			}
+		case *graphql.Interface:
+			for _, field := range visibleFields(typ.Fields) {
+				collect(field.Type)
+			}
+			for _, object := range typ.Types {
+				collect(object)
+			}
+		case *graphql.Union:
+			for _, object := range typ.Types {
+				collect(object)
+			}
+		case *graphql.InputObject:
+			for _, field := range typ.InputFields {
+				collect(field)
+			}
+		}
+	}
+	collect(schema.Query)
+	collect(schema.Mutation)
+	return types
+}
+
+// visibleFields returns fields without hidden fields, fields of hidden
+// objects, and introspection fields.
+func visibleFields(fields map[string]*graphql.Field) map[string]*graphql.Field {
+	visible := make(map[string]*graphql.Field, len(fields))
+	for name, field := range fields {
+		if field.Hidden || isHiddenType(field.Type) || strings.HasPrefix(name, "__") {
+			continue
+		}
+		visible[name] = field
+	}
+	return visible
+}
+
+// isHiddenType returns true if typ is, or is a list of, a hidden object.
+func isHiddenType(typ graphql.Type) bool {
+	switch typ := typ.(type) {
+	case *graphql.Object:
+		return typ.Hidden
+	case *graphql.List:
+		return isHiddenType(typ.Type)
+	case *graphql.NonNull:
+		return isHiddenType(typ.Type)
+	default:
+		return false
+	}
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
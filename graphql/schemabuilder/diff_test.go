@@ -0,0 +1,81 @@
+package schemabuilder
+
+import (
+	"reflect"
+	"testing"
+)
+
+type diffUser struct {
+	Name  string
+	Email *string
+}
+
+type diffAdmin struct {
+	Name string
+}
+
+type diffFilter struct {
+	Limit int64
+}
+
+func TestDiff(t *testing.T) {
+	priorities := map[string]interface{}{
+		"LOW":  priority(0),
+		"HIGH": priority(1),
+	}
+
+	old := NewSchema()
+	old.Enum("Priority", priorities)
+	query := old.Query()
+	query.FieldFunc("me", func() *diffUser { return nil })
+	query.FieldFunc("users", func(args struct{ First *int64 }) []*diffUser { return nil })
+	query.FieldFunc("admin", func() *diffAdmin { return nil })
+	query.FieldFunc("count", func() *int64 { return nil })
+	query.FieldFunc("priority", func(args struct{ Priority priority }) string { return "" })
+	user := old.Object("User", diffUser{})
+	user.FieldFunc("age", func() int64 { return 0 })
+	old.Mutation()
+
+	new := NewSchema()
+	new.Enum("Priority", priorities)
+	query = new.Query()
+	query.FieldFunc("me", func() *diffUser { return nil }, Deprecated("use viewer instead"))
+	query.FieldFunc("users", func(args struct {
+		First  int64
+		After  *string
+		Filter diffFilter
+	}) []*diffUser {
+		return nil
+	})
+	query.FieldFunc("count", func() int64 { return 0 })
+	query.FieldFunc("priority", func(args struct{ Priority *priority }) string { return "" })
+	query.FieldFunc("search", func() []*diffUser { return nil })
+	query.FieldFunc("secret", func() string { return "" }, HideFromIntrospection)
+	new.Object("User", diffUser{})
+	new.Mutation()
+
+	var changes []string
+	for _, change := range Diff(old.MustBuild(), new.MustBuild()) {
+		changes = append(changes, change.String())
+	}
+	expected := []string{
+		"BREAKING Query.admin: field removed",
+		"NON_BREAKING Query.count: type changed from int64 to int64!",
+		"NON_BREAKING Query.me: field deprecated: use viewer instead",
+		"NON_BREAKING Query.priority(priority): type changed from Priority! to Priority",
+		"NON_BREAKING Query.search: field added",
+		"NON_BREAKING Query.users(after): optional argument added",
+		"BREAKING Query.users(filter): required argument added",
+		"BREAKING Query.users(first): type changed from int64 to int64!",
+		"BREAKING User.age: field removed",
+		"BREAKING diffAdmin: type removed",
+		"NON_BREAKING diffFilter_InputObject: type added",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes\n%v\ngot\n%v", expected, changes)
+	}
+
+	if changes := Diff(old.MustBuild(), old.MustBuild()); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
@@ -0,0 +1,60 @@
+package schemabuilder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// A DirectiveFunc wraps the resolution of every field bearing its directive.
+// args holds the arguments the directive was attached with, and next resolves
+// the field. A DirectiveFunc can block resolution by returning an error
+// without calling next, or transform the value returned by next.
+type DirectiveFunc func(ctx context.Context, args map[string]interface{}, next func(ctx context.Context) (interface{}, error)) (interface{}, error)
+
+// directive is a directive attached to a method.
+type directive struct {
+	name string
+	args map[string]interface{}
+}
+
+// RegisterDirective registers fn as the handler for the directive name.
+// Fields are marked with the directive using the Directive option.
+func (s *Schema) RegisterDirective(name string, fn DirectiveFunc) {
+	if _, ok := s.directives[name]; ok {
+		panic("duplicate directive")
+	}
+	s.directives[name] = fn
+}
+
+// Directive is an option that can be passed to a FieldFunc to attach the
+// directive name with args to the field. If multiple directives are attached,
+// the first one wraps the others. For example, given a registered "auth"
+// directive, the secrets field below is resolved through its DirectiveFunc:
+//
+//	query.FieldFunc("secrets", listSecrets, schemabuilder.Directive("auth", map[string]interface{}{"role": "admin"}))
+func Directive(name string, args map[string]interface{}) FieldFuncOption {
+	return func(m *method) {
+		m.Directives = append(m.Directives, directive{name: name, args: args})
+	}
+}
+
+// wrapDirectives wraps the resolver of field with the handlers of directives.
+func (sb *schemaBuilder) wrapDirectives(field *graphql.Field, directives []directive) error {
+	for i := len(directives) - 1; i >= 0; i-- {
+		d := directives[i]
+		fn, ok := sb.directives[d.name]
+		if !ok {
+			return fmt.Errorf("unknown directive @%s", d.name)
+		}
+
+		inner := field.Resolve
+		field.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+			return fn(ctx, d.args, func(ctx context.Context) (interface{}, error) {
+				return inner(ctx, source, args, selectionSet)
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package schemabuilder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// enum is an enum type registered with Enum.
+type enum struct {
+	typ    *graphql.Enum
+	parser *argParser
+}
+
+// Enum registers a GraphQL enum named name. values maps the names of
+// the enum members to Go values, which must all have the same type:
+//
+//	type Status int
+//
+//	const (
+//		Active Status = iota
+//		Archived
+//	)
+//
+//	schema.Enum("Status", map[string]interface{}{
+//		"ACTIVE":   Active,
+//		"ARCHIVED": Archived,
+//	})
+//
+// Resolvers returning the Go type are serialized to member names, and
+// arguments of the Go type accept only member names.
+func (s *Schema) Enum(name string, values map[string]interface{}) {
+	if len(values) == 0 {
+		panic(fmt.Sprintf("enum %s: should have at least one value", name))
+	}
+
+	var typ reflect.Type
+	names := make(map[interface{}]string)
+	var sorted []string
+	for member, value := range values {
+		valueTyp := reflect.TypeOf(value)
+		if typ == nil {
+			typ = valueTyp
+		} else if valueTyp != typ {
+			panic(fmt.Sprintf("enum %s: values should all have type %s, not %s", name, typ, valueTyp))
+		}
+		if other, ok := names[value]; ok {
+			panic(fmt.Sprintf("enum %s: %s and %s have the same value", name, other, member))
+		}
+		names[value] = member
+		sorted = append(sorted, member)
+	}
+	sort.Strings(sorted)
+
+	if typ == nil || typ.Kind() == reflect.Ptr {
+		panic(fmt.Sprintf("enum %s: values should not be pointers or nil", name))
+	}
+	if _, ok := s.enums[typ]; ok {
+		panic(fmt.Sprintf("enum %s: type %s is already registered", name, typ))
+	}
+	for _, other := range s.enums {
+		if other.typ.Name == name {
+			panic(fmt.Sprintf("duplicate enum %s", name))
+		}
+	}
+
+	s.enums[typ] = &enum{
+		typ: &graphql.Enum{
+			Name:   name,
+			Values: sorted,
+			Serialize: func(value interface{}) (interface{}, error) {
+				member, ok := names[value]
+				if !ok {
+					return nil, fmt.Errorf("%v is not a value of enum %s", value, name)
+				}
+				return member, nil
+			},
+		},
+		parser: &argParser{
+			FromJSON: func(value interface{}, dest reflect.Value) error {
+				member, ok := value.(string)
+				if !ok {
+					return errors.New("not a string")
+				}
+				v, ok := values[member]
+				if !ok {
+					return fmt.Errorf("%s is not a value of enum %s", member, name)
+				}
+				dest.Set(reflect.ValueOf(v))
+				return nil
+			},
+			Type: typ,
+		},
+	}
+}
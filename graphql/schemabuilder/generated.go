@@ -0,0 +1,52 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A FieldResolver returns the value of a struct field from a struct or a
+// pointer to a struct, without reflection.
+type FieldResolver func(source interface{}) interface{}
+
+// FieldResolvers registers statically-typed resolvers for the fields of the
+// struct type of typ, such as User{}, keyed by Go field name. Fields with a registered
+// resolver are resolved by calling it instead of reading the field with
+// reflection, which is measurably faster for types resolved many times per
+// query. Other fields, and unregistered types, are still resolved with
+// reflection.
+//
+// FieldResolvers is meant to be called through the functions generated by
+// thundergen for structs annotated with a //thunder:resolvers comment:
+//
+//	//go:generate thundergen $GOFILE
+//
+//	//thunder:resolvers
+//	type User struct {
+//		Name string
+//		Age  int
+//	}
+//
+// which generates a RegisterUserResolvers function to call with the schema.
+func (s *Schema) FieldResolvers(typ interface{}, resolvers map[string]FieldResolver) {
+	goTyp := reflect.TypeOf(typ)
+	if goTyp == nil || goTyp.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("field resolvers: type %v should be a struct", goTyp))
+	}
+	if _, ok := s.fieldResolvers[goTyp]; ok {
+		panic(fmt.Sprintf("field resolvers: type %s is already registered", goTyp))
+	}
+	for name := range resolvers {
+		if _, ok := goTyp.FieldByName(name); !ok {
+			panic(fmt.Sprintf("field resolvers: type %s has no field %s", goTyp, name))
+		}
+	}
+	s.fieldResolvers[goTyp] = resolvers
+}
+
+// getFieldResolver returns the registered resolver for the field of typ, if
+// any.
+func (sb *schemaBuilder) getFieldResolver(typ reflect.Type, field reflect.StructField) (FieldResolver, bool) {
+	resolver, ok := sb.fieldResolvers[typ][field.Name]
+	return resolver, ok
+}
@@ -0,0 +1,151 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// An Interface represents a Go interface type and its implementations, to be
+// converted into an Interface in a GraphQL schema.
+type Interface struct {
+	Name        string
+	Description string
+	Type        interface{} // A pointer to the Go interface type, such as (*Node)(nil).
+	Types       []interface{}
+}
+
+// Interface registers a GraphQL interface named name. iface is a pointer to a
+// Go interface type, such as (*Node)(nil), and types holds instances of the
+// struct types implementing it. Fields returning the Go interface type are
+// exposed as the GraphQL interface:
+//
+//	type Node interface{ isNode() }
+//	...
+//	schema.Interface("Node", (*Node)(nil), User{}, Post{})
+//	query.FieldFunc("node", func(args struct{ Id int64 }) Node { ... })
+//
+// The fields shared by all implementations, with the same type and without
+// arguments, can be selected on the interface. Other fields are selected with
+// fragments on the implementations, such as ... on User { name }. When
+// executing a query, the implementation is picked by the runtime type of the
+// value.
+func (s *Schema) Interface(name string, iface interface{}, types ...interface{}) *Interface {
+	if _, ok := s.interfaces[name]; ok {
+		panic("duplicate interface")
+	}
+	i := &Interface{
+		Name:  name,
+		Type:  iface,
+		Types: types,
+	}
+	s.interfaces[name] = i
+	return i
+}
+
+// buildInterface builds the graphql.Interface for the Go interface type typ.
+// Its fields are computed by finishInterfaces once all types are built.
+func (sb *schemaBuilder) buildInterface(typ reflect.Type) error {
+	if sb.types[typ] != nil {
+		return nil
+	}
+
+	iface, ok := sb.interfaces[typ]
+	if !ok {
+		return fmt.Errorf("bad type %s: interface types must be registered with Interface or Union", typ)
+	}
+
+	built := &graphql.Interface{
+		Name:        iface.Name,
+		Description: iface.Description,
+		Fields:      make(map[string]*graphql.Field),
+	}
+	sb.types[typ] = built
+
+	types, resolveType, err := sb.buildMembers(iface.Name, typ, iface.Types)
+	if err != nil {
+		return err
+	}
+	built.Types = types
+	built.ResolveType = resolveType
+
+	sb.builtInterfaces = append(sb.builtInterfaces, built)
+	return nil
+}
+
+// buildMembers builds the struct types of members, which must implement the
+// Go interface type typ of the abstract type name. It returns the built
+// Objects by name, and a function returning the Object for a value.
+func (sb *schemaBuilder) buildMembers(name string, typ reflect.Type, members []interface{}) (map[string]*graphql.Object, func(interface{}) (*graphql.Object, error), error) {
+	types := make(map[string]*graphql.Object)
+	objects := make(map[reflect.Type]*graphql.Object)
+	for _, member := range members {
+		memberTyp := reflect.TypeOf(member)
+		if memberTyp.Kind() == reflect.Ptr {
+			memberTyp = memberTyp.Elem()
+		}
+		if memberTyp.Kind() != reflect.Struct {
+			return nil, nil, fmt.Errorf("bad type %s: %s should be a struct", name, memberTyp)
+		}
+		if !memberTyp.Implements(typ) && !reflect.PtrTo(memberTyp).Implements(typ) {
+			return nil, nil, fmt.Errorf("bad type %s: %s does not implement %s", name, memberTyp, typ)
+		}
+
+		if err := sb.buildStruct(memberTyp); err != nil {
+			return nil, nil, err
+		}
+		object := sb.types[memberTyp].(*graphql.Object)
+		types[object.Name] = object
+		objects[memberTyp] = object
+	}
+
+	resolveType := func(value interface{}) (*graphql.Object, error) {
+		valueTyp := reflect.TypeOf(value)
+		if valueTyp.Kind() == reflect.Ptr {
+			valueTyp = valueTyp.Elem()
+		}
+		if object, ok := objects[valueTyp]; ok {
+			return object, nil
+		}
+		return nil, fmt.Errorf("%s is not a member of %s", valueTyp, name)
+	}
+
+	return types, resolveType, nil
+}
+
+// finishInterfaces computes the fields of all built interfaces. It runs after
+// all types are built, as the fields of implementations are incomplete while
+// a cycle of types is being built.
+func (sb *schemaBuilder) finishInterfaces() {
+	for _, iface := range sb.builtInterfaces {
+		var names []string
+		for name := range iface.Types {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+
+		first := iface.Types[names[0]]
+		for name, field := range first.Fields {
+			if sharedField(iface, names, name, field) {
+				iface.Fields[name] = field
+			}
+		}
+	}
+}
+
+// sharedField returns true if every implementation of iface has a field name
+// with the same type as field, and no arguments.
+func sharedField(iface *graphql.Interface, names []string, name string, field *graphql.Field) bool {
+	for _, objectName := range names {
+		other, ok := iface.Types[objectName].Fields[name]
+		if !ok || len(other.Args) != 0 || other.Type.String() != field.Type.String() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package schemabuilder
+
+import (
+	"context"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// FieldInfo describes a field being resolved by a FieldMiddlewareFunc.
+type FieldInfo struct {
+	// Type is the name of the object the field belongs to.
+	Type string
+	// Field is the name of the field.
+	Field string
+	// Args holds the field's parsed arguments, or nil if it has none.
+	Args interface{}
+}
+
+// A FieldMiddlewareFunc wraps the resolution of fields, for cross-cutting
+// concerns like timing, authorization, or caching. next resolves the field.
+type FieldMiddlewareFunc func(ctx context.Context, info FieldInfo, next func(ctx context.Context) (interface{}, error)) (interface{}, error)
+
+// UseFieldMiddleware wraps the resolution of every field exposed with
+// FieldFunc with fn. Fields read directly from struct members are not
+// wrapped. Middlewares wrap directives, and the first middleware registered
+// wraps the others.
+func (s *Schema) UseFieldMiddleware(fn FieldMiddlewareFunc) {
+	s.fieldMiddlewares = append(s.fieldMiddlewares, fn)
+}
+
+// wrapFieldMiddlewares wraps the resolver of the field named name on typ with
+// the registered field middlewares.
+func (sb *schemaBuilder) wrapFieldMiddlewares(field *graphql.Field, typ string, name string) {
+	for i := len(sb.fieldMiddlewares) - 1; i >= 0; i-- {
+		fn := sb.fieldMiddlewares[i]
+
+		inner := field.Resolve
+		field.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+			info := FieldInfo{Type: typ, Field: name, Args: args}
+			return fn(ctx, info, func(ctx context.Context) (interface{}, error) {
+				return inner(ctx, source, args, selectionSet)
+			})
+		}
+	}
+}
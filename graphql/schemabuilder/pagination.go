@@ -0,0 +1,326 @@
+package schemabuilder
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// Paginated is an option that can be passed to a FieldFunc returning a slice
+// to expose the field as a Relay-style connection. The field takes the
+// arguments of PaginationArgs in addition to its own, and returns a
+// <Node>Connection object with the selected page:
+//
+//	query.FieldFunc("users", func(ctx context.Context) ([]*User, error) {
+//		...
+//	}, schemabuilder.Paginated)
+//
+// can be queried as
+//
+//	{ users(first: 10, after: $cursor) { edges { node { name } cursor } pageInfo { hasNextPage endCursor } } }
+//
+// Cursors are opaque strings encoding the key of the node if its object has a
+// key, and its offset in the slice otherwise.
+func Paginated(m *method) {
+	m.Paginated = true
+}
+
+// PaginationArgs holds the Relay pagination arguments taken by paginated
+// fields. First and After select the nodes following the After cursor; Last
+// and Before select the nodes preceding the Before cursor.
+type PaginationArgs struct {
+	First  *int64
+	After  *string
+	Last   *int64
+	Before *string
+}
+
+// PageInfo describes the page of a connection returned by a paginated field.
+type PageInfo struct {
+	HasNextPage     bool
+	HasPreviousPage bool
+	StartCursor     *string
+	EndCursor       *string
+}
+
+// connection is the resolved value of a paginated field.
+type connection struct {
+	edges      []edge
+	pageInfo   PageInfo
+	totalCount int64
+}
+
+// edge is a node in a connection, along with its cursor.
+type edge struct {
+	node   interface{}
+	cursor string
+}
+
+// paginatedArgs are the parsed arguments of a paginated field.
+type paginatedArgs struct {
+	args       interface{}
+	pagination PaginationArgs
+}
+
+// paginate turns the field built for a method returning a slice into a
+// paginated field returning a connection.
+func (sb *schemaBuilder) paginate(field *graphql.Field) error {
+	retType := field.Type
+	nonNull, isNonNull := retType.(*graphql.NonNull)
+	if isNonNull {
+		retType = nonNull.Type
+	}
+	list, ok := retType.(*graphql.List)
+	if !ok {
+		return errors.New("paginated fields should return a slice")
+	}
+
+	paginationParser, paginationType, err := sb.makeStructParser(reflect.TypeOf(PaginationArgs{}))
+	if err != nil {
+		return err
+	}
+	for name, typ := range paginationType.(*graphql.InputObject).InputFields {
+		if _, ok := field.Args[name]; ok {
+			return fmt.Errorf("paginated fields cannot take an argument named %s", name)
+		}
+		field.Args[name] = typ
+	}
+
+	parseArguments := field.ParseArguments
+	field.ParseArguments = func(json interface{}) (interface{}, error) {
+		pagination := make(map[string]interface{})
+		rest := make(map[string]interface{})
+		if asMap, ok := json.(map[string]interface{}); ok {
+			for name, value := range asMap {
+				if isPaginationArg(name) {
+					pagination[name] = value
+				} else {
+					rest[name] = value
+				}
+			}
+		}
+
+		args, err := parseArguments(rest)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := paginationParser.Parse(pagination)
+		if err != nil {
+			return nil, err
+		}
+		return paginatedArgs{args: args, pagination: parsed.(PaginationArgs)}, nil
+	}
+
+	// The key of the node object is read when resolving, as it is only set
+	// once the object is fully built.
+	nodeObject, _ := unwrapNonNull(list.Type).(*graphql.Object)
+
+	resolve := field.Resolve
+	field.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		paginated := args.(paginatedArgs)
+		result, err := resolve(ctx, source, paginated.args, selectionSet)
+		if err != nil {
+			return nil, err
+		}
+
+		value := reflect.ValueOf(result)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return nil, nil
+			}
+			value = value.Elem()
+		}
+
+		var key graphql.Resolver
+		if nodeObject != nil {
+			key = nodeObject.Key
+		}
+		return makeConnection(ctx, value, key, paginated.pagination)
+	}
+
+	connectionType, err := sb.getConnectionType(list.Type)
+	if err != nil {
+		return err
+	}
+	if isNonNull {
+		field.Type = &graphql.NonNull{Type: connectionType}
+	} else {
+		field.Type = connectionType
+	}
+	return nil
+}
+
+func isPaginationArg(name string) bool {
+	switch name {
+	case "first", "after", "last", "before":
+		return true
+	default:
+		return false
+	}
+}
+
+func unwrapNonNull(typ graphql.Type) graphql.Type {
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		return nonNull.Type
+	}
+	return typ
+}
+
+// getConnectionType returns the <Node>Connection object for connections of
+// nodes of type nodeType, building it and its <Node>Edge object on first use.
+func (sb *schemaBuilder) getConnectionType(nodeType graphql.Type) (*graphql.Object, error) {
+	name := unwrapNonNull(nodeType).String()
+	if object, ok := sb.connections[name]; ok {
+		return object, nil
+	}
+
+	pageInfoType, err := sb.getType(reflect.TypeOf(PageInfo{}))
+	if err != nil {
+		return nil, err
+	}
+	cursorType, err := sb.getType(reflect.TypeOf(""))
+	if err != nil {
+		return nil, err
+	}
+	countType, err := sb.getType(reflect.TypeOf(int64(0)))
+	if err != nil {
+		return nil, err
+	}
+
+	edgeType := &graphql.Object{
+		Name: name + "Edge",
+		Fields: map[string]*graphql.Field{
+			"node": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					return source.(edge).node, nil
+				},
+				Type:           nodeType,
+				ParseArguments: nilParseArguments,
+			},
+			"cursor": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					return source.(edge).cursor, nil
+				},
+				Type:           cursorType,
+				ParseArguments: nilParseArguments,
+			},
+		},
+	}
+
+	object := &graphql.Object{
+		Name: name + "Connection",
+		Fields: map[string]*graphql.Field{
+			"edges": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					return source.(*connection).edges, nil
+				},
+				Type:           &graphql.NonNull{Type: &graphql.List{Type: &graphql.NonNull{Type: edgeType}}},
+				ParseArguments: nilParseArguments,
+			},
+			"pageInfo": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					return source.(*connection).pageInfo, nil
+				},
+				Type:           pageInfoType,
+				ParseArguments: nilParseArguments,
+			},
+			"totalCount": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					return source.(*connection).totalCount, nil
+				},
+				Type:           countType,
+				ParseArguments: nilParseArguments,
+			},
+		},
+	}
+	sb.connections[name] = object
+	return object, nil
+}
+
+// makeConnection selects the page described by args from the slice nodes.
+func makeConnection(ctx context.Context, nodes reflect.Value, key graphql.Resolver, args PaginationArgs) (*connection, error) {
+	if args.First != nil && *args.First < 0 {
+		return nil, graphql.NewClientError("first must not be negative")
+	}
+	if args.Last != nil && *args.Last < 0 {
+		return nil, graphql.NewClientError("last must not be negative")
+	}
+
+	edges := make([]edge, nodes.Len())
+	for i := range edges {
+		node := nodes.Index(i).Interface()
+		cursor, err := makeCursor(ctx, key, node, i)
+		if err != nil {
+			return nil, err
+		}
+		edges[i] = edge{node: node, cursor: cursor}
+	}
+
+	start, end := 0, len(edges)
+	if args.After != nil {
+		if i := findCursor(edges, *args.After); i >= 0 {
+			start = i + 1
+		}
+	}
+	if args.Before != nil {
+		if i := findCursor(edges, *args.Before); i >= 0 && i < end {
+			end = i
+		}
+	}
+	if end < start {
+		end = start
+	}
+	if args.First != nil && int64(end-start) > *args.First {
+		end = start + int(*args.First)
+	}
+	if args.Last != nil && int64(end-start) > *args.Last {
+		start = end - int(*args.Last)
+	}
+
+	page := edges[start:end]
+	pageInfo := PageInfo{
+		HasPreviousPage: start > 0,
+		HasNextPage:     end < len(edges),
+	}
+	if len(page) > 0 {
+		pageInfo.StartCursor = &page[0].cursor
+		pageInfo.EndCursor = &page[len(page)-1].cursor
+	}
+
+	return &connection{
+		edges:      page,
+		pageInfo:   pageInfo,
+		totalCount: int64(len(edges)),
+	}, nil
+}
+
+// makeCursor returns the cursor of node, at offset i. If the nodes have keys,
+// the cursor encodes the key of node, so that it remains valid as nodes are
+// added and removed.
+func makeCursor(ctx context.Context, key graphql.Resolver, node interface{}, i int) (string, error) {
+	raw := "offset:" + strconv.Itoa(i)
+	if key != nil {
+		value, err := key(ctx, node, nil, nil)
+		if err != nil {
+			return "", err
+		}
+		raw = fmt.Sprintf("key:%v", value)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(raw)), nil
+}
+
+// findCursor returns the index of the edge with cursor, or -1 if there is
+// none. Unknown cursors are ignored, as prescribed by the Relay specification.
+func findCursor(edges []edge, cursor string) int {
+	for i, edge := range edges {
+		if edge.cursor == cursor {
+			return i
+		}
+	}
+	return -1
+}
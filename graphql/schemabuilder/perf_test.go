@@ -11,10 +11,7 @@ import (
 )
 
 func BenchmarkSimpleExecute(b *testing.B) {
-	schema := NewSchema()
-
-	query := schema.Query()
-	query.FieldFunc("users", func() []*User {
+	benchmarkExecuteUsers(b, func() []*User {
 		users := make([]*User, 5000)
 		for i := range users {
 			users[i] = &User{
@@ -24,6 +21,28 @@ func BenchmarkSimpleExecute(b *testing.B) {
 		}
 		return users
 	})
+}
+
+// BenchmarkGeneratedExecute is BenchmarkSimpleExecute with field resolvers
+// registered like thundergen does, to compare against reflection.
+func BenchmarkGeneratedExecute(b *testing.B) {
+	benchmarkExecuteUsers(b, func() []*resolvedUser {
+		users := make([]*resolvedUser, 5000)
+		for i := range users {
+			users[i] = &resolvedUser{
+				Name: "user" + fmt.Sprint(i),
+				Age:  i,
+			}
+		}
+		return users
+	})
+}
+
+func benchmarkExecuteUsers(b *testing.B, users interface{}) {
+	schema := NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("users", users)
 
 	_ = schema.Mutation()
 
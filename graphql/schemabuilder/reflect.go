@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -147,10 +148,14 @@ var scalarArgParsers = map[reflect.Type]*argParser{
 	},
 }
 
-func getScalarArgParser(typ reflect.Type) (*argParser, graphql.Type, bool) {
+func (sb *schemaBuilder) getScalarArgParser(typ reflect.Type) (*argParser, graphql.Type, bool) {
+	if custom, ok := sb.scalars[typ]; ok {
+		return custom.parser, &graphql.Scalar{Type: custom.name}, true
+	}
+
 	for match, argParser := range scalarArgParsers {
 		if internal.TypesIdenticalOrScalarAliases(match, typ) {
-			name, ok := getScalar(typ)
+			name, ok := sb.getScalar(typ)
 			if !ok {
 				panic(typ)
 			}
@@ -180,32 +185,40 @@ type argField struct {
 	field    reflect.StructField
 	parser   *argParser
 	optional bool
+
+	// defaultValue is used in place of the field when it is omitted, if
+	// hasDefault is set.
+	defaultValue interface{}
+	hasDefault   bool
 }
 
-func makeArgParser(typ reflect.Type) (*argParser, graphql.Type, error) {
+func (sb *schemaBuilder) makeArgParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 	if typ.Kind() == reflect.Ptr {
-		parser, argType, err := makeArgParserInner(typ.Elem())
+		parser, argType, err := sb.makeArgParserInner(typ.Elem())
 		if err != nil {
 			return nil, nil, err
 		}
 		return wrapPtrParser(parser), argType, nil
 	}
 
-	parser, argType, err := makeArgParserInner(typ)
+	parser, argType, err := sb.makeArgParserInner(typ)
 	if err != nil {
 		return nil, nil, err
 	}
 	return parser, &graphql.NonNull{Type: argType}, nil
 }
 
-func makeArgParserInner(typ reflect.Type) (*argParser, graphql.Type, error) {
-	if parser, argType, ok := getScalarArgParser(typ); ok {
+func (sb *schemaBuilder) makeArgParserInner(typ reflect.Type) (*argParser, graphql.Type, error) {
+	if enum, ok := sb.enums[typ]; ok {
+		return enum.parser, enum.typ, nil
+	}
+	if parser, argType, ok := sb.getScalarArgParser(typ); ok {
 		return parser, argType, nil
 	}
 
 	switch typ.Kind() {
 	case reflect.Struct:
-		parser, argType, err := makeStructParser(typ)
+		parser, argType, err := sb.makeStructParser(typ)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -214,7 +227,7 @@ func makeArgParserInner(typ reflect.Type) (*argParser, graphql.Type, error) {
 		}
 		return parser, argType, nil
 	case reflect.Slice:
-		return makeSliceParser(typ)
+		return sb.makeSliceParser(typ)
 	default:
 		return nil, nil, fmt.Errorf("bad arg type %s: should be struct, scalar, pointer, or a slice", typ)
 	}
@@ -239,12 +252,13 @@ func wrapPtrParser(inner *argParser) *argParser {
 	}
 }
 
-func makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
+func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 	fields := make(map[string]argField)
 
 	argType := &graphql.InputObject{
-		Name:        typ.Name(),
-		InputFields: make(map[string]graphql.Type),
+		Name:          typ.Name(),
+		InputFields:   make(map[string]graphql.Type),
+		DefaultValues: make(map[string]string),
 	}
 	if argType.Name != "" {
 		argType.Name += "_InputObject"
@@ -270,14 +284,25 @@ func makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 			continue
 		}
 
-		var key bool
+		var key, optional, hasDefault bool
+		var rawDefault string
 
 		if len(tags) > 1 {
-			for _, tag := range tags[1:] {
-				if tag != "key" || key {
+		tagLoop:
+			for i, tag := range tags[1:] {
+				switch {
+				case tag == "key" && !key:
+					key = true
+				case tag == "optional" && !optional:
+					optional = true
+				case strings.HasPrefix(tag, "default="):
+					// The default is last, and may contain commas.
+					hasDefault = true
+					rawDefault = strings.TrimPrefix(strings.Join(tags[i+1:], ","), "default=")
+					break tagLoop
+				default:
 					return nil, nil, fmt.Errorf("bad type %s: field %s has unexpected tag %s", typ, name, tag)
 				}
-				key = true
 			}
 		}
 
@@ -285,14 +310,43 @@ func makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 			return nil, nil, fmt.Errorf("bad arg type %s: duplicate field %s", typ, name)
 		}
 
-		parser, fieldArgTyp, err := makeArgParser(field.Type)
+		parser, fieldArgTyp, err := sb.makeArgParser(field.Type)
 		if err != nil {
 			return nil, nil, err
 		}
 
+		// Defaults are JSON values, or plain strings.
+		var defaultValue interface{}
+		if hasDefault {
+			if err := json.Unmarshal([]byte(rawDefault), &defaultValue); err != nil {
+				defaultValue = rawDefault
+			}
+			if err := parser.FromJSON(defaultValue, reflect.New(field.Type).Elem()); err != nil {
+				return nil, nil, fmt.Errorf("bad arg type %s: field %s has bad default %s: %s", typ, name, rawDefault, err)
+			}
+			encoded, err := json.Marshal(defaultValue)
+			if err != nil {
+				return nil, nil, err
+			}
+			argType.DefaultValues[name] = string(encoded)
+		}
+
+		// Pointer fields are always optional; other fields are required unless
+		// tagged optional or given a default. Omitted optional fields are left
+		// as the zero value, or set to their default.
+		if field.Type.Kind() == reflect.Ptr {
+			optional = true
+		} else if nonNull, ok := fieldArgTyp.(*graphql.NonNull); ok && (optional || hasDefault) {
+			fieldArgTyp = nonNull.Type
+			optional = true
+		}
+
 		fields[name] = argField{
-			field:  field,
-			parser: parser,
+			field:        field,
+			parser:       parser,
+			optional:     optional,
+			defaultValue: defaultValue,
+			hasDefault:   hasDefault,
 		}
 		argType.InputFields[name] = fieldArgTyp
 	}
@@ -305,7 +359,15 @@ func makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 			}
 
 			for name, field := range fields {
-				value := asMap[name]
+				value, ok := asMap[name]
+				switch {
+				case !ok && field.hasDefault:
+					value = field.defaultValue
+				case value == nil && field.optional:
+					continue
+				case !ok:
+					return fmt.Errorf("missing required field %s", name)
+				}
 				fieldDest := dest.FieldByIndex(field.field.Index)
 				if err := field.parser.FromJSON(value, fieldDest); err != nil {
 					return fmt.Errorf("%s: %s", name, err)
@@ -324,8 +386,8 @@ func makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 	}, argType, nil
 }
 
-func makeSliceParser(typ reflect.Type) (*argParser, graphql.Type, error) {
-	inner, argType, err := makeArgParser(typ.Elem())
+func (sb *schemaBuilder) makeSliceParser(typ reflect.Type) (*argParser, graphql.Type, error) {
+	inner, argType, err := sb.makeArgParser(typ.Elem())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -334,7 +396,12 @@ func makeSliceParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 		FromJSON: func(value interface{}, dest reflect.Value) error {
 			asSlice, ok := value.([]interface{})
 			if !ok {
-				return errors.New("not a list")
+				if value == nil {
+					return errors.New("not a list")
+				}
+				// As in the GraphQL spec, a single value is coerced to a
+				// list holding it.
+				asSlice = []interface{}{value}
 			}
 
 			dest.Set(reflect.MakeSlice(typ, len(asSlice), len(asSlice)))
@@ -352,8 +419,18 @@ func makeSliceParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 }
 
 type schemaBuilder struct {
-	types   map[reflect.Type]graphql.Type
-	objects map[reflect.Type]*Object
+	types            map[reflect.Type]graphql.Type
+	objects          map[reflect.Type]*Object
+	interfaces       map[reflect.Type]*Interface
+	unions           map[reflect.Type]*Union
+	scalars          map[reflect.Type]*customScalar
+	enums            map[reflect.Type]*enum
+	fieldResolvers   map[reflect.Type]map[string]FieldResolver
+	connections      map[string]*graphql.Object
+	directives       map[string]DirectiveFunc
+	fieldMiddlewares []FieldMiddlewareFunc
+
+	builtInterfaces []*graphql.Interface
 }
 
 var errType reflect.Type
@@ -402,7 +479,7 @@ func (sb *schemaBuilder) buildFunction(typ reflect.Type, m *method) (*graphql.Fi
 	if len(in) > 0 && in[0] != selectionSetType {
 		hasArgs = true
 		var err error
-		if argParser, argType, err = makeStructParser(in[0]); err != nil {
+		if argParser, argType, err = sb.makeStructParser(in[0]); err != nil {
 			return nil, err
 		}
 		in = in[1:]
@@ -468,6 +545,7 @@ func (sb *schemaBuilder) buildFunction(typ reflect.Type, m *method) (*graphql.Fi
 	}
 
 	args := make(map[string]graphql.Type)
+	argDefaults := make(map[string]string)
 	if hasArgs {
 		inputObject, ok := argType.(*graphql.InputObject)
 		if !ok {
@@ -477,6 +555,9 @@ func (sb *schemaBuilder) buildFunction(typ reflect.Type, m *method) (*graphql.Fi
 		for name, typ := range inputObject.InputFields {
 			args[name] = typ
 		}
+		for name, value := range inputObject.DefaultValues {
+			argDefaults[name] = value
+		}
 	}
 
 	return &graphql.Field{
@@ -538,18 +619,29 @@ func (sb *schemaBuilder) buildFunction(typ reflect.Type, m *method) (*graphql.Fi
 			return result, nil
 		},
 		Args:           args,
+		ArgDefaults:    argDefaults,
 		Type:           retType,
 		ParseArguments: argParser.Parse,
 		Expensive:      hasContext,
 	}, nil
 }
 
-func (sb *schemaBuilder) buildField(field reflect.StructField) (*graphql.Field, error) {
+func (sb *schemaBuilder) buildField(typ reflect.Type, field reflect.StructField) (*graphql.Field, error) {
 	retType, err := sb.getType(field.Type)
 	if err != nil {
 		return nil, err
 	}
 
+	if resolver, ok := sb.getFieldResolver(typ, field); ok {
+		return &graphql.Field{
+			Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+				return resolver(source), nil
+			},
+			Type:           retType,
+			ParseArguments: nilParseArguments,
+		}, nil
+	}
+
 	return &graphql.Field{
 		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
 			value := reflect.ValueOf(source)
@@ -572,11 +664,13 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 	var description string
 	var methods Methods
 	var objectKey string
+	var hidden bool
 	if object, ok := sb.objects[typ]; ok {
 		name = object.Name
 		description = object.Description
 		methods = object.Methods
 		objectKey = object.key
+		hidden = object.hidden
 	}
 
 	if name == "" {
@@ -590,6 +684,7 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		Name:        name,
 		Description: description,
 		Fields:      make(map[string]*graphql.Field),
+		Hidden:      hidden,
 	}
 	sb.types[typ] = object
 
@@ -626,7 +721,7 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 			return fmt.Errorf("bad type %s: two fields named %s", typ, name)
 		}
 
-		built, err := sb.buildField(field)
+		built, err := sb.buildField(typ, field)
 		if err != nil {
 			return fmt.Errorf("bad field %s on type %s: %s", name, typ, err)
 		}
@@ -652,6 +747,23 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		if err != nil {
 			return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
 		}
+		if method.Paginated {
+			if err := sb.paginate(built); err != nil {
+				return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
+			}
+		}
+		if method.CacheTTL > 0 {
+			if err := cacheField(built, object, method); err != nil {
+				return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
+			}
+		}
+		if err := sb.wrapDirectives(built, method.Directives); err != nil {
+			return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
+		}
+		sb.wrapFieldMiddlewares(built, object.Name, name)
+		built.Hidden = method.Hidden
+		built.Timeout = method.Timeout
+		built.DeprecationReason = method.DeprecationReason
 		object.Fields[name] = built
 	}
 
@@ -681,7 +793,11 @@ var scalars = map[reflect.Type]string{
 	reflect.TypeOf([]byte{}):    "bytes",
 }
 
-func getScalar(typ reflect.Type) (string, bool) {
+func (sb *schemaBuilder) getScalar(typ reflect.Type) (string, bool) {
+	if custom, ok := sb.scalars[typ]; ok {
+		return custom.name, true
+	}
+
 	for match, name := range scalars {
 		if internal.TypesIdenticalOrScalarAliases(match, typ) {
 			return name, true
@@ -691,14 +807,25 @@ func getScalar(typ reflect.Type) (string, bool) {
 }
 
 func (sb *schemaBuilder) getType(t reflect.Type) (graphql.Type, error) {
+	// Support enums and optional enums. Enums have precedence over scalars, as
+	// they are often aliases of scalar types.
+	if enum, ok := sb.enums[t]; ok {
+		return &graphql.NonNull{Type: enum.typ}, nil
+	}
+	if t.Kind() == reflect.Ptr {
+		if enum, ok := sb.enums[t.Elem()]; ok {
+			return enum.typ, nil
+		}
+	}
+
 	// Support scalars and optional scalars. Scalars have precedence over structs
 	// to have eg. time.Time function as a scalar.
-	if typ, ok := getScalar(t); ok {
-		return &graphql.NonNull{Type: &graphql.Scalar{Type: typ}}, nil
+	if typ, ok := sb.getScalar(t); ok {
+		return &graphql.NonNull{Type: sb.makeScalar(t, typ)}, nil
 	}
 	if t.Kind() == reflect.Ptr {
-		if typ, ok := getScalar(t.Elem()); ok {
-			return &graphql.Scalar{Type: typ}, nil // XXX: prefix typ with "*"
+		if typ, ok := sb.getScalar(t.Elem()); ok {
+			return sb.makeScalar(t.Elem(), typ), nil // XXX: prefix typ with "*"
 		}
 	}
 
@@ -716,6 +843,20 @@ func (sb *schemaBuilder) getType(t reflect.Type) (graphql.Type, error) {
 		return sb.types[t.Elem()], nil
 	}
 
+	// Interfaces and unions
+	if t.Kind() == reflect.Interface && sb.unions[t] != nil {
+		if err := sb.buildUnion(t); err != nil {
+			return nil, err
+		}
+		return sb.types[t], nil
+	}
+	if t.Kind() == reflect.Interface {
+		if err := sb.buildInterface(t); err != nil {
+			return nil, err
+		}
+		return sb.types[t], nil
+	}
+
 	switch t.Kind() {
 	case reflect.Slice:
 		typ, err := sb.getType(t.Elem())
@@ -736,12 +877,26 @@ func (sb *schemaBuilder) getType(t reflect.Type) (graphql.Type, error) {
 }
 
 type Schema struct {
-	objects map[string]*Object
+	objects          map[string]*Object
+	interfaces       map[string]*Interface
+	unions           map[string]*Union
+	scalars          map[reflect.Type]*customScalar
+	enums            map[reflect.Type]*enum
+	fieldResolvers   map[reflect.Type]map[string]FieldResolver
+	directives       map[string]DirectiveFunc
+	fieldMiddlewares []FieldMiddlewareFunc
 }
 
 func NewSchema() *Schema {
 	return &Schema{
-		objects: make(map[string]*Object),
+		objects:    make(map[string]*Object),
+		interfaces: make(map[string]*Interface),
+		unions:     make(map[string]*Union),
+		scalars:    make(map[reflect.Type]*customScalar),
+		enums:      make(map[reflect.Type]*enum),
+		directives: make(map[string]DirectiveFunc),
+
+		fieldResolvers: make(map[reflect.Type]map[string]FieldResolver),
 	}
 }
 
@@ -774,8 +929,17 @@ func (s *Schema) Mutation() *Object {
 
 func (s *Schema) Build() (*graphql.Schema, error) {
 	sb := &schemaBuilder{
-		types:   make(map[reflect.Type]graphql.Type),
-		objects: make(map[reflect.Type]*Object),
+		types:       make(map[reflect.Type]graphql.Type),
+		objects:     make(map[reflect.Type]*Object),
+		interfaces:  make(map[reflect.Type]*Interface),
+		unions:      make(map[reflect.Type]*Union),
+		scalars:     s.scalars,
+		enums:       s.enums,
+		connections: make(map[string]*graphql.Object),
+		directives:  s.directives,
+
+		fieldResolvers:   s.fieldResolvers,
+		fieldMiddlewares: s.fieldMiddlewares,
 	}
 
 	for _, object := range s.objects {
@@ -791,6 +955,35 @@ func (s *Schema) Build() (*graphql.Schema, error) {
 		sb.objects[typ] = object
 	}
 
+	for _, iface := range s.interfaces {
+		typ := reflect.TypeOf(iface.Type)
+		if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+			return nil, fmt.Errorf("interface %s: Type should be a pointer to an interface, not %v", iface.Name, typ)
+		}
+
+		if _, ok := sb.interfaces[typ.Elem()]; ok {
+			return nil, fmt.Errorf("duplicate interface for %s", typ.Elem().String())
+		}
+
+		sb.interfaces[typ.Elem()] = iface
+	}
+
+	for _, union := range s.unions {
+		typ := reflect.TypeOf(union.Type)
+		if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+			return nil, fmt.Errorf("union %s: Type should be a pointer to an interface, not %v", union.Name, typ)
+		}
+
+		if _, ok := sb.interfaces[typ.Elem()]; ok {
+			return nil, fmt.Errorf("%s is registered as both an interface and a union", typ.Elem().String())
+		}
+		if _, ok := sb.unions[typ.Elem()]; ok {
+			return nil, fmt.Errorf("duplicate union for %s", typ.Elem().String())
+		}
+
+		sb.unions[typ.Elem()] = union
+	}
+
 	queryTyp, err := sb.getType(reflect.TypeOf(&query{}))
 	if err != nil {
 		return nil, err
@@ -799,6 +992,7 @@ func (s *Schema) Build() (*graphql.Schema, error) {
 	if err != nil {
 		return nil, err
 	}
+	sb.finishInterfaces()
 	return &graphql.Schema{
 		Query:    queryTyp,
 		Mutation: mutationTyp,
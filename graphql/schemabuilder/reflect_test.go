@@ -2,7 +2,9 @@ package schemabuilder
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -318,7 +320,7 @@ func testArgParseBad(t *testing.T, p *argParser, input interface{}) {
 }
 
 func TestArgParser(t *testing.T) {
-	parser, _, err := makeArgParser(reflect.TypeOf(kitchenSinkArgs{}))
+	parser, _, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(kitchenSinkArgs{}))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -459,15 +461,770 @@ func TestArgParser(t *testing.T) {
 		}
 	`))
 
-	if _, _, err := makeArgParser(reflect.TypeOf(&duplicate{})); err == nil {
+	if _, _, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(&duplicate{})); err == nil {
 		t.Error("expected duplicate fields to fail")
 	}
 
-	if _, _, err := makeArgParser(reflect.TypeOf(&anonymous{})); err == nil {
+	if _, _, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(&anonymous{})); err == nil {
 		t.Error("expected anonymous fields to fail")
 	}
 
-	if _, _, err := makeArgParser(reflect.TypeOf(&unsupported{})); err == nil {
+	if _, _, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(&unsupported{})); err == nil {
 		t.Error("expected unsupported fields to fail")
 	}
 }
+
+type requiredArgs struct {
+	Name     string
+	Nickname string `graphql:"nickname,optional"`
+	Age      *int64
+}
+
+func TestArgParserRequiredFields(t *testing.T) {
+	parser, argType, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(requiredArgs{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := argType.(*graphql.NonNull).Type.(*graphql.InputObject).InputFields
+	if s := fields["name"].String(); s != "string!" {
+		t.Errorf("expected name to be required, got %s", s)
+	}
+	if s := fields["nickname"].String(); s != "string" {
+		t.Errorf("expected nickname to be optional, got %s", s)
+	}
+	if s := fields["age"].String(); s != "int64" {
+		t.Errorf("expected age to be optional, got %s", s)
+	}
+
+	testArgParseOk(t, parser, internal.ParseJSON(`{"name": "bob"}`), requiredArgs{Name: "bob"})
+	testArgParseOk(t, parser, internal.ParseJSON(`{"name": "bob", "nickname": null}`), requiredArgs{Name: "bob"})
+	testArgParseOk(t, parser, internal.ParseJSON(`{"name": "bob", "nickname": "b"}`), requiredArgs{Name: "bob", Nickname: "b"})
+
+	if _, err := parser.Parse(internal.ParseJSON(`{"nickname": "b"}`)); err == nil || err.Error() != "missing required field name" {
+		t.Errorf("expected missing field error, got %v", err)
+	}
+}
+
+type tagged struct {
+	First  string `graphql:"firstName"`
+	Secret string `graphql:"-"`
+}
+
+type defaultArgs struct {
+	Limit int64    `graphql:",default=10"`
+	Name  *string  `graphql:",default=bob"`
+	Tags  []string `graphql:"tags,default=[\"a\",\"b\"]"`
+}
+
+type badDefaultArgs struct {
+	Limit int64 `graphql:",default=ten"`
+}
+
+func TestArgParserDefaults(t *testing.T) {
+	parser, argType, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(defaultArgs{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputObject := argType.(*graphql.NonNull).Type.(*graphql.InputObject)
+	if s := inputObject.InputFields["limit"].String(); s != "int64" {
+		t.Errorf("expected limit to be optional, got %s", s)
+	}
+	if !reflect.DeepEqual(inputObject.DefaultValues, map[string]string{
+		"limit": "10",
+		"name":  `"bob"`,
+		"tags":  `["a","b"]`,
+	}) {
+		t.Errorf("unexpected defaults %v", inputObject.DefaultValues)
+	}
+
+	bob, alice := "bob", "alice"
+	testArgParseOk(t, parser, internal.ParseJSON(`{}`), defaultArgs{Limit: 10, Name: &bob, Tags: []string{"a", "b"}})
+	testArgParseOk(t, parser, internal.ParseJSON(`{"limit": 5, "name": "alice", "tags": []}`), defaultArgs{Limit: 5, Name: &alice, Tags: []string{}})
+
+	// Explicit nulls are not replaced by defaults.
+	testArgParseOk(t, parser, internal.ParseJSON(`{"limit": null, "name": null, "tags": null}`), defaultArgs{})
+
+	if _, _, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(badDefaultArgs{})); err == nil {
+		t.Error("expected bad default to fail")
+	}
+}
+
+type listArgs struct {
+	Tags     []string
+	Ids      *[]int64
+	Children []inner
+}
+
+func TestArgParserListCoercion(t *testing.T) {
+	parser, _, err := (&schemaBuilder{}).makeArgParser(reflect.TypeOf(listArgs{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Single values are coerced to lists holding them.
+	ids := []int64{1}
+	testArgParseOk(t, parser, internal.ParseJSON(`{"tags": "foo", "ids": 1, "children": {"foo": 1}}`),
+		listArgs{Tags: []string{"foo"}, Ids: &ids, Children: []inner{{Custom: 1}}})
+	testArgParseOk(t, parser, internal.ParseJSON(`{"tags": ["foo", "bar"], "children": []}`),
+		listArgs{Tags: []string{"foo", "bar"}, Children: []inner{}})
+
+	// Coerced values must still match the type of the elements.
+	testArgParseBad(t, parser, internal.ParseJSON(`{"tags": 1, "children": []}`))
+}
+
+func TestStructTags(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("echo", func(args struct {
+		Value  tagged `graphql:"input"`
+		Ignore int64  `graphql:"-"`
+	}) tagged {
+		return args.Value
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ echo(input: {firstName: "bob"}) { firstName } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"echo": {"firstName": "bob"}}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+
+	for _, query := range []string{
+		`{ echo(input: {firstName: "bob"}) { first } }`,
+		`{ echo(input: {firstName: "bob"}) { secret } }`,
+		`{ echo(input: {first: "bob"}) { firstName } }`,
+		`{ echo(input: {firstName: "bob", secret: "x"}) { firstName } }`,
+		`{ echo(input: {firstName: "bob"}, ignore: 1) { firstName } }`,
+	} {
+		q := graphql.MustParse(query, nil)
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil {
+			t.Errorf("expected %s to fail", query)
+		}
+	}
+}
+
+func TestPaginated(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func(args struct{ MinAge int64 }) []User {
+		var users []User
+		for i, name := range []string{"a", "b", "c", "d"} {
+			if int64(i) >= args.MinAge {
+				users = append(users, User{Name: name, Age: i})
+			}
+		}
+		return users
+	}, Paginated)
+	query.FieldFunc("numbers", func() []int64 {
+		return []int64{10, 20, 30}
+	}, Paginated)
+	builtSchema := schema.MustBuild()
+
+	execute := func(query string, vars map[string]interface{}) interface{} {
+		q := graphql.MustParse(query, vars)
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return internal.AsJSON(result)
+	}
+
+	cursor := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	result := execute(`{
+		users(minAge: 0, first: 2) {
+			totalCount
+			edges { cursor node { name } }
+			pageInfo { hasNextPage hasPreviousPage startCursor endCursor }
+		}
+	}`, nil)
+	expected := internal.ParseJSON(fmt.Sprintf(`{"users": {
+		"totalCount": 4,
+		"edges": [
+			{"cursor": %[1]q, "node": {"__key": "a", "name": "a"}},
+			{"cursor": %[2]q, "node": {"__key": "b", "name": "b"}}
+		],
+		"pageInfo": {"hasNextPage": true, "hasPreviousPage": false, "startCursor": %[1]q, "endCursor": %[2]q}
+	}}`, cursor("key:a"), cursor("key:b")))
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	result = execute(`query q($after: string) {
+		users(minAge: 1, first: 5, after: $after) {
+			edges { node { name } }
+			pageInfo { hasNextPage hasPreviousPage }
+		}
+	}`, map[string]interface{}{"after": cursor("key:b")})
+	expected = internal.ParseJSON(`{"users": {
+		"edges": [{"node": {"__key": "c", "name": "c"}}, {"node": {"__key": "d", "name": "d"}}],
+		"pageInfo": {"hasNextPage": false, "hasPreviousPage": true}
+	}}`)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	result = execute(fmt.Sprintf(`{
+		numbers(last: 1, before: %q) { edges { cursor node } pageInfo { hasNextPage hasPreviousPage } }
+	}`, cursor("offset:2")), nil)
+	expected = internal.ParseJSON(fmt.Sprintf(`{"numbers": {
+		"edges": [{"cursor": %q, "node": 20}],
+		"pageInfo": {"hasNextPage": true, "hasPreviousPage": true}
+	}}`, cursor("offset:1")))
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	type roleKey struct{}
+
+	schema := NewSchema()
+	schema.RegisterDirective("auth", func(ctx context.Context, args map[string]interface{}, next func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+		if ctx.Value(roleKey{}) != args["role"] {
+			return nil, graphql.NewClientError("forbidden")
+		}
+		return next(ctx)
+	})
+	schema.RegisterDirective("upper", func(ctx context.Context, args map[string]interface{}, next func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+		value, err := next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(value.(string)), nil
+	})
+
+	query := schema.Query()
+	query.FieldFunc("secret", func() string {
+		return "shh"
+	}, Directive("auth", map[string]interface{}{"role": "admin"}), Directive("upper", nil))
+
+	builtSchema := schema.MustBuild()
+	q := graphql.MustParse(`{ secret }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err == nil || err.Error() != "forbidden" {
+		t.Errorf("expected directive to block resolution, but received %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), roleKey{}, "admin")
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"secret": "SHH"}`)) {
+		t.Errorf("expected directive to transform result, but received %v", result)
+	}
+
+	schema = NewSchema()
+	schema.Query().FieldFunc("field", func() string { return "" }, Directive("missing", nil))
+	if _, err := schema.Build(); err == nil || !strings.Contains(err.Error(), "unknown directive @missing") {
+		t.Errorf("expected unknown directive to fail, but received %v", err)
+	}
+}
+
+func TestFieldMiddleware(t *testing.T) {
+	var calls []string
+
+	schema := NewSchema()
+	schema.UseFieldMiddleware(func(ctx context.Context, info FieldInfo, next func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+		calls = append(calls, fmt.Sprintf("%s.%s %v", info.Type, info.Field, info.Args))
+		return next(ctx)
+	})
+
+	query := schema.Query()
+	query.FieldFunc("user", func(args struct{ Name string }) *User {
+		return &User{Name: args.Name, Age: 10}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("greeting", func(u *User) string {
+		return "hi " + u.Name
+	})
+
+	builtSchema := schema.MustBuild()
+	q := graphql.MustParse(`{ user(name: "bob") { name greeting } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"user": {"__key": "bob", "name": "bob", "greeting": "hi bob"}}`)) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	// Struct fields such as name are not wrapped.
+	expected := []string{"Query.user {bob}", "User.greeting <nil>"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected middleware calls %v, got %v", expected, calls)
+	}
+}
+
+type Node interface {
+	isNode()
+}
+
+type Photo struct {
+	Id  int64
+	Url string
+}
+
+func (Photo) isNode() {}
+
+type Video struct {
+	Id     int64
+	Length int64
+}
+
+func (*Video) isNode() {}
+
+func TestInterface(t *testing.T) {
+	schema := NewSchema()
+	schema.Interface("Node", (*Node)(nil), Photo{}, Video{})
+
+	query := schema.Query()
+	query.FieldFunc("nodes", func() []Node {
+		return []Node{Photo{Id: 1, Url: "a.png"}, &Video{Id: 2, Length: 30}, nil}
+	})
+	query.FieldFunc("bad", func() Node {
+		return badNode{}
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		nodes {
+			__typename
+			id
+			... on Photo { url }
+			... on Video { length }
+		}
+	}`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"nodes": [
+		{"__typename": "Photo", "id": 1, "url": "a.png"},
+		{"__typename": "Video", "id": 2, "length": 30},
+		null
+	]}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+
+	// Only shared fields can be selected on the interface.
+	q = graphql.MustParse(`{ nodes { url } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil || err.Error() != `nodes: unknown field "url"` {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+
+	q = graphql.MustParse(`{ nodes { ... on Query { nodes { id } } } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil {
+		t.Error("expected fragment on non-implementation to fail")
+	}
+
+	q = graphql.MustParse(`{ bad { id } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err == nil {
+		t.Error("expected error resolving unregistered implementation")
+	}
+}
+
+type badNode struct{}
+
+func (badNode) isNode() {}
+
+type SearchResult interface {
+	isSearchResult()
+}
+
+func (Photo) isSearchResult()  {}
+func (*Video) isSearchResult() {}
+
+type Comment struct {
+	Text string
+}
+
+func (Comment) isSearchResult() {}
+
+func TestUnion(t *testing.T) {
+	schema := NewSchema()
+	schema.Union("SearchResult", (*SearchResult)(nil), Photo{}, Video{})
+
+	query := schema.Query()
+	query.FieldFunc("search", func() []SearchResult {
+		return []SearchResult{Photo{Id: 1, Url: "a.png"}, &Video{Id: 2, Length: 30}}
+	})
+	query.FieldFunc("bad", func() SearchResult {
+		return Comment{Text: "hi"}
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		search {
+			__typename
+			... on Photo { url }
+			... on Video { id length }
+		}
+	}`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"search": [
+		{"__typename": "Photo", "url": "a.png"},
+		{"__typename": "Video", "id": 2, "length": 30}
+	]}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+
+	// Fields can only be selected with fragments on members.
+	q = graphql.MustParse(`{ search { id } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil || err.Error() != `search: unknown field "id"` {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+
+	q = graphql.MustParse(`{ bad { __typename } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err == nil || !strings.Contains(err.Error(), "schemabuilder.Comment is not a member of SearchResult") {
+		t.Errorf("expected error resolving non-member, got %v", err)
+	}
+}
+
+type hexID uint64
+
+func TestScalar(t *testing.T) {
+	schema := NewSchema()
+	schema.Scalar("HexID", hexID(0),
+		func(value interface{}) (interface{}, error) {
+			return fmt.Sprintf("%x", value.(hexID)), nil
+		},
+		func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			var id uint64
+			if _, err := fmt.Sscanf(s, "%x", &id); err != nil {
+				return nil, errors.New("not a hex id")
+			}
+			return id, nil
+		})
+	query := schema.Query()
+	query.FieldFunc("next", func(args struct{ Id hexID }) hexID {
+		return args.Id + 1
+	})
+	query.FieldFunc("maybe", func(args struct{ Id *hexID }) *hexID {
+		return args.Id
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`query q($id: HexID) {
+		next(id: $id)
+		some: maybe(id: "ff")
+		none: maybe
+	}`, map[string]interface{}{"id": "1f"})
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"next": "20", "some": "ff", "none": null}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+
+	q = graphql.MustParse(`{ next(id: 5) }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil || !strings.Contains(err.Error(), "not a string") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+
+	// Other schemas are not affected by the registration.
+	other := NewSchema()
+	other.Query().FieldFunc("id", func() hexID {
+		return 31
+	})
+	builtOther := other.MustBuild()
+	q = graphql.MustParse(`{ id }`, nil)
+	if err := graphql.PrepareQuery(builtOther.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	result, err = e.Execute(context.Background(), builtOther.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"id": 31}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+}
+
+type priority int
+
+func TestEnum(t *testing.T) {
+	schema := NewSchema()
+	schema.Enum("Priority", map[string]interface{}{
+		"LOW":  priority(0),
+		"HIGH": priority(1),
+	})
+	query := schema.Query()
+	query.FieldFunc("raise", func(args struct{ Priority priority }) priority {
+		return args.Priority + 1
+	})
+	query.FieldFunc("maybe", func(args struct{ Priority *priority }) *priority {
+		return args.Priority
+	})
+	query.FieldFunc("invalid", func() priority {
+		return priority(5)
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`query q($priority: Priority) {
+		raise(priority: $priority)
+		some: maybe(priority: HIGH)
+		none: maybe
+	}`, map[string]interface{}{"priority": "LOW"})
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"raise": "HIGH", "some": "HIGH", "none": null}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+
+	q = graphql.MustParse(`{ raise(priority: $priority) }`, map[string]interface{}{"priority": "MEDIUM"})
+	err = graphql.PrepareQuery(builtSchema.Query, q.SelectionSet)
+	if errs, ok := err.(graphql.ValidationErrors); !ok || len(errs) != 1 || !strings.Contains(err.Error(), "MEDIUM is not a value of enum Priority") {
+		t.Errorf("expected error for invalid value, got %v", err)
+	} else if _, ok := errs[0].Err.(graphql.ClientError); !ok {
+		t.Errorf("expected client error for invalid value, got %v", errs[0].Err)
+	}
+
+	q = graphql.MustParse(`{ invalid }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err == nil || !strings.Contains(err.Error(), "5 is not a value of enum Priority") {
+		t.Errorf("expected error serializing invalid value, got %v", err)
+	}
+}
+
+type resolvedUser struct {
+	Name string
+	Age  int
+}
+
+func TestFieldResolvers(t *testing.T) {
+	var resolvedNames int
+
+	schema := NewSchema()
+	schema.FieldResolvers(resolvedUser{}, map[string]FieldResolver{
+		"Name": func(source interface{}) interface{} {
+			resolvedNames++
+			if source, ok := source.(*resolvedUser); ok {
+				return source.Name
+			}
+			return source.(resolvedUser).Name
+		},
+	})
+	query := schema.Query()
+	query.FieldFunc("users", func() []*resolvedUser {
+		return []*resolvedUser{{Name: "alice", Age: 30}, {Name: "bob", Age: 40}}
+	})
+	query.FieldFunc("user", func() resolvedUser {
+		return resolvedUser{Name: "carol", Age: 50}
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { name age } user { name age } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{
+		"users": [{"name": "alice", "age": 30}, {"name": "bob", "age": 40}],
+		"user": {"name": "carol", "age": 50}
+	}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+	if resolvedNames != 3 {
+		t.Errorf("expected registered resolver to resolve 3 names, got %d", resolvedNames)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	var computes int
+
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("total", func(args struct{ Region string }) int {
+		computes++
+		return len(args.Region)
+	}, CacheTTL(50*time.Millisecond))
+	builtSchema := schema.MustBuild()
+
+	execute := func(region string) {
+		q := graphql.MustParse(`query q($region: string!) { total(region: $region) }`, map[string]interface{}{"region": region})
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(fmt.Sprintf(`{"total": %d}`, len(region)))) {
+			t.Errorf("unexpected result %v", internal.AsJSON(result))
+		}
+	}
+
+	execute("west")
+	execute("west")
+	if computes != 1 {
+		t.Errorf("expected 1 compute for the same args, got %d", computes)
+	}
+
+	execute("east-1")
+	if computes != 2 {
+		t.Errorf("expected different args to compute, got %d computes", computes)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	execute("west")
+	if computes != 3 {
+		t.Errorf("expected expired result to compute, got %d computes", computes)
+	}
+}
+
+type cacheUserKey struct{}
+
+func TestCacheTTLPerContext(t *testing.T) {
+	var computes int
+	user := func(ctx context.Context) interface{} {
+		return ctx.Value(cacheUserKey{})
+	}
+	resolve := func(ctx context.Context) string {
+		computes++
+		return ctx.Value(cacheUserKey{}).(string)
+	}
+
+	schema := NewSchema()
+	schema.Query().FieldFunc("me", resolve, CacheTTL(time.Minute))
+	if _, err := schema.Build(); err == nil {
+		t.Error("expected CacheTTL on a resolver taking a context to fail")
+	}
+
+	schema = NewSchema()
+	schema.Query().FieldFunc("me", resolve, CacheTTLPerContext(time.Minute, user))
+	builtSchema := schema.MustBuild()
+
+	execute := func(name string) {
+		q := graphql.MustParse(`{ me }`, nil)
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		ctx := context.WithValue(context.Background(), cacheUserKey{}, name)
+		result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(fmt.Sprintf(`{"me": %q}`, name))) {
+			t.Errorf("unexpected result %v", internal.AsJSON(result))
+		}
+	}
+
+	execute("alice")
+	execute("alice")
+	if computes != 1 {
+		t.Errorf("expected 1 compute for the same user, got %d", computes)
+	}
+
+	execute("bob")
+	if computes != 2 {
+		t.Errorf("expected another user not to share results, got %d computes", computes)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("slow", func(ctx context.Context) (*string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, Timeout(10*time.Millisecond))
+	query.FieldFunc("fast", func(ctx context.Context) (string, error) {
+		if _, ok := ctx.Deadline(); ok {
+			return "", errors.New("unexpected deadline")
+		}
+		return "fast", nil
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ slow fast }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{PartialResults: true}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`{"slow": null, "fast": "fast"}`)) {
+		t.Errorf("unexpected result %v", internal.AsJSON(result))
+	}
+	if errs := e.FieldErrors(); len(errs) != 1 || errs[0].Error() != "field timed out after 10ms" {
+		t.Errorf("expected timeout error, got %v", errs)
+	}
+}
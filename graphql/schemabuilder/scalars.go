@@ -0,0 +1,86 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// customScalar is a scalar type registered with Scalar.
+type customScalar struct {
+	name      string
+	serialize func(interface{}) (interface{}, error)
+	parser    *argParser
+}
+
+// Scalar registers the Go type of typ, such as UUID{}, as a GraphQL scalar
+// named name, for types such as UUIDs or JSON blobs that are neither structs
+// nor built-in scalars. Resolvers may return values of the type, which are
+// converted to JSON with serialize. Arguments and variables of the type are
+// converted from JSON with parseValue, which must return a value convertible
+// to the type:
+//
+//	schema.Scalar("UUID", UUID{},
+//		func(value interface{}) (interface{}, error) {
+//			return value.(UUID).String(), nil
+//		},
+//		func(value interface{}) (interface{}, error) {
+//			s, ok := value.(string)
+//			if !ok {
+//				return nil, errors.New("not a string")
+//			}
+//			return ParseUUID(s)
+//		})
+//
+// Registered scalars take precedence over built-in scalars and structs in the
+// schema.
+func (s *Schema) Scalar(name string, typ interface{}, serialize func(interface{}) (interface{}, error), parseValue func(interface{}) (interface{}, error)) {
+	goTyp := reflect.TypeOf(typ)
+	if goTyp == nil || goTyp.Kind() == reflect.Ptr {
+		panic(fmt.Sprintf("scalar %s: type %v should not be a pointer or nil", name, goTyp))
+	}
+	if _, ok := s.scalars[goTyp]; ok {
+		panic(fmt.Sprintf("scalar %s: type %s is already registered", name, goTyp))
+	}
+	for _, scalar := range s.scalars {
+		if scalar.name == name {
+			panic(fmt.Sprintf("duplicate scalar %s", name))
+		}
+	}
+	for _, builtin := range scalars {
+		if builtin == name {
+			panic(fmt.Sprintf("scalar %s: name is used by a built-in scalar", name))
+		}
+	}
+
+	s.scalars[goTyp] = &customScalar{
+		name:      name,
+		serialize: serialize,
+		parser: &argParser{
+			FromJSON: func(value interface{}, dest reflect.Value) error {
+				parsed, err := parseValue(value)
+				if err != nil {
+					return err
+				}
+				v := reflect.ValueOf(parsed)
+				if !v.IsValid() || !v.Type().ConvertibleTo(goTyp) {
+					return fmt.Errorf("%s: parsed %T, expected %s", name, parsed, goTyp)
+				}
+				dest.Set(v.Convert(goTyp))
+				return nil
+			},
+			Type: goTyp,
+		},
+	}
+}
+
+// makeScalar returns the graphql.Scalar named name for typ, serializing
+// values of registered scalar types.
+func (sb *schemaBuilder) makeScalar(typ reflect.Type, name string) *graphql.Scalar {
+	scalar := &graphql.Scalar{Type: name}
+	if custom, ok := sb.scalars[typ]; ok {
+		scalar.Serialize = custom.serialize
+	}
+	return scalar
+}
@@ -0,0 +1,175 @@
+// Command thundergen generates statically-typed field resolvers for structs
+// exposed with schemabuilder, so that their fields are resolved without
+// reflection.
+//
+// Annotate the structs with a //thunder:resolvers comment, and run thundergen
+// with go generate:
+//
+//	//go:generate thundergen $GOFILE
+//
+//	//thunder:resolvers
+//	type User struct {
+//		Name string
+//		Age  int
+//	}
+//
+// For every input file x.go declaring annotated structs, thundergen writes a
+// file x_thunder.go with a function for each struct, such as
+// RegisterUserResolvers, registering resolvers for its exported fields with a
+// schema:
+//
+//	schema := schemabuilder.NewSchema()
+//	models.RegisterUserResolvers(schema)
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const annotation = "//thunder:resolvers"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("thundergen: ")
+
+	files := os.Args[1:]
+	if len(files) == 0 {
+		if file := os.Getenv("GOFILE"); file != "" {
+			files = []string{file}
+		}
+	}
+	if len(files) == 0 {
+		log.Fatal("usage: thundergen file.go...")
+	}
+
+	for _, filename := range files {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, err := generate(filename, src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if out == nil {
+			continue
+		}
+		if err := ioutil.WriteFile(strings.TrimSuffix(filename, ".go")+"_thunder.go", out, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// annotatedStruct is a struct annotated with //thunder:resolvers, and the
+// names of its fields that should be resolved without reflection.
+type annotatedStruct struct {
+	name   string
+	fields []string
+}
+
+// generate returns the generated resolvers for the annotated structs in the
+// Go source src, or nil if src has no annotated structs.
+func generate(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var structs []annotatedStruct
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if !isAnnotated(typeSpec.Doc) && !(len(genDecl.Specs) == 1 && isAnnotated(genDecl.Doc)) {
+				continue
+			}
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s is annotated but is not a struct", fset.Position(typeSpec.Pos()), typeSpec.Name.Name)
+			}
+			structs = append(structs, annotatedStruct{
+				name:   typeSpec.Name.Name,
+				fields: resolvedFields(structType),
+			})
+		}
+	}
+	if len(structs) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by thundergen from %s. DO NOT EDIT.\n\n", filename)
+	fmt.Fprintf(&buf, "package %s\n\n", file.Name.Name)
+	buf.WriteString("import \"github.com/samsarahq/thunder/graphql/schemabuilder\"\n")
+	for _, s := range structs {
+		register := registerFunc(s.name)
+		fmt.Fprintf(&buf, "\n// %s registers the field resolvers of %s with schema.\n", register, s.name)
+		fmt.Fprintf(&buf, "func %s(schema *schemabuilder.Schema) {\n", register)
+		fmt.Fprintf(&buf, "schema.FieldResolvers(%s{}, map[string]schemabuilder.FieldResolver{\n", s.name)
+		for _, field := range s.fields {
+			fmt.Fprintf(&buf, "%q: func(source interface{}) interface{} {\n", field)
+			fmt.Fprintf(&buf, "if source, ok := source.(*%s); ok {\nreturn source.%s\n}\n", s.name, field)
+			fmt.Fprintf(&buf, "return source.(%s).%s\n},\n", s.name, field)
+		}
+		buf.WriteString("})\n}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// registerFunc returns the name of the function registering the resolvers of
+// the struct named name, which is exported if the struct is.
+func registerFunc(name string) string {
+	if ast.IsExported(name) {
+		return "Register" + name + "Resolvers"
+	}
+	return "register" + strings.ToUpper(name[:1]) + name[1:] + "Resolvers"
+}
+
+// isAnnotated returns true if doc holds the //thunder:resolvers annotation.
+func isAnnotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(comment.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvedFields returns the names of the exported fields of structType that
+// schemabuilder exposes. Embedded fields are left to reflection.
+func resolvedFields(structType *ast.StructType) []string {
+	var fields []string
+	for _, field := range structType.Fields.List {
+		if field.Tag != nil {
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err == nil && strings.Split(reflect.StructTag(tag).Get("graphql"), ",")[0] == "-" {
+				continue
+			}
+		}
+		for _, name := range field.Names {
+			if name.IsExported() {
+				fields = append(fields, name.Name)
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src := `package models
+
+//thunder:resolvers
+type User struct {
+	Name     string
+	Age, Id  int64
+	password string
+	Hidden   string ` + "`graphql:\"-\"`" + `
+}
+
+type Other struct {
+	Name string
+}
+`
+
+	out, err := generate("user.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `// Code generated by thundergen from user.go. DO NOT EDIT.
+
+package models
+
+import "github.com/samsarahq/thunder/graphql/schemabuilder"
+
+// RegisterUserResolvers registers the field resolvers of User with schema.
+func RegisterUserResolvers(schema *schemabuilder.Schema) {
+	schema.FieldResolvers(User{}, map[string]schemabuilder.FieldResolver{
+		"Name": func(source interface{}) interface{} {
+			if source, ok := source.(*User); ok {
+				return source.Name
+			}
+			return source.(User).Name
+		},
+		"Age": func(source interface{}) interface{} {
+			if source, ok := source.(*User); ok {
+				return source.Age
+			}
+			return source.(User).Age
+		},
+		"Id": func(source interface{}) interface{} {
+			if source, ok := source.(*User); ok {
+				return source.Id
+			}
+			return source.(User).Id
+		},
+	})
+}
+`
+	if string(out) != expected {
+		t.Errorf("generate returned\n%s\nexpected\n%s", out, expected)
+	}
+}
+
+func TestGenerateUnexported(t *testing.T) {
+	out, err := generate("user.go", []byte("package models\n\n//thunder:resolvers\ntype user struct {\n\tName string\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "func registerUserResolvers(schema *schemabuilder.Schema) {") {
+		t.Errorf("expected unexported register function, got\n%s", out)
+	}
+}
+
+func TestGenerateNoAnnotations(t *testing.T) {
+	out, err := generate("other.go", []byte("package models\n\ntype Other struct{}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != nil {
+		t.Errorf("expected no output, got\n%s", out)
+	}
+}
+
+func TestGenerateNotStruct(t *testing.T) {
+	_, err := generate("other.go", []byte("package models\n\n//thunder:resolvers\ntype Other int\n"))
+	if err == nil {
+		t.Error("expected annotated non-struct to fail")
+	}
+}
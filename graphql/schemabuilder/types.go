@@ -1,5 +1,10 @@
 package schemabuilder
 
+import (
+	"context"
+	"time"
+)
+
 // A Object represents a Go type and set of methods to be converted into an
 // Object in a GraphQL schema.
 type Object struct {
@@ -8,7 +13,8 @@ type Object struct {
 	Type        interface{}
 	Methods     Methods // Deprecated, use FieldFunc instead.
 
-	key string
+	key    string
+	hidden bool
 }
 
 // FieldFuncOption is an interface for the variadic options that can be passed
@@ -21,6 +27,48 @@ func NonNullable(m *method) {
 	m.MarkedNonNullable = true
 }
 
+// HideFromIntrospection is an option that can be passed to a FieldFunc to
+// omit the field from introspection. Clients that know of the field can still
+// query it.
+func HideFromIntrospection(m *method) {
+	m.Hidden = true
+}
+
+// DefaultDeprecationReason is the reason reported for fields deprecated with
+// an empty reason.
+const DefaultDeprecationReason = "No longer supported"
+
+// Deprecated is an option that can be passed to a FieldFunc to mark the field
+// deprecated, with a reason telling clients what to use instead:
+//
+//	user.FieldFunc("fullName", fullName, schemabuilder.Deprecated("use name instead"))
+//
+// Introspection reports the field as deprecated, but clients can still query
+// it.
+func Deprecated(reason string) FieldFuncOption {
+	if reason == "" {
+		reason = DefaultDeprecationReason
+	}
+	return func(m *method) {
+		m.DeprecationReason = reason
+	}
+}
+
+// Timeout is an option that can be passed to a FieldFunc to limit how long
+// its resolver may run, such as for optional fields calling a slow external
+// service:
+//
+//	user.FieldFunc("recommendations", fetchRecommendations, schemabuilder.Timeout(time.Second))
+//
+// The context passed to the resolver expires after timeout. If the resolver
+// then fails, the field fails with a timeout error, which with partial
+// results only nulls out the field.
+func Timeout(timeout time.Duration) FieldFuncOption {
+	return func(m *method) {
+		m.Timeout = timeout
+	}
+}
+
 // FieldFunc exposes a field on an object. The function f can take a number of
 // optional arguments:
 // func([ctx context.Context], [o *Type], [args struct {}]) ([Result], [error])
@@ -59,9 +107,22 @@ func (s *Object) Key(f string) {
 	s.key = f
 }
 
+// HideFromIntrospection omits the object, and all fields returning it, from
+// introspection.
+func (s *Object) HideFromIntrospection() {
+	s.hidden = true
+}
+
 type method struct {
 	MarkedNonNullable bool
 	Fn                interface{}
+	Directives        []directive
+	Hidden            bool
+	Paginated         bool
+	CacheTTL          time.Duration
+	CacheContextKey   func(ctx context.Context) interface{}
+	Timeout           time.Duration
+	DeprecationReason string
 }
 
 // A Methods map represents the set of methods exposed on a Object.
@@ -0,0 +1,69 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// A Union represents a Go interface type and its members, to be converted
+// into a Union in a GraphQL schema.
+type Union struct {
+	Name        string
+	Description string
+	Type        interface{} // A pointer to the Go interface type, such as (*SearchResult)(nil).
+	Types       []interface{}
+}
+
+// Union registers a GraphQL union named name. As Go has no union types, a
+// union is represented by a Go interface type implemented by its members.
+// union is a pointer to the Go interface type, such as
+// (*SearchResult)(nil), and types holds instances of the member struct
+// types. Fields returning the Go interface type are exposed as the union:
+//
+//	type SearchResult interface{ isSearchResult() }
+//	...
+//	schema.Union("SearchResult", (*SearchResult)(nil), User{}, Post{})
+//	query.FieldFunc("search", func(args struct{ Query string }) []SearchResult { ... })
+//
+// Clients select the fields of members with fragments, such as
+// ... on User { name }. When executing a query, the member is picked by the
+// runtime type of the value; values of other types are an error.
+func (s *Schema) Union(name string, union interface{}, types ...interface{}) *Union {
+	if _, ok := s.unions[name]; ok {
+		panic("duplicate union")
+	}
+	u := &Union{
+		Name:  name,
+		Type:  union,
+		Types: types,
+	}
+	s.unions[name] = u
+	return u
+}
+
+// buildUnion builds the graphql.Union for the Go interface type typ.
+func (sb *schemaBuilder) buildUnion(typ reflect.Type) error {
+	if sb.types[typ] != nil {
+		return nil
+	}
+
+	union := sb.unions[typ]
+	built := &graphql.Union{
+		Name:        union.Name,
+		Description: union.Description,
+	}
+	sb.types[typ] = built
+
+	types, resolveType, err := sb.buildMembers(union.Name, typ, union.Types)
+	if err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("bad union %s: should have at least one member", union.Name)
+	}
+	built.Types = types
+	built.ResolveType = resolveType
+	return nil
+}
@@ -0,0 +1,312 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// builtinScalars are the scalars defined by the GraphQL specification, which
+// are not declared in SDL.
+var builtinScalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+// sdlDirectives declares the directives supported in queries beyond the
+// built-in @skip and @include.
+const sdlDirectives = `directive @defer(if: Boolean) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+
+directive @stream(if: Boolean, initialCount: Int) on FIELD
+`
+
+// PrintSchema returns the definition of schema in the GraphQL schema
+// definition language (SDL), for checking the schema into version control and
+// sharing it with schema tooling.
+//
+// The SDL declares the types reachable from the query and mutation objects,
+// and the directives supported in queries. Types, fields, and arguments are
+// sorted by name, so that the SDL only changes when the schema does. Like
+// introspection, the SDL omits hidden objects and fields.
+func PrintSchema(schema *Schema) string {
+	types := make(map[string]Type)
+	collectSDLTypes(schema.Query, types)
+	mutation, _ := schema.Mutation.(*Object)
+	hasMutation := mutation != nil && len(sdlFields(mutation.Fields)) > 0
+	if hasMutation {
+		collectSDLTypes(schema.Mutation, types)
+	}
+
+	// implements holds the names of the interfaces implemented by each object.
+	implements := make(map[string][]string)
+	for _, typ := range types {
+		if iface, ok := typ.(*Interface); ok {
+			for name := range iface.Types {
+				implements[name] = append(implements[name], iface.Name)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("schema {\n")
+	fmt.Fprintf(&buf, "  query: %s\n", schema.Query)
+	if hasMutation {
+		fmt.Fprintf(&buf, "  mutation: %s\n", schema.Mutation)
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString(sdlDirectives)
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		typ := types[name]
+		if scalar, ok := typ.(*Scalar); ok && builtinScalars[scalar.Type] {
+			continue
+		}
+		buf.WriteString("\n")
+
+		switch typ := typ.(type) {
+		case *Scalar:
+			fmt.Fprintf(&buf, "scalar %s\n", typ.Type)
+
+		case *Enum:
+			fmt.Fprintf(&buf, "enum %s {\n", typ.Name)
+			values := append([]string(nil), typ.Values...)
+			sort.Strings(values)
+			for _, value := range values {
+				fmt.Fprintf(&buf, "  %s\n", value)
+			}
+			buf.WriteString("}\n")
+
+		case *Object:
+			printSDLDescription(&buf, typ.Description)
+			fmt.Fprintf(&buf, "type %s", typ.Name)
+			if ifaces := implements[typ.Name]; len(ifaces) > 0 {
+				sort.Strings(ifaces)
+				fmt.Fprintf(&buf, " implements %s", strings.Join(ifaces, " & "))
+			}
+			printSDLFields(&buf, typ.Fields)
+
+		case *Interface:
+			printSDLDescription(&buf, typ.Description)
+			fmt.Fprintf(&buf, "interface %s", typ.Name)
+			printSDLFields(&buf, typ.Fields)
+
+		case *Union:
+			printSDLDescription(&buf, typ.Description)
+			members := make([]string, 0, len(typ.Types))
+			for name := range typ.Types {
+				members = append(members, name)
+			}
+			sort.Strings(members)
+			fmt.Fprintf(&buf, "union %s = %s\n", typ.Name, strings.Join(members, " | "))
+
+		case *InputObject:
+			fmt.Fprintf(&buf, "input %s {\n", typ.Name)
+			for _, name := range sortedTypeKeys(typ.InputFields) {
+				fmt.Fprintf(&buf, "  %s\n", printSDLInputValue(name, typ.InputFields[name], typ.DefaultValues))
+			}
+			buf.WriteString("}\n")
+		}
+	}
+
+	return buf.String()
+}
+
+// collectSDLTypes adds the named types reachable from typ to types, skipping
+// hidden objects and fields.
+func collectSDLTypes(typ Type, types map[string]Type) {
+	switch typ := typ.(type) {
+	case *List:
+		collectSDLTypes(typ.Type, types)
+		return
+	case *NonNull:
+		collectSDLTypes(typ.Type, types)
+		return
+	case *Object:
+		if typ.Hidden {
+			return
+		}
+	}
+
+	name := typ.String()
+	if _, ok := types[name]; ok {
+		return
+	}
+	types[name] = typ
+
+	switch typ := typ.(type) {
+	case *Object:
+		for _, field := range sdlFields(typ.Fields) {
+			collectSDLTypes(field.Type, types)
+			for _, arg := range field.Args {
+				collectSDLTypes(arg, types)
+			}
+		}
+	case *Interface:
+		for _, field := range sdlFields(typ.Fields) {
+			collectSDLTypes(field.Type, types)
+		}
+		for _, object := range typ.Types {
+			collectSDLTypes(object, types)
+		}
+	case *Union:
+		for _, object := range typ.Types {
+			collectSDLTypes(object, types)
+		}
+	case *InputObject:
+		for _, field := range typ.InputFields {
+			collectSDLTypes(field, types)
+		}
+	}
+}
+
+// sdlFields returns the fields shown in SDL, omitting hidden fields, fields of
+// hidden objects, and introspection fields.
+func sdlFields(fields map[string]*Field) map[string]*Field {
+	visible := make(map[string]*Field, len(fields))
+	for name, field := range fields {
+		if field.Hidden || isHiddenType(field.Type) || strings.HasPrefix(name, "__") {
+			continue
+		}
+		visible[name] = field
+	}
+	return visible
+}
+
+// isHiddenType returns true if typ is, or is a list of, a hidden object.
+func isHiddenType(typ Type) bool {
+	switch typ := typ.(type) {
+	case *Object:
+		return typ.Hidden
+	case *List:
+		return isHiddenType(typ.Type)
+	case *NonNull:
+		return isHiddenType(typ.Type)
+	default:
+		return false
+	}
+}
+
+// printSDLFields prints the body of an object or interface with fields.
+func printSDLFields(buf *bytes.Buffer, fields map[string]*Field) {
+	fields = sdlFields(fields)
+	buf.WriteString(" {\n")
+	for _, name := range sortedFieldKeys(fields) {
+		field := fields[name]
+		buf.WriteString("  " + name)
+		if len(field.Args) > 0 {
+			var args []string
+			for _, arg := range sortedTypeKeys(field.Args) {
+				args = append(args, printSDLInputValue(arg, field.Args[arg], field.ArgDefaults))
+			}
+			fmt.Fprintf(buf, "(%s)", strings.Join(args, ", "))
+		}
+		fmt.Fprintf(buf, ": %s", field.Type)
+		if field.DeprecationReason != "" {
+			reason, _ := json.Marshal(field.DeprecationReason)
+			fmt.Fprintf(buf, " @deprecated(reason: %s)", reason)
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+}
+
+// printSDLInputValue prints the argument or input field name of type typ,
+// with its default in defaults, if any.
+func printSDLInputValue(name string, typ Type, defaults map[string]string) string {
+	s := fmt.Sprintf("%s: %s", name, typ)
+	if encoded, ok := defaults[name]; ok {
+		var value interface{}
+		if err := json.Unmarshal([]byte(encoded), &value); err == nil {
+			s += " = " + printSDLValue(typ, value)
+		}
+	}
+	return s
+}
+
+// printSDLValue prints the JSON-decoded value of type typ as a GraphQL
+// literal.
+func printSDLValue(typ Type, value interface{}) string {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if _, ok := typ.(*Enum); ok {
+			return value
+		}
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	case []interface{}:
+		var elemType Type
+		if list, ok := typ.(*List); ok {
+			elemType = list.Type
+		}
+		elems := make([]string, 0, len(value))
+		for _, elem := range value {
+			elems = append(elems, printSDLValue(elemType, elem))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case map[string]interface{}:
+		var fieldTypes map[string]Type
+		if input, ok := typ.(*InputObject); ok {
+			fieldTypes = input.InputFields
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, 0, len(value))
+		for _, k := range keys {
+			fields = append(fields, k+": "+printSDLValue(fieldTypes[k], value[k]))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	default:
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	}
+}
+
+// printSDLDescription prints description as a block string, if it is set.
+func printSDLDescription(buf *bytes.Buffer, description string) {
+	if description == "" {
+		return
+	}
+	description = strings.Replace(description, `"""`, `\"""`, -1)
+	if strings.Contains(description, "\n") {
+		description = "\n" + description + "\n"
+	}
+	fmt.Fprintf(buf, "\"\"\"%s\"\"\"\n", description)
+}
+
+func sortedFieldKeys(fields map[string]*Field) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedTypeKeys(types map[string]Type) []string {
+	keys := make([]string, 0, len(types))
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
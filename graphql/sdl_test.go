@@ -0,0 +1,135 @@
+package graphql_test
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+type sdlColor int
+
+type sdlNode interface {
+	isSDLNode()
+}
+
+type sdlUser struct {
+	Id   int64
+	Name string
+}
+
+func (sdlUser) isSDLNode() {}
+
+type sdlPost struct {
+	Id    int64
+	Title string
+}
+
+func (sdlPost) isSDLNode() {}
+
+type sdlFilter struct {
+	Color sdlColor `graphql:",default=BLUE"`
+	Limit int64    `graphql:",default=10"`
+	Tags  []string `graphql:",default=[\"a\"]"`
+}
+
+type sdlSecret struct {
+	Value string
+}
+
+func TestPrintSchema(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Enum("Color", map[string]interface{}{
+		"RED":  sdlColor(0),
+		"BLUE": sdlColor(1),
+	})
+	schema.Interface("Node", (*sdlNode)(nil), sdlUser{}, sdlPost{}).Description = "An object with an id."
+	user := schema.Object("User", sdlUser{})
+	user.Description = "A user.\nUsers write posts."
+	schema.Object("Post", sdlPost{})
+	schema.Object("Secret", sdlSecret{}).HideFromIntrospection()
+
+	query := schema.Query()
+	query.FieldFunc("node", func(args struct{ Id int64 }) sdlNode {
+		return nil
+	})
+	query.FieldFunc("users", func(args struct {
+		Filter *sdlFilter
+		First  *int64
+	}) []*sdlUser {
+		return nil
+	})
+	query.FieldFunc("secret", func() *sdlSecret {
+		return nil
+	})
+	query.FieldFunc("me", func() *sdlUser {
+		return nil
+	}, schemabuilder.Deprecated("use node instead"))
+	query.FieldFunc("hidden", func() string {
+		return ""
+	}, schemabuilder.HideFromIntrospection)
+
+	mutation := schema.Mutation()
+	mutation.FieldFunc("paint", func(args struct{ Color sdlColor }) sdlColor {
+		return args.Color
+	})
+
+	expected := `schema {
+  query: Query
+  mutation: Mutation
+}
+
+directive @defer(if: Boolean) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+
+directive @stream(if: Boolean, initialCount: Int) on FIELD
+
+enum Color {
+  BLUE
+  RED
+}
+
+type Mutation {
+  paint(color: Color!): Color!
+}
+
+"""An object with an id."""
+interface Node {
+  id: int64!
+}
+
+type Post implements Node {
+  id: int64!
+  title: string!
+}
+
+type Query {
+  me: User @deprecated(reason: "use node instead")
+  node(id: int64!): Node
+  users(filter: sdlFilter_InputObject, first: int64): [User!]!
+}
+
+"""
+A user.
+Users write posts.
+"""
+type User implements Node {
+  id: int64!
+  name: string!
+}
+
+scalar int64
+
+input sdlFilter_InputObject {
+  color: Color = BLUE
+  limit: int64 = 10
+  tags: [string!] = ["a"]
+}
+
+scalar string
+`
+	if diff := pretty.Compare(graphql.PrintSchema(schema.MustBuild()), expected); diff != "" {
+		t.Errorf("unexpected SDL:\n%s", diff)
+	}
+}
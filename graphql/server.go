@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
@@ -20,6 +24,14 @@ import (
 const (
 	MaxSubscriptions = 200
 	MinRerunInterval = 5 * time.Second
+
+	// DefaultRerunDebounce is the default window in which mutations are
+	// coalesced into a single rerun of all subscriptions.
+	DefaultRerunDebounce = 50 * time.Millisecond
+
+	// DefaultRerunJitter is the default window across which the reruns of
+	// subscriptions triggered by a mutation are spread.
+	DefaultRerunJitter = 50 * time.Millisecond
 )
 
 type JSONSocket interface {
@@ -36,10 +48,61 @@ type GraphqlLogger interface {
 	Error(ctx context.Context, err error, tags map[string]string)
 }
 
+// ExecutionStats describes the cost of a single execution of a subscription
+// or mutation.
+type ExecutionStats struct {
+	// Initial is true for the first execution of a subscription.
+	Initial bool
+	// FieldsExecuted counts the resolvers invoked.
+	FieldsExecuted int
+	// DiffBytes is the size of the JSON-encoded update sent to the client, or
+	// 0 if no update was sent.
+	DiffBytes int
+	// BatchRoundTrips counts the batched calls made through package batch.
+	BatchRoundTrips int
+	// BatchSizes holds the number of arguments of each batched call, in
+	// order.
+	BatchSizes []int
+}
+
+// ExecutionStatsLogger is an optional interface implemented by a
+// GraphqlLogger that wants to receive ExecutionStats after every execution.
+type ExecutionStatsLogger interface {
+	ExecutionStats(ctx context.Context, tags map[string]string, stats ExecutionStats)
+}
+
+// UnsubscribeReason describes why a subscription was closed.
+type UnsubscribeReason string
+
+const (
+	// UnsubscribeClient is reported when the client unsubscribes.
+	UnsubscribeClient UnsubscribeReason = "client"
+	// UnsubscribeError is reported when a subscription fails.
+	UnsubscribeError UnsubscribeReason = "error"
+	// UnsubscribeConnectionClosed is reported for subscriptions that are
+	// open when their connection closes.
+	UnsubscribeConnectionClosed UnsubscribeReason = "connection_closed"
+)
+
+// SubscriptionLogger is an optional interface implemented by a GraphqlLogger
+// that wants to be told when subscriptions are created and closed.
+type SubscriptionLogger interface {
+	Subscribe(ctx context.Context, tags map[string]string)
+	Unsubscribe(ctx context.Context, tags map[string]string, reason UnsubscribeReason)
+}
+
 type conn struct {
 	writeMu sync.Mutex
 	socket  JSONSocket
 
+	// writeQueue, if set, buffers messages to be written asynchronously.
+	writeQueue chan OutEnvelope
+	// stopWrites stops draining writeQueue. It is closed when the connection
+	// is closed, so that the queue is still drained while the connection is
+	// parked. writesDone is closed once draining has stopped.
+	stopWrites chan struct{}
+	writesDone chan struct{}
+
 	schema         *Schema
 	mutationSchema *Schema
 	ctx            context.Context
@@ -47,12 +110,108 @@ type conn struct {
 	logger         GraphqlLogger
 	middlewares    []MiddlewareFunc
 
+	// schemaHolder, if set, holds the schema to use instead of schema and
+	// mutationSchema.
+	schemaHolder *SchemaHolder
+
+	// differ computes the updates sent to the client.
+	differ Differ
+	// initialSnapshot sends initial updates as complete results.
+	initialSnapshot bool
+
+	// tagExtractor adds tags from ctx to the tags of each subscription and
+	// mutation.
+	tagExtractor TagExtractor
+	// redactedVariables holds the names of variables redacted from tags.
+	redactedVariables map[string]bool
+	// queryLogFormatter transforms queries in tags.
+	queryLogFormatter QueryLogFormatter
+
+	// resumeWindow is how long subscriptions outlive a disconnected socket,
+	// awaiting resumption with resumeToken. See SetResumeWindow.
+	resumeWindow   time.Duration
+	resumeToken    string
+	resumeIdentity ResumeIdentityFunc
+	parkTimer      *time.Timer
+	// cancelCtx cancels ctx, if it was detached for resumption.
+	cancelCtx context.CancelFunc
+
+	partialResults  bool
+	maxConcurrency  int
+	streamChunkSize int
+
+	trackFieldUsage bool
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+
+	subscriptionKeepAlive time.Duration
+
+	complexityLimit int
+	complexityFunc  ComplexityFunc
+	maxDepth        int
+	maxFields       int
+
+	executionTimeout time.Duration
+
+	mutationLimiter RateLimiter
+
+	persistedQueries PersistedQueryStore
+
+	allowedQueries map[string]bool
+
+	queryCache *QueryCache
+
+	maxMessageBytes int64
+
+	// onConnect authenticates the connection when the client sends a connect
+	// message. connected is set once it has succeeded.
+	onConnect OnConnectFunc
+	connected bool
+
+	rerunLoopThreshold int
+	rerunLoopWindow    time.Duration
+
+	rerunDebounce time.Duration
+	rerunJitter   time.Duration
+	rerunMu       sync.Mutex
+	rerunPending  bool
+	// rerunCausedBy holds the ids of the mutations coalesced into the
+	// pending rerun.
+	rerunCausedBy []string
+
+	registry     *Registry
+	shutdownMu   sync.Mutex
+	shuttingDown bool
+	executions   sync.WaitGroup
+	// readDone is closed once ServeJSONSocket stops reading from the socket.
+	// It is guarded by shutdownMu.
+	readDone chan struct{}
+
+	// url is the page the client reported in a url message. It is only
+	// written by the read loop, under mu.
 	url string
 
 	mutateMu sync.Mutex
+	// mutationBases holds recent mutation results. It is guarded by
+	// mutateMu.
+	mutationBases mutationBases
 
 	mu            sync.Mutex
 	subscriptions map[string]*reactive.Rerunner
+	// mutations holds the runners of mutations still executing. They are
+	// kept apart from subscriptions, so that they neither count towards
+	// MaxSubscriptions nor collide with subscriptions of the same id.
+	mutations map[string]*reactive.Rerunner
+	// subscriptionTags holds the tags of open subscriptions, excluding
+	// mutations.
+	subscriptionTags map[string]map[string]string
+	// groups holds the ids of the subscriptions of each subscription group.
+	groups map[string][]string
+	// keepAlives holds the keepalives of subscriptions, if enabled.
+	keepAlives map[string]*subscriptionKeepAlive
+	// rerunCauses holds the mutations triggering reruns of subscriptions.
+	rerunCauses map[string]*rerunCauses
 }
 
 type InEnvelope struct {
@@ -65,17 +224,45 @@ type OutEnvelope struct {
 	ID       string                 `json:"id,omitempty"`
 	Type     string                 `json:"type"`
 	Message  interface{}            `json:"message,omitempty"`
+	Errors   []ResponseError        `json:"errors,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 type subscribeMessage struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables"`
+
+	// OperationName selects the operation to run if Query holds several
+	// named operations.
+	OperationName string `json:"operationName"`
+
+	// SHA256Hash or Extensions, if set, identify a persisted query. See
+	// SetPersistedQueryStore.
+	SHA256Hash string                    `json:"sha256Hash"`
+	Extensions *persistedQueryExtensions `json:"extensions"`
+
+	// Operations, if set, subscribes to several operations at once as a
+	// group. See handleSubscribeGroup.
+	Operations []*subscribeMessage `json:"operations"`
 }
 
 type mutateMessage struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables"`
+
+	// OperationName selects the operation to run if Query holds several
+	// named operations.
+	OperationName string `json:"operationName"`
+
+	// SHA256Hash or Extensions, if set, identify a persisted query. See
+	// SetPersistedQueryStore.
+	SHA256Hash string                    `json:"sha256Hash"`
+	Extensions *persistedQueryExtensions `json:"extensions"`
+
+	// BaseVersion, if set, is the id of an earlier mutation on the
+	// connection. The result is then sent as a diff against the result of
+	// that mutation, if it is still kept.
+	BaseVersion string `json:"baseVersion"`
 }
 
 type SanitizedError interface {
@@ -85,6 +272,11 @@ type SanitizedError interface {
 
 type SafeError struct {
 	message string
+
+	// Code, if set, is sent to clients as the code entry of the error's
+	// extensions, so that they can tell categories of errors apart, such as
+	// "UNAUTHENTICATED" or "BAD_USER_INPUT".
+	Code string
 }
 
 type ClientError SafeError
@@ -105,38 +297,164 @@ func (e SafeError) SanitizedError() string {
 	return e.message
 }
 
+func (e ClientError) Extensions() map[string]interface{} {
+	return SafeError(e).Extensions()
+}
+
+func (e SafeError) Extensions() map[string]interface{} {
+	if e.Code == "" {
+		return nil
+	}
+	return map[string]interface{}{"code": e.Code}
+}
+
 func NewClientError(format string, a ...interface{}) error {
 	return ClientError{message: fmt.Sprintf(format, a...)}
 }
 
+// NewClientErrorWithCode returns a ClientError with an error code, sent to
+// clients in the extensions of the error.
+func NewClientErrorWithCode(code string, format string, a ...interface{}) error {
+	return ClientError{message: fmt.Sprintf(format, a...), Code: code}
+}
+
 func NewSafeError(format string, a ...interface{}) error {
 	return SafeError{message: fmt.Sprintf(format, a...)}
 }
 
+// NewSafeErrorWithCode returns a SafeError with an error code, sent to
+// clients in the extensions of the error.
+func NewSafeErrorWithCode(code string, format string, a ...interface{}) error {
+	return SafeError{message: fmt.Sprintf(format, a...), Code: code}
+}
+
 func sanitizeError(err error) string {
 	if sanitized, ok := err.(SanitizedError); ok {
 		return sanitized.SanitizedError()
 	}
+	if extractPathError(err) == context.DeadlineExceeded {
+		return "Execution timed out"
+	}
 	return "Internal server error"
 }
 
+// ExtendedError is a SanitizedError that supplies additional information,
+// such as an error code, to clients in the extensions entry of its error.
+type ExtendedError interface {
+	SanitizedError
+	Extensions() map[string]interface{}
+}
+
+// ResponseError is an entry in the errors array of a GraphQL response.
+type ResponseError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// formatErrors converts err into ResponseErrors, with one for each error of
+// ValidationErrors.
+func formatErrors(err error, query *Query) []ResponseError {
+	validationErrs, ok := err.(ValidationErrors)
+	if !ok {
+		return []ResponseError{formatError(err, query)}
+	}
+	formatted := make([]ResponseError, 0, len(validationErrs))
+	for _, err := range validationErrs {
+		formatted = append(formatted, formatError(err, query))
+	}
+	return formatted
+}
+
+// formatError converts err into a ResponseError, including the path of the
+// field that failed. The query err was returned for may be nil.
+func formatError(err error, query *Query) ResponseError {
+	response := ResponseError{Message: sanitizeError(err)}
+
+	path := errorPath(err)
+	// Execute nests errors under the operation name, which is not part of a
+	// response path.
+	if query != nil && query.Name != "" && len(path) > 0 && path[0] == query.Name {
+		path = path[1:]
+	}
+	for _, key := range path {
+		// List indices are numbers in response paths.
+		if index, err := strconv.Atoi(key); err == nil {
+			response.Path = append(response.Path, index)
+		} else {
+			response.Path = append(response.Path, key)
+		}
+	}
+
+	if extended, ok := extractPathError(err).(ExtendedError); ok {
+		response.Extensions = extended.Extensions()
+	}
+	return response
+}
+
+// errorEnvelope returns an error message for err. The message holds the
+// sanitized error as well as a GraphQL errors array.
+func errorEnvelope(id string, err error, query *Query, metadata map[string]interface{}) OutEnvelope {
+	return OutEnvelope{
+		ID:       id,
+		Type:     "error",
+		Message:  sanitizeError(err),
+		Errors:   formatErrors(err, query),
+		Metadata: metadata,
+	}
+}
+
 func isCloseError(err error) bool {
 	_, ok := err.(*websocket.CloseError)
 	return ok || err == websocket.ErrCloseSent
 }
 
 func (c *conn) writeOrClose(out OutEnvelope) {
+	if c.writeQueue != nil {
+		c.enqueueWrite(out)
+		return
+	}
+	c.writeNow(out)
+}
+
+func (c *conn) writeNow(out OutEnvelope) {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 
 	if err := c.socket.WriteJSON(out); err != nil {
+		if err == errParkedBufferFull {
+			// Closing stops subscriptions, which may be the caller.
+			go c.closeParked()
+			return
+		}
 		if !isCloseError(err) {
-			c.socket.Close()
+			c.closeWithReason(websocket.CloseInternalServerErr, "write failed")
 			log.Printf("socket.WriteJSON: %s\n", err)
 		}
 	}
 }
 
+// logStats reports stats to c.logger if it implements ExecutionStatsLogger.
+// The update message sent to the client is only serialized to determine its
+// size if stats are wanted.
+func (c *conn) logStats(ctx context.Context, tags map[string]string, e *Executor, initial bool, message interface{}) {
+	logger, ok := c.logger.(ExecutionStatsLogger)
+	if !ok {
+		return
+	}
+
+	stats := ExecutionStats{
+		Initial:         initial,
+		FieldsExecuted:  e.FieldsExecuted(),
+		BatchRoundTrips: batch.RoundTrips(ctx),
+		BatchSizes:      batch.BatchSizes(ctx),
+	}
+	if message != nil {
+		stats.DiffBytes = len(mustMarshalJson(message))
+	}
+	logger.ExecutionStats(ctx, tags, stats)
+}
+
 func mustMarshalJson(v interface{}) string {
 	bytes, err := json.Marshal(v)
 	if err != nil {
@@ -149,145 +467,283 @@ func (c *conn) handleSubscribe(id string, subscribe *subscribeMessage) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if err := c.checkAllowed(subscribe.Query); err != nil {
+		return err
+	}
+
 	if _, ok := c.subscriptions[id]; ok {
 		return NewSafeError("duplicate subscription")
 	}
+	if _, ok := c.groups[id]; ok {
+		return NewSafeError("duplicate subscription")
+	}
 
 	if len(c.subscriptions)+1 > MaxSubscriptions {
 		return NewSafeError("too many subscriptions")
 	}
 
-	tags := map[string]string{"url": c.url, "query": subscribe.Query, "queryVariables": mustMarshalJson(subscribe.Variables), "id": id}
+	tags := map[string]string{"url": c.url, "query": c.queryTag(subscribe.Query), "queryVariables": c.variablesTag(subscribe.Variables), "id": id}
 
-	query, err := Parse(subscribe.Query, subscribe.Variables)
+	query, err := c.queryCache.ParseOperation(subscribe.Query, subscribe.Variables, subscribe.OperationName)
 	if query != nil {
 		tags["queryType"] = query.Kind
 		tags["queryName"] = query.Name
 	}
+	c.addExtractedTags(tags)
 	if err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
-	if err := PrepareQuery(c.schema.Query, query.SelectionSet); err != nil {
+	if err := c.checkDepth(query); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+	if err := c.checkFieldCount(query); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+	// The subscription keeps using the schema its query was prepared
+	// against, even if the schema is reloaded.
+	schema, _ := c.schemas()
+	if err := prepareQuery(schema, schema.Query, query.SelectionSet); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+	c.logDeprecatedFields(schema.Query, query.SelectionSet, tags)
+	c.logFieldUsage(schema.Query, query.SelectionSet, tags)
+	if err := c.checkComplexity(query); err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
 
 	var previous interface{}
+	var previousErrors []ResponseError
 
-	e := Executor{}
+	e := Executor{PartialResults: c.partialResults, MaxConcurrency: c.maxConcurrency}
 
 	initial := true
+	loop := c.newRerunLoopDetector()
+	keepAlive := c.newSubscriptionKeepAlive(id)
+	if keepAlive != nil {
+		c.keepAlives[id] = keepAlive
+	}
+	causes := &rerunCauses{}
+	c.rerunCauses[id] = causes
 	c.subscriptions[id] = reactive.NewRerunner(c.ctx, func(ctx context.Context) (interface{}, error) {
+		if !c.startExecution() {
+			return nil, errShuttingDown
+		}
+		defer c.executions.Done()
+
+		ctx, cancel := c.withExecutionTimeout(ctx)
+		defer cancel()
+
 		ctx = c.makeCtx(ctx)
 		ctx = batch.WithBatching(ctx)
 
-		start := time.Now()
+		// The initial execution of a query with deferred fragments or streamed
+		// lists leaves them out, so that the rest of the result is sent first.
+		// The deferred fragments then follow in an update from a second
+		// execution, and the remaining elements of streamed lists in updates
+		// from further executions, each appending streamChunkSize elements.
+		rerun := !initial
+		skipDeferred := initial && isIncremental(query.SelectionSet)
+		truncateStreams := skipDeferred
+		streamedElements := 0
+		var causedBy []string
+		if rerun {
+			causedBy = causes.take()
+		}
+		for {
+			start := time.Now()
+
+			c.logger.StartExecution(ctx, tags, initial)
+
+			truncated := false
+			var middlewares []MiddlewareFunc
+			middlewares = append(middlewares, c.middlewares...)
+			middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+				output := next(input)
+				e.SkipDeferred = skipDeferred
+				e.TruncateStreams = truncateStreams
+				e.StreamedElements = streamedElements
+				output.Current, output.Error = e.Execute(input.Ctx, schema.Query, nil, input.ParsedQuery)
+				output.FieldErrors = e.FieldErrors()
+				truncated = e.TruncatedStreams()
+				return output
+			})
 
-		c.logger.StartExecution(ctx, tags, initial)
+			output := runMiddlewares(middlewares, &ComputationInput{
+				Ctx:         ctx,
+				Id:          id,
+				ParsedQuery: query,
+				Previous:    previous,
+				Query:       subscribe.Query,
+				Variables:   subscribe.Variables,
+			})
+			current, err := output.Current, output.Error
 
-		var middlewares []MiddlewareFunc
-		middlewares = append(middlewares, c.middlewares...)
-		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
-			output := next(input)
-			output.Current, output.Error = e.Execute(input.Ctx, c.schema.Query, nil, input.ParsedQuery)
-			return output
-		})
+			c.logger.FinishExecution(ctx, tags, time.Since(start))
 
-		output := runMiddlewares(middlewares, &ComputationInput{
-			Ctx:         ctx,
-			Id:          id,
-			ParsedQuery: query,
-			Previous:    previous,
-			Query:       subscribe.Query,
-			Variables:   subscribe.Variables,
-		})
-		current, err := output.Current, output.Error
+			if err != nil {
+				if extractPathError(err) == context.Canceled {
+					go c.closeSubscription(id, UnsubscribeConnectionClosed)
+					return nil, err
+				}
 
-		c.logger.FinishExecution(ctx, tags, time.Since(start))
+				if !initial {
+					// If this a re-computation, tell the Rerunner to retry the computation
+					// without dumping the contents of the current computation cache.
+					// Note that we are swallowing the propagation of the error in this case,
+					// but we still log it.
+					if _, ok := err.(SanitizedError); !ok {
+						extraTags := map[string]string{"retry": "true"}
+						for k, v := range tags {
+							extraTags[k] = v
+						}
+						c.logger.Error(ctx, err, extraTags)
+					}
 
-		if err != nil {
-			if extractPathError(err) == context.Canceled {
-				go c.closeSubscription(id)
-				return nil, err
-			}
+					// The retry is still caused by the same mutations.
+					causes.add(causedBy)
+					return nil, reactive.RetrySentinelError
+				}
+
+				c.writeOrClose(errorEnvelope(id, err, query, output.Metadata))
+				go c.closeSubscription(id, UnsubscribeError)
 
-			if !initial {
-				// If this a re-computation, tell the Rerunner to retry the computation
-				// without dumping the contents of the current computation cache.
-				// Note that we are swallowing the propagation of the error in this case,
-				// but we still log it.
 				if _, ok := err.(SanitizedError); !ok {
-					extraTags := map[string]string{"retry": "true"}
-					for k, v := range tags {
-						extraTags[k] = v
-					}
-					c.logger.Error(ctx, err, extraTags)
+					c.logger.Error(ctx, err, tags)
 				}
-
-				return nil, reactive.RetrySentinelError
+				return nil, err
 			}
 
-			c.writeOrClose(OutEnvelope{
-				ID:       id,
-				Type:     "error",
-				Message:  sanitizeError(err),
-				Metadata: output.Metadata,
-			})
-			go c.closeSubscription(id)
-
-			if _, ok := err.(SanitizedError); !ok {
-				c.logger.Error(ctx, err, tags)
+			c.logFieldErrors(ctx, output.FieldErrors, tags)
+			fieldErrors := formatFieldErrors(output.FieldErrors, query)
+			errorsChanged := !reflect.DeepEqual(fieldErrors, previousErrors)
+			previousErrors = fieldErrors
+
+			snapshot := initial && c.initialSnapshot
+			var message interface{}
+			if snapshot {
+				message = diff.StripKey(current)
+			} else {
+				message = c.differ.Diff(previous, current)
 			}
-			return nil, err
-		}
+			// The initial update is always sent, even if its diff is empty, so
+			// that clients know the subscription is live.
+			send := initial || message != nil || errorsChanged
+			// Only advance previous when an update is sent, so that changes the
+			// Differ leaves out of a diff, such as ignored fields, are sent with
+			// the next update.
+			if send {
+				previous = current
+			}
+			wasInitial := initial
+			initial = false
+			incomplete := skipDeferred || truncated
+
+			if send {
+				metadata := c.updateMetadata(output.Metadata)
+				if snapshot {
+					metadata = withMetadata(metadata, SnapshotKey, true)
+				}
+				if incomplete {
+					metadata = withMetadata(metadata, DeferredKey, true)
+				}
+				if len(causedBy) > 0 {
+					metadata = withMetadata(metadata, CausedByKey, causedBy)
+				}
+				c.writeOrClose(OutEnvelope{
+					ID:       id,
+					Type:     "update",
+					Message:  message,
+					Errors:   fieldErrors,
+					Metadata: metadata,
+				})
+				keepAlive.touch()
+			}
+			c.logStats(ctx, tags, &e, wasInitial, message)
 
-		d := diff.Diff(previous, current)
-		previous = current
-		initial = false
+			if rerun {
+				if err := loop.observe(time.Now(), message != nil); err != nil {
+					c.logger.Error(ctx, err, tags)
+				}
+				if loop.looping {
+					reactive.Backoff(ctx)
+				}
+			}
 
-		if initial || d != nil {
-			c.writeOrClose(OutEnvelope{
-				ID:       id,
-				Type:     "update",
-				Message:  d,
-				Metadata: output.Metadata,
-			})
+			if !incomplete {
+				return nil, nil
+			}
+			skipDeferred = false
+			streamedElements += c.streamChunkSize
 		}
-
-		return nil, nil
 	}, MinRerunInterval)
+	c.logSubscribe(id, tags)
 
 	return nil
 }
 
 func (c *conn) handleMutate(id string, mutate *mutateMessage) error {
+	if err := c.checkAllowed(mutate.Query); err != nil {
+		return err
+	}
+	if err := c.checkMutationRate(); err != nil {
+		return err
+	}
+
 	// TODO: deduplicate code
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	tags := map[string]string{"url": c.url, "query": mutate.Query, "queryVariables": mustMarshalJson(mutate.Variables), "id": id}
+	tags := map[string]string{"url": c.url, "query": c.queryTag(mutate.Query), "queryVariables": c.variablesTag(mutate.Variables), "id": id}
 
-	query, err := Parse(mutate.Query, mutate.Variables)
+	query, err := c.queryCache.ParseOperation(mutate.Query, mutate.Variables, mutate.OperationName)
 	if query != nil {
 		tags["queryType"] = query.Kind
 		tags["queryName"] = query.Name
 	}
+	c.addExtractedTags(tags)
 	if err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
-	if err := PrepareQuery(c.mutationSchema.Mutation, query.SelectionSet); err != nil {
+	if err := c.checkDepth(query); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+	if err := c.checkFieldCount(query); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+	_, mutationSchema := c.schemas()
+	if err := prepareQuery(mutationSchema, mutationSchema.Mutation, query.SelectionSet); err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
+	c.logDeprecatedFields(mutationSchema.Mutation, query.SelectionSet, tags)
+	c.logFieldUsage(mutationSchema.Mutation, query.SelectionSet, tags)
+
+	e := Executor{PartialResults: c.partialResults, MaxConcurrency: c.maxConcurrency}
+	var runner *reactive.Rerunner
+	runner = reactive.NewRerunner(c.ctx, func(ctx context.Context) (interface{}, error) {
+		// Mutations run once.
+		defer func() { go c.finishMutation(id, &runner) }()
 
-	e := Executor{}
-	c.subscriptions[id] = reactive.NewRerunner(c.ctx, func(ctx context.Context) (interface{}, error) {
 		// Serialize all mutates for a given connection.
 		c.mutateMu.Lock()
 		defer c.mutateMu.Unlock()
 
+		if !c.startExecution() {
+			return nil, errShuttingDown
+		}
+		defer c.executions.Done()
+
+		ctx, cancel := c.withExecutionTimeout(ctx)
+		defer cancel()
+
 		ctx = c.makeCtx(ctx)
 		ctx = batch.WithBatching(ctx)
 
@@ -298,7 +754,8 @@ func (c *conn) handleMutate(id string, mutate *mutateMessage) error {
 		middlewares = append(middlewares, c.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
-			output.Current, output.Error = e.Execute(input.Ctx, c.mutationSchema.Mutation, c.mutationSchema.Mutation, query)
+			output.Current, output.Error = e.Execute(input.Ctx, mutationSchema.Mutation, mutationSchema.Mutation, input.ParsedQuery)
+			output.FieldErrors = e.FieldErrors()
 			return output
 		})
 
@@ -315,14 +772,7 @@ func (c *conn) handleMutate(id string, mutate *mutateMessage) error {
 		c.logger.FinishExecution(ctx, tags, time.Since(start))
 
 		if err != nil {
-			c.writeOrClose(OutEnvelope{
-				ID:       id,
-				Type:     "error",
-				Message:  sanitizeError(err),
-				Metadata: output.Metadata,
-			})
-
-			go c.closeSubscription(id)
+			c.writeOrClose(errorEnvelope(id, err, query, output.Metadata))
 
 			if extractPathError(err) == context.Canceled {
 				return nil, err
@@ -334,37 +784,139 @@ func (c *conn) handleMutate(id string, mutate *mutateMessage) error {
 			return nil, err
 		}
 
+		c.logFieldErrors(ctx, output.FieldErrors, tags)
+
+		metadata := c.updateMetadata(output.Metadata)
+		var base interface{}
+		if mutate.BaseVersion != "" {
+			var ok bool
+			if base, ok = c.mutationBases.get(mutate.BaseVersion); ok {
+				metadata = withMetadata(metadata, BaseVersionKey, mutate.BaseVersion)
+			}
+		}
+		c.mutationBases.put(id, current)
+
+		message := c.differ.Diff(base, current)
 		c.writeOrClose(OutEnvelope{
 			ID:       id,
 			Type:     "result",
-			Message:  diff.Diff(nil, current),
-			Metadata: output.Metadata,
+			Message:  message,
+			Errors:   formatFieldErrors(output.FieldErrors, query),
+			Metadata: metadata,
 		})
+		c.logStats(ctx, tags, &e, true, message)
 
-		go c.rerunSubscriptionsImmediately()
+		go c.rerunSubscriptionsImmediately(id)
 
 		return nil, errors.New("stop")
 	}, MinRerunInterval)
+	c.mutations[id] = runner
 
 	return nil
 }
 
-func (c *conn) rerunSubscriptionsImmediately() {
+// SetRerunDebounce sets the window in which mutations are coalesced into a
+// single rerun of all subscriptions. A zero window reruns subscriptions after
+// every mutation.
+func (c *conn) SetRerunDebounce(window time.Duration) {
+	c.rerunDebounce = window
+}
+
+// SetRerunJitter sets the window across which the reruns of subscriptions
+// triggered by a mutation are spread. Each subscription reruns after a random
+// delay within window, so that a connection with many subscriptions does not
+// execute them all at once. A zero window reruns all subscriptions at once.
+func (c *conn) SetRerunJitter(window time.Duration) {
+	c.rerunJitter = window
+}
+
+// rerunSubscriptionsImmediately reruns all subscriptions once the rerun
+// debounce window has passed, on behalf of the mutation causedBy. Calls made
+// while a rerun is pending are coalesced into that rerun.
+func (c *conn) rerunSubscriptionsImmediately(causedBy string) {
+	if c.rerunDebounce <= 0 {
+		c.rerunSubscriptions([]string{causedBy})
+		return
+	}
+
+	c.rerunMu.Lock()
+	defer c.rerunMu.Unlock()
+
+	c.rerunCausedBy = append(c.rerunCausedBy, causedBy)
+	if c.rerunPending {
+		return
+	}
+	c.rerunPending = true
+
+	time.AfterFunc(c.rerunDebounce, func() {
+		c.rerunMu.Lock()
+		c.rerunPending = false
+		causedBy := c.rerunCausedBy
+		c.rerunCausedBy = nil
+		c.rerunMu.Unlock()
+
+		c.rerunSubscriptions(causedBy)
+	})
+}
+
+// rerunSubscriptions reruns all subscriptions, spread across the rerun
+// jitter window, recording the mutations causedBy as the cause of the reruns.
+func (c *conn) rerunSubscriptions(causedBy []string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, runner := range c.subscriptions {
-		runner.RerunImmediately()
+	for id, runner := range c.subscriptions {
+		if causes, ok := c.rerunCauses[id]; ok {
+			causes.add(causedBy)
+		}
+		if c.rerunJitter <= 0 {
+			runner.RerunImmediately()
+			continue
+		}
+		time.AfterFunc(time.Duration(rand.Int63n(int64(c.rerunJitter))), runner.RerunImmediately)
 	}
 }
 
-func (c *conn) closeSubscription(id string) {
+// finishMutation stops the runner of mutation id once it has run. runner
+// points to the runner, which is set with c.mu held. The runner is only
+// forgotten if it is still the one registered for id, as a later mutation
+// may have reused the id while it was running.
+func (c *conn) finishMutation(id string, runner **reactive.Rerunner) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	(*runner).Stop()
+	if c.mutations[id] == *runner {
+		delete(c.mutations, id)
+	}
+}
+
+func (c *conn) closeSubscription(id string, reason UnsubscribeReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if members, ok := c.groups[id]; ok {
+		delete(c.groups, id)
+		for _, member := range members {
+			c.stopSubscription(member, reason)
+		}
+		return
+	}
+	c.stopSubscription(id, reason)
+}
+
+// stopSubscription stops the subscription id, if it is open. c.mu must be
+// held.
+func (c *conn) stopSubscription(id string, reason UnsubscribeReason) {
+	if keepAlive, ok := c.keepAlives[id]; ok {
+		keepAlive.stop()
+		delete(c.keepAlives, id)
+	}
+	delete(c.rerunCauses, id)
 	if runner, ok := c.subscriptions[id]; ok {
 		runner.Stop()
 		delete(c.subscriptions, id)
+		c.logUnsubscribe(id, reason)
 	}
 }
 
@@ -375,23 +927,86 @@ func (c *conn) closeSubscriptions() {
 	for id, runner := range c.subscriptions {
 		runner.Stop()
 		delete(c.subscriptions, id)
+		c.logUnsubscribe(id, UnsubscribeConnectionClosed)
+	}
+	for id, runner := range c.mutations {
+		runner.Stop()
+		delete(c.mutations, id)
+	}
+	for id := range c.groups {
+		delete(c.groups, id)
+	}
+	for id, keepAlive := range c.keepAlives {
+		keepAlive.stop()
+		delete(c.keepAlives, id)
+	}
+	for id := range c.rerunCauses {
+		delete(c.rerunCauses, id)
+	}
+}
+
+// logSubscribe records the tags of subscription id and reports it to
+// c.logger if it implements SubscriptionLogger. c.mu must be held.
+func (c *conn) logSubscribe(id string, tags map[string]string) {
+	c.subscriptionTags[id] = tags
+	if logger, ok := c.logger.(SubscriptionLogger); ok {
+		logger.Subscribe(c.ctx, tags)
+	}
+}
+
+// logUnsubscribe reports that subscription id closed to c.logger if it
+// implements SubscriptionLogger. Mutations are not reported. c.mu must be
+// held.
+func (c *conn) logUnsubscribe(id string, reason UnsubscribeReason) {
+	tags, ok := c.subscriptionTags[id]
+	if !ok {
+		return
+	}
+	delete(c.subscriptionTags, id)
+	if logger, ok := c.logger.(SubscriptionLogger); ok {
+		logger.Unsubscribe(c.ctx, tags, reason)
 	}
 }
 
 type WebsocketWriter func(e OutEnvelope)
 type WebsocketHandler func(e *InEnvelope, write WebsocketWriter) error
 
+// ErrHandled is returned by a WebsocketHandler that has handled a message, so
+// that no further handlers, including the default handler, run for it.
+var ErrHandled = errors.New("handled")
+
 func (c *conn) handle(e *InEnvelope, write WebsocketWriter) error {
 	switch e.Type {
+	case "subscribe", "mutate", "operation":
+		if c.isShuttingDown() {
+			return errShuttingDown
+		}
+		if err := c.checkConnected(); err != nil {
+			return err
+		}
+	}
+
+	switch e.Type {
+	case "connect":
+		return c.handleConnect(e.Message)
+
 	case "subscribe":
 		var subscribe subscribeMessage
 		if err := json.Unmarshal(e.Message, &subscribe); err != nil {
 			return err
 		}
+		if len(subscribe.Operations) > 0 {
+			return c.handleSubscribeGroup(e.ID, subscribe.Operations)
+		}
+		query, err := c.resolvePersistedQuery(subscribe.Query, persistedQueryHash(subscribe.SHA256Hash, subscribe.Extensions))
+		if err != nil {
+			return err
+		}
+		subscribe.Query = query
 		return c.handleSubscribe(e.ID, &subscribe)
 
 	case "unsubscribe":
-		c.closeSubscription(e.ID)
+		c.closeSubscription(e.ID, UnsubscribeClient)
 		return nil
 
 	case "mutate":
@@ -399,8 +1014,16 @@ func (c *conn) handle(e *InEnvelope, write WebsocketWriter) error {
 		if err := json.Unmarshal(e.Message, &mutate); err != nil {
 			return err
 		}
+		query, err := c.resolvePersistedQuery(mutate.Query, persistedQueryHash(mutate.SHA256Hash, mutate.Extensions))
+		if err != nil {
+			return err
+		}
+		mutate.Query = query
 		return c.handleMutate(e.ID, &mutate)
 
+	case "operation":
+		return c.handleOperation(e.ID, e.Message)
+
 	case "echo":
 		write(OutEnvelope{
 			ID:       e.ID,
@@ -415,7 +1038,9 @@ func (c *conn) handle(e *InEnvelope, write WebsocketWriter) error {
 		if err := json.Unmarshal(e.Message, &url); err != nil {
 			return err
 		}
+		c.mu.Lock()
 		c.url = url
+		c.mu.Unlock()
 		return nil
 
 	default:
@@ -423,7 +1048,28 @@ func (c *conn) handle(e *InEnvelope, write WebsocketWriter) error {
 	}
 }
 
+// simpleLogger is a GraphqlLogger that only logs errors.
 type simpleLogger struct {
+	// logger receives errors as text, or the standard logger if nil.
+	logger *log.Logger
+
+	// jsonOut, if set, receives errors as JSON lines instead.
+	jsonOut io.Writer
+	jsonMu  sync.Mutex
+}
+
+// NewSimpleLogger returns a GraphqlLogger that logs errors with their tags to
+// logger, and ignores executions. It is the default logger of Handler, which
+// logs to the standard logger.
+func NewSimpleLogger(logger *log.Logger) GraphqlLogger {
+	return &simpleLogger{logger: logger}
+}
+
+// NewJSONLogger returns a GraphqlLogger that writes errors to out as JSON
+// lines, and ignores executions. Each line is an object with the fields
+// "time", "error", and "tags".
+func NewJSONLogger(out io.Writer) GraphqlLogger {
+	return &simpleLogger{jsonOut: out}
 }
 
 func (s *simpleLogger) StartExecution(ctx context.Context, tags map[string]string, initial bool) {
@@ -431,19 +1077,173 @@ func (s *simpleLogger) StartExecution(ctx context.Context, tags map[string]strin
 func (s *simpleLogger) FinishExecution(ctx context.Context, tags map[string]string, delay time.Duration) {
 }
 func (s *simpleLogger) Error(ctx context.Context, err error, tags map[string]string) {
+	if s.jsonOut != nil {
+		line, _ := json.Marshal(map[string]interface{}{
+			"time":  time.Now().UTC().Format(time.RFC3339Nano),
+			"error": err.Error(),
+			"tags":  tags,
+		})
+		s.jsonMu.Lock()
+		defer s.jsonMu.Unlock()
+		s.jsonOut.Write(append(line, '\n'))
+		return
+	}
+	if s.logger != nil {
+		s.logger.Printf("error:%v\n%s", tags, err)
+		return
+	}
 	log.Printf("error:%v\n%s", tags, err)
 }
 
+// HandlerOptions configures the websocket handler created by
+// HandlerWithOptions.
+type HandlerOptions struct {
+	// CheckOrigin returns true if a request's Origin header is acceptable. If
+	// CheckOrigin is nil, all origins are accepted.
+	CheckOrigin func(r *http.Request) bool
+
+	// ReadBufferSize and WriteBufferSize specify websocket I/O buffer sizes in
+	// bytes. A zero size uses a 1024 byte buffer.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// EnableCompression negotiates permessage-deflate compression with
+	// clients that support it. Each message written by the connection is
+	// compressed as it is streamed to the socket by WriteJSON, so
+	// compression does not add another buffer on top of the write buffer.
+	EnableCompression bool
+
+	// WriteBuffer, if positive, makes writes asynchronous with a buffer of
+	// WriteBuffer messages. See SetWriteBuffer.
+	WriteBuffer int
+
+	// OnConnect, if set, authenticates connections when the client sends a
+	// connect message. See SetOnConnect.
+	OnConnect OnConnectFunc
+
+	// MakeCtx, if set, is called once with the upgrade request and returns
+	// the base context for all computations on the connection. It can be used
+	// to authenticate the request and add the principal to the context.
+	MakeCtx func(ctx context.Context, r *http.Request) context.Context
+
+	// MaxMessageBytes, if positive, overrides DefaultMaxMessageBytes as the
+	// limit on the size of messages read from clients. See
+	// SetMaxMessageBytes.
+	MaxMessageBytes int64
+
+	// PersistedQueries, if set, enables automatic persisted queries. See
+	// SetPersistedQueryStore.
+	PersistedQueries PersistedQueryStore
+
+	// AllowedQueries, if set, restricts connections to the queries whose
+	// QueryHash is in AllowedQueries. See SetAllowedQueries.
+	AllowedQueries map[string]bool
+
+	// ResumeWindow, if positive, lets clients of the native protocol resume
+	// their subscriptions after disconnecting for up to ResumeWindow. See
+	// SetResumeWindow.
+	ResumeWindow time.Duration
+
+	// ResumeIdentity, if set, restricts resuming a connection to connections
+	// authenticated as the same principal. See SetResumeIdentity.
+	ResumeIdentity ResumeIdentityFunc
+
+	// TagExtractor, if set, adds tags from each connection's context to the
+	// tags passed to Logger. See SetTagExtractor.
+	TagExtractor TagExtractor
+
+	// RedactedVariables lists the names of sensitive variables whose values
+	// are redacted from the tags passed to Logger. See SetRedactedVariables.
+	RedactedVariables []string
+
+	// QueryLogFormatter, if set, transforms the queries in the tags passed
+	// to Logger. See SetQueryLogFormatter.
+	QueryLogFormatter QueryLogFormatter
+
+	// TrackFieldUsage reports the fields selected by each subscription and
+	// mutation to Logger, if it implements FieldUsageLogger. See
+	// SetFieldUsageTracking.
+	TrackFieldUsage bool
+
+	// SchemaHolder, if set, holds the schema served instead of the schema
+	// passed to HandlerWithOptions, so that it can be reloaded without
+	// dropping connections. See SetSchemaHolder.
+	SchemaHolder *SchemaHolder
+
+	// Registry, if set, holds the handler's connections instead of
+	// DefaultRegistry. See SetRegistry.
+	Registry *Registry
+
+	// MaxConnections, if positive, limits the number of connections in
+	// Registry, including connections awaiting resumption. Upgrade requests
+	// beyond the limit are rejected with a 503 Service Unavailable.
+	MaxConnections int
+
+	// ConnectionQuota, if set, limits the concurrent connections of each
+	// client, keyed by ConnectionKey. Upgrade requests beyond a client's
+	// quota are rejected with a 429 Too Many Requests. A connection counts
+	// against its client's quota until its socket disconnects.
+	ConnectionQuota ConnectionQuota
+
+	// ConnectionKey returns the key identifying the client of an upgrade
+	// request in ConnectionQuota, such as an authenticated principal. If
+	// ConnectionKey is nil, clients are keyed by RemoteIP. Requests with an
+	// empty key are not limited.
+	ConnectionKey func(r *http.Request) string
+
+	// Logger, if set, receives execution events for all connections. If
+	// Logger is nil, errors are logged with the standard library logger.
+	Logger GraphqlLogger
+}
+
 func Handler(schema *Schema) http.Handler {
-	upgrader := &websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin: func(r *http.Request) bool {
+	return HandlerWithOptions(schema, HandlerOptions{})
+}
+
+// HandlerWithOptions is like Handler, but configures the websocket upgrade
+// with opts.
+func HandlerWithOptions(schema *Schema, opts HandlerOptions) http.Handler {
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = func(r *http.Request) bool {
 			return true
-		},
+		}
+	}
+	readBufferSize := opts.ReadBufferSize
+	if readBufferSize == 0 {
+		readBufferSize = 1024
+	}
+	writeBufferSize := opts.WriteBufferSize
+	if writeBufferSize == 0 {
+		writeBufferSize = 1024
+	}
+
+	upgrader := &websocket.Upgrader{
+		ReadBufferSize:    readBufferSize,
+		WriteBufferSize:   writeBufferSize,
+		CheckOrigin:       checkOrigin,
+		Subprotocols:      []string{GraphQLWSProtocol},
+		EnableCompression: opts.EnableCompression,
+	}
+
+	registry := opts.Registry
+	if registry == nil {
+		registry = DefaultRegistry
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		releaseQuota, ok := acquireConnectionQuota(w, r, opts.ConnectionQuota, opts.ConnectionKey)
+		if !ok {
+			return
+		}
+		defer releaseQuota()
+
+		release, ok := reserveConnection(w, registry, opts.MaxConnections)
+		if !ok {
+			return
+		}
+		defer release()
+
 		socket, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Printf("upgrader.Upgrade: %v", err)
@@ -451,11 +1251,56 @@ func Handler(schema *Schema) http.Handler {
 		}
 		defer socket.Close()
 
+		// Write compression only takes effect if the client negotiated it.
+		socket.EnableWriteCompression(opts.EnableCompression)
+
+		ctx := r.Context()
+		if opts.MakeCtx != nil {
+			ctx = opts.MakeCtx(ctx, r)
+		}
+
 		makeCtx := func(ctx context.Context) context.Context {
 			return ctx
 		}
 
-		ServeJSONSocket(r.Context(), socket, schema, makeCtx, &simpleLogger{})
+		var jsonSocket JSONSocket = socket
+		if socket.Subprotocol() == GraphQLWSProtocol {
+			jsonSocket = NewGraphQLWSSocket(socket)
+		}
+
+		var logger GraphqlLogger = &simpleLogger{}
+		if opts.Logger != nil {
+			logger = opts.Logger
+		}
+
+		conn := CreateJSONSocket(ctx, jsonSocket, schema, makeCtx, logger)
+		conn.SetRegistry(registry)
+		// Register the connection before releasing its reservation, so that
+		// it is always counted against MaxConnections. ServeJSONSocket
+		// registering it again is harmless.
+		registry.register(conn)
+		release()
+		if opts.OnConnect != nil {
+			conn.SetOnConnect(opts.OnConnect)
+		}
+		conn.SetWriteBuffer(opts.WriteBuffer)
+		if opts.MaxMessageBytes > 0 {
+			conn.SetMaxMessageBytes(opts.MaxMessageBytes)
+		}
+		if opts.PersistedQueries != nil {
+			conn.SetPersistedQueryStore(opts.PersistedQueries)
+		}
+		conn.SetAllowedQueries(opts.AllowedQueries)
+		conn.SetTagExtractor(opts.TagExtractor)
+		conn.SetRedactedVariables(opts.RedactedVariables...)
+		conn.SetQueryLogFormatter(opts.QueryLogFormatter)
+		conn.SetSchemaHolder(opts.SchemaHolder)
+		conn.SetFieldUsageTracking(opts.TrackFieldUsage)
+		if socket.Subprotocol() != GraphQLWSProtocol {
+			conn.SetResumeWindow(opts.ResumeWindow)
+			conn.SetResumeIdentity(opts.ResumeIdentity)
+		}
+		conn.ServeJSONSocket()
 	})
 }
 
@@ -469,21 +1314,11 @@ func ServeJSONSocket(ctx context.Context, socket JSONSocket, schema *Schema, mak
 }
 
 func CreateJSONSocket(ctx context.Context, socket JSONSocket, schema *Schema, makeCtx MakeCtxFunc, logger GraphqlLogger) *conn {
-	return &conn{
-		socket: socket,
-		ctx:    ctx,
-
-		schema:         schema,
-		mutationSchema: schema,
-		makeCtx:        makeCtx,
-		logger:         logger,
-
-		subscriptions: make(map[string]*reactive.Rerunner),
-	}
+	return CreateJSONSocketWithMutationSchema(ctx, socket, schema, schema, makeCtx, logger)
 }
 
 func CreateJSONSocketWithMutationSchema(ctx context.Context, socket JSONSocket, schema, mutationSchema *Schema, makeCtx MakeCtxFunc, logger GraphqlLogger) *conn {
-	return &conn{
+	c := &conn{
 		socket: socket,
 		ctx:    ctx,
 
@@ -492,34 +1327,117 @@ func CreateJSONSocketWithMutationSchema(ctx context.Context, socket JSONSocket,
 		makeCtx:        makeCtx,
 		logger:         logger,
 
-		subscriptions: make(map[string]*reactive.Rerunner),
+		differ:       defaultDiffer(socket),
+		pingInterval: DefaultPingInterval,
+		pongTimeout:  DefaultPongTimeout,
+
+		rerunLoopThreshold: DefaultRerunLoopThreshold,
+		rerunLoopWindow:    DefaultRerunLoopWindow,
+
+		rerunDebounce: DefaultRerunDebounce,
+		rerunJitter:   DefaultRerunJitter,
+
+		streamChunkSize: DefaultStreamChunkSize,
+
+		registry:   DefaultRegistry,
+		queryCache: DefaultQueryCache,
+
+		maxMessageBytes: DefaultMaxMessageBytes,
+
+		subscriptions:    make(map[string]*reactive.Rerunner),
+		subscriptionTags: make(map[string]map[string]string),
+		mutations:        make(map[string]*reactive.Rerunner),
+		groups:           make(map[string][]string),
+		keepAlives:       make(map[string]*subscriptionKeepAlive),
+		rerunCauses:      make(map[string]*rerunCauses),
 	}
+	return c
 }
 
+// ServeJSONSocket registers the connection with its Registry, and handles
+// the messages read from its socket until the socket is closed.
 func (c *conn) ServeJSONSocket(handlers ...WebsocketHandler) {
-	defer c.closeSubscriptions()
+	c.registry.register(c)
+	defer func() {
+		if !c.park() {
+			c.registry.deregister(c)
+			c.close()
+		}
+	}()
+
+	readDone := make(chan struct{})
+	c.shutdownMu.Lock()
+	c.readDone = readDone
+	c.shutdownMu.Unlock()
+	defer close(readDone)
+
+	// A resumed connection is served again, but keeps its writer.
+	c.startWriter()
+
+	stopKeepAlive := func() {}
+	if socket, ok := unwrapSocket(c.socket).(pingSocket); ok && c.pingInterval > 0 {
+		stopKeepAlive = c.keepAlive(socket)
+	}
+	defer func() { stopKeepAlive() }()
 
-	handlers = append(handlers, c.handle)
+	c.applyReadLimit()
+	c.sendResumeToken()
+
+	userHandlers := handlers
+	handlers = append(handlers[:len(handlers):len(handlers)], c.handle)
 
 	for {
 		var envelope InEnvelope
 		if err := c.socket.ReadJSON(&envelope); err != nil {
-			if !isCloseError(err) {
+			if err == websocket.ErrReadLimit {
+				// The socket has already sent a message too big close frame.
+				log.Printf("socket.ReadJSON: closing connection after message larger than %d bytes", c.maxMessageBytes)
+			} else if !isCloseError(err) {
 				log.Println("socket.ReadJSON:", err)
+				c.closeWithReason(readCloseReason(err))
 			}
 			return
 		}
 
-		for _, handler := range handlers {
-			if err := handler(&envelope, c.writeOrClose); err != nil {
-				log.Println("c.handle:", err)
-				c.writeOrClose(OutEnvelope{
-					ID:       envelope.ID,
-					Type:     "error",
-					Message:  sanitizeError(err),
-					Metadata: nil,
-				})
+		if envelope.Type == "resume" && c.resumeWindow > 0 {
+			resumed, err := c.resume(envelope.Message)
+			if err != nil {
+				log.Println("c.resume:", err)
+				c.writeOrClose(errorEnvelope(envelope.ID, err, nil, nil))
+				continue
 			}
+			// The resumed connection now reads from the socket, and must be
+			// the only one to ping it.
+			stopKeepAlive()
+			stopKeepAlive = func() {}
+			resumed.ServeJSONSocket(userHandlers...)
+			return
+		}
+
+		c.dispatch(handlers, &envelope)
+	}
+}
+
+// dispatch passes envelope to handlers. A panicking handler is logged and
+// answered with an internal error, so that it cannot kill the connection's
+// read loop and leak its subscriptions.
+func (c *conn) dispatch(handlers []WebsocketHandler, envelope *InEnvelope) {
+	defer func() {
+		if p := recover(); p != nil {
+			err := newPanicError(p)
+			c.logger.Error(c.ctx, err, map[string]string{"url": c.url, "id": envelope.ID, "type": envelope.Type})
+			c.writeOrClose(errorEnvelope(envelope.ID, err, nil, nil))
+		}
+	}()
+
+	for _, handler := range handlers {
+		err := handler(envelope, c.writeOrClose)
+		if err == ErrHandled {
+			break
+		}
+		if err != nil {
+			log.Println("c.handle:", err)
+			c.writeOrClose(errorEnvelope(envelope.ID, err, nil, nil))
 		}
 	}
 }
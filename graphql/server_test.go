@@ -0,0 +1,1135 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/introspection"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+// fakeSocket is an in-memory JSONSocket. Messages sent on in are read by the
+// server, and messages written by the server are available on out.
+type fakeSocket struct {
+	in  chan interface{}
+	out chan interface{}
+}
+
+func newFakeSocket() *fakeSocket {
+	return &fakeSocket{
+		in:  make(chan interface{}, 16),
+		out: make(chan interface{}, 16),
+	}
+}
+
+func (s *fakeSocket) ReadJSON(value interface{}) error {
+	message, ok := <-s.in
+	if !ok {
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	return json.Unmarshal([]byte(internal.MarshalJSON(message)), value)
+}
+
+func (s *fakeSocket) WriteJSON(value interface{}) error {
+	s.out <- internal.AsJSON(value)
+	return nil
+}
+
+func (s *fakeSocket) Close() error {
+	return nil
+}
+
+// expect waits for the next message written by the server and compares it
+// against the JSON string expected.
+func (s *fakeSocket) expect(t *testing.T, expected string) {
+	select {
+	case message := <-s.out:
+		if !reflect.DeepEqual(message, internal.ParseJSON(expected)) {
+			t.Errorf("expected %s, got %s", expected, internal.MarshalJSON(message))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s", expected)
+	}
+}
+
+// nopLogger is a GraphqlLogger that discards everything.
+type nopLogger struct{}
+
+func (nopLogger) StartExecution(ctx context.Context, tags map[string]string, initial bool)         {}
+func (nopLogger) FinishExecution(ctx context.Context, tags map[string]string, delay time.Duration) {}
+func (nopLogger) Error(ctx context.Context, err error, tags map[string]string)                     {}
+
+type statsLogger struct {
+	nopLogger
+
+	mu    sync.Mutex
+	stats []graphql.ExecutionStats
+}
+
+func (l *statsLogger) ExecutionStats(ctx context.Context, tags map[string]string, stats graphql.ExecutionStats) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats = append(l.stats, stats)
+}
+
+func TestExecutionStats(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	double := &batch.Func{
+		// Wait generously so that all items land in a single batch even on a
+		// loaded machine.
+		WaitInterval: 50 * time.Millisecond,
+		MaxDuration:  time.Second,
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			results := make([]interface{}, len(args))
+			for i, arg := range args {
+				results[i] = arg.(int64) * 2
+			}
+			return results, nil
+		},
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("items", func() []Item {
+		return []Item{{Id: 1}, {Id: 2}, {Id: 3}}
+	})
+	item := schema.Object("Item", Item{})
+	item.FieldFunc("double", func(ctx context.Context, i Item) (int64, error) {
+		result, err := double.Invoke(ctx, i.Id)
+		if err != nil {
+			return 0, err
+		}
+		return result.(int64), nil
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+	logger := &statsLogger{}
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, logger)
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { double } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"double": 2}, {"double": 4}, {"double": 6}]}], "metadata": {"diffVersion": 1}}`)
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.stats) != 1 {
+		t.Fatalf("expected 1 stats, got %d", len(logger.stats))
+	}
+	stats := logger.stats[0]
+	if !stats.Initial {
+		t.Error("expected initial execution")
+	}
+	if stats.FieldsExecuted != 4 {
+		t.Errorf("expected 4 fields executed, got %d", stats.FieldsExecuted)
+	}
+	if stats.BatchRoundTrips != 1 {
+		t.Errorf("expected 1 batch round trip, got %d", stats.BatchRoundTrips)
+	}
+	if len(stats.BatchSizes) != 1 || stats.BatchSizes[0] != 3 {
+		t.Errorf("expected a batch of 3, got %v", stats.BatchSizes)
+	}
+	if expected := len(`[{"items":[{"double":2},{"double":4},{"double":6}]}]`); stats.DiffBytes != expected {
+		t.Errorf("expected %d diff bytes, got %d", expected, stats.DiffBytes)
+	}
+}
+
+func TestComplexityLimit(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetComplexityLimit(2, nil)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { id name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "query complexity 3 exceeds limit 2", "errors": [{"message": "query complexity 3 exceeds limit 2"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+}
+
+func TestDisableIntrospection(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	schema := makeGraphQLWSSchema()
+	introspection.AddIntrospectionToSchema(schema)
+	schema.DisableIntrospection = true
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema, makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ __schema { queryType { name } } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "introspection is disabled", "errors": [{"message": "introspection is disabled"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+}
+
+func TestMaxDepth(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetMaxDepth(1)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "query exceeds maximum depth of 1", "errors": [{"message": "query exceeds maximum depth of 1"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "hi") }`},
+	}
+	socket.expect(t, `{"id": "2", "type": "result", "message": [{"echo": "hi"}], "metadata": {"diffVersion": 1}}`)
+}
+
+func TestMaxFields(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetMaxFields(4)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ a: items { name } b: items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"a": [{"name": "a"}, {"name": "b"}], "b": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ a: items { name } b: items { name } c: items { name } }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "query exceeds maximum of 4 fields", "errors": [{"message": "query exceeds maximum of 4 fields"}]}`)
+
+	// Every spread of a fragment counts.
+	socket.in <- map[string]interface{}{
+		"id":   "3",
+		"type": "mutate",
+		"message": map[string]interface{}{"query": `
+			mutation { ...A ...A ...A }
+			fragment A on Mutation { a: echo(text: "a") b: echo(text: "b") }`},
+	}
+	socket.expect(t, `{"id": "3", "type": "error", "message": "query exceeds maximum of 4 fields", "errors": [{"message": "query exceeds maximum of 4 fields"}]}`)
+}
+
+type codedError struct {
+	code string
+}
+
+func (e codedError) Error() string          { return "failed with " + e.code }
+func (e codedError) SanitizedError() string { return "failed with " + e.code }
+func (e codedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func TestErrorPathAndExtensions(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("items", func() []Item {
+		return []Item{{Id: 1}, {Id: 2}}
+	})
+	item := schema.Object("Item", Item{})
+	item.FieldFunc("check", func(i Item) (bool, error) {
+		if i.Id == 2 {
+			return false, codedError{code: "BAD_ITEM"}
+		}
+		return true, nil
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "query Items { items { check } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "failed with BAD_ITEM", "errors": [
+		{"message": "failed with BAD_ITEM", "path": ["items", 1, "check"], "extensions": {"code": "BAD_ITEM"}}
+	]}`)
+}
+
+func TestErrorCodes(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("client", func() (bool, error) {
+		return false, graphql.NewClientErrorWithCode("BAD_USER_INPUT", "bad %s", "input")
+	})
+	query.FieldFunc("safe", func() (bool, error) {
+		return false, graphql.NewSafeError("no code")
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ client }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "bad input", "errors": [
+		{"message": "bad input", "path": ["client"], "extensions": {"code": "BAD_USER_INPUT"}}
+	]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ safe }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "no code", "errors": [
+		{"message": "no code", "path": ["safe"]}
+	]}`)
+}
+
+func TestExecutionTimeout(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("slow", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	c.SetExecutionTimeout(10 * time.Millisecond)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ slow }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "Execution timed out", "errors": [{"message": "Execution timed out", "path": ["slow"]}]}`)
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	socket := newFakeSocket()
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	registry := graphql.NewRegistry()
+
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetRegistry(registry)
+	// Connections are only registered once they are served.
+	if registry.Len() != 0 {
+		t.Errorf("expected no live connections before serving, got %d", registry.Len())
+	}
+	done := make(chan struct{})
+	go func() {
+		c.ServeJSONSocket()
+		close(done)
+	}()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	if registry.Len() != 1 {
+		t.Errorf("expected 1 live connection, got %d", registry.Len())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := registry.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+	socket.expect(t, `{"id": "1", "type": "complete"}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "server is shutting down", "errors": [{"message": "server is shutting down"}]}`)
+
+	close(socket.in)
+	<-done
+	if registry.Len() != 0 {
+		t.Errorf("expected no live connections, got %d", registry.Len())
+	}
+}
+
+func TestRerunDebounce(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("count", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource)
+		return atomic.AddInt64(&executions, 1)
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("bump", func() bool {
+		resource.Strobe()
+		return true
+	})
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	c.SetRerunDebounce(200 * time.Millisecond)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "sub",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ count }"},
+	}
+	socket.expect(t, `{"id": "sub", "type": "update", "message": [{"count": 1}], "metadata": {"diffVersion": 1}}`)
+
+	for i := 0; i < 5; i++ {
+		socket.in <- map[string]interface{}{
+			"id":      fmt.Sprint(i),
+			"type":    "mutate",
+			"message": map[string]interface{}{"query": "mutation { bump }"},
+		}
+		socket.expect(t, fmt.Sprintf(`{"id": "%d", "type": "result", "message": [{"bump": true}], "metadata": {"diffVersion": 1}}`, i))
+	}
+
+	// All five mutations should be coalesced into a single rerun.
+	// Mutations trigger reruns asynchronously, so their ids may be in any
+	// order.
+	select {
+	case message := <-socket.out:
+		metadata := message.(map[string]interface{})["metadata"].(map[string]interface{})
+		causedBy := metadata["causedBy"].([]interface{})
+		sort.Slice(causedBy, func(i, j int) bool { return causedBy[i].(string) < causedBy[j].(string) })
+		if !reflect.DeepEqual(message, internal.ParseJSON(`{"id": "sub", "type": "update", "message": {"count": 2},
+			"metadata": {"diffVersion": 1, "causedBy": ["0", "1", "2", "3", "4"]}}`)) {
+			t.Errorf("unexpected update %s", internal.MarshalJSON(message))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for update")
+	}
+	select {
+	case message := <-socket.out:
+		t.Errorf("unexpected message %s", internal.MarshalJSON(message))
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestRerunJitter(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("count", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource)
+		return atomic.AddInt64(&executions, 1)
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("bump", func() bool {
+		resource.Strobe()
+		return true
+	})
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	c.SetRerunDebounce(0)
+	c.SetRerunJitter(300 * time.Millisecond)
+	go c.ServeJSONSocket()
+
+	const subscriptions = 10
+	for i := 0; i < subscriptions; i++ {
+		socket.in <- map[string]interface{}{
+			"id":      fmt.Sprint(i),
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": "{ count }"},
+		}
+		socket.expect(t, fmt.Sprintf(`{"id": "%d", "type": "update", "message": [{"count": %d}], "metadata": {"diffVersion": 1}}`, i, i+1))
+	}
+
+	socket.in <- map[string]interface{}{
+		"id":      "bump",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { bump }"},
+	}
+	socket.expect(t, `{"id": "bump", "type": "result", "message": [{"bump": true}], "metadata": {"diffVersion": 1}}`)
+
+	// The reruns, and so the updates, are spread across the jitter window.
+	var first, last time.Time
+	for i := 0; i < subscriptions; i++ {
+		select {
+		case <-socket.out:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for updates")
+		}
+		now := time.Now()
+		if i == 0 {
+			first = now
+		}
+		last = now
+	}
+	if spread := last.Sub(first); spread < 30*time.Millisecond {
+		t.Errorf("expected reruns to be spread out, but they finished within %v", spread)
+	}
+}
+
+func TestRerunCausedBy(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("count", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource)
+		return atomic.AddInt64(&executions, 1)
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("bump", func() bool {
+		resource.Strobe()
+		return true
+	})
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "sub",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ count }"},
+	}
+	socket.expect(t, `{"id": "sub", "type": "update", "message": [{"count": 1}], "metadata": {"diffVersion": 1}}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "bump",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { bump }"},
+	}
+	socket.expect(t, `{"id": "bump", "type": "result", "message": [{"bump": true}], "metadata": {"diffVersion": 1}}`)
+	socket.expect(t, `{"id": "sub", "type": "update", "message": {"count": 2}, "metadata": {"diffVersion": 1, "causedBy": ["bump"]}}`)
+}
+
+func TestMutationReusingSubscriptionID(t *testing.T) {
+	resource := reactive.NewResource()
+	var executions int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("count", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource)
+		return atomic.AddInt64(&executions, 1)
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("bump", func() bool {
+		resource.Strobe()
+		return true
+	})
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ count }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"count": 1}], "metadata": {"diffVersion": 1}}`)
+
+	// A mutation with the same id leaves the subscription in place, which is
+	// rerun after the mutation.
+	for i := 0; i < 2; i++ {
+		socket.in <- map[string]interface{}{
+			"id":      "1",
+			"type":    "mutate",
+			"message": map[string]interface{}{"query": "mutation { bump }"},
+		}
+		socket.expect(t, `{"id": "1", "type": "result", "message": [{"bump": true}], "metadata": {"diffVersion": 1}}`)
+		socket.expect(t, fmt.Sprintf(`{"id": "1", "type": "update", "message": {"count": %d}, "metadata": {"diffVersion": 1, "causedBy": ["1"]}}`, i+2))
+	}
+}
+
+func TestMutationReusingMutationID(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("ok", func() bool { return true })
+	mutation := schema.Mutation()
+	mutation.FieldFunc("wait", func(ctx context.Context) (bool, error) {
+		started <- struct{}{}
+		<-release
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return true, nil
+		}
+	})
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, nopLogger{})
+	go c.ServeJSONSocket()
+
+	// The second mutation reuses the id of the first while it is running.
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { a: wait }"},
+	}
+	<-started
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { b: wait }"},
+	}
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ ok }"},
+	}
+	socket.expect(t, `{"id": "2", "type": "update", "message": [{"ok": true}], "metadata": {"diffVersion": 1}}`)
+
+	// Finishing the first mutation does not stop the second.
+	close(release)
+	socket.expect(t, `{"id": "1", "type": "result", "message": [{"a": true}], "metadata": {"diffVersion": 1}}`)
+	socket.expect(t, `{"id": "1", "type": "result", "message": [{"b": true}], "metadata": {"diffVersion": 1}}`)
+}
+
+// blockingSocket is a JSONSocket whose writes block until released.
+type blockingSocket struct {
+	in        chan interface{}
+	release   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *blockingSocket) ReadJSON(value interface{}) error {
+	select {
+	case message := <-s.in:
+		return json.Unmarshal([]byte(internal.MarshalJSON(message)), value)
+	case <-s.closed:
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+}
+
+func (s *blockingSocket) WriteJSON(value interface{}) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-s.closed:
+		return websocket.ErrCloseSent
+	}
+}
+
+func (s *blockingSocket) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestWriteBufferClosesSlowConsumer(t *testing.T) {
+	socket := &blockingSocket{
+		in:      make(chan interface{}, 16),
+		release: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetWriteBuffer(1)
+
+	done := make(chan struct{})
+	go func() {
+		c.ServeJSONSocket()
+		close(done)
+	}()
+
+	// The first echo blocks the writer, the second fills the buffer, and the
+	// third overflows it.
+	for i := 0; i < 3; i++ {
+		socket.in <- map[string]interface{}{"id": fmt.Sprint(i), "type": "echo"}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected slow consumer to be closed")
+	}
+}
+
+type subscriptionEvent struct {
+	id     string
+	reason graphql.UnsubscribeReason
+}
+
+type subscriptionLogger struct {
+	nopLogger
+	events chan subscriptionEvent
+}
+
+func (l *subscriptionLogger) Subscribe(ctx context.Context, tags map[string]string) {
+	l.events <- subscriptionEvent{id: tags["id"]}
+}
+
+func (l *subscriptionLogger) Unsubscribe(ctx context.Context, tags map[string]string, reason graphql.UnsubscribeReason) {
+	l.events <- subscriptionEvent{id: tags["id"], reason: reason}
+}
+
+func (l *subscriptionLogger) expect(t *testing.T, expected subscriptionEvent) {
+	select {
+	case event := <-l.events:
+		if event != expected {
+			t.Errorf("expected %v, got %v", expected, event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %v", expected)
+	}
+}
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("ok", func() bool {
+		return true
+	})
+	query.FieldFunc("fail", func() (bool, error) {
+		return false, errors.New("fail")
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	logger := &subscriptionLogger{events: make(chan subscriptionEvent, 16)}
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, logger)
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ ok }"},
+	}
+	logger.expect(t, subscriptionEvent{id: "1"})
+	socket.in <- map[string]interface{}{"id": "1", "type": "unsubscribe"}
+	logger.expect(t, subscriptionEvent{id: "1", reason: graphql.UnsubscribeClient})
+
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ fail }"},
+	}
+	logger.expect(t, subscriptionEvent{id: "2"})
+	logger.expect(t, subscriptionEvent{id: "2", reason: graphql.UnsubscribeError})
+
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ ok }"},
+	}
+	logger.expect(t, subscriptionEvent{id: "3"})
+	close(socket.in)
+	logger.expect(t, subscriptionEvent{id: "3", reason: graphql.UnsubscribeConnectionClosed})
+}
+
+func TestHandlerShortCircuits(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	go c.ServeJSONSocket(func(e *graphql.InEnvelope, write graphql.WebsocketWriter) error {
+		if e.Type != "presence" {
+			return nil
+		}
+		write(graphql.OutEnvelope{ID: e.ID, Type: "presence"})
+		return graphql.ErrHandled
+	})
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "presence"}
+	socket.expect(t, `{"id": "1", "type": "presence"}`)
+
+	// The default handler still handles other messages, and did not report
+	// presence as an unknown message type.
+	socket.in <- map[string]interface{}{"id": "2", "type": "echo"}
+	socket.expect(t, `{"id": "2", "type": "echo"}`)
+}
+
+func TestSubscriptionKeepAlive(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetSubscriptionKeepAlive(10 * time.Millisecond)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+	socket.expect(t, `{"id": "1", "type": "ka"}`)
+	socket.expect(t, `{"id": "1", "type": "ka"}`)
+
+	// Keepalives stop with the subscription, once any in flight are sent.
+	socket.in <- map[string]interface{}{"id": "1", "type": "unsubscribe"}
+	time.Sleep(50 * time.Millisecond)
+	for len(socket.out) > 0 {
+		<-socket.out
+	}
+	select {
+	case message := <-socket.out:
+		t.Errorf("unexpected message %s after unsubscribe", internal.MarshalJSON(message))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscriptionKeepAliveStartsAfterInitialUpdate(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	c.SetSubscriptionKeepAlive(10 * time.Millisecond)
+	c.Use(func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		time.Sleep(50 * time.Millisecond)
+		return next(input)
+	})
+	go c.ServeJSONSocket()
+
+	// No keepalives are sent while the initial execution is still running.
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+	socket.expect(t, `{"id": "1", "type": "ka"}`)
+}
+
+func TestMutationBaseVersion(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, nopLogger{})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { a: echo(text: "a") b: echo(text: "b") }`},
+	}
+	socket.expect(t, `{"id": "1", "type": "result", "message": [{"a": "a", "b": "b"}], "metadata": {"diffVersion": 1}}`)
+
+	// Only the fields that differ from the base are sent.
+	socket.in <- map[string]interface{}{
+		"id":   "2",
+		"type": "mutate",
+		"message": map[string]interface{}{
+			"query":       `mutation { a: echo(text: "a") b: echo(text: "c") }`,
+			"baseVersion": "1",
+		},
+	}
+	socket.expect(t, `{"id": "2", "type": "result", "message": {"b": "c"}, "metadata": {"diffVersion": 1, "baseVersion": "1"}}`)
+
+	// An unknown base sends the full result.
+	socket.in <- map[string]interface{}{
+		"id":   "3",
+		"type": "mutate",
+		"message": map[string]interface{}{
+			"query":       `mutation { a: echo(text: "a") }`,
+			"baseVersion": "missing",
+		},
+	}
+	socket.expect(t, `{"id": "3", "type": "result", "message": [{"a": "a"}], "metadata": {"diffVersion": 1}}`)
+}
+
+func TestResolverPanic(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("panic", func() string {
+		panic("test panic")
+	})
+	schema.Mutation()
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	logger := &errorLogger{errs: make(chan error, 1)}
+	c := graphql.CreateJSONSocket(context.Background(), socket, schema.MustBuild(), makeCtx, logger)
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ panic }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "Internal server error", "errors": [{"message": "Internal server error", "path": ["panic"]}]}`)
+
+	select {
+	case err := <-logger.errs:
+		if !strings.Contains(err.Error(), "test panic") || !strings.Contains(err.Error(), "server_test.go") {
+			t.Errorf("expected panic value and stack, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for logged panic")
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	socket := newFakeSocket()
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	logger := &subscriptionLogger{events: make(chan subscriptionEvent, 16)}
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, logger)
+	done := make(chan struct{})
+	go func() {
+		c.ServeJSONSocket(func(e *graphql.InEnvelope, write graphql.WebsocketWriter) error {
+			if e.Type == "boom" {
+				panic("test panic")
+			}
+			return nil
+		})
+		close(done)
+	}()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+	logger.expect(t, subscriptionEvent{id: "1"})
+	socket.expect(t, `{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)
+
+	// The panic is answered with an internal error, and the connection keeps
+	// serving messages.
+	socket.in <- map[string]interface{}{"id": "2", "type": "boom"}
+	socket.expect(t, `{"id": "2", "type": "error", "message": "Internal server error", "errors": [{"message": "Internal server error"}]}`)
+
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": `mutation { echo(text: "hi") }`},
+	}
+	socket.expect(t, `{"id": "3", "type": "result", "message": [{"echo": "hi"}], "metadata": {"diffVersion": 1}}`)
+
+	// Subscriptions are still closed when the connection closes.
+	close(socket.in)
+	<-done
+	logger.expect(t, subscriptionEvent{id: "1", reason: graphql.UnsubscribeConnectionClosed})
+}
+
+func TestHandlerCompression(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		server := httptest.NewServer(graphql.HandlerWithOptions(makeGraphQLWSSchema(), graphql.HandlerOptions{
+			EnableCompression: enabled,
+		}))
+
+		dialer := websocket.Dialer{EnableCompression: true}
+		socket, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		negotiated := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+		if negotiated != enabled {
+			t.Errorf("EnableCompression %v: expected compression negotiated %v, got %v", enabled, enabled, negotiated)
+		}
+
+		// Messages must make it through compressed sockets intact.
+		if err := socket.WriteJSON(map[string]interface{}{
+			"id":      "1",
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": "{ items { name } }"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var message interface{}
+		if err := socket.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(message, internal.ParseJSON(`{"id": "1", "type": "update", "message": [{"items": [{"name": "a"}, {"name": "b"}]}], "metadata": {"diffVersion": 1}}`)) {
+			t.Errorf("EnableCompression %v: unexpected message %s", enabled, internal.MarshalJSON(message))
+		}
+
+		socket.Close()
+		server.Close()
+	}
+}
+
+func TestHandlerCheckOrigin(t *testing.T) {
+	dial := func(server *httptest.Server, origin string) (*websocket.Conn, *http.Response, error) {
+		return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {origin}})
+	}
+
+	server := httptest.NewServer(graphql.HandlerWithOptions(makeGraphQLWSSchema(), graphql.HandlerOptions{
+		CheckOrigin: func(r *http.Request) bool {
+			return r.Header.Get("Origin") == "https://allowed.example"
+		},
+	}))
+	defer server.Close()
+
+	socket, resp, err := dial(server, "https://evil.example")
+	if err == nil {
+		socket.Close()
+		t.Error("expected upgrade from rejected origin to fail")
+	} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 Forbidden for rejected origin, got %v", err)
+	}
+
+	socket, _, err = dial(server, "https://allowed.example")
+	if err != nil {
+		t.Fatalf("expected upgrade from allowed origin to succeed, got %v", err)
+	}
+	socket.Close()
+
+	// Without CheckOrigin, all origins are accepted.
+	defaultServer := httptest.NewServer(graphql.HandlerWithOptions(makeGraphQLWSSchema(), graphql.HandlerOptions{}))
+	defer defaultServer.Close()
+
+	socket, _, err = dial(defaultServer, "https://evil.example")
+	if err != nil {
+		t.Fatalf("expected upgrade to succeed without CheckOrigin, got %v", err)
+	}
+	socket.Close()
+}
+
+type handlerUserKey struct{}
+
+func TestHandlerMakeCtx(t *testing.T) {
+	user := func(ctx context.Context) string {
+		name, _ := ctx.Value(handlerUserKey{}).(string)
+		return name
+	}
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", user)
+	schema.Mutation().FieldFunc("user", user)
+
+	server := httptest.NewServer(graphql.HandlerWithOptions(schema.MustBuild(), graphql.HandlerOptions{
+		MakeCtx: func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, handlerUserKey{}, r.Header.Get("X-User"))
+		},
+	}))
+	defer server.Close()
+
+	socket, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"X-User": {"alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+
+	expect := func(expected string) {
+		var message interface{}
+		if err := socket.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(message, internal.ParseJSON(expected)) {
+			t.Errorf("expected %s, got %s", expected, internal.MarshalJSON(message))
+		}
+	}
+
+	// The context derived from the upgrade request reaches resolvers of both
+	// subscriptions and mutations.
+	if err := socket.WriteJSON(map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ user }"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expect(`{"id": "1", "type": "update", "message": [{"user": "alice"}], "metadata": {"diffVersion": 1}}`)
+
+	if err := socket.WriteJSON(map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { user }"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expect(`{"id": "2", "type": "result", "message": [{"user": "alice"}], "metadata": {"diffVersion": 1}}`)
+}
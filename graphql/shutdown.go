@@ -0,0 +1,240 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeWriteTimeout bounds the time spent writing a close frame.
+const closeWriteTimeout = 10 * time.Second
+
+// closeWaitTimeout bounds the time a connection that was sent a close frame
+// on shutdown waits for the client to close it, before closing the socket
+// itself.
+var closeWaitTimeout = 10 * time.Second
+
+var errShuttingDown = NewSafeError("server is shutting down")
+
+// Registry tracks live connections so that they can be shut down gracefully.
+type Registry struct {
+	mu    sync.Mutex
+	conns map[*conn]struct{}
+	// parked holds disconnected connections awaiting resumption, by resume
+	// token. See SetResumeWindow.
+	parked map[string]*conn
+	// reserved counts connections being upgraded by a handler with
+	// MaxConnections set, which are not yet registered.
+	reserved int
+	// rerunLimiter throttles RerunAllImmediately.
+	rerunLimiter RateLimiter
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		conns:        make(map[*conn]struct{}),
+		parked:       make(map[string]*conn),
+		rerunLimiter: NewTokenBucket(1/DefaultRerunAllInterval.Seconds(), 1),
+	}
+}
+
+// DefaultRegistry holds all connections served with ServeJSONSocket, unless
+// they were moved to another registry with SetRegistry.
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(c *conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns[c] = struct{}{}
+}
+
+func (r *Registry) deregister(c *conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, c)
+}
+
+// live returns the live connections. Callers inspect them without holding
+// r.mu, so that a slow connection does not block registering and
+// deregistering others.
+func (r *Registry) live() []*conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// park moves c from the live connections to the parked connections, closing
+// it unless it is unparked within window.
+func (r *Registry) park(token string, c *conn, window time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, c)
+	r.parked[token] = c
+	c.parkTimer = time.AfterFunc(window, func() {
+		if r.unpark(token) == c {
+			c.close()
+		}
+	})
+}
+
+// parkedConn returns the parked connection with token, or nil if there is
+// none, without unparking it.
+func (r *Registry) parkedConn(token string) *conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.parked[token]
+}
+
+// unpark removes and returns the parked connection with token, or nil if
+// there is none.
+func (r *Registry) unpark(token string) *conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.parked[token]
+	delete(r.parked, token)
+	return c
+}
+
+// Len returns the number of live connections.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.conns)
+}
+
+// Subscriptions returns the number of open subscriptions across all live
+// connections. Mutations in progress are not counted.
+func (r *Registry) Subscriptions() int {
+	n := 0
+	for _, c := range r.live() {
+		c.mu.Lock()
+		n += len(c.subscriptionTags)
+		c.mu.Unlock()
+	}
+	return n
+}
+
+// Shutdown gracefully shuts down all live connections. Connections stop
+// accepting new subscriptions and mutations, and Shutdown waits for in-flight
+// executions to finish or for ctx to expire, whichever comes first. Each
+// subscription is then sent a complete message and closed, and the socket is
+// sent a close frame. The subscriptions of connections awaiting resumption are
+// closed right away.
+//
+// Shutdown returns ctx.Err() if ctx expired before all executions finished.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	parked := r.parked
+	r.parked = make(map[string]*conn)
+	r.mu.Unlock()
+
+	for _, c := range parked {
+		c.parkTimer.Stop()
+		c.close()
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *conn) {
+			defer wg.Done()
+			c.shutdown(ctx)
+		}(c)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// SetRegistry makes the connection register with registry instead of
+// DefaultRegistry, so that it is shut down by registry.Shutdown. SetRegistry
+// must be called before ServeJSONSocket.
+func (c *conn) SetRegistry(registry *Registry) {
+	c.registry = registry
+}
+
+// startExecution registers an execution so that shutdown can wait for it.
+// It returns false if the connection is shutting down, in which case the
+// execution should not start.
+func (c *conn) startExecution() bool {
+	c.shutdownMu.Lock()
+	defer c.shutdownMu.Unlock()
+
+	if c.shuttingDown {
+		return false
+	}
+	c.executions.Add(1)
+	return true
+}
+
+func (c *conn) isShuttingDown() bool {
+	c.shutdownMu.Lock()
+	defer c.shutdownMu.Unlock()
+	return c.shuttingDown
+}
+
+// closeSocket is implemented by sockets that can send a close frame, such as
+// *websocket.Conn.
+type closeSocket interface {
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+}
+
+func (c *conn) shutdown(ctx context.Context) {
+	c.shutdownMu.Lock()
+	c.shuttingDown = true
+	readDone := c.readDone
+	c.shutdownMu.Unlock()
+
+	// No executions start once shuttingDown is set, so it is safe to wait.
+	done := make(chan struct{})
+	go func() {
+		c.executions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	ids := make([]string, 0, len(c.subscriptions))
+	for id := range c.subscriptions {
+		ids = append(ids, id)
+	}
+	c.mu.Unlock()
+
+	for _, id := range ids {
+		c.writeOrClose(OutEnvelope{
+			ID:   id,
+			Type: "complete",
+		})
+	}
+	c.closeSubscriptions()
+
+	c.writeMu.Lock()
+	sent := c.sendClose(websocket.CloseGoingAway, "server shutting down")
+	c.writeMu.Unlock()
+	if sent && readDone != nil {
+		// Give the client a chance to close the connection, but do not let a
+		// client that never answers keep the socket open.
+		timer := time.NewTimer(closeWaitTimeout)
+		select {
+		case <-readDone:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	c.socket.Close()
+}
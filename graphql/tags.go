@@ -0,0 +1,61 @@
+package graphql
+
+import "context"
+
+// A TagExtractor returns tags describing ctx, such as the tenant or user it
+// belongs to, to add to the tags of subscriptions and mutations passed to the
+// connection's GraphqlLogger.
+type TagExtractor func(ctx context.Context) map[string]string
+
+// SetTagExtractor adds the tags extracted from the connection's context to
+// the tags of every subscription and mutation, so that logs and metrics can
+// be sliced by request-scoped values. The context holds the values added by
+// HandlerOptions.MakeCtx and by OnConnect. Extracted tags never replace the
+// connection's own tags, such as "query" or "id".
+func (c *conn) SetTagExtractor(extractor TagExtractor) {
+	c.tagExtractor = extractor
+}
+
+// addExtractedTags adds the tags extracted from the connection's context to
+// tags.
+func (c *conn) addExtractedTags(tags map[string]string) {
+	if c.tagExtractor == nil {
+		return
+	}
+	for k, v := range c.tagExtractor(c.ctx) {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+}
+
+// A QueryLogFormatter transforms the query in the "query" tag passed to the
+// connection's GraphqlLogger, for example to truncate or hash large queries.
+type QueryLogFormatter func(query string) string
+
+// SetQueryLogFormatter transforms the query in the "query" tag of every
+// subscription and mutation with formatter. Execution still uses the query
+// as sent.
+func (c *conn) SetQueryLogFormatter(formatter QueryLogFormatter) {
+	c.queryLogFormatter = formatter
+}
+
+// HashLongQueries returns a QueryLogFormatter that replaces queries longer
+// than maxBytes with "sha256:" followed by their QueryHash, which identifies
+// them as in an allowlist. Shorter queries are left as they are.
+func HashLongQueries(maxBytes int) QueryLogFormatter {
+	return func(query string) string {
+		if len(query) <= maxBytes {
+			return query
+		}
+		return "sha256:" + QueryHash(query)
+	}
+}
+
+// queryTag returns the "query" tag for query.
+func (c *conn) queryTag(query string) string {
+	if c.queryLogFormatter == nil {
+		return query
+	}
+	return c.queryLogFormatter(query)
+}
@@ -0,0 +1,99 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+type tenantKey struct{}
+
+// tagsLogger records the tags of started executions.
+type tagsLogger struct {
+	nopLogger
+	tags chan map[string]string
+}
+
+func (l *tagsLogger) StartExecution(ctx context.Context, tags map[string]string, initial bool) {
+	l.tags <- tags
+}
+
+func TestTagExtractor(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	logger := &tagsLogger{tags: make(chan map[string]string, 1)}
+	c := graphql.CreateJSONSocket(ctx, socket, makeGraphQLWSSchema(), makeCtx, logger)
+	c.SetTagExtractor(func(ctx context.Context) map[string]string {
+		return map[string]string{"tenant": ctx.Value(tenantKey{}).(string), "id": "ignored"}
+	})
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ items { name } }"},
+	}
+
+	select {
+	case tags := <-logger.tags:
+		if tags["tenant"] != "acme" {
+			t.Errorf("expected tenant tag acme, got %q", tags["tenant"])
+		}
+		if tags["id"] != "1" {
+			t.Errorf("expected id tag 1, got %q", tags["id"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for execution")
+	}
+}
+
+func TestRedactedVariables(t *testing.T) {
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	logger := &tagsLogger{tags: make(chan map[string]string, 1)}
+	c := graphql.CreateJSONSocket(context.Background(), socket, makeGraphQLWSSchema(), makeCtx, logger)
+	c.SetRedactedVariables("password")
+	go c.ServeJSONSocket()
+
+	socket.in <- map[string]interface{}{
+		"id":   "1",
+		"type": "mutate",
+		"message": map[string]interface{}{
+			"query": "mutation($text: String!) { echo(text: $text) }",
+			"variables": map[string]interface{}{
+				"text":     "password",
+				"password": "hunter2",
+				"input":    map[string]interface{}{"password": "hunter2", "name": "bob"},
+			},
+		},
+	}
+	socket.expect(t, `{"id": "1", "type": "result", "message": [{"echo": "password"}], "metadata": {"diffVersion": 1}}`)
+
+	select {
+	case tags := <-logger.tags:
+		expected := `{"input":{"name":"bob","password":"[REDACTED]"},"password":"[REDACTED]","text":"password"}`
+		if tags["queryVariables"] != expected {
+			t.Errorf("expected variables tag %s, got %s", expected, tags["queryVariables"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for execution")
+	}
+}
+
+func TestHashLongQueries(t *testing.T) {
+	format := graphql.HashLongQueries(20)
+	if query := "{ items { name } }"; format(query) != query {
+		t.Errorf("expected short query to be kept, got %s", format(query))
+	}
+	query := "{ items { id name } }"
+	if expected := "sha256:" + graphql.QueryHash(query); format(query) != expected {
+		t.Errorf("expected %s, got %s", expected, format(query))
+	}
+}
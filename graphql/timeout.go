@@ -0,0 +1,37 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+// SetExecutionTimeout limits the duration of every execution of a
+// subscription or mutation. The timeout applies to each rerun separately,
+// not to the lifetime of the connection. When the timeout expires, the
+// context passed to resolvers is cancelled. A timed out initial execution
+// fails with an "Execution timed out" error, while timed out reruns are
+// retried like other rerun errors. A zero timeout disables the limit.
+func (c *conn) SetExecutionTimeout(timeout time.Duration) {
+	c.executionTimeout = timeout
+}
+
+// withExecutionTimeout returns a context that expires after the execution
+// timeout, if any.
+func (c *conn) withExecutionTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.executionTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.executionTimeout)
+}
+
+// fieldTimeoutError returns the error of a resolver of field that ran with
+// ctx, a context derived from parent that expires after the field's timeout.
+// A resolver that fails once ctx expired fails with a timeout error, which
+// only nulls out the field with partial results, unlike the expiry of the
+// execution timeout.
+func fieldTimeoutError(ctx, parent context.Context, field *Field, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		return NewSafeError("field timed out after %s", field.Timeout)
+	}
+	return err
+}
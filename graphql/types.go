@@ -3,6 +3,7 @@ package graphql
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // Type represents a GraphQL type, and should be either an Object, a Scalar,
@@ -18,6 +19,10 @@ type Type interface {
 // Scalar is a leaf value
 type Scalar struct {
 	Type string
+
+	// Serialize, if set, converts resolved values into their JSON
+	// representation.
+	Serialize func(interface{}) (interface{}, error)
 }
 
 func (s *Scalar) isType() {}
@@ -32,6 +37,9 @@ type Object struct {
 	Description string
 	Key         Resolver
 	Fields      map[string]*Field
+
+	// Hidden objects are omitted from introspection.
+	Hidden bool
 }
 
 func (o *Object) isType() {}
@@ -54,6 +62,10 @@ func (l *List) String() string {
 type InputObject struct {
 	Name        string
 	InputFields map[string]Type
+
+	// DefaultValues holds the JSON-encoded defaults of input fields that have
+	// one.
+	DefaultValues map[string]string
 }
 
 func (io *InputObject) isType() {}
@@ -73,9 +85,71 @@ func (n *NonNull) String() string {
 	return fmt.Sprintf("%s!", n.Type)
 }
 
-// Verify *Scalar, *Object, *List, *InputObject, and *NonNull implement Type
+// Interface is an abstract type implemented by several Objects. Fields holds
+// the fields shared by all implementations, which can be selected directly;
+// other fields are selected with fragments on the implementing Objects.
+type Interface struct {
+	Name        string
+	Description string
+	Fields      map[string]*Field
+
+	// Types holds the implementing Objects by name.
+	Types map[string]*Object
+
+	// ResolveType returns the Object implementing value, whose fields are used
+	// to execute queries on value.
+	ResolveType func(value interface{}) (*Object, error)
+}
+
+func (i *Interface) isType() {}
+
+func (i *Interface) String() string {
+	return i.Name
+}
+
+// Union is an abstract type that is one of several Objects. Only __typename
+// can be selected on a Union; other fields are selected with fragments on its
+// member Objects.
+type Union struct {
+	Name        string
+	Description string
+
+	// Types holds the member Objects by name.
+	Types map[string]*Object
+
+	// ResolveType returns the member Object of value, whose fields are used to
+	// execute queries on value.
+	ResolveType func(value interface{}) (*Object, error)
+}
+
+func (u *Union) isType() {}
+
+func (u *Union) String() string {
+	return u.Name
+}
+
+// Enum is a leaf value that is one of a fixed set of names.
+type Enum struct {
+	Name   string
+	Values []string
+
+	// Serialize converts resolved values into their enum names.
+	Serialize func(interface{}) (interface{}, error)
+}
+
+func (e *Enum) isType() {}
+
+func (e *Enum) String() string {
+	return e.Name
+}
+
+// Verify *Scalar, *Enum, *Object, *Interface, *Union, *List, *InputObject,
+// and *NonNull implement Type
 var _ Type = &Scalar{}
+var _ Type = &Enum{}
 var _ Type = &Object{}
+var _ Type = &Interface{}
+var _ Type = &Union{}
 var _ Type = &List{}
 var _ Type = &InputObject{}
 var _ Type = &NonNull{}
@@ -92,12 +166,32 @@ type Field struct {
 	Args           map[string]Type
 	ParseArguments func(json interface{}) (interface{}, error)
 
+	// ArgDefaults holds the JSON-encoded defaults of arguments that have one.
+	ArgDefaults map[string]string
+
 	Expensive bool
+
+	// Hidden fields are omitted from introspection, but can still be queried.
+	Hidden bool
+
+	// DeprecationReason, if set, marks the field deprecated in introspection,
+	// explaining what clients should use instead. Deprecated fields can still
+	// be queried.
+	DeprecationReason string
+
+	// Timeout, if positive, limits the duration of the field's resolver, whose
+	// context expires after Timeout.
+	Timeout time.Duration
 }
 
 type Schema struct {
 	Query    Type
 	Mutation Type
+
+	// DisableIntrospection rejects queries selecting the __schema or __type
+	// introspection fields, for deployments that must not reveal their
+	// schema.
+	DisableIntrospection bool
 }
 
 // SelectionSet represents a core GraphQL query
@@ -141,6 +235,13 @@ type Selection struct {
 	Args         interface{}
 	SelectionSet *SelectionSet
 
+	// Streamed is true for list fields marked with @stream, of which only the
+	// first InitialCount elements are part of the initial result of a
+	// subscription. The remaining elements are appended by follow-up updates,
+	// in chunks. See SetStreamChunkSize.
+	Streamed     bool
+	InitialCount int
+
 	// The parsed flag is used to make sure the args for this Selection are only
 	// parsed once.
 	parsed bool
@@ -149,9 +250,14 @@ type Selection struct {
 // A Fragment represents a reusable part of a GraphQL query
 //
 // The On part of a Fragment represents the type of source object for which
-// this Fragment should be used. That is not currently implemented in this
-// package.
+// this Fragment should be used. It is only checked for fragments selecting on
+// an Interface or Union, where it picks the Objects the fragment applies to.
+// On is empty for inline fragments without a type condition.
+//
+// Deferred is true for fragments marked with @defer, which are left out of the
+// initial result of a subscription and sent in a follow-up update.
 type Fragment struct {
 	On           string
 	SelectionSet *SelectionSet
+	Deferred     bool
 }
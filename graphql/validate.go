@@ -0,0 +1,74 @@
+package graphql
+
+import "strings"
+
+// A ValidationError is an error found by ValidateQuery in the selection set
+// of the field at Path, which holds the aliases of the fields leading to it.
+// Path is empty for errors in the query itself, such as syntax errors.
+type ValidationError struct {
+	Path []string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return e.withPath(e.Err.Error())
+}
+
+// SanitizedError returns the sanitized message of Err, prefixed with Path.
+func (e *ValidationError) SanitizedError() string {
+	return e.withPath(sanitizeError(e.Err))
+}
+
+// withPath prefixes message with e.Path, if any.
+func (e *ValidationError) withPath(message string) string {
+	if len(e.Path) == 0 {
+		return message
+	}
+	return strings.Join(e.Path, ".") + ": " + message
+}
+
+// ValidationErrors holds all the errors found by PrepareQuery. Its sanitized
+// message is the message of the first error, while its full message lists all
+// of them.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) SanitizedError() string {
+	return e[0].SanitizedError()
+}
+
+// ValidateQuery checks that query parses and matches schema, with variables,
+// without executing any resolvers. It returns the errors it finds in the
+// query, at most 100, each as a *ValidationError, or nil if the query is
+// valid.
+//
+// ValidateQuery is meant for tools checking queries ahead of time, such as
+// linters of stored queries.
+func ValidateQuery(schema *Schema, query string, variables map[string]interface{}) []error {
+	parsed, err := Parse(query, variables)
+	if err != nil {
+		return []error{&ValidationError{Err: err}}
+	}
+
+	typ := schema.Query
+	if parsed.Kind == "mutation" {
+		typ = schema.Mutation
+	}
+	if typ == nil {
+		return []error{&ValidationError{Err: NewClientError("schema does not support %ss", parsed.Kind)}}
+	}
+	validationErrs, _ := prepareQuery(schema, typ, parsed.SelectionSet).(ValidationErrors)
+
+	var errs []error
+	for _, err := range validationErrs {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,178 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+)
+
+func TestValidateQuery(t *testing.T) {
+	resolved := false
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*partialUser {
+		resolved = true
+		return nil
+	})
+	query.FieldFunc("user", func(args struct{ Name string }) *partialUser {
+		resolved = true
+		return nil
+	})
+	mutation := schema.Mutation()
+	mutation.FieldFunc("rename", func(args struct{ Name string }) bool {
+		resolved = true
+		return true
+	})
+	schema.Object("User", partialUser{})
+	builtSchema := schema.MustBuild()
+
+	testCases := []struct {
+		query     string
+		variables map[string]interface{}
+		// errors holds the prefixes of the expected errors.
+		errors []string
+	}{
+		{
+			query: `{ users { name } a: user(name: "a") { name } }`,
+		},
+		{
+			query:     `mutation ($name: string!) { rename(name: $name) }`,
+			variables: map[string]interface{}{"name": "bob"},
+		},
+		{
+			query: `{ users { nam } user(id: 1) { name } missing static: users }`,
+			errors: []string{
+				`users: unknown field "nam"`,
+				`error parsing args for "user": missing required field name`,
+				`unknown field "missing"`,
+				`static: object field must have selections`,
+			},
+		},
+		{
+			query:  `mutation { rename }`,
+			errors: []string{`error parsing args for "rename": missing required field name`},
+		},
+		{
+			// The rest of syntax errors depends on the version of the parser.
+			query:  `{ users {`,
+			errors: []string{`Syntax Error GraphQL (1:10)`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		var messages []string
+		for _, err := range graphql.ValidateQuery(builtSchema, testCase.query, testCase.variables) {
+			messages = append(messages, err.Error())
+		}
+		if len(messages) != len(testCase.errors) {
+			t.Errorf("%s: expected errors %q, got %q", testCase.query, testCase.errors, messages)
+			continue
+		}
+		for i := range messages {
+			if !strings.HasPrefix(messages[i], testCase.errors[i]) {
+				t.Errorf("%s: expected errors %q, got %q", testCase.query, testCase.errors, messages)
+				break
+			}
+		}
+	}
+
+	if resolved {
+		t.Error("expected no resolvers to run")
+	}
+}
+
+func TestValidateQueryRepeatedFragments(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*partialUser {
+		return nil
+	})
+	schema.Object("User", partialUser{})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	// Each fragment spreads the previous one twice, so a fragment checked at
+	// every spread would report its error 2^16 times.
+	var b bytes.Buffer
+	b.WriteString("{ users { ...f16 } }\nfragment f0 on User { nam }\n")
+	for i := 1; i <= 16; i++ {
+		fmt.Fprintf(&b, "fragment f%d on User { ...f%d ...f%d }\n", i, i-1, i-1)
+	}
+	errs := graphql.ValidateQuery(builtSchema, b.String(), nil)
+	if len(errs) != 1 || errs[0].Error() != `users: unknown field "nam"` {
+		t.Errorf("expected a single unknown field error, got %d errors", len(errs))
+	}
+
+	// The number of errors is capped.
+	b.Reset()
+	b.WriteString("{ users {")
+	for i := 0; i < 150; i++ {
+		fmt.Fprintf(&b, " missing%d", i)
+	}
+	b.WriteString(" } }")
+	if errs := graphql.ValidateQuery(builtSchema, b.String(), nil); len(errs) != 100 {
+		t.Errorf("expected 100 errors, got %d", len(errs))
+	}
+}
+
+type errorLogger struct {
+	nopLogger
+	errs chan error
+}
+
+func (l *errorLogger) Error(ctx context.Context, err error, tags map[string]string) {
+	l.errs <- err
+}
+
+func TestValidationErrors(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*partialUser {
+		return nil
+	})
+	schema.Object("User", partialUser{})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	// A single error is returned as ValidationErrors too.
+	q := graphql.MustParse(`{ users { nam } }`, nil)
+	err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet)
+	if errs, ok := err.(graphql.ValidationErrors); !ok || len(errs) != 1 || err.Error() != `users: unknown field "nam"` {
+		t.Errorf("expected a single unknown field error, got %v", err)
+	}
+
+	socket := newFakeSocket()
+	defer close(socket.in)
+
+	logger := &errorLogger{errs: make(chan error, 1)}
+	makeCtx := func(ctx context.Context) context.Context { return ctx }
+	go graphql.ServeJSONSocket(context.Background(), socket, builtSchema, makeCtx, logger)
+
+	// Clients get the first error as the message of the error, alongside all
+	// errors, while the logger gets all errors.
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ users { nam } missing }"},
+	}
+	socket.expect(t, `{"id": "1", "type": "error", "message": "users: unknown field \"nam\"", "errors": [
+		{"message": "users: unknown field \"nam\""},
+		{"message": "unknown field \"missing\""}
+	]}`)
+
+	select {
+	case err := <-logger.errs:
+		if err.Error() != `users: unknown field "nam"; unknown field "missing"` {
+			t.Errorf("expected all errors to be logged, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for logged error")
+	}
+}
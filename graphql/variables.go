@@ -0,0 +1,144 @@
+package graphql
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// This file validates variables against the types declared in an operation,
+// so that clients get a descriptive error instead of a failure deep inside
+// argument parsing. Only scalar and list types are checked, as other types
+// can not be validated without the schema.
+
+var (
+	intTypes    = map[string]bool{"Int": true, "int": true, "int8": true, "int16": true, "int32": true, "int64": true}
+	uintTypes   = map[string]bool{"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true}
+	floatTypes  = map[string]bool{"Float": true, "float32": true, "float64": true}
+	stringTypes = map[string]bool{"String": true, "string": true}
+	boolTypes   = map[string]bool{"Boolean": true, "bool": true}
+)
+
+// typeString formats typ as it is written in a query.
+func typeString(typ ast.Type) string {
+	switch typ := typ.(type) {
+	case *ast.NonNull:
+		return typeString(typ.Type) + "!"
+	case *ast.List:
+		return "[" + typeString(typ.Type) + "]"
+	case *ast.Named:
+		return typ.Name.Value
+	default:
+		return typ.GetKind()
+	}
+}
+
+// jsonKind describes the JSON type of value.
+func jsonKind(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return reflect.TypeOf(value).String()
+	}
+}
+
+// isIntegral returns true if value is a whole number.
+func isIntegral(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		return f == math.Trunc(f) && !math.IsInf(f, 0)
+	default:
+		return false
+	}
+}
+
+// isNegative returns true if value is a number below 0.
+func isNegative(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() < 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() < 0
+	default:
+		return false
+	}
+}
+
+// matchesType returns true if value is acceptable for typ.
+func matchesType(typ ast.Type, value interface{}) bool {
+	switch typ := typ.(type) {
+	case *ast.NonNull:
+		return value != nil && matchesType(typ.Type, value)
+
+	case *ast.List:
+		if value == nil {
+			return true
+		}
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			// A single value is coerced to a list holding it.
+			return matchesType(typ.Type, value)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if !matchesType(typ.Type, v.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.Named:
+		if value == nil {
+			return true
+		}
+		name := typ.Name.Value
+		v := reflect.ValueOf(value)
+		switch {
+		case intTypes[name]:
+			return isIntegral(v)
+		case uintTypes[name]:
+			// Negative numbers would wrap around when converted.
+			return isIntegral(v) && !isNegative(v)
+		case floatTypes[name]:
+			return jsonKind(value) == "number"
+		case stringTypes[name]:
+			return v.Kind() == reflect.String
+		case boolTypes[name]:
+			return v.Kind() == reflect.Bool
+		case name == "ID":
+			return v.Kind() == reflect.String || isIntegral(v)
+		default:
+			return true
+		}
+
+	default:
+		return true
+	}
+}
+
+// validateVariable returns a ClientError if value does not match the type of
+// the variable declared by definition.
+func validateVariable(definition *ast.VariableDefinition, value interface{}) error {
+	if matchesType(definition.Type, value) {
+		return nil
+	}
+	return NewClientError("variable $%s expected %s, got %s", definition.Variable.Name.Value, typeString(definition.Type), jsonKind(value))
+}
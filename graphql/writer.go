@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// SetWriteBuffer makes writes to the connection asynchronous. Messages are
+// queued in a buffer holding up to size messages and written in order by a
+// dedicated goroutine, so that a slow client does not block reruns. If the
+// buffer overflows, the client is considered too slow and the connection is
+// closed. SetWriteBuffer must be called before ServeJSONSocket. A zero size
+// writes synchronously.
+func (c *conn) SetWriteBuffer(size int) {
+	if size <= 0 {
+		c.writeQueue = nil
+		return
+	}
+	c.writeQueue = make(chan OutEnvelope, size)
+}
+
+// enqueueWrite queues out to be written by drainWrites, closing the socket if
+// the queue is full.
+func (c *conn) enqueueWrite(out OutEnvelope) {
+	select {
+	case c.writeQueue <- out:
+	default:
+		log.Println("closing slow consumer: write buffer full")
+		c.closeWithReason(websocket.CloseTryAgainLater, "write buffer full")
+	}
+}
+
+// startWriter starts draining writeQueue, unless writes are synchronous or
+// it is already being drained.
+func (c *conn) startWriter() {
+	if c.writeQueue == nil || c.stopWrites != nil {
+		return
+	}
+	c.stopWrites = make(chan struct{})
+	c.writesDone = make(chan struct{})
+	go c.drainWrites(c.stopWrites, c.writesDone)
+}
+
+// stopWriter stops draining writeQueue and waits for the pending write, if
+// any, then writes the messages still queued, so that the connection no
+// longer writes to its socket once stopWriter returns.
+func (c *conn) stopWriter() {
+	if c.stopWrites == nil {
+		return
+	}
+	close(c.stopWrites)
+	<-c.writesDone
+	c.stopWrites, c.writesDone = nil, nil
+
+	for {
+		select {
+		case out := <-c.writeQueue:
+			c.writeNow(out)
+		default:
+			return
+		}
+	}
+}
+
+// drainWrites writes queued messages until stop is closed, and then closes
+// done.
+func (c *conn) drainWrites(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		select {
+		case out := <-c.writeQueue:
+			c.writeNow(out)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -40,11 +40,13 @@ func (r *dbResource) shouldInvalidate(update *update) bool {
 // dbTracker tracks many dbResources
 type dbTracker struct {
 	mu        sync.Mutex
+	schema    *sqlgen.Schema
 	resources map[*dbResource]struct{}
 }
 
-func newDbTracker() *dbTracker {
+func newDbTracker(schema *sqlgen.Schema) *dbTracker {
 	return &dbTracker{
+		schema:    schema,
 		resources: make(map[*dbResource]struct{}),
 	}
 }
@@ -65,6 +67,10 @@ func (t *dbTracker) remove(r *dbResource) {
 
 // processBinlog processes a set of updates from the MySQL binlog
 func (t *dbTracker) processBinlog(update *update) {
+	if table, ok := t.schema.ByName[update.table]; ok {
+		invalidateRows(table, update)
+	}
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -100,7 +106,7 @@ type LiveDB struct {
 func NewLiveDB(db *sqlgen.DB) *LiveDB {
 	return &LiveDB{
 		DB:      db,
-		tracker: newDbTracker(),
+		tracker: newDbTracker(db.Schema),
 	}
 }
 
@@ -127,6 +133,12 @@ func (ldb *LiveDB) query(ctx context.Context, query *sqlgen.BaseSelectQuery) ([]
 	key := queryCacheKey{clause: clause, args: internal.ToArray(args)}
 
 	result, err := reactive.Cache(ctx, key, func(ctx context.Context) (interface{}, error) {
+		// Depend only on the selected row when querying by primary key. As for
+		// testers, register the dependency before querying.
+		if addRowDependency(ctx, query.Table, query.Filter) {
+			return ldb.DB.BaseQuery(ctx, query)
+		}
+
 		// Build a tester for the dependency.
 		tester, err := ldb.Schema.MakeTester(query.Table.Name, query.Filter)
 		if err != nil {
@@ -0,0 +1,145 @@
+package livesql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/samsarahq/thunder/internal"
+	"github.com/samsarahq/thunder/reactive"
+	"github.com/samsarahq/thunder/sqlgen"
+)
+
+// Queries selecting rows by primary key, such as
+//
+//   ldb.QueryRow(ctx, &user, sqlgen.Filter{"id": id}, nil)
+//   ldb.QueryRow(ctx, &membership, sqlgen.Filter{"group_id": groupId, "user_id": userId}, nil)
+//
+// do not register a tester with the dbTracker. Instead, they depend on the
+// reactive dependency key of the row through reactive.AddDependencyKey, and
+// binlog updates invalidate the keys of the rows they change. An UPDATE to a
+// row thus reruns only the computations that read that row, without testing
+// every tracked query against the update.
+//
+// Rows changed outside of the binlog can be invalidated with InvalidateRow.
+
+// rowKey is the reactive dependency key of the row of table with the primary
+// key values in values, an array holding one value per primary key column.
+type rowKey struct {
+	table  string
+	values interface{}
+}
+
+// tableKey is the reactive dependency key of all rows of a table read by
+// primary key. It is invalidated when an update to the table could not be
+// parsed.
+type tableKey struct {
+	table string
+}
+
+// makeRowKey returns the rowKey of the row of table with primary key values,
+// given in the order of table.PrimaryKey. Values are converted to the types
+// of the primary key columns, so that filters on untyped constants and
+// pointers match the rows parsed from the binlog.
+func makeRowKey(table *sqlgen.Table, values []interface{}) rowKey {
+	coerced := make([]interface{}, len(values))
+	for i, column := range table.PrimaryKey {
+		coerced[i] = coerceKey(column, values[i])
+	}
+	return rowKey{table: table.Name, values: internal.ToArray(coerced)}
+}
+
+// coerceKey converts value to the type of the primary key column.
+func coerceKey(column *sqlgen.Column, value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	for v.IsValid() && v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil
+	}
+
+	typ := column.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if v.Type().ConvertibleTo(typ) {
+		v = v.Convert(typ)
+	}
+	return v.Interface()
+}
+
+// isInValue returns true if value is a slice of keys matched with an IN
+// clause.
+func isInValue(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+// addRowDependency registers a dependency on the rows selected by filter if
+// filter selects rows by their full primary key only, and returns false
+// otherwise. The key of a single-column primary key may be a slice of keys,
+// as for batched loads of many rows.
+func addRowDependency(ctx context.Context, table *sqlgen.Table, filter sqlgen.Filter) bool {
+	if len(filter) != len(table.PrimaryKey) {
+		return false
+	}
+	values := make([]interface{}, len(table.PrimaryKey))
+	for i, column := range table.PrimaryKey {
+		value, ok := filter[column.Name]
+		if !ok || (isInValue(value) && len(values) > 1) {
+			return false
+		}
+		values[i] = value
+	}
+
+	reactive.AddDependencyKey(ctx, tableKey{table: table.Name})
+	if isInValue(values[0]) {
+		v := reflect.ValueOf(values[0])
+		for i := 0; i < v.Len(); i++ {
+			reactive.AddDependencyKey(ctx, makeRowKey(table, []interface{}{v.Index(i).Interface()}))
+		}
+		return true
+	}
+	reactive.AddDependencyKey(ctx, makeRowKey(table, values))
+	return true
+}
+
+// invalidateRows invalidates the keys of the rows changed by update, or of
+// the whole table if update could not be parsed.
+func invalidateRows(table *sqlgen.Table, update *update) {
+	if update.err != nil {
+		reactive.Invalidate(tableKey{table: update.table})
+		return
+	}
+
+	values := make([]interface{}, len(table.PrimaryKey))
+	for _, d := range update.deltas {
+		for _, row := range []interface{}{d.before, d.after} {
+			if row == nil {
+				continue
+			}
+			struc := reflect.ValueOf(row).Elem()
+			for i, column := range table.PrimaryKey {
+				values[i] = struc.FieldByIndex(column.Index).Interface()
+			}
+			reactive.Invalidate(makeRowKey(table, values))
+		}
+	}
+}
+
+// InvalidateRow reruns the live queries that read the row of table with the
+// given primary key, for rows changed without going through the binlog. For
+// composite primary keys, primaryKey holds one value per key column, in
+// column order.
+func (ldb *LiveDB) InvalidateRow(table string, primaryKey ...interface{}) error {
+	descriptor, ok := ldb.Schema.ByName[table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	if len(primaryKey) != len(descriptor.PrimaryKey) {
+		return fmt.Errorf("table %s has %d primary key columns, got %d values", table, len(descriptor.PrimaryKey), len(primaryKey))
+	}
+	reactive.Invalidate(makeRowKey(descriptor, primaryKey))
+	return nil
+}
@@ -0,0 +1,180 @@
+package livesql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/reactive"
+	"github.com/samsarahq/thunder/sqlgen"
+)
+
+type user struct {
+	Id   int64 `sql:",primary"`
+	Name string
+}
+
+type membership struct {
+	GroupId int64 `sql:",primary"`
+	UserId  int64 `sql:",primary"`
+	Role    string
+}
+
+// runRowReaders starts a rerunner for each filter, reading rows of table by
+// filter, and returns a channel receiving the name of each run.
+func runRowReaders(t *testing.T, table *sqlgen.Table, filters map[string]sqlgen.Filter) (chan string, func()) {
+	runs := make(chan string, 16)
+	var runners []*reactive.Rerunner
+	for name, filter := range filters {
+		name, filter := name, filter
+		runners = append(runners, reactive.NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+			if !addRowDependency(ctx, table, filter) {
+				t.Errorf("expected %s to read rows by primary key", name)
+			}
+			runs <- name
+			return nil, nil
+		}, 0))
+	}
+	return runs, func() {
+		for _, runner := range runners {
+			runner.Stop()
+		}
+	}
+}
+
+// expectRuns checks that runs receives exactly the expected names.
+func expectRuns(t *testing.T, runs chan string, expected ...string) {
+	got := make(map[string]int)
+	for range expected {
+		select {
+		case name := <-runs:
+			got[name]++
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected runs of %v, got %v", expected, got)
+		}
+	}
+	for _, name := range expected {
+		if got[name] == 0 {
+			t.Errorf("expected run of %s, got %v", name, got)
+		}
+	}
+	select {
+	case name := <-runs:
+		t.Errorf("unexpected run of %s", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRowKeyInvalidation(t *testing.T) {
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("users", sqlgen.UniqueId, user{})
+	table := schema.ByName["users"]
+
+	one := 1
+	runs, stop := runRowReaders(t, table, map[string]sqlgen.Filter{
+		"one":     {"id": int64(1)},
+		"untyped": {"id": &one},
+		"many":    {"id": []int64{2, 3}},
+	})
+	defer stop()
+	expectRuns(t, runs, "one", "untyped", "many")
+
+	// An UPDATE reruns only the readers of the changed row, whatever the type
+	// of the key they filtered on.
+	invalidateRows(table, &update{
+		table:  "users",
+		deltas: []delta{{before: &user{Id: 1, Name: "a"}, after: &user{Id: 1, Name: "b"}}},
+	})
+	expectRuns(t, runs, "one", "untyped")
+
+	// Rows inserted, deleted, and read in batches are invalidated too.
+	invalidateRows(table, &update{
+		table:  "users",
+		deltas: []delta{{after: &user{Id: 3}}},
+	})
+	expectRuns(t, runs, "many")
+
+	invalidateRows(table, &update{
+		table:  "users",
+		deltas: []delta{{before: &user{Id: 4}}},
+	})
+	expectRuns(t, runs)
+
+	// Updates that could not be parsed rerun all readers of the table.
+	invalidateRows(table, &update{table: "users", err: errors.New("bad update")})
+	expectRuns(t, runs, "one", "untyped", "many")
+}
+
+func TestAddRowDependencyRequiresPrimaryKey(t *testing.T) {
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("users", sqlgen.UniqueId, user{})
+	table := schema.ByName["users"]
+
+	for _, filter := range []sqlgen.Filter{
+		nil,
+		{"name": "a"},
+		{"id": int64(1), "name": "a"},
+	} {
+		if addRowDependency(context.Background(), table, filter) {
+			t.Errorf("expected %v not to read rows by primary key", filter)
+		}
+	}
+}
+
+func TestCompositeRowKeyInvalidation(t *testing.T) {
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("memberships", sqlgen.UniqueId, membership{})
+	table := schema.ByName["memberships"]
+
+	runs, stop := runRowReaders(t, table, map[string]sqlgen.Filter{
+		"1,2": {"group_id": int64(1), "user_id": int64(2)},
+		"2,1": {"group_id": 2, "user_id": 1},
+		"1,3": {"group_id": int64(1), "user_id": int64(3)},
+	})
+	defer stop()
+	expectRuns(t, runs, "1,2", "2,1", "1,3")
+
+	// The key of a row is the full tuple of its primary key, so that rows
+	// sharing some of their key columns do not collide.
+	invalidateRows(table, &update{
+		table:  "memberships",
+		deltas: []delta{{before: &membership{GroupId: 1, UserId: 2}, after: &membership{GroupId: 1, UserId: 2, Role: "admin"}}},
+	})
+	expectRuns(t, runs, "1,2")
+
+	invalidateRows(table, &update{
+		table:  "memberships",
+		deltas: []delta{{before: &membership{GroupId: 2, UserId: 1}}},
+	})
+	expectRuns(t, runs, "2,1")
+
+	ldb := &LiveDB{DB: sqlgen.NewDB(nil, schema)}
+	if err := ldb.InvalidateRow("memberships", int64(1), 3); err != nil {
+		t.Fatal(err)
+	}
+	expectRuns(t, runs, "1,3")
+
+	if err := ldb.InvalidateRow("memberships", int64(1)); err == nil {
+		t.Error("expected partial primary key to fail")
+	}
+	if err := ldb.InvalidateRow("unknown", int64(1)); err == nil {
+		t.Error("expected unknown table to fail")
+	}
+}
+
+func TestAddRowDependencyRequiresFullCompositeKey(t *testing.T) {
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("memberships", sqlgen.UniqueId, membership{})
+	table := schema.ByName["memberships"]
+
+	for _, filter := range []sqlgen.Filter{
+		{"group_id": int64(1)},
+		{"group_id": int64(1), "role": "admin"},
+		{"group_id": int64(1), "user_id": []int64{2, 3}},
+	} {
+		if addRowDependency(context.Background(), table, filter) {
+			t.Errorf("expected %v not to read rows by primary key", filter)
+		}
+	}
+}
@@ -45,6 +45,12 @@ func TestMerge(t *testing.T) {
 			Diff:        `{"$": [[1, 3], -1], "3": [{"name": "eli"}]}`,
 			ExpectedNew: `[{"name": "bob"}, {"name": "carol"}, {"name": "dean"}, {"name": "eli"}]`,
 		},
+		{
+			Case:        "Array with a run starting at zero",
+			Prev:        `[{"name": "alice"}, {"name": "bob"}]`,
+			Diff:        `{"$": [-1, [0, 2]], "0": [{"name": "carol"}]}`,
+			ExpectedNew: `[{"name": "carol"}, {"name": "alice"}, {"name": "bob"}]`,
+		},
 		{
 			Case:        "Map",
 			Prev:        `{"name": "bob", "address": {"state": "ca", "city": "sf"}, "age": 30}`,
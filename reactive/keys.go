@@ -0,0 +1,52 @@
+package reactive
+
+import (
+	"context"
+	"sync"
+)
+
+// keyResources holds the Resources of keys depended on with AddDependencyKey.
+// A key's Resource is removed once it is invalidated, or once no computation
+// depends on it anymore.
+var keyResources = struct {
+	mu sync.Mutex
+	m  map[interface{}]*Resource
+}{
+	m: make(map[interface{}]*Resource),
+}
+
+// AddDependencyKey registers that the current computation depends on the data
+// identified by key, such as a table name or a row ID. The computation is
+// rerun when Invalidate is called with key, from any goroutine.
+func AddDependencyKey(ctx context.Context, key interface{}) {
+	keyResources.mu.Lock()
+	defer keyResources.mu.Unlock()
+
+	r, ok := keyResources.m[key]
+	if !ok || r.node.Invalidated() {
+		r = NewResource()
+		keyResources.m[key] = r
+		r.Cleanup(func() {
+			keyResources.mu.Lock()
+			defer keyResources.mu.Unlock()
+			if keyResources.m[key] == r {
+				delete(keyResources.m, key)
+			}
+		})
+	}
+	AddDependency(ctx, r)
+}
+
+// Invalidate reruns all computations that depend on key through
+// AddDependencyKey. It allows data changed outside of any computation, for
+// example by a background job, to update the subscriptions reading it.
+func Invalidate(key interface{}) {
+	keyResources.mu.Lock()
+	r, ok := keyResources.m[key]
+	delete(keyResources.m, key)
+	keyResources.mu.Unlock()
+
+	if ok {
+		r.Invalidate()
+	}
+}
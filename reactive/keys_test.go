@@ -0,0 +1,61 @@
+package reactive
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestInvalidate tests that Invalidate reruns only the computations that
+// depend on the key.
+func TestInvalidate(t *testing.T) {
+	runs := make(chan string, 16)
+
+	for _, key := range []string{"a", "b"} {
+		key := key
+		runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+			AddDependencyKey(ctx, key)
+			runs <- key
+			return nil, nil
+		}, 0)
+		defer runner.Stop()
+	}
+
+	expectRuns := func(expected ...string) {
+		got := make(map[string]int)
+		for range expected {
+			select {
+			case key := <-runs:
+				got[key]++
+			case <-time.After(2 * time.Second):
+				t.Fatalf("expected runs of %v, got %v", expected, got)
+			}
+		}
+		for _, key := range expected {
+			if got[key] == 0 {
+				t.Errorf("expected run of %s, got %v", key, got)
+			}
+		}
+		select {
+		case key := <-runs:
+			t.Errorf("unexpected run of %s", key)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	expectRuns("a", "b")
+
+	Invalidate("a")
+	expectRuns("a")
+
+	// The rerun depends on the key again.
+	Invalidate("a")
+	expectRuns("a")
+
+	Invalidate("b")
+	expectRuns("b")
+
+	// Unknown keys are ignored.
+	Invalidate("c")
+	expectRuns()
+}
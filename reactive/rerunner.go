@@ -1,12 +1,27 @@
 package reactive
 
 import (
+	"container/list"
 	"context"
 	"errors"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// DefaultMinRetryDelay is the shortest delay before retrying a computation
+	// that returned RetrySentinelError.
+	DefaultMinRetryDelay = 100 * time.Millisecond
+	// DefaultMaxRetryDelay caps the delay before retrying a computation that
+	// returned RetrySentinelError.
+	DefaultMaxRetryDelay = time.Minute
+	// DefaultRetryJitter is the fraction of the retry delay randomly added to
+	// it, to spread out the retries of computations that failed together.
+	DefaultRetryJitter = 0.25
+)
+
 var (
 	// Sentrinel error to tell the rerunner to not dump the current
 	// computation cache and let the error'd function retry.
@@ -65,17 +80,38 @@ type computation struct {
 }
 
 // cache caches computations
+//
+// If maxSize is positive, the cache holds at most maxSize computations,
+// evicting the least recently used. Evicted computations are recomputed the
+// next time they are needed. order holds the keys of the computations, most
+// recently used first.
 type cache struct {
 	mu           sync.Mutex
 	locker       *locker
 	computations map[interface{}]*computation
+	maxSize      int
+	order        *list.List
+	elements     map[interface{}]*list.Element
+}
+
+func newCache() *cache {
+	return &cache{
+		computations: make(map[interface{}]*computation),
+		locker:       newLocker(),
+		order:        list.New(),
+		elements:     make(map[interface{}]*list.Element),
+	}
 }
 
 func (c *cache) get(key interface{}) *computation {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	return c.computations[key]
+	computation := c.computations[key]
+	if computation != nil {
+		c.order.MoveToFront(c.elements[key])
+	}
+	return computation
 }
 
 // set adds a computation to the cache for the given key
@@ -85,6 +121,56 @@ func (c *cache) set(key interface{}, computation *computation) {
 
 	if c.computations[key] == nil {
 		c.computations[key] = computation
+		c.elements[key] = c.order.PushFront(key)
+		c.evict()
+	}
+}
+
+// delete removes the computation for the given key. c.mu must be held.
+func (c *cache) delete(key interface{}) {
+	delete(c.computations, key)
+	c.order.Remove(c.elements[key])
+	delete(c.elements, key)
+}
+
+// evict removes the least recently used computations until the cache holds at
+// most maxSize computations. c.mu must be held.
+func (c *cache) evict() {
+	for c.maxSize > 0 && len(c.computations) > c.maxSize {
+		c.delete(c.order.Back().Value)
+	}
+}
+
+// setMaxSize changes the maximum size of the cache, evicting computations if
+// it holds more.
+func (c *cache) setMaxSize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxSize = size
+	c.evict()
+}
+
+// size returns the number of computations in the cache.
+func (c *cache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.computations)
+}
+
+// invalidate invalidates all cached computations, so that they are
+// recomputed the next time they are needed.
+func (c *cache) invalidate() {
+	c.mu.Lock()
+	computations := make([]*computation, 0, len(c.computations))
+	for _, computation := range c.computations {
+		computations = append(computations, computation)
+	}
+	c.mu.Unlock()
+
+	for _, computation := range computations {
+		computation.node.invalidate()
 	}
 }
 
@@ -94,7 +180,7 @@ func (c *cache) cleanInvalidated() {
 
 	for key, computation := range c.computations {
 		if computation.node.Invalidated() {
-			delete(c.computations, key)
+			c.delete(key)
 		}
 	}
 }
@@ -130,6 +216,7 @@ func (r *Resource) Cleanup(f func()) {
 
 type computationKey struct{}
 type cacheKey struct{}
+type rerunnerKey struct{}
 
 func AddDependency(ctx context.Context, r *Resource) {
 	if !HasRerunner(ctx) {
@@ -207,6 +294,17 @@ type Rerunner struct {
 	minRerunInterval time.Duration
 	retryDelay       time.Duration
 
+	// backoff is the retry delay before jitter, doubled on every consecutive
+	// RetrySentinelError between minRetryDelay and maxRetryDelay. retryMu
+	// guards the retry options, which can be changed while computing.
+	// backoffNext is set by Backoff to delay the next rerun the same way.
+	backoff       time.Duration
+	retryMu       sync.Mutex
+	minRetryDelay time.Duration
+	maxRetryDelay time.Duration
+	retryJitter   float64
+	backoffNext   bool
+
 	// flushed tracks if the next computation should run without delay. It is set
 	// to false as soon as the next computation starts. flushCh is closed when
 	// flushed is set to true.
@@ -218,9 +316,27 @@ type Rerunner struct {
 	computation *computation
 	stop        bool
 
+	// stopped is set to 1 by the first call to Stop.
+	stopped int32
+
 	lastRun time.Time
 }
 
+// rerunnersCreated and rerunnersStopped count the Rerunners created and
+// stopped by the process.
+var rerunnersCreated, rerunnersStopped int64
+
+// RerunnerCounts returns the number of Rerunners created and stopped since the
+// process started. Their difference is the number of Rerunners that have not
+// been stopped; if it keeps growing while the number of subscriptions stays
+// flat, Rerunners are leaking.
+func RerunnerCounts() (created, stopped int64) {
+	// Load stopped first, so that stopped never exceeds created.
+	stopped = atomic.LoadInt64(&rerunnersStopped)
+	created = atomic.LoadInt64(&rerunnersCreated)
+	return created, stopped
+}
+
 // NewRerunner runs f continuously
 func NewRerunner(ctx context.Context, f ComputeFunc, minRerunInterval time.Duration) *Rerunner {
 	ctx, cancelCtx := context.WithCancel(ctx)
@@ -229,20 +345,91 @@ func NewRerunner(ctx context.Context, f ComputeFunc, minRerunInterval time.Durat
 		ctx:       ctx,
 		cancelCtx: cancelCtx,
 
-		f: f,
-		cache: &cache{
-			computations: make(map[interface{}]*computation),
-			locker:       newLocker(),
-		},
+		f:                f,
+		cache:            newCache(),
 		minRerunInterval: minRerunInterval,
 		retryDelay:       minRerunInterval,
 
+		backoff:       minRerunInterval,
+		minRetryDelay: DefaultMinRetryDelay,
+		maxRetryDelay: DefaultMaxRetryDelay,
+		retryJitter:   DefaultRetryJitter,
+
 		flushCh: make(chan struct{}, 0),
 	}
+	atomic.AddInt64(&rerunnersCreated, 1)
 	go r.run()
 	return r
 }
 
+// SetRetryBackoff configures the delay before retrying a computation that
+// returned RetrySentinelError. The delay starts at minDelay, or the minimum
+// rerun interval if it is longer, and doubles on every consecutive retry up to
+// maxDelay. A random fraction of up to jitter of the delay is added to it, so
+// that computations failing at the same time do not all retry at once.
+func (r *Rerunner) SetRetryBackoff(minDelay, maxDelay time.Duration, jitter float64) {
+	r.retryMu.Lock()
+	defer r.retryMu.Unlock()
+
+	r.minRetryDelay = minDelay
+	r.maxRetryDelay = maxDelay
+	r.retryJitter = jitter
+}
+
+// nextRetryDelay doubles the backoff and returns the jittered delay before the
+// next retry. r.mu must be held.
+func (r *Rerunner) nextRetryDelay() time.Duration {
+	r.retryMu.Lock()
+	defer r.retryMu.Unlock()
+
+	r.backoff = r.backoff * 2
+	if r.backoff < r.minRetryDelay {
+		r.backoff = r.minRetryDelay
+	}
+	if r.backoff > r.maxRetryDelay {
+		r.backoff = r.maxRetryDelay
+	}
+	return r.backoff + time.Duration(rand.Float64()*r.retryJitter*float64(r.backoff))
+}
+
+// Backoff delays the next rerun of the Rerunner computing ctx, for
+// computations that keep changing on every rerun. The delay grows like the
+// delay before retrying a computation that returned RetrySentinelError,
+// doubling on every consecutive rerun calling Backoff up to the maximum retry
+// delay, and is reset to the minimum rerun interval by the first rerun that
+// does not call Backoff. Outside of a Rerunner, Backoff does nothing.
+func Backoff(ctx context.Context) {
+	r, ok := ctx.Value(rerunnerKey{}).(*Rerunner)
+	if !ok {
+		return
+	}
+	r.retryMu.Lock()
+	defer r.retryMu.Unlock()
+	r.backoffNext = true
+}
+
+// takeBackoff returns true if the computation called Backoff, and clears the
+// request.
+func (r *Rerunner) takeBackoff() bool {
+	r.retryMu.Lock()
+	defer r.retryMu.Unlock()
+	backoff := r.backoffNext
+	r.backoffNext = false
+	return backoff
+}
+
+// SetCacheLimit limits the number of computations cached with Cache to size,
+// evicting the least recently used ones. Evicted computations are recomputed
+// the next time they are needed. Zero, the default, means no limit.
+func (r *Rerunner) SetCacheLimit(size int) {
+	r.cache.setMaxSize(size)
+}
+
+// CacheSize returns the number of computations currently cached with Cache.
+func (r *Rerunner) CacheSize() int {
+	return r.cache.size()
+}
+
 // RerunImmediately removes the delay from the next recomputation.
 func (r *Rerunner) RerunImmediately() {
 	r.flushMu.Lock()
@@ -254,6 +441,25 @@ func (r *Rerunner) RerunImmediately() {
 	}
 }
 
+// Invalidate reruns the computation as if all of its dependencies were
+// invalidated, recomputing the computations cached with Cache as well. Like
+// other invalidations, the rerun waits for the minimum rerun interval unless
+// RerunImmediately is called.
+func (r *Rerunner) Invalidate() {
+	// The lock is held while computing, so invalidate in the background once
+	// the current computation, if any, has finished.
+	go func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.stop || r.computation == nil {
+			return
+		}
+		r.cache.invalidate()
+		r.computation.node.invalidate()
+	}()
+}
+
 // run performs an actual computation
 func (r *Rerunner) run() {
 	// Wait for the minimum rerun interval. Exit early if the computation is stopped.
@@ -287,17 +493,14 @@ func (r *Rerunner) run() {
 
 	r.cache.cleanInvalidated()
 	ctx := context.WithValue(r.ctx, cacheKey{}, r.cache)
+	ctx = context.WithValue(ctx, rerunnerKey{}, r)
 
 	computation, err := run(ctx, r.f)
 	r.lastRun = time.Now()
+	backoff := r.takeBackoff()
 	if err != nil {
 		if err == RetrySentinelError {
-			r.retryDelay = r.retryDelay * 2
-
-			// Max out the retry delay to at 1 minute.
-			if r.retryDelay > time.Minute {
-				r.retryDelay = time.Minute
-			}
+			r.retryDelay = r.nextRetryDelay()
 			go r.run()
 		} else {
 			// If we encountered an error that is not the retry sentinel,
@@ -306,18 +509,24 @@ func (r *Rerunner) run() {
 		}
 	} else {
 		// If we succeeded in the computation, we can release the old computation
-		// and reset the retry delay.
+		// and reset the retry delay, unless the computation asked to back off.
 		if r.computation != nil {
 			go r.computation.node.release()
 			r.computation = nil
 		}
 
 		r.computation = computation
-		r.retryDelay = r.minRerunInterval
+		if backoff {
+			r.retryDelay = r.nextRetryDelay()
+		} else {
+			r.retryDelay = r.minRerunInterval
+			r.backoff = r.minRerunInterval
+		}
 
 		// Schedule a rerun whenever our node becomes invalidated (which might already
-		// have happened!)
-		computation.node.handleInvalidate(r.run)
+		// have happened!) The rerun waits for the minimum rerun interval in its own
+		// goroutine, so that it does not hold up invalidating other computations.
+		computation.node.handleInvalidate(func() { go r.run() })
 	}
 }
 
@@ -325,6 +534,10 @@ func (r *Rerunner) Stop() {
 	// Call cancelCtx before acquiring the lock as the lock might be held for a long time during a running computation.
 	r.cancelCtx()
 
+	if atomic.CompareAndSwapInt32(&r.stopped, 0, 1) {
+		atomic.AddInt64(&rerunnersStopped, 1)
+	}
+
 	r.mu.Lock()
 	r.stop = true
 	if r.computation != nil {
@@ -116,6 +116,78 @@ func TestRerunCache(t *testing.T) {
 	run.Expect(t, "expected rerun")
 }
 
+// TestRerunnerInvalidate tests that Invalidate reruns a computation and the
+// computations it cached, although none of their dependencies changed.
+func TestRerunnerInvalidate(t *testing.T) {
+	run := NewExpect()
+	innerRun := NewExpect()
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		Cache(ctx, 0, func(ctx context.Context) (interface{}, error) {
+			innerRun.Trigger()
+			return nil, nil
+		})
+
+		run.Trigger()
+		return nil, nil
+	}, 0)
+
+	run.Expect(t, "expected run")
+	innerRun.Expect(t, "expected inner run")
+
+	run = NewExpect()
+	innerRun = NewExpect()
+	runner.Invalidate()
+
+	run.Expect(t, "expected rerun")
+	innerRun.Expect(t, "expected inner rerun")
+}
+
+// TestCacheLimit tests that the least recently used cached computations are
+// evicted and recomputed when the cache is full.
+func TestCacheLimit(t *testing.T) {
+	dep := NewResource()
+
+	run := NewExpect()
+	var innerRuns [3]int32
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep)
+
+		for i := range innerRuns {
+			i := i
+			Cache(ctx, i, func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&innerRuns[i], 1)
+				return nil, nil
+			})
+		}
+
+		run.Trigger()
+		return nil, nil
+	}, 0)
+	runner.SetCacheLimit(2)
+	defer runner.Stop()
+
+	run.Expect(t, "expected run")
+	if size := runner.CacheSize(); size != 2 {
+		t.Errorf("expected 2 cached computations, got %d", size)
+	}
+
+	run = NewExpect()
+	dep.Strobe()
+	run.Expect(t, "expected rerun")
+
+	// Key 0 was evicted, and recomputing it evicts the others in turn.
+	for i := range innerRuns {
+		if runs := atomic.LoadInt32(&innerRuns[i]); runs != 2 {
+			t.Errorf("expected key %d to be computed twice, got %d", i, runs)
+		}
+	}
+	if size := runner.CacheSize(); size != 2 {
+		t.Errorf("expected 2 cached computations, got %d", size)
+	}
+}
+
 // TestStop tests that a runner stops recomputating after Stop is called.
 func TestStop(t *testing.T) {
 	dep := NewResource()
@@ -136,6 +208,24 @@ func TestStop(t *testing.T) {
 	// run is supposed to stop; if it runs, it will panic in calling Trigger
 }
 
+func TestRerunnerCounts(t *testing.T) {
+	created, stopped := RerunnerCounts()
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	}, 0)
+	if c, s := RerunnerCounts(); c != created+1 || s != stopped {
+		t.Errorf("expected %d created and %d stopped, got %d and %d", created+1, stopped, c, s)
+	}
+
+	// Only the first Stop counts.
+	runner.Stop()
+	runner.Stop()
+	if c, s := RerunnerCounts(); c != created+1 || s != stopped+1 {
+		t.Errorf("expected %d created and %d stopped, got %d and %d", created+1, stopped+1, c, s)
+	}
+}
+
 func TestError(t *testing.T) {
 	dep := NewResource()
 
@@ -244,6 +334,103 @@ func TestErrorRetryDelay(t *testing.T) {
 	runner.Stop()
 }
 
+// TestErrorRetryBackoff verifies that retries are delayed exponentially with
+// jitter, up to the configured maximum delay.
+func TestErrorRetryBackoff(t *testing.T) {
+	var mu sync.Mutex
+	var runTimes []time.Time
+	ready := make(chan struct{})
+	done := NewExpect()
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		<-ready
+
+		mu.Lock()
+		defer mu.Unlock()
+		runTimes = append(runTimes, time.Now())
+		if len(runTimes) == 6 {
+			done.Trigger()
+		}
+		return nil, RetrySentinelError
+	}, 0)
+	runner.SetRetryBackoff(20*time.Millisecond, 80*time.Millisecond, 0.5)
+	close(ready)
+
+	done.Expect(t, "expected retries")
+	runner.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, delay := range []time.Duration{20, 40, 80, 80, 80} {
+		delay *= time.Millisecond
+		delta := runTimes[i+1].Sub(runTimes[i])
+		if delta < delay {
+			t.Errorf("expected retry %d after at least %v, got %v", i, delay, delta)
+		}
+		// Allow for the jitter, and some scheduling delay.
+		if delta > delay*3/2+50*time.Millisecond {
+			t.Errorf("expected retry %d after at most %v, got %v", i, delay*3/2, delta)
+		}
+	}
+}
+
+// TestBackoff verifies that reruns of a computation calling Backoff are
+// delayed like retries, and that the delay resets once it stops.
+func TestBackoff(t *testing.T) {
+	var mu sync.Mutex
+	var runTimes []time.Time
+	backoff := true
+	resource := NewResource()
+	done := NewExpect()
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, resource)
+
+		mu.Lock()
+		defer mu.Unlock()
+		runTimes = append(runTimes, time.Now())
+		if len(runTimes) == 5 {
+			backoff = false
+		}
+		if len(runTimes) == 8 {
+			done.Trigger()
+		}
+		if backoff {
+			Backoff(ctx)
+		}
+		return nil, nil
+	}, 0)
+	runner.SetRetryBackoff(20*time.Millisecond, 80*time.Millisecond, 0)
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				resource.Strobe()
+			}
+		}
+	}()
+
+	done.Expect(t, "expected reruns")
+	close(stop)
+	runner.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, delay := range []time.Duration{20, 40, 80, 80} {
+		delay *= time.Millisecond
+		if delta := runTimes[i+1].Sub(runTimes[i]); delta < delay {
+			t.Errorf("expected rerun %d after at least %v, got %v", i, delay, delta)
+		}
+	}
+	if delta := runTimes[6].Sub(runTimes[5]); delta > 15*time.Millisecond {
+		t.Errorf("expected rerun without backoff after at most 15ms, got %v", delta)
+	}
+}
+
 // TestCacheLock tests that concurrent calls to Cache with the same key result
 // in only one execution.
 func TestCacheLock(t *testing.T) {
@@ -383,3 +570,38 @@ func TestRerunImmediately(t *testing.T) {
 	r.Invalidate()
 	run.Expect(t, "expected rerun")
 }
+
+// TestRerunDoesNotBlockInvalidation tests that a rerun waiting for its
+// minimum rerun interval does not hold up invalidating the other computations
+// depending on the same resource.
+func TestRerunDoesNotBlockInvalidation(t *testing.T) {
+	dep := NewResource()
+
+	var slowRuns sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		slowRuns.Add(1)
+		var once sync.Once
+		slow := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, dep)
+			once.Do(slowRuns.Done)
+			return nil, nil
+		}, time.Hour)
+		defer slow.Stop()
+	}
+	slowRuns.Wait()
+
+	run := NewExpect()
+	fast := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep)
+		run.Trigger()
+		return nil, nil
+	}, time.Hour)
+	defer fast.Stop()
+	run.Expect(t, "expected run")
+
+	run = NewExpect()
+	fast.RerunImmediately()
+	dep.Strobe()
+
+	run.Expect(t, "expected rerun without waiting for the other reruns")
+}
@@ -0,0 +1,114 @@
+package reactive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A TTLCache shares the results of expensive computations that change rarely,
+// such as aggregates, for a fixed time-to-live.
+//
+// Unlike Cache, which shares results within a Rerunner for as long as their
+// dependencies are valid, a TTLCache shares results across Rerunners and
+// requests, and expires them after their time-to-live. Computations that used
+// an expired result rerun. A result is still dropped as soon as one of its
+// dependencies is invalidated, for example with Invalidate, so that explicit
+// invalidations are not delayed until the result expires.
+//
+// A TTLCache is safe for concurrent use.
+type TTLCache struct {
+	mu      sync.Mutex
+	locker  *locker
+	entries map[interface{}]*ttlEntry
+}
+
+// ttlEntry is a cached computation. holder keeps the computation and its
+// dependencies alive while the entry is cached, even if no computation uses
+// it.
+type ttlEntry struct {
+	computation *computation
+	holder      *node
+}
+
+// NewTTLCache creates an empty TTLCache.
+func NewTTLCache() *TTLCache {
+	return &TTLCache{
+		locker:  newLocker(),
+		entries: make(map[interface{}]*ttlEntry),
+	}
+}
+
+// Get returns the cached result of key if it was computed less than ttl ago
+// and none of its dependencies have been invalidated. Otherwise, Get computes
+// the result with f and caches it for ttl. Errors are not cached. The current
+// computation depends on the result, and reruns when it expires or is
+// invalidated.
+func (c *TTLCache) Get(ctx context.Context, key interface{}, ttl time.Duration, f ComputeFunc) (interface{}, error) {
+	c.locker.Lock(key)
+	defer c.locker.Unlock(key)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if !ok || entry.computation.node.Invalidated() {
+		var err error
+		if entry, err = c.compute(ctx, key, ttl, f); err != nil {
+			return nil, err
+		}
+	}
+
+	if HasRerunner(ctx) {
+		computation := ctx.Value(computationKey{}).(*computation)
+		entry.computation.node.addOut(&computation.node)
+	}
+	return entry.computation.value, nil
+}
+
+// compute computes and caches the result of key. Outside of a Rerunner, f has
+// no dependencies and the result only expires.
+func (c *TTLCache) compute(ctx context.Context, key interface{}, ttl time.Duration, f ComputeFunc) (*ttlEntry, error) {
+	var child *computation
+	if HasRerunner(ctx) {
+		var err error
+		if child, err = run(ctx, f); err != nil {
+			return nil, err
+		}
+	} else {
+		value, err := f(ctx)
+		if err != nil {
+			return nil, err
+		}
+		child = &computation{value: value}
+	}
+
+	entry := &ttlEntry{computation: child, holder: &node{}}
+	child.node.addOut(entry.holder)
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	timer := time.AfterFunc(ttl, child.node.invalidate)
+	child.node.handleInvalidate(func() {
+		timer.Stop()
+
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+
+		go entry.holder.release()
+	})
+
+	return entry, nil
+}
+
+// Len returns the number of results currently cached.
+func (c *TTLCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
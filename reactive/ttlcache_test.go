@@ -0,0 +1,84 @@
+package reactive
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTTLCache tests that TTLCache shares a result across rerunners until it
+// expires or one of its dependencies is invalidated.
+func TestTTLCache(t *testing.T) {
+	cache := NewTTLCache()
+	var computes int64
+
+	runs := make(chan int64, 16)
+	for i := 0; i < 2; i++ {
+		runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+			value, err := cache.Get(ctx, "total", 200*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+				AddDependencyKey(ctx, "ttl-cache-test")
+				return atomic.AddInt64(&computes, 1), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			runs <- value.(int64)
+			return nil, nil
+		}, 0)
+		defer runner.Stop()
+	}
+
+	expectRuns := func(expected int64) {
+		for i := 0; i < 2; i++ {
+			select {
+			case value := <-runs:
+				if value != expected {
+					t.Errorf("expected value %d, got %d", expected, value)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("expected run with value %d", expected)
+			}
+		}
+	}
+
+	// Both rerunners share the first result.
+	expectRuns(1)
+
+	// An explicit invalidation busts the cache before the result expires.
+	Invalidate("ttl-cache-test")
+	expectRuns(2)
+
+	// The result expires after its time-to-live.
+	expectRuns(3)
+}
+
+// TestTTLCacheWithoutRerunner tests that TTLCache caches results outside of a
+// Rerunner until they expire.
+func TestTTLCacheWithoutRerunner(t *testing.T) {
+	cache := NewTTLCache()
+	var computes int
+
+	get := func() interface{} {
+		value, err := cache.Get(context.Background(), 0, 50*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+			computes++
+			return computes, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	if get() != 1 || get() != 1 {
+		t.Error("expected cached result")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if get() != 2 {
+		t.Error("expected expired result to be recomputed")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 cached result, got %d", cache.Len())
+	}
+}
@@ -93,7 +93,9 @@ func NewDB(conn *sql.DB, schema *Schema) *DB {
 }
 
 func (db *DB) BaseQuery(ctx context.Context, query *BaseSelectQuery) ([]interface{}, error) {
-	if query.Options == nil && !db.HasTx(ctx) && batch.HasBatching(ctx) {
+	// Queries with IN clauses are not batched, as the batch matcher only
+	// handles equality constraints.
+	if query.Options == nil && !hasInValues(query.Filter) && !db.HasTx(ctx) && batch.HasBatching(ctx) {
 		rows, err := db.batchFetch.Invoke(ctx, query)
 		if err != nil {
 			return nil, err
@@ -117,6 +119,16 @@ func (db *DB) BaseQuery(ctx context.Context, query *BaseSelectQuery) ([]interfac
 	return db.Schema.ParseRows(selectQuery, res)
 }
 
+// hasInValues returns true if filter matches any column with an IN clause.
+func hasInValues(filter Filter) bool {
+	for _, value := range filter {
+		if _, ok := inValues(value); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (db *DB) execWithTrace(ctx context.Context, query SQLQuery, operationName string) (sql.Result, error) {
 	clause, args := query.ToSQL()
 
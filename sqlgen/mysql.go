@@ -3,29 +3,75 @@ package sqlgen
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 )
 
 // SimpleWhere represents a simple WHERE clause
+//
+// Values that are slices, other than []byte, match any of their elements.
 type SimpleWhere struct {
 	Columns []string
 	Values  []interface{}
 }
 
-// ToSQL builds a `a = ? AND b = ?` clause
+// ToSQL builds a `a = ? AND b IN (?, ?)` clause
+//
+// Slice values are expanded into one placeholder per element. An empty slice
+// matches no rows and becomes `1=0`, as `b IN ()` is not valid SQL.
 func (w *SimpleWhere) ToSQL() (string, []interface{}) {
 	var buffer bytes.Buffer
+	values := make([]interface{}, 0, len(w.Values))
+	expanded := false
 
-	if len(w.Columns) > 0 {
-		for i, column := range w.Columns {
-			if i > 0 {
-				buffer.WriteString(" AND ")
-			}
+	for i, column := range w.Columns {
+		if i > 0 {
+			buffer.WriteString(" AND ")
+		}
+
+		elems, ok := inValues(w.Values[i])
+		if !ok {
 			buffer.WriteString(column)
 			buffer.WriteString(" = ?")
+			values = append(values, w.Values[i])
+			continue
+		}
+
+		expanded = true
+		if len(elems) == 0 {
+			buffer.WriteString("1=0")
+			continue
+		}
+		buffer.WriteString(column)
+		buffer.WriteString(" IN (")
+		for j := range elems {
+			if j > 0 {
+				buffer.WriteString(", ")
+			}
+			buffer.WriteString("?")
 		}
+		buffer.WriteString(")")
+		values = append(values, elems...)
+	}
+
+	if !expanded {
+		return buffer.String(), w.Values
 	}
+	return buffer.String(), values
+}
 
-	return buffer.String(), w.Values
+// inValues returns the elements of value if it is a slice matched with an IN
+// clause. []byte values are matched as a whole.
+func inValues(value interface{}) ([]interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	elems := make([]interface{}, v.Len())
+	for i := range elems {
+		elems[i] = v.Index(i).Interface()
+	}
+	return elems, true
 }
 
 type SQLQuery interface {
@@ -32,6 +32,21 @@ func TestSimpleWhere(t *testing.T) {
 		Columns: []string{"foo", "bar"},
 		Values:  []interface{}{1, 2},
 	}, "foo = ? AND bar = ?", []interface{}{1, 2}, t)
+
+	testQuery(&SimpleWhere{
+		Columns: []string{"foo", "bar"},
+		Values:  []interface{}{[]int64{1, 2, 3}, 4},
+	}, "foo IN (?, ?, ?) AND bar = ?", []interface{}{int64(1), int64(2), int64(3), 4}, t)
+
+	testQuery(&SimpleWhere{
+		Columns: []string{"foo", "bar"},
+		Values:  []interface{}{1, []string{}},
+	}, "foo = ? AND 1=0", []interface{}{1}, t)
+
+	testQuery(&SimpleWhere{
+		Columns: []string{"foo"},
+		Values:  []interface{}{[]byte("bar")},
+	}, "foo = ?", []interface{}{[]byte("bar")}, t)
 }
 
 func TestSelectQuery(t *testing.T) {
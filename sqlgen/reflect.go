@@ -196,6 +196,17 @@ type Table struct {
 	Columns       []*Column
 	ColumnsByName map[string]*Column
 
+	// PrimaryKey holds the columns tagged primary, in column order. Tables
+	// with a composite primary key tag several columns, such as
+	//
+	//   type Membership struct {
+	//     GroupId int64 `sql:",primary"`
+	//     UserId  int64 `sql:",primary"`
+	//   }
+	//
+	// and rows are identified by the tuple of their primary key values.
+	PrimaryKey []*Column
+
 	Scannables *sync.Pool
 }
 
@@ -270,14 +281,13 @@ func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, ty
 		columnsByName[column] = descriptor
 	}
 
-	hasPrimary := false
+	var primaryKey []*Column
 	for _, column := range columns {
 		if column.Primary {
-			hasPrimary = true
-			break
+			primaryKey = append(primaryKey, column)
 		}
 	}
-	if !hasPrimary {
+	if len(primaryKey) == 0 {
 		return nil, fmt.Errorf("bad type %s: no primary key specified", typ)
 	}
 
@@ -299,6 +309,8 @@ func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, ty
 		Columns:       columns,
 		ColumnsByName: columnsByName,
 
+		PrimaryKey: primaryKey,
+
 		Scannables: scannables,
 	}, nil
 }
@@ -652,28 +664,23 @@ func (s *Schema) MakeUpdateRow(row interface{}) (*UpdateQuery, error) {
 		return nil, err
 	}
 
-	var columns, whereColumns []string
-	var values, whereValues []interface{}
+	var columns []string
+	var values []interface{}
 
 	for _, column := range table.Columns {
-		value := coerce(elem.FieldByIndex(column.Index))
 		if column.Primary {
-			whereColumns = append(whereColumns, column.Name)
-			whereValues = append(whereValues, value)
-		} else {
-			columns = append(columns, column.Name)
-			values = append(values, value)
+			continue
 		}
+		value := coerce(elem.FieldByIndex(column.Index))
+		columns = append(columns, column.Name)
+		values = append(values, value)
 	}
 
 	return &UpdateQuery{
 		Table:   table.Name,
 		Columns: columns,
 		Values:  values,
-		Where: &SimpleWhere{
-			Columns: whereColumns,
-			Values:  whereValues,
-		},
+		Where:   table.primaryKeyWhere(elem),
 	}, nil
 }
 
@@ -691,26 +698,41 @@ func (s *Schema) MakeDeleteRow(row interface{}) (*DeleteQuery, error) {
 		return nil, err
 	}
 
+	return &DeleteQuery{
+		Table: table.Name,
+		Where: table.primaryKeyWhere(elem),
+	}, nil
+}
+
+// primaryKeyWhere builds a `k1 = ? AND k2 = ?` clause matching the row elem
+// by its full primary key
+func (t *Table) primaryKeyWhere(elem reflect.Value) *SimpleWhere {
 	var columns []string
 	var values []interface{}
 
-	for _, column := range table.Columns {
-		if !column.Primary {
-			continue
-		}
-
-		value := coerce(elem.FieldByIndex(column.Index))
+	for _, column := range t.PrimaryKey {
 		columns = append(columns, column.Name)
-		values = append(values, value)
+		values = append(values, coerce(elem.FieldByIndex(column.Index)))
 	}
 
-	return &DeleteQuery{
-		Table: table.Name,
-		Where: &SimpleWhere{
-			Columns: columns,
-			Values:  values,
-		},
-	}, nil
+	return &SimpleWhere{
+		Columns: columns,
+		Values:  values,
+	}
+}
+
+// PrimaryKeyFilter returns the Filter selecting the row of t with primary key
+// values key, given in the order of t.PrimaryKey.
+func (t *Table) PrimaryKeyFilter(key ...interface{}) (Filter, error) {
+	if len(key) != len(t.PrimaryKey) {
+		return nil, fmt.Errorf("table %s has %d primary key columns, got %d values", t.Name, len(t.PrimaryKey), len(key))
+	}
+
+	filter := make(Filter, len(key))
+	for i, column := range t.PrimaryKey {
+		filter[column.Name] = key[i]
+	}
+	return filter, nil
 }
 
 type Tester interface {
@@ -755,8 +777,14 @@ func (t *tester) Test(row interface{}) bool {
 	struc := reflect.ValueOf(row).Elem()
 	for i, column := range t.columns {
 		// coerces some pointer types to make filters more idiomatic
-		expected := coerce(reflect.ValueOf(t.values[i]))
 		value := coerce(struc.FieldByIndex(column.Index))
+		if elems, ok := inValues(t.values[i]); ok {
+			if !containsValue(elems, value) {
+				return false
+			}
+			continue
+		}
+		expected := coerce(reflect.ValueOf(t.values[i]))
 		if expected != value {
 			return false
 		}
@@ -765,6 +793,16 @@ func (t *tester) Test(row interface{}) bool {
 	return true
 }
 
+// containsValue returns true if any of elems coerces to value.
+func containsValue(elems []interface{}, value interface{}) bool {
+	for _, elem := range elems {
+		if coerce(reflect.ValueOf(elem)) == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Schema) MakeTester(table string, filter Filter) (Tester, error) {
 	t, ok := s.ByName[table]
 	if !ok {
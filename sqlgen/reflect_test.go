@@ -531,6 +531,65 @@ func TestMakeDelete(t *testing.T) {
 	}
 }
 
+type membership struct {
+	GroupId int64 `sql:",primary"`
+	UserId  int64 `sql:",primary"`
+	Role    string
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	s := NewSchema()
+	if err := s.RegisterType("memberships", UniqueId, membership{}); err != nil {
+		t.Fatal(err)
+	}
+	table := s.ByName["memberships"]
+
+	if len(table.PrimaryKey) != 2 || table.PrimaryKey[0].Name != "group_id" || table.PrimaryKey[1].Name != "user_id" {
+		t.Errorf("bad primary key %v", table.PrimaryKey)
+	}
+
+	filter, err := table.PrimaryKeyFilter(int64(1), int64(2))
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(filter, Filter{"group_id": int64(1), "user_id": int64(2)}) {
+		t.Errorf("bad filter %v", filter)
+	}
+	if _, err := table.PrimaryKeyFilter(int64(1)); err == nil {
+		t.Error("expected partial primary key to fail")
+	}
+
+	// The filter selects only the row with the full primary key.
+	tester, err := s.MakeTester("memberships", filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tester.Test(&membership{GroupId: 1, UserId: 2}) {
+		t.Error("expected filter to match row")
+	}
+	if tester.Test(&membership{GroupId: 2, UserId: 1}) || tester.Test(&membership{GroupId: 1, UserId: 3}) {
+		t.Error("expected filter not to match rows sharing part of the key")
+	}
+
+	update, err := s.MakeUpdateRow(&membership{GroupId: 1, UserId: 2, Role: "admin"})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(update, &UpdateQuery{
+		Table:   "memberships",
+		Columns: []string{"role"},
+		Values:  []interface{}{"admin"},
+		Where: &SimpleWhere{
+			Columns: []string{"group_id", "user_id"},
+			Values:  []interface{}{int64(1), int64(2)},
+		},
+	}) {
+		t.Error("bad update")
+	}
+
+	testQuery(update.Where, "group_id = ? AND user_id = ?", []interface{}{int64(1), int64(2)}, t)
+}
+
 func TestCoerce(t *testing.T) {
 	ten := int64(10)
 	foo := "foo"
@@ -586,6 +645,16 @@ func TestMakeTester(t *testing.T) {
 		t.Error(err)
 	}
 
+	idInTenTwenty, err := s.MakeTester("users", Filter{"id": []int64{10, 20}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	idInNone, err := s.MakeTester("users", Filter{"id": []int64{}})
+	if err != nil {
+		t.Error(err)
+	}
+
 	foo := "foo"
 
 	cases := []struct {
@@ -600,6 +669,9 @@ func TestMakeTester(t *testing.T) {
 		{Description: "compare nil fail", Tester: idTenOptionalNil, User: &user{Id: 10, Optional: &foo}, Expected: false},
 		{Description: "compare ptr match", Tester: idTenOptionalFoo, User: &user{Id: 10, Optional: &foo}, Expected: true},
 		{Description: "compare ptr fail", Tester: idTenOptionalFoo, User: &user{Id: 10}, Expected: false},
+		{Description: "compare in match", Tester: idInTenTwenty, User: &user{Id: 20}, Expected: true},
+		{Description: "compare in fail", Tester: idInTenTwenty, User: &user{Id: 5}, Expected: false},
+		{Description: "compare empty in", Tester: idInNone, User: &user{Id: 10}, Expected: false},
 	}
 
 	for _, c := range cases {